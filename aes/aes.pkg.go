@@ -84,7 +84,7 @@ func DecryptCBC(rawData string, key []byte) (res string, err error) {
 	encryptData = encryptData[blockSize:]
 
 	// CBC mode always works in whole blocks.
-	if len(encryptData)%blockSize != 0 {
+	if len(encryptData) == 0 || len(encryptData)%blockSize != 0 {
 		err = stderrors.New("cipherText is not a multiple of the block size")
 		return
 	}
@@ -95,6 +95,10 @@ func DecryptCBC(rawData string, key []byte) (res string, err error) {
 
 	// 解填充
 	unPadding := int(encryptData[len(encryptData)-1])
+	if unPadding == 0 || unPadding > blockSize {
+		err = stderrors.New("invalid padding")
+		return
+	}
 	res = string(encryptData[:(len(encryptData) - unPadding)])
 	return
 }
@@ -38,6 +38,12 @@ type Payload struct {
 	LoginLimit LoginLimitEnum_LoginLimit `json:"ll,omitempty"`
 	// TokenType 令牌类型
 	TokenType TokenTypeEnum_TokenType `json:"tt,omitempty"`
+	// Roles 角色
+	Roles []string `json:"rs,omitempty"`
+	// Scopes 权限
+	Scopes []string `json:"sc,omitempty"`
+	// TenantID 租户id；令牌管理按租户隔离，参考 GetTenantFromContext
+	TenantID string `json:"tid,omitempty"`
 }
 
 // UserIdentifier ...
@@ -61,6 +67,20 @@ func DefaultExpireTime() *jwt.NumericDate {
 type Claims struct {
 	jwt.RegisteredClaims
 
+	// FamilyID 令牌家族id：同一次登录及其后续刷新的令牌属于同一家族
+	FamilyID string `json:"fid,omitempty"`
+	// Service 调用方服务名称；服务令牌
+	Service string `json:"svc,omitempty"`
+	// Generation 签发时用户的令牌代数；小于当前代数的令牌已注销，参考 BumpTokenGeneration
+	Generation uint64 `json:"gen,omitempty"`
+	// AMR 认证方式；例：pwd、otp，参考 AMRPassword
+	AMR []string `json:"amr,omitempty"`
+	// ACR 认证强度；参考 ACRMultiFactor
+	ACR string `json:"acr,omitempty"`
+	// AuthTime 认证时间；多因素认证后更新，续期与刷新时保持不变
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	// Actor 代理登录的管理员；参考 ImpersonateToken
+	Actor *Actor `json:"act,omitempty"`
 	// payload 授权信息
 	Payload *Payload `json:"p,omitempty"`
 }
@@ -84,11 +104,11 @@ func (s *Claims) DecodeString(claims string) error {
 }
 
 // DefaultClaims ...
+// 不设置过期时间：签发时按 Config.Lifetimes 的令牌有效期设置；需要指定过期时间时设置 ExpiresAt
 func DefaultClaims(payload Payload) *Claims {
 	return &Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: DefaultExpireTime(),
-			ID:        uuidpkg.NewUUID(),
+			ID: uuidpkg.NewUUID(),
 		},
 		Payload: &payload,
 	}
@@ -102,6 +122,11 @@ func DefaultRefreshClaims(authClaims *Claims) *Claims {
 	regClaims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(RefreshTokenExpire))
 	return &Claims{
 		RegisteredClaims: regClaims,
+		FamilyID:         authClaims.FamilyID,
+		Generation:       authClaims.Generation,
+		AMR:              authClaims.AMR,
+		ACR:              authClaims.ACR,
+		AuthTime:         authClaims.AuthTime,
 		Payload:          &payload,
 	}
 }
@@ -112,6 +137,12 @@ type TokenItem struct {
 	RefreshTokenID string `json:"rti,omitempty"`
 	ExpiredAt      int64  `json:"ea,omitempty"`
 	IsRefreshToken bool   `json:"ift,omitempty"`
+	FamilyID       string `json:"fid,omitempty"`
+	IssuedAt       int64  `json:"ia,omitempty"`
+	ClientIP       string `json:"ip,omitempty"`
+	UserAgent      string `json:"ua,omitempty"`
+	// Actor 代理登录的管理员；参考 Actor.Subject
+	Actor string `json:"act,omitempty"`
 
 	// payload 授权信息
 	Payload *Payload `json:"p,omitempty"`
@@ -149,10 +180,25 @@ func GetAuthClaimsFromContext(ctx context.Context) (*Claims, bool) {
 	return token, ok
 }
 
+// contextAuthToken context.Context key
+type contextAuthToken struct{}
+
+// PutAuthTokenIntoContext put raw token into context
+func PutAuthTokenIntoContext(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, contextAuthToken{}, token)
+}
+
+// GetAuthTokenFromContext extract raw token from context
+func GetAuthTokenFromContext(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(contextAuthToken{}).(string)
+	return token, ok && token != ""
+}
+
 // KeyFunc 自定义 jwt.Keyfunc
 type KeyFunc func(context.Context) jwt.Keyfunc
 
 // Server is a server auth middleware. Check the token and extract the info from token.
+// 使用 WithRouteMatcher 为 operation 设置认证模式：公开、可选、必须(默认)
 func Server(signKeyFunc KeyFunc, opts ...Option) middleware.Middleware {
 	o := &options{
 		signingMethod: jwt.SigningMethodHS256,
@@ -163,68 +209,29 @@ func Server(signKeyFunc KeyFunc, opts ...Option) middleware.Middleware {
 	return func(handler middleware.Handler) middleware.Handler {
 		return func(ctx context.Context, req interface{}) (interface{}, error) {
 			if header, ok := transport.FromServerContext(ctx); ok {
-				var keyFunc jwt.Keyfunc
-				if signKeyFunc == nil {
-					e := ErrMissingSignKeyFunc()
-					return nil, errorpkg.WithStack(e)
-				}
-				keyFunc = signKeyFunc(ctx)
-				if keyFunc == nil {
-					e := ErrMissingSignKeyFunc()
-					return nil, errorpkg.WithStack(e)
-				}
-				//auths := strings.SplitN(header.RequestHeader().Get(AuthorizationKey), " ", 2)
-				//if len(auths) != 2 || !strings.EqualFold(auths[0], BearerWord) {
-				//	e := ErrMissingToken()
-				//	return nil, errorpkg.WithStack(e)
-				//}
-				//jwtToken := auths[1]
-				jwtToken := header.RequestHeader().Get(AuthorizationKey)
-				if jwtToken == "" {
-					e := ErrMissingToken()
-					return nil, errorpkg.WithStack(e)
-				}
-				var (
-					tokenInfo *jwt.Token
-					err       error
-				)
-				if o.claims != nil {
-					tokenInfo, err = jwt.ParseWithClaims(jwtToken, o.claims(), keyFunc)
-				} else {
-					tokenInfo, err = jwt.Parse(jwtToken, keyFunc)
+				mode := AuthModeRequired
+				if o.routeMatcher != nil {
+					mode = o.routeMatcher.Match(header.Operation())
 				}
-				if err != nil {
-					ve, ok := err.(*jwt.ValidationError)
-					if !ok {
-						e := ErrInvalidAuthToken()
-						return nil, errorpkg.WithStack(e)
-					}
-					if ve.Errors&jwt.ValidationErrorMalformed != 0 {
-						e := ErrTokenInvalid()
-						return nil, errorpkg.WithStack(e)
+				switch mode {
+				case AuthModePublic:
+					return handler(ctx, req)
+				case AuthModeOptional:
+					// 令牌无效时不拒绝请求
+					if tokenInfo, err := parseServerToken(ctx, header, signKeyFunc, o); err == nil {
+						ctx = PutAuthClaimsIntoContext(ctx, tokenInfo.Claims)
+						ctx = PutAuthTokenIntoContext(ctx, tokenInfo.Raw)
+						renewServerToken(ctx, header, tokenInfo, o)
 					}
-					if ve.Errors&(jwt.ValidationErrorExpired|jwt.ValidationErrorNotValidYet) != 0 {
-						e := ErrTokenExpired()
-						return nil, errorpkg.WithStack(e)
-					}
-					e := ErrTokenParseFail()
-					e.Metadata = map[string]string{"error": err.Error()}
-					return nil, errorpkg.WithStack(e)
-				}
-				if !tokenInfo.Valid {
-					e := ErrTokenInvalid()
-					return nil, errorpkg.WithStack(e)
+					return handler(ctx, req)
 				}
-				if tokenInfo.Method != o.signingMethod {
-					e := ErrUnSupportSigningMethod()
-					return nil, errorpkg.WithStack(e)
-				}
-				if o.tokenValidatorFunc != nil {
-					if err = o.tokenValidatorFunc(ctx, tokenInfo); err != nil {
-						return nil, err
-					}
+				tokenInfo, err := parseServerToken(ctx, header, signKeyFunc, o)
+				if err != nil {
+					return nil, err
 				}
 				ctx = PutAuthClaimsIntoContext(ctx, tokenInfo.Claims)
+				ctx = PutAuthTokenIntoContext(ctx, tokenInfo.Raw)
+				renewServerToken(ctx, header, tokenInfo, o)
 				return handler(ctx, req)
 			}
 			e := ErrWrongContext()
@@ -233,56 +240,136 @@ func Server(signKeyFunc KeyFunc, opts ...Option) middleware.Middleware {
 	}
 }
 
+// renewServerToken 滑动会话：续期的令牌写入响应头
+func renewServerToken(ctx context.Context, header transport.Transporter, tokenInfo *jwt.Token, o *options) {
+	if o.tokenRenewer == nil {
+		return
+	}
+	// 外部身份提供方的令牌不续期
+	if claims, ok := tokenInfo.Claims.(*Claims); ok && oidcProviderOfIssuer(o.oidcProviders, claims.Issuer) != nil {
+		return
+	}
+	res, err := o.tokenRenewer.RenewToken(ctx, tokenInfo)
+	if err != nil || res == nil {
+		return
+	}
+	header.ReplyHeader().Set(RenewAccessTokenKey, res.AccessToken)
+	header.ReplyHeader().Set(RenewRefreshTokenKey, res.RefreshToken)
+}
+
+// parseServerToken 解析并验证请求头中的令牌
+func parseServerToken(ctx context.Context, header transport.Transporter, signKeyFunc KeyFunc, o *options) (*jwt.Token, error) {
+	var keyFunc jwt.Keyfunc
+	if signKeyFunc == nil {
+		e := ErrMissingSignKeyFunc()
+		return nil, errorpkg.WithStack(e)
+	}
+	keyFunc = signKeyFunc(ctx)
+	if keyFunc == nil {
+		e := ErrMissingSignKeyFunc()
+		return nil, errorpkg.WithStack(e)
+	}
+	extractors := o.tokenExtractors
+	if len(extractors) == 0 {
+		extractors = DefaultTokenExtractors()
+	}
+	jwtToken := extractToken(ctx, header, extractors)
+	if jwtToken == "" {
+		e := ErrMissingToken()
+		return nil, errorpkg.WithStack(e)
+	}
+	// 外部身份提供方的令牌
+	if provider := oidcProviderOf(o.oidcProviders, jwtToken); provider != nil {
+		return provider.ParseToken(ctx, jwtToken)
+	}
+	var (
+		tokenInfo *jwt.Token
+		err       error
+	)
+	if o.claims != nil {
+		tokenInfo, err = jwt.ParseWithClaims(jwtToken, o.claims(), keyFunc)
+	} else {
+		tokenInfo, err = jwt.Parse(jwtToken, keyFunc)
+	}
+	if err != nil {
+		return nil, parseTokenError(err)
+	}
+	if !tokenInfo.Valid {
+		e := ErrTokenInvalid()
+		return nil, errorpkg.WithStack(e)
+	}
+	if !IsSameSigningMethodFamily(o.signingMethod, tokenInfo.Method) {
+		e := ErrUnSupportSigningMethod()
+		return nil, errorpkg.WithStack(e)
+	}
+	if o.tokenValidatorFunc != nil {
+		if err = o.tokenValidatorFunc(ctx, tokenInfo); err != nil {
+			return nil, err
+		}
+	}
+	return tokenInfo, nil
+}
+
+// parseTokenError 解析令牌的错误
+func parseTokenError(err error) error {
+	ve, ok := err.(*jwt.ValidationError)
+	if !ok {
+		e := ErrInvalidAuthToken()
+		return errorpkg.WithStack(e)
+	}
+	if ve.Errors&jwt.ValidationErrorMalformed != 0 {
+		e := ErrTokenInvalid()
+		return errorpkg.WithStack(e)
+	}
+	if ve.Errors&(jwt.ValidationErrorExpired|jwt.ValidationErrorNotValidYet) != 0 {
+		e := ErrTokenExpired()
+		return errorpkg.WithStack(e)
+	}
+	e := ErrTokenParseFail()
+	e.Metadata = map[string]string{"error": err.Error()}
+	return errorpkg.WithStack(e)
+}
+
 // Client is a client jwt middleware.
+// 使用 WithClientMode 选择令牌：签发(默认)、转发用户令牌、代表用户的服务令牌、缓存的服务令牌
 func Client(customKeyFunc KeyFunc, opts ...Option) middleware.Middleware {
 	claims := jwt.RegisteredClaims{}
 	o := &options{
-		signingMethod: jwt.SigningMethodHS256,
-		claims:        func() jwt.Claims { return claims },
+		signingMethod:      jwt.SigningMethodHS256,
+		claims:             func() jwt.Claims { return claims },
+		serviceTokenExpire: DefaultServiceTokenExpire,
 	}
 	for _, opt := range opts {
 		opt(o)
 	}
+	credential := &serviceCredential{}
 	return func(handler middleware.Handler) middleware.Handler {
 		return func(ctx context.Context, req interface{}) (interface{}, error) {
-			var keyProvider jwt.Keyfunc
-			if customKeyFunc == nil {
-				e := ErrMissingSignKeyFunc()
-				return nil, errorpkg.WithStack(e)
-			}
-			keyProvider = customKeyFunc(ctx)
-			if keyProvider == nil {
-				e := ErrMissingSignKeyFunc()
-				return nil, errorpkg.WithStack(e)
-			}
-			if keyProvider == nil {
-				e := ErrNeedTokenProvider()
-				return nil, errorpkg.WithStack(e)
-			}
-			token := jwt.NewWithClaims(o.signingMethod, o.claims())
-			if o.tokenHeader != nil {
-				for k, v := range o.tokenHeader {
-					token.Header[k] = v
-				}
-			}
-			key, err := keyProvider(token)
-			if err != nil {
-				e := ErrGetKey()
-				return nil, errorpkg.WithStack(e)
+			var (
+				tokenStr string
+				err      error
+			)
+			switch o.clientMode {
+			case ClientModeForward:
+				tokenStr, err = forwardClientToken(ctx)
+			case ClientModeOnBehalfOf:
+				tokenStr, err = signClientToken(ctx, customKeyFunc, o, newOnBehalfOfClaims(ctx, o))
+			case ClientModeServiceCredential:
+				tokenStr, err = credential.Token(ctx, func(ctx context.Context) (string, time.Time, error) {
+					return serviceCredentialToken(ctx, customKeyFunc, o)
+				})
+			default:
+				tokenStr, err = signClientToken(ctx, customKeyFunc, o, o.claims())
 			}
-			tokenStr, err := token.SignedString(key)
 			if err != nil {
-				e := ErrSignToken()
-				return nil, errorpkg.WithStack(e)
-			}
-			if o.tokenValidatorFunc != nil {
-				if err = o.tokenValidatorFunc(ctx, token); err != nil {
-					return nil, err
-				}
+				return nil, err
 			}
 			if clientContext, ok := transport.FromClientContext(ctx); ok {
-				//clientContext.RequestHeader().Set(AuthorizationKey, fmt.Sprintf(BearerFormat, tokenStr))
-				clientContext.RequestHeader().Set(AuthorizationKey, tokenStr)
+				headerKey := AuthorizationKey
+				if o.authorizationHeader != "" {
+					headerKey = o.authorizationHeader
+				}
+				clientContext.RequestHeader().Set(headerKey, FormatToken(o.authorizationScheme, tokenStr))
 				return handler(ctx, req)
 			}
 			e := ErrWrongContext()
@@ -290,3 +377,39 @@ func Client(customKeyFunc KeyFunc, opts ...Option) middleware.Middleware {
 		}
 	}
 }
+
+// signClientToken 签发令牌
+func signClientToken(ctx context.Context, customKeyFunc KeyFunc, o *options, claims jwt.Claims) (string, error) {
+	var keyProvider jwt.Keyfunc
+	if customKeyFunc == nil {
+		e := ErrMissingSignKeyFunc()
+		return "", errorpkg.WithStack(e)
+	}
+	keyProvider = customKeyFunc(ctx)
+	if keyProvider == nil {
+		e := ErrMissingSignKeyFunc()
+		return "", errorpkg.WithStack(e)
+	}
+	token := jwt.NewWithClaims(o.signingMethod, claims)
+	if o.tokenHeader != nil {
+		for k, v := range o.tokenHeader {
+			token.Header[k] = v
+		}
+	}
+	key, err := keyProvider(token)
+	if err != nil {
+		e := ErrGetKey()
+		return "", errorpkg.WithStack(e)
+	}
+	tokenStr, err := token.SignedString(key)
+	if err != nil {
+		e := ErrSignToken()
+		return "", errorpkg.WithStack(e)
+	}
+	if o.tokenValidatorFunc != nil {
+		if err = o.tokenValidatorFunc(ctx, token); err != nil {
+			return "", err
+		}
+	}
+	return tokenStr, nil
+}
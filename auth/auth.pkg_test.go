@@ -2,10 +2,15 @@ package authpkg
 
 import (
 	"context"
+	"net/http"
 
-	aespkg "github.com/eden-quan/go-kratos-pkg/aes"
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/go-kratos/kratos/v2/middleware/selector"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+
+	aespkg "github.com/eden-quan/go-kratos-pkg/aes"
 )
 
 func ExampleServer() {
@@ -16,12 +21,12 @@ func ExampleServer() {
 		whiteList = map[string]struct{}{}
 	)
 	authConfig := Config{
-		SigningMethod:      jwt.SigningMethodHS256,
-		SignKey:            signKey,
-		RefreshCrypto:      aespkg.NewCBCCipher(),
-		AuthCacheKeyPrefix: CheckAuthCacheKeyPrefix(nil),
+		SigningMethod: jwt.SigningMethodHS256,
+		SignKey:       signKey,
+		RefreshCrypto: aespkg.NewCBCCipher(),
 	}
-	repo, err := NewAuthRepo(redisCC, logger, authConfig)
+	tokenManger := NewTokenManger(redisCC, CheckAuthCacheKeyPrefix(nil))
+	repo, err := NewAuthRepo(tokenManger, logger, authConfig)
 	if err != nil {
 		return
 	}
@@ -55,3 +60,42 @@ func ExampleServer() {
 
 	return
 }
+
+// testHeader transport.Header
+type testHeader http.Header
+
+func (s testHeader) Get(key string) string      { return http.Header(s).Get(key) }
+func (s testHeader) Set(key, value string)      { http.Header(s).Set(key, value) }
+func (s testHeader) Add(key, value string)      { http.Header(s).Add(key, value) }
+func (s testHeader) Values(key string) []string { return http.Header(s).Values(key) }
+func (s testHeader) Keys() []string {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// testTransport transport.Transporter
+type testTransport struct {
+	kind        transport.Kind
+	operation   string
+	reqHeader   testHeader
+	replyHeader testHeader
+}
+
+func (s *testTransport) Kind() transport.Kind            { return s.kind }
+func (s *testTransport) Endpoint() string                { return "" }
+func (s *testTransport) Operation() string               { return s.operation }
+func (s *testTransport) RequestHeader() transport.Header { return s.reqHeader }
+func (s *testTransport) ReplyHeader() transport.Header   { return s.replyHeader }
+
+// newTestTransport ...
+func newTestTransport(operation string) *testTransport {
+	return &testTransport{
+		kind:        transport.KindGRPC,
+		operation:   operation,
+		reqHeader:   testHeader{},
+		replyHeader: testHeader{},
+	}
+}
@@ -0,0 +1,478 @@
+package authpkg
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+
+	errorpkg "github.com/eden-quan/go-kratos-pkg/error"
+	uuidpkg "github.com/eden-quan/go-kratos-pkg/uuid"
+)
+
+const (
+	// DefaultAPIKeyPrefix API密钥的默认前缀；用于识别密钥与代码扫描
+	DefaultAPIKeyPrefix = "gsk"
+	// DefaultAPIKeyKeyPrefix API密钥的缓存key前缀
+	DefaultAPIKeyKeyPrefix RedisCacheKeyPrefix = "gs:auth:apikey:"
+	// APIKeyHeaderKey 请求头
+	APIKeyHeaderKey = "X-Api-Key"
+	// APIKeyScheme Authorization 请求头的 scheme
+	APIKeyScheme = "ApiKey"
+	// DefaultAPIKeyTable 数据表
+	DefaultAPIKeyTable = "auth_api_key"
+
+	// apiKeySecretSize 密钥的随机字节数
+	apiKeySecretSize = 32
+	// apiKeySeparator 分隔符：前缀_id_密钥
+	apiKeySeparator = "_"
+)
+
+// APIKeyTableSQL MySQL 数据表
+const APIKeyTableSQL = "CREATE TABLE IF NOT EXISTS `" + DefaultAPIKeyTable + "` (" +
+	"`id` VARCHAR(64) NOT NULL," +
+	"`prefix` VARCHAR(32) NOT NULL DEFAULT ''," +
+	"`name` VARCHAR(255) NOT NULL DEFAULT ''," +
+	"`user_identifier` VARCHAR(255) NOT NULL DEFAULT ''," +
+	"`secret_hash` VARCHAR(128) NOT NULL," +
+	"`payload` TEXT NOT NULL," +
+	"`scopes` TEXT NOT NULL," +
+	"`expires_at` BIGINT NOT NULL DEFAULT 0," +
+	"`revoked_at` BIGINT NOT NULL DEFAULT 0," +
+	"`created_at` BIGINT NOT NULL DEFAULT 0," +
+	"PRIMARY KEY (`id`)," +
+	"KEY `idx_user_identifier` (`user_identifier`)" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+
+// APIKey API密钥；仅存储密钥的哈希
+type APIKey struct {
+	// ID 密钥id；明文密钥的一部分
+	ID string `json:"id" db:"id"`
+	// Prefix 前缀
+	Prefix string `json:"prefix" db:"prefix"`
+	// Name 名称；例：合作方、定时任务
+	Name string `json:"name,omitempty" db:"name"`
+	// UserIdentifier 所属用户
+	UserIdentifier string `json:"uid,omitempty" db:"user_identifier"`
+	// SecretHash 密钥的哈希
+	SecretHash string `json:"secret_hash" db:"secret_hash"`
+	// Payload 授权信息
+	Payload *Payload `json:"payload,omitempty" db:"-"`
+	// Scopes 权限
+	Scopes []string `json:"scopes,omitempty" db:"-"`
+	// ExpiresAt 过期时间(秒)；0为永不过期
+	ExpiresAt int64 `json:"expires_at,omitempty" db:"expires_at"`
+	// RevokedAt 注销时间(秒)；0为未注销
+	RevokedAt int64 `json:"revoked_at,omitempty" db:"revoked_at"`
+	// CreatedAt 创建时间(秒)
+	CreatedAt int64 `json:"created_at,omitempty" db:"created_at"`
+}
+
+// EncodeToString ...
+func (s *APIKey) EncodeToString() (string, error) {
+	res, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("encode api key failed : %w", err)
+	}
+	return string(res), nil
+}
+
+// DecodeString ...
+func (s *APIKey) DecodeString(apiKey string) error {
+	err := json.Unmarshal([]byte(apiKey), s)
+	if err != nil {
+		return fmt.Errorf("decode api key failed : %w", err)
+	}
+	return nil
+}
+
+// IsExpired 是否过期
+func (s *APIKey) IsExpired(now time.Time) bool {
+	return s.ExpiresAt > 0 && s.ExpiresAt <= now.Unix()
+}
+
+// IsRevoked 是否注销
+func (s *APIKey) IsRevoked() bool {
+	return s.RevokedAt > 0
+}
+
+// Claims 与令牌相同的授权信息；下游无需区分认证方式
+func (s *APIKey) Claims() *Claims {
+	var payload Payload
+	if s.Payload != nil {
+		payload = *s.Payload
+	}
+	payload.TokenID = s.ID
+	payload.Scopes = s.Scopes
+	authClaims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       s.ID,
+			IssuedAt: jwt.NewNumericDate(time.Unix(s.CreatedAt, 0)),
+		},
+		Payload: &payload,
+	}
+	if s.ExpiresAt > 0 {
+		authClaims.ExpiresAt = jwt.NewNumericDate(time.Unix(s.ExpiresAt, 0))
+	}
+	return authClaims
+}
+
+// CreateAPIKeyParam 创建API密钥
+type CreateAPIKeyParam struct {
+	// Name 名称
+	Name string
+	// Payload 授权信息；UserIdentifier 为密钥的所属用户
+	Payload *Payload
+	// Scopes 权限
+	Scopes []string
+	// Expire 有效期；0为永不过期
+	Expire time.Duration
+}
+
+// APIKeyStore API密钥的存储
+type APIKeyStore interface {
+	// SaveAPIKey 保存
+	SaveAPIKey(ctx context.Context, apiKey *APIKey) error
+	// GetAPIKey 获取
+	GetAPIKey(ctx context.Context, id string) (apiKey *APIKey, isNotFound bool, err error)
+	// RevokeAPIKey 注销
+	RevokeAPIKey(ctx context.Context, id string, revokedAt int64) error
+	// ListAPIKeys 用户的API密钥
+	ListAPIKeys(ctx context.Context, userIdentifier string) ([]*APIKey, error)
+}
+
+// APIKeyRepo API密钥：用于合作方与定时任务调用接口
+type APIKeyRepo interface {
+	// CreateAPIKey 创建密钥；明文密钥仅在创建时返回
+	CreateAPIKey(ctx context.Context, param *CreateAPIKeyParam) (plaintext string, apiKey *APIKey, err error)
+	// VerifyAPIKey 验证明文密钥
+	VerifyAPIKey(ctx context.Context, plaintext string) (*APIKey, error)
+	// RevokeAPIKey 注销密钥
+	RevokeAPIKey(ctx context.Context, id string) error
+	// ListAPIKeys 用户的API密钥
+	ListAPIKeys(ctx context.Context, userIdentifier string) ([]*APIKey, error)
+}
+
+// apiKeyRepo ...
+type apiKeyRepo struct {
+	store      APIKeyStore
+	prefix     string
+	now        func() time.Time
+	logHandler *log.Helper
+}
+
+// NewAPIKeyRepo API密钥；prefix 为空时使用 DefaultAPIKeyPrefix
+func NewAPIKeyRepo(store APIKeyStore, prefix string, logger log.Logger) (APIKeyRepo, error) {
+	if strings.Contains(prefix, apiKeySeparator) {
+		return nil, fmt.Errorf("api key prefix must not contain %q : %s", apiKeySeparator, prefix)
+	}
+	if prefix == "" {
+		prefix = DefaultAPIKeyPrefix
+	}
+	return &apiKeyRepo{
+		store:      store,
+		prefix:     prefix,
+		now:        time.Now,
+		logHandler: log.NewHelper(log.With(logger, "module", "auth/api_key")),
+	}, nil
+}
+
+// CreateAPIKey ...
+func (s *apiKeyRepo) CreateAPIKey(ctx context.Context, param *CreateAPIKeyParam) (string, *APIKey, error) {
+	secretBytes := make([]byte, apiKeySecretSize)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", nil, fmt.Errorf("generate api key secret failed : %w", err)
+	}
+	var (
+		now    = s.now()
+		secret = hex.EncodeToString(secretBytes)
+		apiKey = &APIKey{
+			ID:         uuidpkg.NewUUID(),
+			Prefix:     s.prefix,
+			Name:       param.Name,
+			SecretHash: hashAPIKeySecret(secret),
+			Payload:    param.Payload,
+			Scopes:     param.Scopes,
+			CreatedAt:  now.Unix(),
+		}
+	)
+	if param.Payload != nil {
+		apiKey.UserIdentifier = param.Payload.UserIdentifier()
+	}
+	if param.Expire > 0 {
+		apiKey.ExpiresAt = now.Add(param.Expire).Unix()
+	}
+	if err := s.store.SaveAPIKey(ctx, apiKey); err != nil {
+		return "", nil, err
+	}
+	return strings.Join([]string{apiKey.Prefix, apiKey.ID, secret}, apiKeySeparator), apiKey, nil
+}
+
+// VerifyAPIKey ...
+func (s *apiKeyRepo) VerifyAPIKey(ctx context.Context, plaintext string) (*APIKey, error) {
+	prefix, id, secret, ok := parseAPIKey(plaintext)
+	if !ok || prefix != s.prefix {
+		e := ErrAPIKeyInvalid()
+		return nil, errorpkg.WithStack(e)
+	}
+	apiKey, isNotFound, err := s.store.GetAPIKey(ctx, id)
+	if err != nil {
+		s.logHandler.WithContext(ctx).Errorw("msg", "get api key failed", "id", id, "err", err)
+		e := ErrInvalidClaims()
+		return nil, errorpkg.WithStack(e)
+	}
+	if isNotFound || subtle.ConstantTimeCompare([]byte(apiKey.SecretHash), []byte(hashAPIKeySecret(secret))) != 1 {
+		e := ErrAPIKeyInvalid()
+		return nil, errorpkg.WithStack(e)
+	}
+	if apiKey.IsRevoked() {
+		e := ErrAPIKeyRevoked()
+		return nil, errorpkg.WithStack(e)
+	}
+	if apiKey.IsExpired(s.now()) {
+		e := ErrAPIKeyExpired()
+		return nil, errorpkg.WithStack(e)
+	}
+	return apiKey, nil
+}
+
+// RevokeAPIKey ...
+func (s *apiKeyRepo) RevokeAPIKey(ctx context.Context, id string) error {
+	return s.store.RevokeAPIKey(ctx, id, s.now().Unix())
+}
+
+// ListAPIKeys ...
+func (s *apiKeyRepo) ListAPIKeys(ctx context.Context, userIdentifier string) ([]*APIKey, error) {
+	return s.store.ListAPIKeys(ctx, userIdentifier)
+}
+
+// parseAPIKey 前缀_id_密钥
+func parseAPIKey(plaintext string) (prefix, id, secret string, ok bool) {
+	parts := strings.Split(plaintext, apiKeySeparator)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], parts[2], true
+}
+
+// hashAPIKeySecret 密钥为高熵的随机数，使用 SHA-256 即可
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyOption is api key middleware option.
+type APIKeyOption func(*apiKeyOptions)
+
+// apiKeyOptions ...
+type apiKeyOptions struct {
+	extractors []TokenExtractor
+	fallback   middleware.Middleware
+}
+
+// WithAPIKeyExtractors 提取API密钥；默认 X-Api-Key 请求头与 Authorization: ApiKey
+func WithAPIKeyExtractors(extractors ...TokenExtractor) APIKeyOption {
+	return func(o *apiKeyOptions) {
+		o.extractors = extractors
+	}
+}
+
+// WithAPIKeyFallback 请求没有API密钥时使用的认证中间件；例：Server
+func WithAPIKeyFallback(fallback middleware.Middleware) APIKeyOption {
+	return func(o *apiKeyOptions) {
+		o.fallback = fallback
+	}
+}
+
+// DefaultAPIKeyExtractors X-Api-Key 请求头与 Authorization: ApiKey
+func DefaultAPIKeyExtractors() []TokenExtractor {
+	return []TokenExtractor{
+		HeaderExtractor(APIKeyHeaderKey, ""),
+		HeaderExtractor(AuthorizationKey, APIKeyScheme),
+	}
+}
+
+// APIKeyServer API密钥认证中间件；认证成功后 GetAuthClaimsFromContext 获取授权信息
+// 使用 WithAPIKeyFallback 同时支持令牌认证
+func APIKeyServer(repo APIKeyRepo, opts ...APIKeyOption) middleware.Middleware {
+	o := &apiKeyOptions{
+		extractors: DefaultAPIKeyExtractors(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(handler middleware.Handler) middleware.Handler {
+		var fallbackHandler middleware.Handler
+		if o.fallback != nil {
+			fallbackHandler = o.fallback(handler)
+		}
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok {
+				e := ErrWrongContext()
+				return nil, errorpkg.WithStack(e)
+			}
+			plaintext := extractToken(ctx, tr, o.extractors)
+			if plaintext == "" {
+				if fallbackHandler != nil {
+					return fallbackHandler(ctx, req)
+				}
+				e := ErrMissingToken()
+				return nil, errorpkg.WithStack(e)
+			}
+			apiKey, err := repo.VerifyAPIKey(ctx, plaintext)
+			if err != nil {
+				return nil, err
+			}
+			ctx = PutAuthClaimsIntoContext(ctx, apiKey.Claims())
+			return handler(ctx, req)
+		}
+	}
+}
+
+// redisAPIKeyRevokeRetries 注销密钥时并发修改的最大重试次数
+const redisAPIKeyRevokeRetries = 8
+
+// redisAPIKeyStore ...
+type redisAPIKeyStore struct {
+	redisCC   redis.UniversalClient
+	keyPrefix RedisCacheKeyPrefix
+}
+
+// NewRedisAPIKeyStore 基于Redis的API密钥存储；keyPrefix 为空时使用 DefaultAPIKeyKeyPrefix
+func NewRedisAPIKeyStore(redisCC redis.UniversalClient, keyPrefix RedisCacheKeyPrefix) APIKeyStore {
+	if keyPrefix == "" {
+		keyPrefix = DefaultAPIKeyKeyPrefix
+	}
+	return &redisAPIKeyStore{
+		redisCC:   redisCC,
+		keyPrefix: keyPrefix,
+	}
+}
+
+// genKey ...
+func (s *redisAPIKeyStore) genKey(id string) string {
+	return s.keyPrefix.String() + "key:" + id
+}
+
+// genUserKey ...
+func (s *redisAPIKeyStore) genUserKey(userIdentifier string) string {
+	return s.keyPrefix.String() + "user:" + userIdentifier
+}
+
+// SaveAPIKey ...
+func (s *redisAPIKeyStore) SaveAPIKey(ctx context.Context, apiKey *APIKey) error {
+	value, err := apiKey.EncodeToString()
+	if err != nil {
+		return err
+	}
+	var expiration time.Duration
+	if apiKey.ExpiresAt > 0 {
+		expiration = time.Until(time.Unix(apiKey.ExpiresAt, 0))
+		if expiration <= 0 {
+			return nil
+		}
+	}
+	_, err = s.redisCC.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.genKey(apiKey.ID), value, expiration)
+		if apiKey.UserIdentifier != "" {
+			pipe.SAdd(ctx, s.genUserKey(apiKey.UserIdentifier), apiKey.ID)
+		}
+		return nil
+	})
+	return err
+}
+
+// GetAPIKey ...
+func (s *redisAPIKeyStore) GetAPIKey(ctx context.Context, id string) (*APIKey, bool, error) {
+	value, err := s.redisCC.Get(ctx, s.genKey(id)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, true, nil
+		}
+		return nil, false, err
+	}
+	apiKey := &APIKey{}
+	if err = apiKey.DecodeString(value); err != nil {
+		return nil, false, err
+	}
+	return apiKey, false, nil
+}
+
+// RevokeAPIKey 保留注销的密钥直至过期，用于返回 ErrAPIKeyRevoked
+// WATCH 密钥后读取并写回，期间密钥被修改时重试；已注销的密钥保留首次注销的时间
+func (s *redisAPIKeyStore) RevokeAPIKey(ctx context.Context, id string, revokedAt int64) error {
+	key := s.genKey(id)
+	revoke := func(tx *redis.Tx) error {
+		value, err := tx.Get(ctx, key).Result()
+		if err != nil {
+			if err == redis.Nil {
+				return nil
+			}
+			return err
+		}
+		apiKey := &APIKey{}
+		if err = apiKey.DecodeString(value); err != nil {
+			return err
+		}
+		if apiKey.RevokedAt > 0 {
+			return nil
+		}
+		apiKey.RevokedAt = revokedAt
+		if value, err = apiKey.EncodeToString(); err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetArgs(ctx, key, value, redis.SetArgs{KeepTTL: true})
+			return nil
+		})
+		return err
+	}
+	for i := 0; i < redisAPIKeyRevokeRetries; i++ {
+		if err := s.redisCC.Watch(ctx, revoke, key); err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return fmt.Errorf("revoke api key failed : %w", redis.TxFailedErr)
+}
+
+// ListAPIKeys 清除已过期的密钥id
+func (s *redisAPIKeyStore) ListAPIKeys(ctx context.Context, userIdentifier string) ([]*APIKey, error) {
+	userKey := s.genUserKey(userIdentifier)
+	ids, err := s.redisCC.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		apiKeys    = make([]*APIKey, 0, len(ids))
+		expiredIDs []interface{}
+	)
+	for _, id := range ids {
+		apiKey, isNotFound, err := s.GetAPIKey(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if isNotFound {
+			expiredIDs = append(expiredIDs, id)
+			continue
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+	if len(expiredIDs) > 0 {
+		if err = s.redisCC.SRem(ctx, userKey, expiredIDs...).Err(); err != nil {
+			return nil, err
+		}
+	}
+	return apiKeys, nil
+}
@@ -0,0 +1,191 @@
+package authpkg
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -count=1 ./auth -test.run=TestAPIKeyRepo
+func TestAPIKeyRepo(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		mr      = miniredis.RunT(t)
+		redisCC = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	)
+	defer func() { _ = redisCC.Close() }()
+
+	_, err := NewAPIKeyRepo(NewRedisAPIKeyStore(redisCC, ""), "invalid_prefix", log.DefaultLogger)
+	require.NotNil(t, err)
+	repo, err := NewAPIKeyRepo(NewRedisAPIKeyStore(redisCC, ""), "", log.DefaultLogger)
+	require.Nil(t, err)
+
+	plaintext, apiKey, err := repo.CreateAPIKey(ctx, &CreateAPIKeyParam{
+		Name:    "cron",
+		Payload: &Payload{UserID: 1, TokenType: TokenTypeEnum_USER},
+		Scopes:  []string{"order:read"},
+		Expire:  time.Hour,
+	})
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(plaintext, DefaultAPIKeyPrefix+"_"+apiKey.ID+"_"))
+	require.Equal(t, "1", apiKey.UserIdentifier)
+
+	// 仅存储哈希
+	stored, isNotFound, err := NewRedisAPIKeyStore(redisCC, "").GetAPIKey(ctx, apiKey.ID)
+	require.Nil(t, err)
+	require.False(t, isNotFound)
+	require.NotContains(t, plaintext, stored.SecretHash)
+	require.NotContains(t, stored.SecretHash, plaintext[strings.LastIndex(plaintext, "_")+1:])
+
+	verified, err := repo.VerifyAPIKey(ctx, plaintext)
+	require.Nil(t, err)
+	require.Equal(t, apiKey.ID, verified.ID)
+	require.Equal(t, []string{"order:read"}, verified.Scopes)
+
+	// 无效的密钥
+	tests := []string{
+		"",
+		"gsk_only",
+		"other_" + apiKey.ID + "_secret",
+		DefaultAPIKeyPrefix + "_" + apiKey.ID + "_secret",
+		DefaultAPIKeyPrefix + "_unknown_secret",
+	}
+	for _, tt := range tests {
+		_, err = repo.VerifyAPIKey(ctx, tt)
+		require.True(t, Is(err, ErrAPIKeyInvalid()), tt)
+	}
+
+	// 过期
+	repo.(*apiKeyRepo).now = func() time.Time { return time.Now().Add(time.Hour) }
+	_, err = repo.VerifyAPIKey(ctx, plaintext)
+	require.True(t, Is(err, ErrAPIKeyExpired()))
+	repo.(*apiKeyRepo).now = time.Now
+
+	// 注销
+	another, _, err := repo.CreateAPIKey(ctx, &CreateAPIKeyParam{Payload: &Payload{UserID: 1}})
+	require.Nil(t, err)
+	apiKeys, err := repo.ListAPIKeys(ctx, "1")
+	require.Nil(t, err)
+	require.Len(t, apiKeys, 2)
+	require.Nil(t, repo.RevokeAPIKey(ctx, apiKey.ID))
+	_, err = repo.VerifyAPIKey(ctx, plaintext)
+	require.True(t, Is(err, ErrAPIKeyRevoked()))
+	_, err = repo.VerifyAPIKey(ctx, another)
+	require.Nil(t, err)
+
+	// 过期的密钥从列表中清除
+	mr.FastForward(time.Hour)
+	apiKeys, err = repo.ListAPIKeys(ctx, "1")
+	require.Nil(t, err)
+	require.Len(t, apiKeys, 1)
+}
+
+// go test -v -count=1 ./auth -test.run=TestRedisAPIKeyStore_RevokeAPIKey
+func TestRedisAPIKeyStore_RevokeAPIKey(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		mr      = miniredis.RunT(t)
+		redisCC = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		store   = NewRedisAPIKeyStore(redisCC, "")
+	)
+	defer func() { _ = redisCC.Close() }()
+
+	apiKey := &APIKey{ID: "1", Prefix: DefaultAPIKeyPrefix, SecretHash: "hash", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	require.Nil(t, store.SaveAPIKey(ctx, apiKey))
+	require.Nil(t, store.RevokeAPIKey(ctx, "unknown", 1))
+
+	// 并发注销：保留首次注销的时间与过期时间
+	var wg sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(revokedAt int64) {
+			defer wg.Done()
+			require.Nil(t, store.RevokeAPIKey(ctx, apiKey.ID, revokedAt))
+		}(int64(i))
+	}
+	wg.Wait()
+	stored, isNotFound, err := store.GetAPIKey(ctx, apiKey.ID)
+	require.Nil(t, err)
+	require.False(t, isNotFound)
+	require.NotZero(t, stored.RevokedAt)
+	require.Positive(t, mr.TTL(DefaultAPIKeyKeyPrefix.String()+"key:"+apiKey.ID))
+	revokedAt := stored.RevokedAt
+	require.Nil(t, store.RevokeAPIKey(ctx, apiKey.ID, 100))
+	stored, _, err = store.GetAPIKey(ctx, apiKey.ID)
+	require.Nil(t, err)
+	require.Equal(t, revokedAt, stored.RevokedAt)
+}
+
+// go test -v -count=1 ./auth -test.run=TestAPIKeyServer
+func TestAPIKeyServer(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		mr      = miniredis.RunT(t)
+		redisCC = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	)
+	defer func() { _ = redisCC.Close() }()
+
+	repo, err := NewAPIKeyRepo(NewRedisAPIKeyStore(redisCC, ""), "", log.DefaultLogger)
+	require.Nil(t, err)
+	plaintext, apiKey, err := repo.CreateAPIKey(ctx, &CreateAPIKeyParam{
+		Payload: &Payload{UserID: 1, Roles: []string{"partner"}},
+		Scopes:  []string{"order:read"},
+	})
+	require.Nil(t, err)
+
+	var (
+		authRepo, _ = NewAuthRepo(newMemoryTokenManger(time.Now), log.DefaultLogger, Config{SignKey: "1234567890ABCDEF"})
+		jwtServer   = Server(
+			authRepo.JWTSigningKeyFunc,
+			WithSigningMethod(authRepo.JWTSigningMethod()),
+			WithClaims(authRepo.JWTSigningClaims),
+			WithTokenValidator(authRepo.VerifyToken),
+		)
+		call = func(key, value string, opts ...APIKeyOption) (*Claims, error) {
+			tr := newTestTransport("/api.order.v1.Order/List")
+			if key != "" {
+				tr.reqHeader.Set(key, value)
+			}
+			var authClaims *Claims
+			_, err := APIKeyServer(repo, opts...)(func(ctx context.Context, req interface{}) (interface{}, error) {
+				authClaims, _ = GetAuthClaimsFromContext(ctx)
+				return nil, nil
+			})(transport.NewServerContext(ctx, tr), nil)
+			return authClaims, err
+		}
+	)
+
+	// X-Api-Key 与 Authorization: ApiKey
+	authClaims, err := call(APIKeyHeaderKey, plaintext)
+	require.Nil(t, err)
+	require.Equal(t, apiKey.ID, authClaims.ID)
+	require.Equal(t, uint64(1), authClaims.Payload.UserID)
+	require.Equal(t, []string{"partner"}, authClaims.Payload.Roles)
+	require.Equal(t, []string{"order:read"}, authClaims.Payload.Scopes)
+	authClaims, err = call(AuthorizationKey, FormatToken(APIKeyScheme, plaintext))
+	require.Nil(t, err)
+	require.Equal(t, apiKey.ID, authClaims.ID)
+
+	_, err = call(APIKeyHeaderKey, plaintext+"0")
+	require.True(t, Is(err, ErrAPIKeyInvalid()))
+	_, err = call("", "")
+	require.True(t, Is(err, ErrMissingToken()))
+
+	// 没有API密钥时使用令牌认证
+	res, _, err := authRepo.SignToken(ctx, DefaultClaims(Payload{UserID: 2}))
+	require.Nil(t, err)
+	authClaims, err = call(AuthorizationKey, FormatToken(BearerWord, res.AccessToken), WithAPIKeyFallback(jwtServer))
+	require.Nil(t, err)
+	require.Equal(t, uint64(2), authClaims.Payload.UserID)
+	authClaims, err = call(APIKeyHeaderKey, plaintext, WithAPIKeyFallback(jwtServer))
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), authClaims.Payload.UserID)
+}
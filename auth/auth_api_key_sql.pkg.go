@@ -0,0 +1,116 @@
+package authpkg
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// apiKeyRow 数据表的行；授权信息与权限存储为json
+type apiKeyRow struct {
+	APIKey
+	PayloadJSON string `db:"payload"`
+	ScopesJSON  string `db:"scopes"`
+}
+
+// toAPIKey ...
+func (s *apiKeyRow) toAPIKey() (*APIKey, error) {
+	apiKey := s.APIKey
+	if s.PayloadJSON != "" && s.PayloadJSON != "null" {
+		apiKey.Payload = &Payload{}
+		if err := json.Unmarshal([]byte(s.PayloadJSON), apiKey.Payload); err != nil {
+			return nil, fmt.Errorf("decode api key payload failed : %w", err)
+		}
+	}
+	if s.ScopesJSON != "" && s.ScopesJSON != "null" {
+		if err := json.Unmarshal([]byte(s.ScopesJSON), &apiKey.Scopes); err != nil {
+			return nil, fmt.Errorf("decode api key scopes failed : %w", err)
+		}
+	}
+	return &apiKey, nil
+}
+
+// sqlAPIKeyStore ...
+type sqlAPIKeyStore struct {
+	db    *sqlx.DB
+	table string
+}
+
+// NewSQLAPIKeyStore 基于数据库的API密钥存储；table 为空时使用 DefaultAPIKeyTable，参考 APIKeyTableSQL
+func NewSQLAPIKeyStore(db *sqlx.DB, table string) APIKeyStore {
+	if table == "" {
+		table = DefaultAPIKeyTable
+	}
+	return &sqlAPIKeyStore{
+		db:    db,
+		table: table,
+	}
+}
+
+// columns ...
+func (s *sqlAPIKeyStore) columns() string {
+	return "id, prefix, name, user_identifier, secret_hash, payload, scopes, expires_at, revoked_at, created_at"
+}
+
+// SaveAPIKey ...
+func (s *sqlAPIKeyStore) SaveAPIKey(ctx context.Context, apiKey *APIKey) error {
+	payload, err := json.Marshal(apiKey.Payload)
+	if err != nil {
+		return fmt.Errorf("encode api key payload failed : %w", err)
+	}
+	scopes, err := json.Marshal(apiKey.Scopes)
+	if err != nil {
+		return fmt.Errorf("encode api key scopes failed : %w", err)
+	}
+	query := s.db.Rebind("INSERT INTO " + s.table + " (" + s.columns() + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	_, err = s.db.ExecContext(ctx, query,
+		apiKey.ID, apiKey.Prefix, apiKey.Name, apiKey.UserIdentifier, apiKey.SecretHash,
+		string(payload), string(scopes), apiKey.ExpiresAt, apiKey.RevokedAt, apiKey.CreatedAt,
+	)
+	return err
+}
+
+// GetAPIKey ...
+func (s *sqlAPIKeyStore) GetAPIKey(ctx context.Context, id string) (*APIKey, bool, error) {
+	row := &apiKeyRow{}
+	query := s.db.Rebind("SELECT " + s.columns() + " FROM " + s.table + " WHERE id = ?")
+	if err := s.db.GetContext(ctx, row, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, true, nil
+		}
+		return nil, false, err
+	}
+	apiKey, err := row.toAPIKey()
+	if err != nil {
+		return nil, false, err
+	}
+	return apiKey, false, nil
+}
+
+// RevokeAPIKey ...
+func (s *sqlAPIKeyStore) RevokeAPIKey(ctx context.Context, id string, revokedAt int64) error {
+	query := s.db.Rebind("UPDATE " + s.table + " SET revoked_at = ? WHERE id = ? AND revoked_at = 0")
+	_, err := s.db.ExecContext(ctx, query, revokedAt, id)
+	return err
+}
+
+// ListAPIKeys ...
+func (s *sqlAPIKeyStore) ListAPIKeys(ctx context.Context, userIdentifier string) ([]*APIKey, error) {
+	var rows []*apiKeyRow
+	query := s.db.Rebind("SELECT " + s.columns() + " FROM " + s.table + " WHERE user_identifier = ? ORDER BY created_at DESC")
+	if err := s.db.SelectContext(ctx, &rows, query, userIdentifier); err != nil {
+		return nil, err
+	}
+	apiKeys := make([]*APIKey, 0, len(rows))
+	for _, row := range rows {
+		apiKey, err := row.toAPIKey()
+		if err != nil {
+			return nil, err
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+	return apiKeys, nil
+}
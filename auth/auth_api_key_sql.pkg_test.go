@@ -0,0 +1,138 @@
+package authpkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+// apiKeyTableSQLite 与 APIKeyTableSQL 相同的列
+const apiKeyTableSQLite = "CREATE TABLE " + DefaultAPIKeyTable + " (" +
+	"id TEXT NOT NULL PRIMARY KEY," +
+	"prefix TEXT NOT NULL DEFAULT ''," +
+	"name TEXT NOT NULL DEFAULT ''," +
+	"user_identifier TEXT NOT NULL DEFAULT ''," +
+	"secret_hash TEXT NOT NULL," +
+	"payload TEXT NOT NULL," +
+	"scopes TEXT NOT NULL," +
+	"expires_at BIGINT NOT NULL DEFAULT 0," +
+	"revoked_at BIGINT NOT NULL DEFAULT 0," +
+	"created_at BIGINT NOT NULL DEFAULT 0" +
+	")"
+
+// newTestSQLAPIKeyStore ...
+func newTestSQLAPIKeyStore(t *testing.T) (APIKeyStore, *sqlx.DB) {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	require.Nil(t, err)
+	// 内存数据库按连接隔离
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	_, err = db.Exec(apiKeyTableSQLite)
+	require.Nil(t, err)
+	return NewSQLAPIKeyStore(db, ""), db
+}
+
+// go test -v -count=1 ./auth -test.run=TestSQLAPIKeyStore
+func TestSQLAPIKeyStore(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		store, _ = newTestSQLAPIKeyStore(t)
+		now      = time.Now().Unix()
+	)
+
+	// 不存在
+	apiKey, isNotFound, err := store.GetAPIKey(ctx, "unknown")
+	require.Nil(t, err)
+	require.True(t, isNotFound)
+	require.Nil(t, apiKey)
+	require.Nil(t, store.RevokeAPIKey(ctx, "unknown", now))
+
+	// 保存与读取：授权信息与权限存储为json
+	saved := &APIKey{
+		ID:             "1",
+		Prefix:         DefaultAPIKeyPrefix,
+		Name:           "cron",
+		UserIdentifier: "1",
+		SecretHash:     hashAPIKeySecret("secret"),
+		Payload:        &Payload{UserID: 1, TokenType: TokenTypeEnum_USER, Roles: []string{"partner"}},
+		Scopes:         []string{"order:read", "order:write"},
+		ExpiresAt:      now + 3600,
+		CreatedAt:      now,
+	}
+	require.Nil(t, store.SaveAPIKey(ctx, saved))
+	require.NotNil(t, store.SaveAPIKey(ctx, saved))
+	apiKey, isNotFound, err = store.GetAPIKey(ctx, saved.ID)
+	require.Nil(t, err)
+	require.False(t, isNotFound)
+	require.Equal(t, saved.SecretHash, apiKey.SecretHash)
+	require.Equal(t, saved.Scopes, apiKey.Scopes)
+	require.Equal(t, uint64(1), apiKey.Payload.UserID)
+	require.Equal(t, []string{"partner"}, apiKey.Payload.Roles)
+	require.Equal(t, saved.ExpiresAt, apiKey.ExpiresAt)
+	require.Zero(t, apiKey.RevokedAt)
+
+	// 没有授权信息与权限
+	other := &APIKey{ID: "2", Prefix: DefaultAPIKeyPrefix, UserIdentifier: "1", SecretHash: "hash", CreatedAt: now + 1}
+	require.Nil(t, store.SaveAPIKey(ctx, other))
+	apiKey, _, err = store.GetAPIKey(ctx, other.ID)
+	require.Nil(t, err)
+	require.Nil(t, apiKey.Payload)
+	require.Empty(t, apiKey.Scopes)
+
+	// 注销：保留首次注销的时间
+	require.Nil(t, store.RevokeAPIKey(ctx, saved.ID, now))
+	require.Nil(t, store.RevokeAPIKey(ctx, saved.ID, now+60))
+	apiKey, _, err = store.GetAPIKey(ctx, saved.ID)
+	require.Nil(t, err)
+	require.Equal(t, now, apiKey.RevokedAt)
+
+	// 列表：按创建时间倒序
+	apiKeys, err := store.ListAPIKeys(ctx, "1")
+	require.Nil(t, err)
+	require.Len(t, apiKeys, 2)
+	require.Equal(t, other.ID, apiKeys[0].ID)
+	require.Equal(t, saved.ID, apiKeys[1].ID)
+	require.Equal(t, now, apiKeys[1].RevokedAt)
+	apiKeys, err = store.ListAPIKeys(ctx, "2")
+	require.Nil(t, err)
+	require.Empty(t, apiKeys)
+}
+
+// go test -v -count=1 ./auth -test.run=TestAPIKeyRepo_SQL
+func TestAPIKeyRepo_SQL(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		store, db = newTestSQLAPIKeyStore(t)
+	)
+	repo, err := NewAPIKeyRepo(store, "", log.DefaultLogger)
+	require.Nil(t, err)
+	plaintext, apiKey, err := repo.CreateAPIKey(ctx, &CreateAPIKeyParam{
+		Payload: &Payload{UserID: 1, TokenType: TokenTypeEnum_USER},
+		Scopes:  []string{"order:read"},
+		Expire:  time.Hour,
+	})
+	require.Nil(t, err)
+
+	verified, err := repo.VerifyAPIKey(ctx, plaintext)
+	require.Nil(t, err)
+	require.Equal(t, apiKey.ID, verified.ID)
+	require.Equal(t, []string{"order:read"}, verified.Scopes)
+	_, err = repo.VerifyAPIKey(ctx, DefaultAPIKeyPrefix+"_unknown_secret")
+	require.True(t, Is(err, ErrAPIKeyInvalid()))
+
+	// 注销
+	require.Nil(t, repo.RevokeAPIKey(ctx, apiKey.ID))
+	_, err = repo.VerifyAPIKey(ctx, plaintext)
+	require.True(t, Is(err, ErrAPIKeyRevoked()))
+
+	// 无效的授权信息
+	_, err = db.Exec("UPDATE "+DefaultAPIKeyTable+" SET payload = ? WHERE id = ?", "{", apiKey.ID)
+	require.Nil(t, err)
+	_, _, err = store.GetAPIKey(ctx, apiKey.ID)
+	require.NotNil(t, err)
+}
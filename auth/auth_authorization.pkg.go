@@ -0,0 +1,68 @@
+package authpkg
+
+import (
+	"context"
+
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+
+	errorpkg "github.com/eden-quan/go-kratos-pkg/error"
+)
+
+// AuthorizationOption is authorization option.
+type AuthorizationOption func(*authorizationOptions)
+
+// authorizationOptions ...
+type authorizationOptions struct {
+	denyUnmatched bool
+}
+
+// WithDenyUnmatched 拒绝没有匹配规则的 operation；默认放行
+func WithDenyUnmatched() AuthorizationOption {
+	return func(o *authorizationOptions) {
+		o.denyUnmatched = true
+	}
+}
+
+// Authorization 授权中间件：根据授权策略校验令牌的角色与权限
+// 需在 Server 之后使用；令牌信息从 GetAuthClaimsFromContext 获取
+func Authorization(source PolicySource, opts ...AuthorizationOption) middleware.Middleware {
+	o := &authorizationOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok {
+				e := ErrWrongContext()
+				return nil, errorpkg.WithStack(e)
+			}
+			policy, err := source.Policy(ctx)
+			if err != nil {
+				e := ErrPermissionDenied()
+				e.Metadata = map[string]string{"operation": tr.Operation(), "error": err.Error()}
+				return nil, errorpkg.WithStack(e)
+			}
+			var payload *Payload
+			if authClaims, ok := GetAuthClaimsFromContext(ctx); ok {
+				payload = authClaims.Payload
+			}
+			matched, allowed := policy.Authorize(tr.Operation(), payload)
+			if !matched {
+				if !o.denyUnmatched {
+					return handler(ctx, req)
+				}
+			} else if allowed {
+				return handler(ctx, req)
+			}
+			if payload == nil {
+				e := ErrMissingToken()
+				return nil, errorpkg.WithStack(e)
+			}
+			e := ErrPermissionDenied()
+			e.Metadata = map[string]string{"operation": tr.Operation()}
+			return nil, errorpkg.WithStack(e)
+		}
+	}
+}
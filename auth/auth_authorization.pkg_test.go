@@ -0,0 +1,53 @@
+package authpkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -count=1 ./auth -test.run=TestAuthorization
+func TestAuthorization(t *testing.T) {
+	source := NewStaticPolicySource(&Policy{
+		Rules: []*PolicyRule{
+			{Operation: "/api.user.v1.User/Delete", Roles: []string{"admin"}},
+		},
+	})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	call := func(operation string, payload *Payload, opts ...AuthorizationOption) error {
+		ctx := transport.NewServerContext(context.Background(), newTestTransport(operation))
+		if payload != nil {
+			ctx = PutAuthClaimsIntoContext(ctx, DefaultClaims(*payload))
+		}
+		_, err := Authorization(source, opts...)(handler)(ctx, nil)
+		return err
+	}
+
+	// 允许
+	require.Nil(t, call("/api.user.v1.User/Delete", &Payload{Roles: []string{"admin"}}))
+	require.Nil(t, call("/api.user.v1.User/Get", &Payload{}))
+	require.Nil(t, call("/api.user.v1.User/Get", nil))
+
+	// 拒绝
+	err := call("/api.user.v1.User/Delete", &Payload{Roles: []string{"user"}})
+	require.NotNil(t, err)
+	e := errors.FromError(err)
+	require.Equal(t, int32(403), e.Code)
+	require.Equal(t, ERROR_PERMISSION_DENIED.String(), e.Reason)
+	require.Equal(t, "/api.user.v1.User/Delete", e.Metadata["operation"])
+
+	err = call("/api.user.v1.User/Get", &Payload{}, WithDenyUnmatched())
+	require.True(t, Is(err, ErrPermissionDenied()))
+
+	// 未登录
+	err = call("/api.user.v1.User/Delete", nil)
+	require.True(t, Is(err, ErrMissingToken()))
+
+	_, err = Authorization(source)(handler)(context.Background(), nil)
+	require.True(t, Is(err, ErrWrongContext()))
+}
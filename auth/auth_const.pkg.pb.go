@@ -25,17 +25,37 @@ const (
 type ERROR int32
 
 const (
-	ERROR_UNKNOWN               ERROR = 0
-	ERROR_TOKEN_MISSING         ERROR = 1
-	ERROR_TOKEN_KEY_MISSING     ERROR = 2
-	ERROR_TOKEN_METHOD_MISSING  ERROR = 3
-	ERROR_UNAUTHORIZED          ERROR = 4
-	ERROR_TOKEN_EXPIRED         ERROR = 5
-	ERROR_AUTHENTICATION_FAILED ERROR = 6
-	ERROR_TOKEN_INVALID         ERROR = 7
-	ERROR_TOKEN_DEPRECATED      ERROR = 8
-	ERROR_VERIFICATION_FAILED   ERROR = 9
-	ERROR_INVALID_CLAIMS        ERROR = 10
+	ERROR_UNKNOWN                  ERROR = 0
+	ERROR_TOKEN_MISSING            ERROR = 1
+	ERROR_TOKEN_KEY_MISSING        ERROR = 2
+	ERROR_TOKEN_METHOD_MISSING     ERROR = 3
+	ERROR_UNAUTHORIZED             ERROR = 4
+	ERROR_TOKEN_EXPIRED            ERROR = 5
+	ERROR_AUTHENTICATION_FAILED    ERROR = 6
+	ERROR_TOKEN_INVALID            ERROR = 7
+	ERROR_TOKEN_DEPRECATED         ERROR = 8
+	ERROR_VERIFICATION_FAILED      ERROR = 9
+	ERROR_INVALID_CLAIMS           ERROR = 10
+	ERROR_REFRESH_TOKEN_INVALID    ERROR = 11
+	ERROR_REFRESH_TOKEN_REUSED     ERROR = 12
+	ERROR_PERMISSION_DENIED        ERROR = 13
+	ERROR_LOGIN_LIMIT              ERROR = 14
+	ERROR_LOGIN_TOO_FREQUENT       ERROR = 15
+	ERROR_LOGIN_LOCKED             ERROR = 16
+	ERROR_API_KEY_INVALID          ERROR = 17
+	ERROR_API_KEY_EXPIRED          ERROR = 18
+	ERROR_API_KEY_REVOKED          ERROR = 19
+	ERROR_SIGNATURE_MISSING        ERROR = 20
+	ERROR_SIGNATURE_INVALID        ERROR = 21
+	ERROR_SIGNATURE_EXPIRED        ERROR = 22
+	ERROR_SIGNATURE_REPLAYED       ERROR = 23
+	ERROR_OIDC_TOKEN_INVALID       ERROR = 24
+	ERROR_TOKEN_GENERATION_REVOKED ERROR = 25
+	ERROR_MFA_REQUIRED             ERROR = 26
+	ERROR_MFA_CODE_INVALID         ERROR = 27
+	ERROR_MFA_NOT_ENROLLED         ERROR = 28
+	ERROR_IMPERSONATION_FORBIDDEN  ERROR = 29
+	ERROR_MFA_ALREADY_ENROLLED     ERROR = 30
 )
 
 // Enum value maps for ERROR.
@@ -52,19 +72,59 @@ var (
 		8:  "TOKEN_DEPRECATED",
 		9:  "VERIFICATION_FAILED",
 		10: "INVALID_CLAIMS",
+		11: "REFRESH_TOKEN_INVALID",
+		12: "REFRESH_TOKEN_REUSED",
+		13: "PERMISSION_DENIED",
+		14: "LOGIN_LIMIT",
+		15: "LOGIN_TOO_FREQUENT",
+		16: "LOGIN_LOCKED",
+		17: "API_KEY_INVALID",
+		18: "API_KEY_EXPIRED",
+		19: "API_KEY_REVOKED",
+		20: "SIGNATURE_MISSING",
+		21: "SIGNATURE_INVALID",
+		22: "SIGNATURE_EXPIRED",
+		23: "SIGNATURE_REPLAYED",
+		24: "OIDC_TOKEN_INVALID",
+		25: "TOKEN_GENERATION_REVOKED",
+		26: "MFA_REQUIRED",
+		27: "MFA_CODE_INVALID",
+		28: "MFA_NOT_ENROLLED",
+		29: "IMPERSONATION_FORBIDDEN",
+		30: "MFA_ALREADY_ENROLLED",
 	}
 	ERROR_value = map[string]int32{
-		"UNKNOWN":               0,
-		"TOKEN_MISSING":         1,
-		"TOKEN_KEY_MISSING":     2,
-		"TOKEN_METHOD_MISSING":  3,
-		"UNAUTHORIZED":          4,
-		"TOKEN_EXPIRED":         5,
-		"AUTHENTICATION_FAILED": 6,
-		"TOKEN_INVALID":         7,
-		"TOKEN_DEPRECATED":      8,
-		"VERIFICATION_FAILED":   9,
-		"INVALID_CLAIMS":        10,
+		"UNKNOWN":                  0,
+		"TOKEN_MISSING":            1,
+		"TOKEN_KEY_MISSING":        2,
+		"TOKEN_METHOD_MISSING":     3,
+		"UNAUTHORIZED":             4,
+		"TOKEN_EXPIRED":            5,
+		"AUTHENTICATION_FAILED":    6,
+		"TOKEN_INVALID":            7,
+		"TOKEN_DEPRECATED":         8,
+		"VERIFICATION_FAILED":      9,
+		"INVALID_CLAIMS":           10,
+		"REFRESH_TOKEN_INVALID":    11,
+		"REFRESH_TOKEN_REUSED":     12,
+		"PERMISSION_DENIED":        13,
+		"LOGIN_LIMIT":              14,
+		"LOGIN_TOO_FREQUENT":       15,
+		"LOGIN_LOCKED":             16,
+		"API_KEY_INVALID":          17,
+		"API_KEY_EXPIRED":          18,
+		"API_KEY_REVOKED":          19,
+		"SIGNATURE_MISSING":        20,
+		"SIGNATURE_INVALID":        21,
+		"SIGNATURE_EXPIRED":        22,
+		"SIGNATURE_REPLAYED":       23,
+		"OIDC_TOKEN_INVALID":       24,
+		"TOKEN_GENERATION_REVOKED": 25,
+		"MFA_REQUIRED":             26,
+		"MFA_CODE_INVALID":         27,
+		"MFA_NOT_ENROLLED":         28,
+		"IMPERSONATION_FORBIDDEN":  29,
+		"MFA_ALREADY_ENROLLED":     30,
 	}
 )
 
@@ -271,6 +331,8 @@ const (
 	TokenTypeEnum_UNSPECIFIED TokenTypeEnum_TokenType = 0
 	TokenTypeEnum_ADMIN       TokenTypeEnum_TokenType = 1
 	TokenTypeEnum_USER        TokenTypeEnum_TokenType = 2
+	// SERVICE 服务间调用
+	TokenTypeEnum_SERVICE TokenTypeEnum_TokenType = 3
 )
 
 // Enum value maps for TokenTypeEnum_TokenType.
@@ -279,11 +341,13 @@ var (
 		0: "UNSPECIFIED",
 		1: "ADMIN",
 		2: "USER",
+		3: "SERVICE",
 	}
 	TokenTypeEnum_TokenType_value = map[string]int32{
 		"UNSPECIFIED": 0,
 		"ADMIN":       1,
 		"USER":        2,
+		"SERVICE":     3,
 	}
 )
 
@@ -497,37 +561,73 @@ var file_auth_auth_const_pkg_proto_rawDesc = []byte{
 	0x74, 0x12, 0x0d, 0x0a, 0x09, 0x55, 0x4e, 0x4c, 0x49, 0x4d, 0x49, 0x54, 0x45, 0x44, 0x10, 0x00,
 	0x12, 0x0c, 0x0a, 0x08, 0x4f, 0x4e, 0x4c, 0x59, 0x5f, 0x4f, 0x4e, 0x45, 0x10, 0x01, 0x12, 0x10,
 	0x0a, 0x0c, 0x50, 0x4c, 0x41, 0x54, 0x46, 0x4f, 0x52, 0x4d, 0x5f, 0x4f, 0x4e, 0x45, 0x10, 0x02,
-	0x22, 0x42, 0x0a, 0x0d, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x45, 0x6e, 0x75,
-	0x6d, 0x22, 0x31, 0x0a, 0x09, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0f,
+	0x22, 0x4f, 0x0a, 0x0d, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x45, 0x6e, 0x75,
+	0x6d, 0x22, 0x3e, 0x0a, 0x09, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0f,
 	0x0a, 0x0b, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12,
 	0x09, 0x0a, 0x05, 0x41, 0x44, 0x4d, 0x49, 0x4e, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x55, 0x53,
-	0x45, 0x52, 0x10, 0x02, 0x2a, 0xb6, 0x02, 0x0a, 0x05, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x12, 0x11,
-	0x0a, 0x07, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x1a, 0x04, 0xa8, 0x45, 0xf4,
-	0x03, 0x12, 0x17, 0x0a, 0x0d, 0x54, 0x4f, 0x4b, 0x45, 0x4e, 0x5f, 0x4d, 0x49, 0x53, 0x53, 0x49,
-	0x4e, 0x47, 0x10, 0x01, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x1b, 0x0a, 0x11, 0x54, 0x4f,
-	0x4b, 0x45, 0x4e, 0x5f, 0x4b, 0x45, 0x59, 0x5f, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4e, 0x47, 0x10,
-	0x02, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x1e, 0x0a, 0x14, 0x54, 0x4f, 0x4b, 0x45, 0x4e,
-	0x5f, 0x4d, 0x45, 0x54, 0x48, 0x4f, 0x44, 0x5f, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4e, 0x47, 0x10,
-	0x03, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x16, 0x0a, 0x0c, 0x55, 0x4e, 0x41, 0x55, 0x54,
-	0x48, 0x4f, 0x52, 0x49, 0x5a, 0x45, 0x44, 0x10, 0x04, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12,
-	0x17, 0x0a, 0x0d, 0x54, 0x4f, 0x4b, 0x45, 0x4e, 0x5f, 0x45, 0x58, 0x50, 0x49, 0x52, 0x45, 0x44,
-	0x10, 0x05, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x1f, 0x0a, 0x15, 0x41, 0x55, 0x54, 0x48,
-	0x45, 0x4e, 0x54, 0x49, 0x43, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45,
-	0x44, 0x10, 0x06, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x17, 0x0a, 0x0d, 0x54, 0x4f, 0x4b,
-	0x45, 0x4e, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x07, 0x1a, 0x04, 0xa8, 0x45,
-	0x91, 0x03, 0x12, 0x1a, 0x0a, 0x10, 0x54, 0x4f, 0x4b, 0x45, 0x4e, 0x5f, 0x44, 0x45, 0x50, 0x52,
-	0x45, 0x43, 0x41, 0x54, 0x45, 0x44, 0x10, 0x08, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x1d,
-	0x0a, 0x13, 0x56, 0x45, 0x52, 0x49, 0x46, 0x49, 0x43, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x46,
-	0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x09, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x18, 0x0a,
-	0x0e, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x5f, 0x43, 0x4c, 0x41, 0x49, 0x4d, 0x53, 0x10,
-	0x0a, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x1a, 0x04, 0xa0, 0x45, 0xf4, 0x03, 0x42, 0x60, 0x0a,
-	0x0b, 0x70, 0x6b, 0x67, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x70, 0x6b, 0x67, 0x42, 0x0a, 0x50, 0x6b,
-	0x67, 0x41, 0x75, 0x74, 0x68, 0x50, 0x6b, 0x67, 0x50, 0x01, 0x5a, 0x43, 0x67, 0x69, 0x74, 0x6c,
-	0x61, 0x62, 0x2e, 0x72, 0x65, 0x61, 0x6c, 0x69, 0x62, 0x6f, 0x78, 0x2e, 0x63, 0x6e, 0x2f, 0x64,
-	0x65, 0x73, 0x69, 0x67, 0x6e, 0x68, 0x75, 0x62, 0x2f, 0x61, 0x70, 0x70, 0x2d, 0x73, 0x65, 0x72,
-	0x76, 0x65, 0x72, 0x2f, 0x68, 0x75, 0x62, 0x2d, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2d, 0x70,
-	0x6b, 0x67, 0x2f, 0x61, 0x75, 0x74, 0x68, 0x3b, 0x61, 0x75, 0x74, 0x68, 0x70, 0x6b, 0x67, 0x62,
-	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x45, 0x52, 0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x45, 0x52, 0x56, 0x49, 0x43, 0x45, 0x10,
+	0x03, 0x2a, 0xfc, 0x06, 0x0a, 0x05, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x12, 0x11, 0x0a, 0x07, 0x55,
+	0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x1a, 0x04, 0xa8, 0x45, 0xf4, 0x03, 0x12, 0x17,
+	0x0a, 0x0d, 0x54, 0x4f, 0x4b, 0x45, 0x4e, 0x5f, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4e, 0x47, 0x10,
+	0x01, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x1b, 0x0a, 0x11, 0x54, 0x4f, 0x4b, 0x45, 0x4e,
+	0x5f, 0x4b, 0x45, 0x59, 0x5f, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4e, 0x47, 0x10, 0x02, 0x1a, 0x04,
+	0xa8, 0x45, 0x91, 0x03, 0x12, 0x1e, 0x0a, 0x14, 0x54, 0x4f, 0x4b, 0x45, 0x4e, 0x5f, 0x4d, 0x45,
+	0x54, 0x48, 0x4f, 0x44, 0x5f, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4e, 0x47, 0x10, 0x03, 0x1a, 0x04,
+	0xa8, 0x45, 0x91, 0x03, 0x12, 0x16, 0x0a, 0x0c, 0x55, 0x4e, 0x41, 0x55, 0x54, 0x48, 0x4f, 0x52,
+	0x49, 0x5a, 0x45, 0x44, 0x10, 0x04, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x17, 0x0a, 0x0d,
+	0x54, 0x4f, 0x4b, 0x45, 0x4e, 0x5f, 0x45, 0x58, 0x50, 0x49, 0x52, 0x45, 0x44, 0x10, 0x05, 0x1a,
+	0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x1f, 0x0a, 0x15, 0x41, 0x55, 0x54, 0x48, 0x45, 0x4e, 0x54,
+	0x49, 0x43, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x06,
+	0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x17, 0x0a, 0x0d, 0x54, 0x4f, 0x4b, 0x45, 0x4e, 0x5f,
+	0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x07, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12,
+	0x1a, 0x0a, 0x10, 0x54, 0x4f, 0x4b, 0x45, 0x4e, 0x5f, 0x44, 0x45, 0x50, 0x52, 0x45, 0x43, 0x41,
+	0x54, 0x45, 0x44, 0x10, 0x08, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x1d, 0x0a, 0x13, 0x56,
+	0x45, 0x52, 0x49, 0x46, 0x49, 0x43, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x46, 0x41, 0x49, 0x4c,
+	0x45, 0x44, 0x10, 0x09, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x18, 0x0a, 0x0e, 0x49, 0x4e,
+	0x56, 0x41, 0x4c, 0x49, 0x44, 0x5f, 0x43, 0x4c, 0x41, 0x49, 0x4d, 0x53, 0x10, 0x0a, 0x1a, 0x04,
+	0xa8, 0x45, 0x91, 0x03, 0x12, 0x1f, 0x0a, 0x15, 0x52, 0x45, 0x46, 0x52, 0x45, 0x53, 0x48, 0x5f,
+	0x54, 0x4f, 0x4b, 0x45, 0x4e, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x0b, 0x1a,
+	0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x1e, 0x0a, 0x14, 0x52, 0x45, 0x46, 0x52, 0x45, 0x53, 0x48,
+	0x5f, 0x54, 0x4f, 0x4b, 0x45, 0x4e, 0x5f, 0x52, 0x45, 0x55, 0x53, 0x45, 0x44, 0x10, 0x0c, 0x1a,
+	0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x1b, 0x0a, 0x11, 0x50, 0x45, 0x52, 0x4d, 0x49, 0x53, 0x53,
+	0x49, 0x4f, 0x4e, 0x5f, 0x44, 0x45, 0x4e, 0x49, 0x45, 0x44, 0x10, 0x0d, 0x1a, 0x04, 0xa8, 0x45,
+	0x93, 0x03, 0x12, 0x15, 0x0a, 0x0b, 0x4c, 0x4f, 0x47, 0x49, 0x4e, 0x5f, 0x4c, 0x49, 0x4d, 0x49,
+	0x54, 0x10, 0x0e, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x1c, 0x0a, 0x12, 0x4c, 0x4f, 0x47,
+	0x49, 0x4e, 0x5f, 0x54, 0x4f, 0x4f, 0x5f, 0x46, 0x52, 0x45, 0x51, 0x55, 0x45, 0x4e, 0x54, 0x10,
+	0x0f, 0x1a, 0x04, 0xa8, 0x45, 0xad, 0x03, 0x12, 0x16, 0x0a, 0x0c, 0x4c, 0x4f, 0x47, 0x49, 0x4e,
+	0x5f, 0x4c, 0x4f, 0x43, 0x4b, 0x45, 0x44, 0x10, 0x10, 0x1a, 0x04, 0xa8, 0x45, 0xad, 0x03, 0x12,
+	0x19, 0x0a, 0x0f, 0x41, 0x50, 0x49, 0x5f, 0x4b, 0x45, 0x59, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c,
+	0x49, 0x44, 0x10, 0x11, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x19, 0x0a, 0x0f, 0x41, 0x50,
+	0x49, 0x5f, 0x4b, 0x45, 0x59, 0x5f, 0x45, 0x58, 0x50, 0x49, 0x52, 0x45, 0x44, 0x10, 0x12, 0x1a,
+	0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x19, 0x0a, 0x0f, 0x41, 0x50, 0x49, 0x5f, 0x4b, 0x45, 0x59,
+	0x5f, 0x52, 0x45, 0x56, 0x4f, 0x4b, 0x45, 0x44, 0x10, 0x13, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03,
+	0x12, 0x1b, 0x0a, 0x11, 0x53, 0x49, 0x47, 0x4e, 0x41, 0x54, 0x55, 0x52, 0x45, 0x5f, 0x4d, 0x49,
+	0x53, 0x53, 0x49, 0x4e, 0x47, 0x10, 0x14, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x1b, 0x0a,
+	0x11, 0x53, 0x49, 0x47, 0x4e, 0x41, 0x54, 0x55, 0x52, 0x45, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c,
+	0x49, 0x44, 0x10, 0x15, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x1b, 0x0a, 0x11, 0x53, 0x49,
+	0x47, 0x4e, 0x41, 0x54, 0x55, 0x52, 0x45, 0x5f, 0x45, 0x58, 0x50, 0x49, 0x52, 0x45, 0x44, 0x10,
+	0x16, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x1c, 0x0a, 0x12, 0x53, 0x49, 0x47, 0x4e, 0x41,
+	0x54, 0x55, 0x52, 0x45, 0x5f, 0x52, 0x45, 0x50, 0x4c, 0x41, 0x59, 0x45, 0x44, 0x10, 0x17, 0x1a,
+	0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x1c, 0x0a, 0x12, 0x4f, 0x49, 0x44, 0x43, 0x5f, 0x54, 0x4f,
+	0x4b, 0x45, 0x4e, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x18, 0x1a, 0x04, 0xa8,
+	0x45, 0x91, 0x03, 0x12, 0x22, 0x0a, 0x18, 0x54, 0x4f, 0x4b, 0x45, 0x4e, 0x5f, 0x47, 0x45, 0x4e,
+	0x45, 0x52, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x52, 0x45, 0x56, 0x4f, 0x4b, 0x45, 0x44, 0x10,
+	0x19, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x16, 0x0a, 0x0c, 0x4d, 0x46, 0x41, 0x5f, 0x52,
+	0x45, 0x51, 0x55, 0x49, 0x52, 0x45, 0x44, 0x10, 0x1a, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12,
+	0x1a, 0x0a, 0x10, 0x4d, 0x46, 0x41, 0x5f, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x49, 0x4e, 0x56, 0x41,
+	0x4c, 0x49, 0x44, 0x10, 0x1b, 0x1a, 0x04, 0xa8, 0x45, 0x91, 0x03, 0x12, 0x1a, 0x0a, 0x10, 0x4d,
+	0x46, 0x41, 0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x45, 0x4e, 0x52, 0x4f, 0x4c, 0x4c, 0x45, 0x44, 0x10,
+	0x1c, 0x1a, 0x04, 0xa8, 0x45, 0x93, 0x03, 0x12, 0x21, 0x0a, 0x17, 0x49, 0x4d, 0x50, 0x45, 0x52,
+	0x53, 0x4f, 0x4e, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x46, 0x4f, 0x52, 0x42, 0x49, 0x44, 0x44,
+	0x45, 0x4e, 0x10, 0x1d, 0x1a, 0x04, 0xa8, 0x45, 0x93, 0x03, 0x12, 0x1e, 0x0a, 0x14, 0x4d, 0x46,
+	0x41, 0x5f, 0x41, 0x4c, 0x52, 0x45, 0x41, 0x44, 0x59, 0x5f, 0x45, 0x4e, 0x52, 0x4f, 0x4c, 0x4c,
+	0x45, 0x44, 0x10, 0x1e, 0x1a, 0x04, 0xa8, 0x45, 0x99, 0x03, 0x1a, 0x04, 0xa0, 0x45, 0xf4, 0x03,
+	0x42, 0x4c, 0x0a, 0x0b, 0x70, 0x6b, 0x67, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x70, 0x6b, 0x67, 0x42,
+	0x0a, 0x50, 0x6b, 0x67, 0x41, 0x75, 0x74, 0x68, 0x50, 0x6b, 0x67, 0x50, 0x01, 0x5a, 0x2f, 0x67,
+	0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x65, 0x64, 0x65, 0x6e, 0x2d, 0x71,
+	0x75, 0x61, 0x6e, 0x2f, 0x67, 0x6f, 0x2d, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2d, 0x70, 0x6b,
+	0x67, 0x2f, 0x61, 0x75, 0x74, 0x68, 0x3b, 0x61, 0x75, 0x74, 0x68, 0x70, 0x6b, 0x67, 0x62, 0x06,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -48,6 +48,66 @@ func ErrBlacklist() *errors.Error {
 func ErrWhitelist() *errors.Error {
 	return errors.Unauthorized(ERROR_TOKEN_INVALID.String(), "[validator] invalid token")
 }
+func ErrRefreshTokenInvalid() *errors.Error {
+	return errors.Unauthorized(ERROR_REFRESH_TOKEN_INVALID.String(), "[refresh] invalid refresh token")
+}
+func ErrRefreshTokenReused() *errors.Error {
+	return errors.Unauthorized(ERROR_REFRESH_TOKEN_REUSED.String(), "[refresh] refresh token has been used")
+}
+func ErrPermissionDenied() *errors.Error {
+	return errors.Forbidden(ERROR_PERMISSION_DENIED.String(), "[authorization] permission denied")
+}
+func ErrLoginLimit() *errors.Error {
+	return errors.Unauthorized(ERROR_LOGIN_LIMIT.String(), "[validator] signed in elsewhere")
+}
+func ErrLoginTooFrequent() *errors.Error {
+	return errorpkg.TooManyRequests(ERROR_LOGIN_TOO_FREQUENT.String(), "[login] too many failed attempts, please try again later")
+}
+func ErrLoginLocked() *errors.Error {
+	return errorpkg.TooManyRequests(ERROR_LOGIN_LOCKED.String(), "[login] account is temporarily locked")
+}
+func ErrAPIKeyInvalid() *errors.Error {
+	return errors.Unauthorized(ERROR_API_KEY_INVALID.String(), "[api key] invalid api key")
+}
+func ErrAPIKeyExpired() *errors.Error {
+	return errors.Unauthorized(ERROR_API_KEY_EXPIRED.String(), "[api key] api key has expired")
+}
+func ErrAPIKeyRevoked() *errors.Error {
+	return errors.Unauthorized(ERROR_API_KEY_REVOKED.String(), "[api key] api key has been revoked")
+}
+func ErrSignatureMissing() *errors.Error {
+	return errors.Unauthorized(ERROR_SIGNATURE_MISSING.String(), "[signature] signature is missing")
+}
+func ErrSignatureInvalid() *errors.Error {
+	return errors.Unauthorized(ERROR_SIGNATURE_INVALID.String(), "[signature] invalid signature")
+}
+func ErrSignatureExpired() *errors.Error {
+	return errors.Unauthorized(ERROR_SIGNATURE_EXPIRED.String(), "[signature] signature timestamp is out of range")
+}
+func ErrSignatureReplayed() *errors.Error {
+	return errors.Unauthorized(ERROR_SIGNATURE_REPLAYED.String(), "[signature] request has been replayed")
+}
+func ErrOIDCTokenInvalid() *errors.Error {
+	return errors.Unauthorized(ERROR_OIDC_TOKEN_INVALID.String(), "[oidc] invalid identity provider token")
+}
+func ErrTokenGenerationRevoked() *errors.Error {
+	return errors.Unauthorized(ERROR_TOKEN_GENERATION_REVOKED.String(), "[validator] token has been revoked")
+}
+func ErrMFARequired() *errors.Error {
+	return errors.Unauthorized(ERROR_MFA_REQUIRED.String(), "[mfa] multi-factor authentication is required")
+}
+func ErrMFACodeInvalid() *errors.Error {
+	return errors.Unauthorized(ERROR_MFA_CODE_INVALID.String(), "[mfa] invalid verification code")
+}
+func ErrMFANotEnrolled() *errors.Error {
+	return errors.Forbidden(ERROR_MFA_NOT_ENROLLED.String(), "[mfa] multi-factor authentication is not enrolled")
+}
+func ErrImpersonationForbidden() *errors.Error {
+	return errors.Forbidden(ERROR_IMPERSONATION_FORBIDDEN.String(), "[impersonation] operation is not allowed while impersonating")
+}
+func ErrMFAAlreadyEnrolled() *errors.Error {
+	return errors.Conflict(ERROR_MFA_ALREADY_ENROLLED.String(), "[mfa] multi-factor authentication is already enrolled")
+}
 
 // Is ...
 func Is(err, target error) bool {
@@ -0,0 +1,223 @@
+package authpkg
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultAuthEventStreamKey 审计事件的Redis Stream
+	DefaultAuthEventStreamKey = "gs:auth:event"
+	// DefaultAuthEventStreamMaxLen Stream 的最大长度(近似)
+	DefaultAuthEventStreamMaxLen = 100000
+)
+
+// AuthEventType 审计事件类型
+type AuthEventType string
+
+const (
+	// AuthEventSignIn 签发令牌
+	AuthEventSignIn AuthEventType = "sign_in"
+	// AuthEventRefresh 使用刷新令牌换取新的令牌
+	AuthEventRefresh AuthEventType = "refresh"
+	// AuthEventRefreshReused 已使用过的刷新令牌再次使用
+	AuthEventRefreshReused AuthEventType = "refresh_reused"
+	// AuthEventRenew 滑动会话续期
+	AuthEventRenew AuthEventType = "renew"
+	// AuthEventRevoke 注销令牌
+	AuthEventRevoke AuthEventType = "revoke"
+	// AuthEventBlacklistHit 使用黑名单中的令牌
+	AuthEventBlacklistHit AuthEventType = "blacklist_hit"
+	// AuthEventWhitelistMiss 令牌不在白名单中
+	AuthEventWhitelistMiss AuthEventType = "whitelist_miss"
+	// AuthEventLoginLimitEvicted 令牌因其他登录被注销
+	AuthEventLoginLimitEvicted AuthEventType = "login_limit_evicted"
+	// AuthEventGenerationRevoked 令牌代数小于用户当前的令牌代数
+	AuthEventGenerationRevoked AuthEventType = "generation_revoked"
+	// AuthEventStepUp 多因素认证后签发令牌
+	AuthEventStepUp AuthEventType = "step_up"
+	// AuthEventImpersonate 管理员代理登录
+	AuthEventImpersonate AuthEventType = "impersonate"
+)
+
+// AuthEventOutcome 审计事件结果
+type AuthEventOutcome string
+
+const (
+	// AuthEventOutcomeSuccess 成功
+	AuthEventOutcomeSuccess AuthEventOutcome = "success"
+	// AuthEventOutcomeFailure 失败：令牌无效或内部错误
+	AuthEventOutcomeFailure AuthEventOutcome = "failure"
+	// AuthEventOutcomeDenied 拒绝：令牌已注销
+	AuthEventOutcomeDenied AuthEventOutcome = "denied"
+)
+
+// AuthEvent 审计事件
+type AuthEvent struct {
+	Type    AuthEventType    `json:"type"`
+	Outcome AuthEventOutcome `json:"outcome"`
+	// Reason 原因；例：错误原因、注销的方式
+	Reason string `json:"reason,omitempty"`
+
+	UserIdentifier string                          `json:"uid,omitempty"`
+	TokenID        string                          `json:"ti,omitempty"`
+	FamilyID       string                          `json:"fid,omitempty"`
+	TenantID       string                          `json:"tid,omitempty"`
+	LoginPlatform  LoginPlatformEnum_LoginPlatform `json:"lp,omitempty"`
+	LoginType      LoginTypeEnum_LoginType         `json:"lt,omitempty"`
+	// ClientIP 当前请求的客户端ip
+	ClientIP string `json:"ip,omitempty"`
+	// UserAgent 当前请求的客户端
+	UserAgent string `json:"ua,omitempty"`
+	// Actor 代理登录的管理员
+	Actor string `json:"act,omitempty"`
+	// OccurredAt 发生时间(unix毫秒)
+	OccurredAt int64 `json:"at,omitempty"`
+}
+
+// Values 键值对；用于日志与 Redis Stream
+func (s *AuthEvent) Values() []interface{} {
+	return []interface{}{
+		"type", string(s.Type),
+		"outcome", string(s.Outcome),
+		"reason", s.Reason,
+		"uid", s.UserIdentifier,
+		"ti", s.TokenID,
+		"fid", s.FamilyID,
+		"tid", s.TenantID,
+		"lp", s.LoginPlatform.String(),
+		"lt", s.LoginType.String(),
+		"ip", s.ClientIP,
+		"ua", s.UserAgent,
+		"act", s.Actor,
+		"at", strconv.FormatInt(s.OccurredAt, 10),
+	}
+}
+
+// newAuthEvent ...
+func newAuthEvent(eventType AuthEventType, outcome AuthEventOutcome, tokenID string, payload *Payload) *AuthEvent {
+	event := &AuthEvent{
+		Type:    eventType,
+		Outcome: outcome,
+		TokenID: tokenID,
+	}
+	if payload != nil {
+		event.UserIdentifier = payload.UserIdentifier()
+		event.TenantID = payload.TenantID
+		event.LoginPlatform = payload.LoginPlatform
+		event.LoginType = payload.LoginType
+	}
+	return event
+}
+
+// AuthEventSink 审计事件的输出；Emit 在认证流程中同步调用，失败时仅记录日志
+type AuthEventSink interface {
+	Emit(ctx context.Context, event *AuthEvent) error
+}
+
+// AuthEventSinkFunc ...
+type AuthEventSinkFunc func(ctx context.Context, event *AuthEvent) error
+
+// Emit ...
+func (f AuthEventSinkFunc) Emit(ctx context.Context, event *AuthEvent) error {
+	return f(ctx, event)
+}
+
+// multiAuthEventSink ...
+type multiAuthEventSink []AuthEventSink
+
+// MultiAuthEventSink 依次输出到多个 AuthEventSink
+func MultiAuthEventSink(sinks ...AuthEventSink) AuthEventSink {
+	return multiAuthEventSink(sinks)
+}
+
+// Emit ...
+func (s multiAuthEventSink) Emit(ctx context.Context, event *AuthEvent) error {
+	var errs []error
+	for _, sink := range s {
+		if err := sink.Emit(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// logAuthEventSink ...
+type logAuthEventSink struct {
+	logHandler *log.Helper
+}
+
+// NewLogAuthEventSink 输出到日志；成功的事件为 Info 级别，其他为 Warn 级别
+func NewLogAuthEventSink(logger log.Logger) AuthEventSink {
+	return &logAuthEventSink{
+		logHandler: log.NewHelper(log.With(logger, "module", "auth/event")),
+	}
+}
+
+// Emit ...
+func (s *logAuthEventSink) Emit(ctx context.Context, event *AuthEvent) error {
+	keyvals := append([]interface{}{"msg", "auth event"}, event.Values()...)
+	if event.Outcome == AuthEventOutcomeSuccess {
+		s.logHandler.WithContext(ctx).Infow(keyvals...)
+	} else {
+		s.logHandler.WithContext(ctx).Warnw(keyvals...)
+	}
+	return nil
+}
+
+// redisAuthEventSink ...
+type redisAuthEventSink struct {
+	redisCC   redis.UniversalClient
+	streamKey string
+	maxLen    int64
+}
+
+// NewRedisAuthEventSink 输出到 Redis Stream；使用 XREAD 或消费者组读取
+// streamKey 为空时使用 DefaultAuthEventStreamKey；maxLen 小于等于0时使用 DefaultAuthEventStreamMaxLen
+func NewRedisAuthEventSink(redisCC redis.UniversalClient, streamKey string, maxLen int64) AuthEventSink {
+	if streamKey == "" {
+		streamKey = DefaultAuthEventStreamKey
+	}
+	if maxLen <= 0 {
+		maxLen = DefaultAuthEventStreamMaxLen
+	}
+	return &redisAuthEventSink{
+		redisCC:   redisCC,
+		streamKey: streamKey,
+		maxLen:    maxLen,
+	}
+}
+
+// Emit ...
+func (s *redisAuthEventSink) Emit(ctx context.Context, event *AuthEvent) error {
+	return s.redisCC.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.streamKey,
+		MaxLen: s.maxLen,
+		Approx: true,
+		Values: event.Values(),
+	}).Err()
+}
+
+// emitEvent 输出审计事件；补充当前请求的客户端信息与租户
+func (s *authRepo) emitEvent(ctx context.Context, event *AuthEvent) {
+	if s.config.EventSink == nil {
+		return
+	}
+	if event.ClientIP == "" && event.UserAgent == "" {
+		event.ClientIP, event.UserAgent = clientInfoFromContext(ctx)
+	}
+	if event.TenantID == "" {
+		event.TenantID, _ = GetTenantFromContext(ctx)
+	}
+	if event.OccurredAt == 0 {
+		event.OccurredAt = time.Now().UnixMilli()
+	}
+	if err := s.config.EventSink.Emit(ctx, event); err != nil {
+		s.logHandler.WithContext(ctx).Errorw("msg", "emit auth event failed", "type", event.Type, "err", err)
+	}
+}
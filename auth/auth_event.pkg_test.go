@@ -0,0 +1,162 @@
+package authpkg
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	contextpkg "github.com/eden-quan/go-kratos-pkg/context"
+)
+
+// testEventSink 记录审计事件
+type testEventSink struct {
+	mu     sync.Mutex
+	events []*AuthEvent
+}
+
+// Emit ...
+func (s *testEventSink) Emit(ctx context.Context, event *AuthEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+// find 指定类型的事件
+func (s *testEventSink) find(eventType AuthEventType) []*AuthEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []*AuthEvent
+	for _, event := range s.events {
+		if event.Type == eventType {
+			res = append(res, event)
+		}
+	}
+	return res
+}
+
+// go test -v -count=1 ./auth -test.run=TestAuthRepo_Events
+func TestAuthRepo_Events(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		sink      = &testEventSink{}
+		repo, err = NewAuthRepo(newMemoryTokenManger(time.Now), log.DefaultLogger, Config{
+			SignKey:   "1234567890ABCDEF",
+			EventSink: sink,
+		})
+		signIn = func(ip string, platform LoginPlatformEnum_LoginPlatform) (*TokenResponse, *Claims) {
+			claims := DefaultClaims(Payload{UserID: 1, LoginPlatform: platform, LoginLimit: LoginLimitEnum_ONLY_ONE})
+			res, _, err := repo.SignToken(contextpkg.SetClientIpToContext(ctx, ip), claims)
+			require.Nil(t, err)
+			return res, claims
+		}
+		verify = func(token string) error {
+			tr := newTestTransport("/api.user.v1.User/Get")
+			tr.reqHeader.Set(AuthorizationKey, token)
+			_, err := Server(
+				repo.JWTSigningKeyFunc,
+				WithSigningMethod(repo.JWTSigningMethod()),
+				WithClaims(repo.JWTSigningClaims),
+				WithTokenValidator(repo.VerifyToken),
+			)(func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})(transport.NewServerContext(contextpkg.SetClientIpToContext(ctx, "10.0.0.9"), tr), nil)
+			return err
+		}
+	)
+	require.Nil(t, err)
+
+	// 登录
+	res1, claims1 := signIn("10.0.0.1", LoginPlatformEnum_COMPUTER)
+	events := sink.find(AuthEventSignIn)
+	require.Len(t, events, 1)
+	require.Equal(t, AuthEventOutcomeSuccess, events[0].Outcome)
+	require.Equal(t, "1", events[0].UserIdentifier)
+	require.Equal(t, claims1.ID, events[0].TokenID)
+	require.Equal(t, LoginPlatformEnum_COMPUTER, events[0].LoginPlatform)
+	require.Equal(t, "10.0.0.1", events[0].ClientIP)
+	require.NotZero(t, events[0].OccurredAt)
+
+	// 登录限制：旧的令牌被注销；等待异步的登录限制检查
+	time.Sleep(time.Millisecond * 100)
+	_, claims2 := signIn("10.0.0.2", LoginPlatformEnum_ANDROID)
+	require.Eventually(t, func() bool {
+		return len(sink.find(AuthEventLoginLimitEvicted)) == 1
+	}, time.Second, time.Millisecond*10)
+	evicted := sink.find(AuthEventLoginLimitEvicted)[0]
+	require.Equal(t, claims1.ID, evicted.TokenID)
+	require.Equal(t, LoginPlatformEnum_COMPUTER, evicted.LoginPlatform)
+	require.Equal(t, "10.0.0.2", evicted.ClientIP)
+	require.Equal(t, LoginLimitEnum_ONLY_ONE.String(), evicted.Reason)
+
+	// 黑名单命中
+	require.True(t, Is(verify(res1.AccessToken), ErrLoginLimit()))
+	events = sink.find(AuthEventBlacklistHit)
+	require.Len(t, events, 1)
+	require.Equal(t, AuthEventOutcomeDenied, events[0].Outcome)
+	require.Equal(t, claims1.ID, events[0].TokenID)
+	require.Equal(t, "10.0.0.9", events[0].ClientIP)
+	require.Equal(t, ERROR_LOGIN_LIMIT.String(), events[0].Reason)
+
+	// 刷新令牌重复使用
+	_, _, err = repo.RefreshToken(ctx, "invalid")
+	require.NotNil(t, err)
+	_, _, err = repo.RefreshToken(ctx, res1.RefreshToken)
+	require.True(t, Is(err, ErrRefreshTokenReused()))
+	events = sink.find(AuthEventRefresh)
+	require.Len(t, events, 1)
+	require.Equal(t, AuthEventOutcomeFailure, events[0].Outcome)
+	require.Equal(t, ERROR_REFRESH_TOKEN_INVALID.String(), events[0].Reason)
+	events = sink.find(AuthEventRefreshReused)
+	require.Len(t, events, 1)
+	require.Equal(t, claims1.FamilyID, events[0].FamilyID)
+
+	// 注销
+	require.Nil(t, repo.RevokeSession(ctx, "1", claims2.ID))
+	events = sink.find(AuthEventRevoke)
+	require.Len(t, events, 1)
+	require.Equal(t, claims2.ID, events[0].TokenID)
+	require.Equal(t, "revoke_session", events[0].Reason)
+}
+
+// go test -v -count=1 ./auth -test.run=TestRedisAuthEventSink
+func TestRedisAuthEventSink(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		mr      = miniredis.RunT(t)
+		redisCC = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		event   = &AuthEvent{
+			Type:           AuthEventSignIn,
+			Outcome:        AuthEventOutcomeSuccess,
+			UserIdentifier: "1",
+			TokenID:        "token-1",
+			LoginPlatform:  LoginPlatformEnum_IOS,
+			ClientIP:       "10.0.0.1",
+			OccurredAt:     1700000000000,
+		}
+		sink = MultiAuthEventSink(NewLogAuthEventSink(log.DefaultLogger), NewRedisAuthEventSink(redisCC, "", 0))
+	)
+	defer func() { _ = redisCC.Close() }()
+
+	require.Nil(t, sink.Emit(ctx, event))
+	messages, err := redisCC.XRange(ctx, DefaultAuthEventStreamKey, "-", "+").Result()
+	require.Nil(t, err)
+	require.Len(t, messages, 1)
+	require.Equal(t, "sign_in", messages[0].Values["type"])
+	require.Equal(t, "success", messages[0].Values["outcome"])
+	require.Equal(t, "token-1", messages[0].Values["ti"])
+	require.Equal(t, "IOS", messages[0].Values["lp"])
+	require.Equal(t, "10.0.0.1", messages[0].Values["ip"])
+	require.Equal(t, "1700000000000", messages[0].Values["at"])
+
+	// 输出失败
+	mr.Close()
+	require.NotNil(t, sink.Emit(ctx, event))
+}
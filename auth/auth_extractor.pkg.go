@@ -0,0 +1,118 @@
+package authpkg
+
+import (
+	"context"
+	stdhttp "net/http"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/go-kratos/kratos/v2/transport/http"
+)
+
+// CookieKey 请求头
+const CookieKey = "Cookie"
+
+// TokenExtractor 从请求中提取令牌；未找到时返回空字符串
+type TokenExtractor func(ctx context.Context, tr transport.Transporter) string
+
+// DefaultTokenExtractors Authorization 请求头；兼容带有或不带 Bearer 的令牌
+func DefaultTokenExtractors() []TokenExtractor {
+	return []TokenExtractor{AuthorizationExtractor()}
+}
+
+// AuthorizationExtractor Authorization 请求头；Bearer 可选
+func AuthorizationExtractor() TokenExtractor {
+	return HeaderExtractor(AuthorizationKey, "")
+}
+
+// BearerExtractor Authorization 请求头；必须带有 Bearer
+func BearerExtractor() TokenExtractor {
+	return func(ctx context.Context, tr transport.Transporter) string {
+		token, ok := cutScheme(tr.RequestHeader().Get(AuthorizationKey), BearerWord)
+		if !ok {
+			return ""
+		}
+		return token
+	}
+}
+
+// HeaderExtractor 自定义请求头；scheme 为空时去除可选的 Bearer
+func HeaderExtractor(key, scheme string) TokenExtractor {
+	return func(ctx context.Context, tr transport.Transporter) string {
+		value := tr.RequestHeader().Get(key)
+		if scheme == "" {
+			if token, ok := cutScheme(value, BearerWord); ok {
+				return token
+			}
+			return strings.TrimSpace(value)
+		}
+		token, ok := cutScheme(value, scheme)
+		if !ok {
+			return ""
+		}
+		return token
+	}
+}
+
+// CookieExtractor 指定名称的Cookie
+func CookieExtractor(name string) TokenExtractor {
+	return func(ctx context.Context, tr transport.Transporter) string {
+		if tr.Kind() != transport.KindHTTP {
+			return ""
+		}
+		r := &stdhttp.Request{Header: stdhttp.Header{CookieKey: tr.RequestHeader().Values(CookieKey)}}
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+}
+
+// QueryExtractor 查询参数；用于无法设置请求头的 WebSocket 握手
+func QueryExtractor(name string) TokenExtractor {
+	return func(ctx context.Context, tr transport.Transporter) string {
+		ht, ok := tr.(http.Transporter)
+		if !ok || ht.Request() == nil {
+			return ""
+		}
+		return ht.Request().URL.Query().Get(name)
+	}
+}
+
+// MetadataExtractor gRPC metadata
+func MetadataExtractor(key string) TokenExtractor {
+	return func(ctx context.Context, tr transport.Transporter) string {
+		if tr.Kind() != transport.KindGRPC {
+			return ""
+		}
+		return strings.TrimSpace(tr.RequestHeader().Get(strings.ToLower(key)))
+	}
+}
+
+// extractToken 依次尝试提取令牌
+func extractToken(ctx context.Context, tr transport.Transporter, extractors []TokenExtractor) string {
+	for i := range extractors {
+		if token := extractors[i](ctx, tr); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// FormatToken 令牌的请求头格式；scheme 为空时为原始令牌
+func FormatToken(scheme, token string) string {
+	if scheme == "" {
+		return token
+	}
+	return scheme + " " + token
+}
+
+// cutScheme 去除 scheme；忽略大小写
+func cutScheme(value, scheme string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) <= len(scheme) || !strings.EqualFold(value[:len(scheme)], scheme) || value[len(scheme)] != ' ' {
+		return "", false
+	}
+	return strings.TrimSpace(value[len(scheme)+1:]), true
+}
@@ -0,0 +1,169 @@
+package authpkg
+
+import (
+	"context"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// testHTTPTransport http.Transporter
+type testHTTPTransport struct {
+	*testTransport
+	request *stdhttp.Request
+}
+
+func (s *testHTTPTransport) Request() *stdhttp.Request { return s.request }
+func (s *testHTTPTransport) PathTemplate() string      { return "" }
+
+// newTestHTTPTransport ...
+func newTestHTTPTransport(r *stdhttp.Request) *testHTTPTransport {
+	tr := newTestTransport(r.URL.Path)
+	tr.kind = transport.KindHTTP
+	tr.reqHeader = testHeader(r.Header)
+	return &testHTTPTransport{testTransport: tr, request: r}
+}
+
+// go test -v -count=1 ./auth -test.run=TestTokenExtractor
+func TestTokenExtractor(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		request = func(header map[string]string, target string) transport.Transporter {
+			r := httptest.NewRequest(stdhttp.MethodGet, target, nil)
+			for k, v := range header {
+				r.Header.Set(k, v)
+			}
+			return newTestHTTPTransport(r)
+		}
+		grpcTransport = func(key, value string) transport.Transporter {
+			tr := newTestTransport("/api.user.v1.User/Get")
+			tr.reqHeader.Set(key, value)
+			return tr
+		}
+	)
+
+	tests := []struct {
+		name      string
+		extractor TokenExtractor
+		tr        transport.Transporter
+		want      string
+	}{
+		{
+			name:      "#authorization_raw",
+			extractor: AuthorizationExtractor(),
+			tr:        request(map[string]string{AuthorizationKey: "token"}, "/"),
+			want:      "token",
+		},
+		{
+			name:      "#authorization_bearer",
+			extractor: AuthorizationExtractor(),
+			tr:        request(map[string]string{AuthorizationKey: "bearer  token"}, "/"),
+			want:      "token",
+		},
+		{
+			name:      "#bearer",
+			extractor: BearerExtractor(),
+			tr:        request(map[string]string{AuthorizationKey: "Bearer token"}, "/"),
+			want:      "token",
+		},
+		{
+			name:      "#bearer_missing_scheme",
+			extractor: BearerExtractor(),
+			tr:        request(map[string]string{AuthorizationKey: "token"}, "/"),
+		},
+		{
+			name:      "#bearer_word_only",
+			extractor: BearerExtractor(),
+			tr:        request(map[string]string{AuthorizationKey: "Bearertoken"}, "/"),
+		},
+		{
+			name:      "#header_scheme",
+			extractor: HeaderExtractor("X-Auth-Token", "Token"),
+			tr:        request(map[string]string{"X-Auth-Token": "Token token"}, "/"),
+			want:      "token",
+		},
+		{
+			name:      "#cookie",
+			extractor: CookieExtractor("access_token"),
+			tr:        request(map[string]string{CookieKey: "lang=zh; access_token=token"}, "/"),
+			want:      "token",
+		},
+		{
+			name:      "#cookie_grpc",
+			extractor: CookieExtractor("access_token"),
+			tr:        grpcTransport(CookieKey, "access_token=token"),
+		},
+		{
+			name:      "#query",
+			extractor: QueryExtractor("access_token"),
+			tr:        request(nil, "/ws?access_token=token"),
+			want:      "token",
+		},
+		{
+			name:      "#query_grpc",
+			extractor: QueryExtractor("access_token"),
+			tr:        grpcTransport("access_token", "token"),
+		},
+		{
+			name:      "#metadata",
+			extractor: MetadataExtractor("X-Access-Token"),
+			tr:        grpcTransport("x-access-token", "token"),
+			want:      "token",
+		},
+		{
+			name:      "#metadata_http",
+			extractor: MetadataExtractor("X-Access-Token"),
+			tr:        request(map[string]string{"X-Access-Token": "token"}, "/"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.extractor(ctx, tt.tr))
+		})
+	}
+
+	// 依次尝试
+	extractors := []TokenExtractor{BearerExtractor(), CookieExtractor("access_token"), QueryExtractor("access_token")}
+	tr := request(map[string]string{CookieKey: "access_token=cookie"}, "/ws?access_token=query")
+	require.Equal(t, "cookie", extractToken(ctx, tr, extractors))
+	tr = request(nil, "/ws?access_token=query")
+	require.Equal(t, "query", extractToken(ctx, tr, extractors))
+	require.Equal(t, "", extractToken(ctx, request(nil, "/"), extractors))
+}
+
+// go test -v -count=1 ./auth -test.run=TestClientServer_Bearer
+func TestClientServer_Bearer(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		signKey = []byte("1234567890ABCDEF")
+		keyFunc = func(ctx context.Context) jwt.Keyfunc {
+			return func(*jwt.Token) (interface{}, error) { return signKey, nil }
+		}
+		clientTr = newTestTransport("/api.user.v1.User/Get")
+	)
+
+	// 客户端
+	_, err := Client(keyFunc, WithAuthorizationFormat(AuthorizationKey, BearerWord))(
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil },
+	)(transport.NewClientContext(ctx, clientTr), nil)
+	require.Nil(t, err)
+	authorization := clientTr.reqHeader.Get(AuthorizationKey)
+	require.Regexp(t, "^Bearer [^ ]+$", authorization)
+
+	// 服务端
+	serverTr := newTestTransport("/api.user.v1.User/Get")
+	serverTr.reqHeader.Set(AuthorizationKey, authorization)
+	_, err = Server(keyFunc)(
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil },
+	)(transport.NewServerContext(ctx, serverTr), nil)
+	require.Nil(t, err)
+
+	_, err = Server(keyFunc, WithTokenExtractors(CookieExtractor("access_token")))(
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil },
+	)(transport.NewServerContext(ctx, serverTr), nil)
+	require.True(t, Is(err, ErrMissingToken()))
+}
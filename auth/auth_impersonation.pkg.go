@@ -0,0 +1,146 @@
+package authpkg
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/golang-jwt/jwt/v4"
+
+	errorpkg "github.com/eden-quan/go-kratos-pkg/error"
+)
+
+const (
+	// DefaultImpersonationExpire 代理登录令牌的默认有效期
+	DefaultImpersonationExpire = time.Minute * 15
+	// MaxImpersonationExpire 代理登录令牌的最长有效期
+	MaxImpersonationExpire = time.Hour
+	// ImpersonatorLogKey 日志字段：代理登录的管理员；参考 ImpersonatorValuer
+	ImpersonatorLogKey = "impersonator"
+)
+
+// Actor 代理登录的管理员：令牌的 act 声明，参考 RFC 8693
+type Actor struct {
+	// Subject 管理员的用户标识；参考 Payload.UserIdentifier
+	Subject string `json:"sub"`
+	// TokenID 管理员的令牌id
+	TokenID string `json:"ti,omitempty"`
+	// TenantID 管理员的租户id
+	TenantID string `json:"tid,omitempty"`
+	// Reason 代理原因；例：工单号
+	Reason string `json:"reason,omitempty"`
+}
+
+// ImpersonateParam 代理登录
+type ImpersonateParam struct {
+	// Actor 管理员的令牌信息；例：GetAuthClaimsFromContext
+	Actor *Claims
+	// Target 被代理用户的授权信息
+	Target Payload
+	// Reason 代理原因；记录在令牌与审计事件中
+	Reason string
+	// Expire 有效期；默认 DefaultImpersonationExpire，不超过 MaxImpersonationExpire 与管理员令牌的过期时间
+	Expire time.Duration
+}
+
+// IsImpersonation 是否为代理登录的令牌
+func (s *Claims) IsImpersonation() bool {
+	return s.Actor != nil
+}
+
+// IsImpersonating 当前请求是否为代理登录
+func IsImpersonating(ctx context.Context) bool {
+	authClaims, ok := GetAuthClaimsFromContext(ctx)
+	return ok && authClaims.IsImpersonation()
+}
+
+// GetActorFromContext 代理登录的管理员
+func GetActorFromContext(ctx context.Context) (*Actor, bool) {
+	authClaims, ok := GetAuthClaimsFromContext(ctx)
+	if !ok || !authClaims.IsImpersonation() {
+		return nil, false
+	}
+	return authClaims.Actor, true
+}
+
+// ImpersonateToken 代理登录：签发被代理用户的短期令牌，携带管理员信息
+// 令牌不能刷新、续期与多因素认证，不受登录限制；注销被代理用户的会话时一并注销
+// 管理员的权限由调用方检查；例：Authorization
+func (s *authRepo) ImpersonateToken(ctx context.Context, param *ImpersonateParam) (*TokenResponse, error) {
+	actorClaims := param.Actor
+	if actorClaims == nil || actorClaims.Payload == nil || actorClaims.IsServiceToken() || actorClaims.IsImpersonation() {
+		e := ErrImpersonationForbidden()
+		e.Metadata = map[string]string{"reason": "invalid actor"}
+		return nil, errorpkg.WithStack(e)
+	}
+	actor := &Actor{
+		Subject:  actorClaims.Payload.UserIdentifier(),
+		TokenID:  actorClaims.ID,
+		TenantID: actorClaims.Payload.TenantID,
+		Reason:   param.Reason,
+	}
+	if actor.Subject == param.Target.UserIdentifier() && actor.TenantID == param.Target.TenantID {
+		e := ErrImpersonationForbidden()
+		e.Metadata = map[string]string{"reason": "cannot impersonate self"}
+		return nil, errorpkg.WithStack(e)
+	}
+
+	expire := param.Expire
+	if expire <= 0 {
+		expire = DefaultImpersonationExpire
+	}
+	expire = min(expire, MaxImpersonationExpire)
+	var (
+		now       = time.Now()
+		expiresAt = now.Add(expire)
+	)
+	if actorClaims.ExpiresAt != nil && actorClaims.ExpiresAt.Time.Before(expiresAt) {
+		expiresAt = actorClaims.ExpiresAt.Time
+	}
+
+	claims := DefaultClaims(param.Target)
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(expiresAt)
+	claims.Actor = actor
+	res, _, err := s.signToken(ctx, claims, AuthEventImpersonate)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// BlockImpersonation 代理登录时禁止的操作；例：修改密码、支付
+// 返回 ErrImpersonationForbidden；需在 Server 之后使用
+func BlockImpersonation(operations ...string) middleware.Middleware {
+	blocked := make(map[string]struct{}, len(operations))
+	for _, operation := range operations {
+		blocked[operation] = struct{}{}
+	}
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok {
+				e := ErrWrongContext()
+				return nil, errorpkg.WithStack(e)
+			}
+			if _, ok = blocked[tr.Operation()]; ok && IsImpersonating(ctx) {
+				e := ErrImpersonationForbidden()
+				return nil, errorpkg.WithStack(e)
+			}
+			return handler(ctx, req)
+		}
+	}
+}
+
+// ImpersonatorValuer 日志字段：代理登录的管理员；非代理登录时为空
+// 例：log.With(logger, ImpersonatorLogKey, ImpersonatorValuer())
+func ImpersonatorValuer() log.Valuer {
+	return func(ctx context.Context) interface{} {
+		if actor, ok := GetActorFromContext(ctx); ok {
+			return actor.Subject
+		}
+		return ""
+	}
+}
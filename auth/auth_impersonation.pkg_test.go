@@ -0,0 +1,106 @@
+package authpkg
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -count=1 ./auth -test.run=TestAuthRepo_ImpersonateToken
+func TestAuthRepo_ImpersonateToken(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		repo, err = NewAuthRepo(newMemoryTokenManger(time.Now), log.DefaultLogger, Config{SignKey: "1234567890ABCDEF"})
+		operation = "/api.user.v1.User/ChangePassword"
+		buf       = &bytes.Buffer{}
+		logger    = log.NewHelper(log.With(log.NewStdLogger(buf), ImpersonatorLogKey, ImpersonatorValuer()))
+		request   = func(op, token string) (*Claims, error) {
+			var authClaims *Claims
+			tr := newTestTransport(op)
+			tr.reqHeader.Set(AuthorizationKey, token)
+			_, err := middleware.Chain(
+				Server(
+					repo.JWTSigningKeyFunc,
+					WithSigningMethod(repo.JWTSigningMethod()),
+					WithClaims(repo.JWTSigningClaims),
+					WithTokenValidator(repo.VerifyToken),
+				),
+				BlockImpersonation(operation),
+			)(func(ctx context.Context, req interface{}) (interface{}, error) {
+				authClaims, _ = GetAuthClaimsFromContext(ctx)
+				logger.WithContext(ctx).Info("request")
+				return nil, nil
+			})(transport.NewServerContext(ctx, tr), nil)
+			return authClaims, err
+		}
+	)
+	require.Nil(t, err)
+
+	adminClaims := DefaultClaims(Payload{UserID: 1, TokenType: TokenTypeEnum_ADMIN})
+	_, _, err = repo.SignToken(ctx, adminClaims)
+	require.Nil(t, err)
+	userClaims := DefaultClaims(Payload{UserID: 2, TokenType: TokenTypeEnum_USER})
+	userRes, _, err := repo.SignToken(ctx, userClaims)
+	require.Nil(t, err)
+
+	// 代理登录：不能刷新，有效期不超过上限
+	res, err := repo.ImpersonateToken(ctx, &ImpersonateParam{
+		Actor:  adminClaims,
+		Target: Payload{UserID: 2, TokenType: TokenTypeEnum_USER},
+		Reason: "ticket-1",
+		Expire: time.Hour * 24,
+	})
+	require.Nil(t, err)
+	require.NotEmpty(t, res.AccessToken)
+	require.Empty(t, res.RefreshToken)
+	authClaims, err := request("/api.user.v1.User/Get", res.AccessToken)
+	require.Nil(t, err)
+	require.True(t, authClaims.IsImpersonation())
+	require.Equal(t, uint64(2), authClaims.Payload.UserID)
+	require.Equal(t, &Actor{Subject: "1", TokenID: adminClaims.ID, Reason: "ticket-1"}, authClaims.Actor)
+	require.LessOrEqual(t, authClaims.ExpiresAt.Sub(authClaims.IssuedAt.Time), MaxImpersonationExpire)
+	require.Contains(t, buf.String(), ImpersonatorLogKey+"=1")
+
+	// 禁止的操作
+	_, err = request(operation, res.AccessToken)
+	require.True(t, Is(err, ErrImpersonationForbidden()))
+	buf.Reset()
+	_, err = request(operation, userRes.AccessToken)
+	require.Nil(t, err)
+	require.Contains(t, buf.String(), ImpersonatorLogKey+"= ")
+
+	// 不能续期与多因素认证
+	renewed, err := repo.RenewToken(ctx, &jwt.Token{Claims: authClaims})
+	require.Nil(t, err)
+	require.Nil(t, renewed)
+	_, err = repo.StepUpToken(ctx, authClaims, AMROTP)
+	require.True(t, Is(err, ErrImpersonationForbidden()))
+
+	// 不能嵌套代理与代理自己
+	_, err = repo.ImpersonateToken(ctx, &ImpersonateParam{Actor: authClaims, Target: Payload{UserID: 3}})
+	require.True(t, Is(err, ErrImpersonationForbidden()))
+	_, err = repo.ImpersonateToken(ctx, &ImpersonateParam{Actor: adminClaims, Target: *adminClaims.Payload})
+	require.True(t, Is(err, ErrImpersonationForbidden()))
+
+	// 会话
+	sessions, err := repo.ListSessions(ctx, "2")
+	require.Nil(t, err)
+	require.Len(t, sessions, 2)
+	actors := map[string]string{}
+	for _, session := range sessions {
+		actors[session.TokenID] = session.Actor
+	}
+	require.Equal(t, map[string]string{userClaims.ID: "", authClaims.ID: "1"}, actors)
+
+	// 注销被代理用户的会话
+	require.Nil(t, repo.RevokeAllSessions(ctx, "2"))
+	_, err = request("/api.user.v1.User/Get", res.AccessToken)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,162 @@
+package authpkg
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	stdhttp "net/http"
+	"strconv"
+	"time"
+
+	headerpkg "github.com/eden-quan/go-kratos-pkg/header"
+)
+
+const (
+	// JWKSPath 公钥集的默认路由
+	JWKSPath = "/.well-known/jwks.json"
+	// JWKSMaxAge 公钥集的缓存时间
+	JWKSMaxAge = time.Minute * 10
+)
+
+// JSONWebKey RFC 7517 公钥
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC(crv/x/y) OKP(crv/x)
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet RFC 7517 公钥集
+type JSONWebKeySet struct {
+	Keys []*JSONWebKey `json:"keys"`
+}
+
+// Key 根据密钥id查找公钥
+func (s *JSONWebKeySet) Key(keyID string) (*JSONWebKey, bool) {
+	for i := range s.Keys {
+		if s.Keys[i].KeyID == keyID {
+			return s.Keys[i], true
+		}
+	}
+	return nil, false
+}
+
+// NewJSONWebKey 公钥转换为 JSONWebKey
+func NewJSONWebKey(keyID, alg string, publicKey crypto.PublicKey) (*JSONWebKey, error) {
+	jwk := &JSONWebKey{
+		Use:       "sig",
+		KeyID:     keyID,
+		Algorithm: alg,
+	}
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = key.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(key)
+	default:
+		return nil, fmt.Errorf("unsupported public key type: %T", publicKey)
+	}
+	return jwk, nil
+}
+
+// PublicKey 解析公钥
+func (s *JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch s.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(s.N)
+		if err != nil {
+			return nil, fmt.Errorf("decode jwk n failed: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(s.E)
+		if err != nil {
+			return nil, fmt.Errorf("decode jwk e failed: %w", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch s.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported jwk curve: %s", s.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(s.X)
+		if err != nil {
+			return nil, fmt.Errorf("decode jwk x failed: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(s.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decode jwk y failed: %w", err)
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	case "OKP":
+		if s.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported jwk curve: %s", s.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(s.X)
+		if err != nil {
+			return nil, fmt.Errorf("decode jwk x failed: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 public key size: %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported jwk key type: %s", s.KeyType)
+}
+
+// JWKSHandler 发布公钥集
+// 例：httpServer.Handle(authpkg.JWKSPath, authpkg.JWKSHandler(repo.JWKS))
+func JWKSHandler(jwksFunc func() (*JSONWebKeySet, error)) stdhttp.Handler {
+	return stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		jwks, err := jwksFunc()
+		if err != nil {
+			stdhttp.Error(w, err.Error(), stdhttp.StatusInternalServerError)
+			return
+		}
+		body, err := json.Marshal(jwks)
+		if err != nil {
+			stdhttp.Error(w, err.Error(), stdhttp.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(headerpkg.ContentType, headerpkg.ContentTypeJSONUtf8)
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(JWKSMaxAge/time.Second)))
+		_, _ = w.Write(body)
+	})
+}
@@ -0,0 +1,145 @@
+package authpkg
+
+import (
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"sort"
+	"time"
+)
+
+// KeyIDHeader 令牌头部的密钥id
+const KeyIDHeader = "kid"
+
+// SigningKey 密钥环中的签名密钥
+type SigningKey struct {
+	// KeyID 密钥id；签发令牌时写入令牌头部 kid
+	KeyID string
+	// Secret HMAC签名密钥
+	Secret string
+	// PrivateKey 非对称签名的私钥(PEM)
+	PrivateKey []byte
+	// PublicKey 非对称签名的公钥(PEM)；为空时从私钥导出
+	PublicKey []byte
+	// RetiredAt 退役时间；退役后不再签发令牌，
+	// 但在此前签发的令牌过期之前(RetiredAt + 配置的最长有效期，参考 maxTokenLifetime)仍可用于验证
+	RetiredAt time.Time
+}
+
+// ringKey ...
+type ringKey struct {
+	*signingKey
+	keyID     string
+	retiredAt time.Time
+}
+
+// isRetired 是否已退役
+func (s *ringKey) isRetired(now time.Time) bool {
+	return !s.retiredAt.IsZero() && !now.Before(s.retiredAt)
+}
+
+// keyRing 密钥环：一个当前签名密钥，多个验证密钥
+type keyRing struct {
+	method       jwt.SigningMethod
+	currentKeyID string
+	keys         map[string]*ringKey
+	// verifyGrace 退役密钥的验证宽限期：配置的最长有效期
+	verifyGrace time.Duration
+}
+
+// newKeyRing 根据配置生成密钥环
+// 未配置 SigningKeys 时，使用 SignKey/SignPrivateKey/SignPublicKey 生成密钥id为空的单密钥
+func newKeyRing(config *Config) (*keyRing, error) {
+	ring := &keyRing{
+		method:      config.SigningMethod,
+		keys:        make(map[string]*ringKey),
+		verifyGrace: maxTokenLifetime(config.Lifetimes),
+	}
+	if len(config.SigningKeys) == 0 {
+		key, err := newSigningKey(config.SigningMethod, config.SignKey, config.SignPrivateKey, config.SignPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		ring.keys[""] = &ringKey{signingKey: key}
+		return ring, nil
+	}
+
+	now := time.Now()
+	for i := range config.SigningKeys {
+		conf := config.SigningKeys[i]
+		if _, ok := ring.keys[conf.KeyID]; ok {
+			return nil, fmt.Errorf("duplicate signing key id: %s", conf.KeyID)
+		}
+		key, err := newSigningKey(config.SigningMethod, conf.Secret, conf.PrivateKey, conf.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("signing key(%s): %w", conf.KeyID, err)
+		}
+		ring.keys[conf.KeyID] = &ringKey{
+			signingKey: key,
+			keyID:      conf.KeyID,
+			retiredAt:  conf.RetiredAt,
+		}
+		// 默认当前密钥：第一个未退役的密钥
+		if config.CurrentSigningKeyID == "" && ring.currentKeyID == "" && !ring.keys[conf.KeyID].isRetired(now) {
+			ring.currentKeyID = conf.KeyID
+		}
+	}
+	if config.CurrentSigningKeyID != "" {
+		if _, ok := ring.keys[config.CurrentSigningKeyID]; !ok {
+			return nil, fmt.Errorf("current signing key not found: %s", config.CurrentSigningKeyID)
+		}
+		ring.currentKeyID = config.CurrentSigningKeyID
+	}
+	if cur := ring.keys[ring.currentKeyID]; cur == nil || cur.isRetired(now) {
+		return nil, fmt.Errorf("current signing key is missing or retired: %s", ring.currentKeyID)
+	}
+	return ring, nil
+}
+
+// current 当前签名密钥
+func (s *keyRing) current() *ringKey {
+	return s.keys[s.currentKeyID]
+}
+
+// lookup 根据密钥id查找验证密钥
+// 令牌未携带 kid 时，优先使用密钥id为空的密钥，否则使用当前签名密钥
+func (s *keyRing) lookup(keyID string, now time.Time) (*ringKey, error) {
+	key, ok := s.keys[keyID]
+	if !ok && keyID == "" {
+		key, ok = s.current(), true
+	}
+	if !ok {
+		return nil, fmt.Errorf("signing key not found: %s", keyID)
+	}
+	if !s.isVerifiable(key, now) {
+		return nil, fmt.Errorf("signing key has expired: %s", keyID)
+	}
+	return key, nil
+}
+
+// isVerifiable 密钥是否仍可用于验证
+func (s *keyRing) isVerifiable(key *ringKey, now time.Time) bool {
+	if key.retiredAt.IsZero() {
+		return true
+	}
+	return now.Before(key.retiredAt.Add(s.verifyGrace))
+}
+
+// verifyKeys 可用于验证的密钥；按密钥id排序
+func (s *keyRing) verifyKeys(now time.Time) []*ringKey {
+	keys := make([]*ringKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		if s.isVerifiable(key, now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].keyID < keys[j].keyID
+	})
+	return keys
+}
+
+// keyIDFromToken 令牌头部的密钥id
+func keyIDFromToken(token *jwt.Token) string {
+	keyID, _ := token.Header[KeyIDHeader].(string)
+	return keyID
+}
@@ -0,0 +1,172 @@
+package authpkg
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -count=1 ./auth -test.run=TestAuthRepo_KeyRing
+func TestAuthRepo_KeyRing(t *testing.T) {
+	var (
+		ctx               = context.Background()
+		oldPriKey, oldPub = genKeyPairPEM(t, jwt.SigningMethodES256)
+		newPriKey, _      = genKeyPairPEM(t, jwt.SigningMethodES256)
+		expiredPriKey, _  = genKeyPairPEM(t, jwt.SigningMethodES256)
+		claims            = DefaultClaims(Payload{UserID: 1})
+		signWithKey       = func(keyID string, priKey []byte) string {
+			key, err := ParsePrivateKey(jwt.SigningMethodES256, priKey)
+			require.Nil(t, err)
+			token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+			token.Header[KeyIDHeader] = keyID
+			tokenString, err := token.SignedString(key)
+			require.Nil(t, err)
+			return tokenString
+		}
+	)
+
+	repo, err := NewAuthRepo(NewTokenManger(&redis.Client{}, nil), log.DefaultLogger, Config{
+		SigningMethod: jwt.SigningMethodES256,
+		SignKey:       "1234567890ABCDEF",
+		SigningKeys: []*SigningKey{
+			{KeyID: "2023-01", PrivateKey: expiredPriKey, RetiredAt: time.Now().Add(-RefreshTokenExpire - time.Hour)},
+			{KeyID: "2023-06", PrivateKey: oldPriKey, PublicKey: oldPub, RetiredAt: time.Now().Add(-time.Hour)},
+			{KeyID: "2024-01", PrivateKey: newPriKey},
+		},
+	})
+	require.Nil(t, err)
+
+	// 当前密钥：第一个未退役的密钥
+	require.Equal(t, "2024-01", repo.(*authRepo).keyRing.current().keyID)
+
+	// 退役密钥在宽限期内仍可验证
+	_, err = repo.DecodeAccessToken(ctx, signWithKey("2023-06", oldPriKey))
+	require.Nil(t, err)
+	_, err = repo.DecodeAccessToken(ctx, signWithKey("2024-01", newPriKey))
+	require.Nil(t, err)
+
+	// 超过宽限期、未知、与kid不匹配的密钥
+	_, err = repo.DecodeAccessToken(ctx, signWithKey("2023-01", expiredPriKey))
+	require.NotNil(t, err)
+	_, err = repo.DecodeAccessToken(ctx, signWithKey("unknown", newPriKey))
+	require.NotNil(t, err)
+	_, err = repo.DecodeAccessToken(ctx, signWithKey("2024-01", oldPriKey))
+	require.NotNil(t, err)
+
+	// 公钥集
+	rec := httptest.NewRecorder()
+	JWKSHandler(repo.JWKS).ServeHTTP(rec, httptest.NewRequest("GET", JWKSPath, nil))
+	jwks := &JSONWebKeySet{}
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), jwks))
+	require.Len(t, jwks.Keys, 2)
+	_, ok := jwks.Key("2023-01")
+	require.False(t, ok)
+	jwk, ok := jwks.Key("2023-06")
+	require.True(t, ok)
+	require.Equal(t, "ES256", jwk.Algorithm)
+	pubKey, err := jwk.PublicKey()
+	require.Nil(t, err)
+	_, err = jwt.Parse(signWithKey("2023-06", oldPriKey), func(token *jwt.Token) (interface{}, error) {
+		return pubKey, nil
+	})
+	require.Nil(t, err)
+}
+
+// go test -v -count=1 ./auth -test.run=TestAuthRepo_KeyRing_Config
+func TestAuthRepo_KeyRing_Config(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{
+			name: "#current_key",
+			config: Config{
+				SignKey:             "1234567890ABCDEF",
+				SigningKeys:         []*SigningKey{{KeyID: "a", Secret: "a"}, {KeyID: "b", Secret: "b"}},
+				CurrentSigningKeyID: "b",
+			},
+		},
+		{
+			name: "#current_key_not_found",
+			config: Config{
+				SignKey:             "1234567890ABCDEF",
+				SigningKeys:         []*SigningKey{{KeyID: "a", Secret: "a"}},
+				CurrentSigningKeyID: "b",
+			},
+			wantErr: true,
+		},
+		{
+			name: "#duplicate_key",
+			config: Config{
+				SignKey:     "1234567890ABCDEF",
+				SigningKeys: []*SigningKey{{KeyID: "a", Secret: "a"}, {KeyID: "a", Secret: "b"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "#all_retired",
+			config: Config{
+				SignKey:     "1234567890ABCDEF",
+				SigningKeys: []*SigningKey{{KeyID: "a", Secret: "a", RetiredAt: time.Now()}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, err := NewAuthRepo(NewTokenManger(&redis.Client{}, nil), log.DefaultLogger, tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewAuthRepo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			// HMAC密钥不会发布
+			jwks, err := repo.JWKS()
+			require.Nil(t, err)
+			require.Empty(t, jwks.Keys)
+		})
+	}
+}
+
+// go test -v -count=1 ./auth -test.run=TestAuthRepo_KeyRing_VerifyGrace
+func TestAuthRepo_KeyRing_VerifyGrace(t *testing.T) {
+	require.Equal(t, RefreshTokenExpire, maxTokenLifetime(nil))
+	lifetimes := []*TokenLifetime{
+		{TokenType: TokenTypeEnum_ADMIN, AccessTokenExpire: time.Hour},
+		{LoginPlatform: LoginPlatformEnum_IOS, AccessTokenExpire: time.Hour * 24 * 30},
+		nil,
+	}
+	require.Equal(t, time.Hour*24*30, maxTokenLifetime(lifetimes))
+
+	// 退役密钥在最长有效期内仍可验证
+	var (
+		priKey, _ = genKeyPairPEM(t, jwt.SigningMethodES256)
+		retiredAt = time.Now().Add(-time.Hour * 24 * 20)
+	)
+	repo, err := NewAuthRepo(newMemoryTokenManger(time.Now), log.DefaultLogger, Config{
+		SigningMethod: jwt.SigningMethodES256,
+		SignKey:       "1234567890ABCDEF",
+		Lifetimes:     lifetimes,
+		SigningKeys: []*SigningKey{
+			{KeyID: "old", PrivateKey: priKey, RetiredAt: retiredAt},
+			{KeyID: "new", PrivateKey: priKey},
+		},
+	})
+	require.Nil(t, err)
+	ring := repo.(*authRepo).keyRing
+	key, err := ring.lookup("old", time.Now())
+	require.Nil(t, err)
+	require.Equal(t, "old", key.keyID)
+	_, err = ring.lookup("old", retiredAt.Add(time.Hour*24*30+time.Second))
+	require.NotNil(t, err)
+}
@@ -0,0 +1,510 @@
+package authpkg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/redis/go-redis/v9"
+
+	errorpkg "github.com/eden-quan/go-kratos-pkg/error"
+)
+
+const (
+	// DefaultLoginAttemptKeyPrefix 登录尝试的缓存key前缀
+	DefaultLoginAttemptKeyPrefix RedisCacheKeyPrefix = "gs:auth:attempt:"
+	// RetryAfterKey 错误元数据：重试等待的秒数
+	RetryAfterKey = "retry_after"
+)
+
+// LoginAttemptConfig 登录尝试限制
+type LoginAttemptConfig struct {
+	// MaxFailures 用户在窗口内的最大失败次数，达到后锁定；默认5
+	MaxFailures int64
+	// MaxFailuresPerIP 客户端ip在窗口内的最大失败次数，达到后锁定；默认20
+	MaxFailuresPerIP int64
+	// Window 统计窗口：最后一次失败后重新计时；默认15分钟
+	Window time.Duration
+	// LockoutDuration 锁定时长；默认15分钟
+	LockoutDuration time.Duration
+	// BaseDelay 渐进延迟：第n次失败后需等待 BaseDelay*2^(n-1)；默认1秒
+	BaseDelay time.Duration
+	// MaxDelay 最大延迟；默认30秒
+	MaxDelay time.Duration
+	// AttemptTimeout 进行中的尝试(Allow 后未调用 Failure/Success)的超时时间；默认30秒
+	AttemptTimeout time.Duration
+}
+
+// DefaultLoginAttemptConfig 默认配置
+func DefaultLoginAttemptConfig() *LoginAttemptConfig {
+	return &LoginAttemptConfig{
+		MaxFailures:      5,
+		MaxFailuresPerIP: 20,
+		Window:           time.Minute * 15,
+		LockoutDuration:  time.Minute * 15,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Second * 30,
+		AttemptTimeout:   time.Second * 30,
+	}
+}
+
+// LoginAttemptRule 统计对象(用户或客户端ip)的限制规则
+type LoginAttemptRule struct {
+	// MaxFailures 窗口内的最大失败次数：失败次数与进行中的尝试之和达到后拒绝，失败次数达到后锁定
+	MaxFailures int64
+	// Window 统计窗口
+	Window time.Duration
+	// LockoutDuration 锁定时长
+	LockoutDuration time.Duration
+	// BaseDelay 渐进延迟；为0时不延迟
+	BaseDelay time.Duration
+	// MaxDelay 最大延迟
+	MaxDelay time.Duration
+	// AttemptTimeout 进行中的尝试的超时时间
+	AttemptTimeout time.Duration
+}
+
+// delay 第n次失败后的等待时间
+func (s *LoginAttemptRule) delay(failures int64) time.Duration {
+	if failures <= 0 || s.BaseDelay <= 0 {
+		return 0
+	}
+	d := float64(s.BaseDelay) * math.Pow(2, float64(failures-1))
+	if d > float64(s.MaxDelay) {
+		return s.MaxDelay
+	}
+	return time.Duration(d)
+}
+
+// LoginAttemptStore 登录尝试的存储；每个方法需原子地执行，保证并发登录时窗口内的尝试次数不超过最大失败次数
+type LoginAttemptStore interface {
+	// Acquire 登录前检查锁定、失败次数与渐进延迟，通过时占用一次进行中的尝试
+	// 拒绝时返回需等待的时间；locked 为已锁定
+	Acquire(ctx context.Context, key string, now time.Time, rule *LoginAttemptRule) (wait time.Duration, locked bool, err error)
+	// Failure 释放进行中的尝试，失败次数+1；达到最大失败次数时清除失败次数并锁定；返回窗口内的失败次数
+	Failure(ctx context.Context, key string, now time.Time, rule *LoginAttemptRule) (failures int64, err error)
+	// Release 释放进行中的尝试
+	Release(ctx context.Context, key string, now time.Time, rule *LoginAttemptRule) error
+	// Reset 清除失败次数、进行中的尝试与锁定
+	Reset(ctx context.Context, key string) error
+}
+
+// LoginAttemptLimiter 登录尝试限制：防止暴力破解密码与验证码
+// 按 用户+登录类型、客户端ip+登录类型 分别统计；渐进延迟仅作用于用户，避免共享ip的用户相互影响
+// 有租户时按租户隔离统计
+type LoginAttemptLimiter interface {
+	// Allow 登录前检查并占用一次尝试；锁定或需要等待时返回 ErrLoginLocked、ErrLoginTooFrequent，元数据 RetryAfterKey 为等待的秒数
+	// 通过后须调用 Failure 或 Success 结束本次尝试
+	Allow(ctx context.Context, userIdentifier string, loginType LoginTypeEnum_LoginType) error
+	// Failure 登录失败
+	Failure(ctx context.Context, userIdentifier string, loginType LoginTypeEnum_LoginType) error
+	// Success 登录成功：清除用户的失败次数；不清除客户端ip的失败次数
+	Success(ctx context.Context, userIdentifier string, loginType LoginTypeEnum_LoginType) error
+	// Unlock 管理员解锁用户
+	Unlock(ctx context.Context, userIdentifier string, loginType LoginTypeEnum_LoginType) error
+	// UnlockIP 管理员解锁客户端ip
+	UnlockIP(ctx context.Context, clientIP string, loginType LoginTypeEnum_LoginType) error
+}
+
+// loginAttemptLimiter ...
+type loginAttemptLimiter struct {
+	store    LoginAttemptStore
+	userRule *LoginAttemptRule
+	ipRule   *LoginAttemptRule
+	now      func() time.Time
+}
+
+// NewLoginAttemptLimiter 登录尝试限制
+// store NewRedisLoginAttemptStore 或 NewMemoryLoginAttemptStore；config 为空时使用 DefaultLoginAttemptConfig
+func NewLoginAttemptLimiter(store LoginAttemptStore, config *LoginAttemptConfig) LoginAttemptLimiter {
+	return newLoginAttemptLimiter(store, config, time.Now)
+}
+
+// newLoginAttemptLimiter ...
+func newLoginAttemptLimiter(store LoginAttemptStore, config *LoginAttemptConfig, now func() time.Time) *loginAttemptLimiter {
+	conf := DefaultLoginAttemptConfig()
+	if config != nil {
+		if config.MaxFailures > 0 {
+			conf.MaxFailures = config.MaxFailures
+		}
+		if config.MaxFailuresPerIP > 0 {
+			conf.MaxFailuresPerIP = config.MaxFailuresPerIP
+		}
+		if config.Window > 0 {
+			conf.Window = config.Window
+		}
+		if config.LockoutDuration > 0 {
+			conf.LockoutDuration = config.LockoutDuration
+		}
+		if config.BaseDelay > 0 {
+			conf.BaseDelay = config.BaseDelay
+		}
+		if config.MaxDelay > 0 {
+			conf.MaxDelay = config.MaxDelay
+		}
+		if config.AttemptTimeout > 0 {
+			conf.AttemptTimeout = config.AttemptTimeout
+		}
+	}
+	return &loginAttemptLimiter{
+		store: store,
+		userRule: &LoginAttemptRule{
+			MaxFailures:     conf.MaxFailures,
+			Window:          conf.Window,
+			LockoutDuration: conf.LockoutDuration,
+			BaseDelay:       conf.BaseDelay,
+			MaxDelay:        conf.MaxDelay,
+			AttemptTimeout:  conf.AttemptTimeout,
+		},
+		ipRule: &LoginAttemptRule{
+			MaxFailures:     conf.MaxFailuresPerIP,
+			Window:          conf.Window,
+			LockoutDuration: conf.LockoutDuration,
+			AttemptTimeout:  conf.AttemptTimeout,
+		},
+		now: now,
+	}
+}
+
+// loginAttemptKey ...
+type loginAttemptKey struct {
+	key  string
+	rule *LoginAttemptRule
+}
+
+// keys 用户与客户端ip
+func (s *loginAttemptLimiter) keys(ctx context.Context, userIdentifier string, loginType LoginTypeEnum_LoginType) []*loginAttemptKey {
+	keys := []*loginAttemptKey{
+		{key: genUserAttemptKey(ctx, userIdentifier, loginType), rule: s.userRule},
+	}
+	if clientIP, _ := clientInfoFromContext(ctx); clientIP != "" {
+		keys = append(keys, &loginAttemptKey{key: genIPAttemptKey(ctx, clientIP, loginType), rule: s.ipRule})
+	}
+	return keys
+}
+
+// genUserAttemptKey ...
+func genUserAttemptKey(ctx context.Context, userIdentifier string, loginType LoginTypeEnum_LoginType) string {
+	return tenantKey(ctx, "user:"+strconv.Itoa(int(loginType))+":"+userIdentifier)
+}
+
+// genIPAttemptKey ...
+func genIPAttemptKey(ctx context.Context, clientIP string, loginType LoginTypeEnum_LoginType) string {
+	return tenantKey(ctx, "ip:"+strconv.Itoa(int(loginType))+":"+clientIP)
+}
+
+// Allow ...
+func (s *loginAttemptLimiter) Allow(ctx context.Context, userIdentifier string, loginType LoginTypeEnum_LoginType) error {
+	var (
+		now      = s.now()
+		acquired []*loginAttemptKey
+	)
+	for _, key := range s.keys(ctx, userIdentifier, loginType) {
+		wait, locked, err := s.store.Acquire(ctx, key.key, now, key.rule)
+		if err == nil && wait <= 0 {
+			acquired = append(acquired, key)
+			continue
+		}
+		// 释放已占用的尝试
+		for _, k := range acquired {
+			_ = s.store.Release(ctx, k.key, now, k.rule)
+		}
+		if err != nil {
+			return fmt.Errorf("Acquire failed: %w", err)
+		}
+		if locked {
+			e := withRetryAfter(ErrLoginLocked(), wait)
+			return errorpkg.WithStack(e)
+		}
+		e := withRetryAfter(ErrLoginTooFrequent(), wait)
+		return errorpkg.WithStack(e)
+	}
+	return nil
+}
+
+// Failure ...
+func (s *loginAttemptLimiter) Failure(ctx context.Context, userIdentifier string, loginType LoginTypeEnum_LoginType) error {
+	now := s.now()
+	for _, key := range s.keys(ctx, userIdentifier, loginType) {
+		if _, err := s.store.Failure(ctx, key.key, now, key.rule); err != nil {
+			return fmt.Errorf("Failure failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// Success ...
+func (s *loginAttemptLimiter) Success(ctx context.Context, userIdentifier string, loginType LoginTypeEnum_LoginType) error {
+	now := s.now()
+	for _, key := range s.keys(ctx, userIdentifier, loginType) {
+		if key.rule == s.userRule {
+			if err := s.store.Reset(ctx, key.key); err != nil {
+				return fmt.Errorf("Reset failed: %w", err)
+			}
+			continue
+		}
+		if err := s.store.Release(ctx, key.key, now, key.rule); err != nil {
+			return fmt.Errorf("Release failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// Unlock ...
+func (s *loginAttemptLimiter) Unlock(ctx context.Context, userIdentifier string, loginType LoginTypeEnum_LoginType) error {
+	return s.store.Reset(ctx, genUserAttemptKey(ctx, userIdentifier, loginType))
+}
+
+// UnlockIP ...
+func (s *loginAttemptLimiter) UnlockIP(ctx context.Context, clientIP string, loginType LoginTypeEnum_LoginType) error {
+	return s.store.Reset(ctx, genIPAttemptKey(ctx, clientIP, loginType))
+}
+
+// withRetryAfter 元数据：重试等待的秒数(向上取整)
+func withRetryAfter(e *errors.Error, d time.Duration) *errors.Error {
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]string)
+	}
+	e.Metadata[RetryAfterKey] = strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+	return e
+}
+
+// redisLoginAttemptStore ...
+type redisLoginAttemptStore struct {
+	redisCC   redis.UniversalClient
+	keyPrefix RedisCacheKeyPrefix
+}
+
+// NewRedisLoginAttemptStore 基于Redis的登录尝试存储；keyPrefix 为空时使用 DefaultLoginAttemptKeyPrefix
+func NewRedisLoginAttemptStore(redisCC redis.UniversalClient, keyPrefix RedisCacheKeyPrefix) LoginAttemptStore {
+	if keyPrefix == "" {
+		keyPrefix = DefaultLoginAttemptKeyPrefix
+	}
+	return &redisLoginAttemptStore{
+		redisCC:   redisCC,
+		keyPrefix: keyPrefix,
+	}
+}
+
+// genFailureKey ...
+func (s *redisLoginAttemptStore) genFailureKey(key string) string {
+	return s.keyPrefix.String() + "failure:" + key
+}
+
+// genLockKey ...
+func (s *redisLoginAttemptStore) genLockKey(key string) string {
+	return s.keyPrefix.String() + "lock:" + key
+}
+
+// acquireLoginAttemptScript 原子地检查并占用一次尝试
+// KEYS[1] 失败次数(c:失败次数 t:最后一次失败 p:进行中的尝试 pt:最后一次占用)；KEYS[2] 锁定
+// ARGV: 当前时间、统计窗口、最大失败次数、渐进延迟、最大延迟、尝试超时(毫秒)
+// 返回 {0, 0} 通过；{1, 等待} 锁定；{2, 等待} 需要等待
+var acquireLoginAttemptScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[2])
+if ttl > 0 then
+	return {1, ttl}
+end
+local now, window, maxFailures = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local baseDelay, maxDelay, timeout = tonumber(ARGV[4]), tonumber(ARGV[5]), tonumber(ARGV[6])
+local v = redis.call('HMGET', KEYS[1], 'c', 't', 'p', 'pt')
+local c, t, p, pt = tonumber(v[1]) or 0, tonumber(v[2]) or 0, tonumber(v[3]) or 0, tonumber(v[4]) or 0
+if now - pt >= timeout then
+	p = 0
+end
+if c + p >= maxFailures then
+	return {2, pt + timeout - now}
+end
+if baseDelay > 0 and c > 0 then
+	local wait = t + math.min(baseDelay * 2 ^ (c - 1), maxDelay) - now
+	if wait > 0 then
+		return {2, wait}
+	end
+end
+redis.call('HSET', KEYS[1], 'p', p + 1, 'pt', now)
+if redis.call('PTTL', KEYS[1]) < timeout then
+	redis.call('PEXPIRE', KEYS[1], math.max(window, timeout))
+end
+return {0, 0}
+`)
+
+// failureLoginAttemptScript 原子地释放尝试、失败次数+1，达到最大失败次数时锁定
+// KEYS 同 acquireLoginAttemptScript；ARGV: 当前时间、统计窗口、最大失败次数、锁定时长、尝试超时(毫秒)
+// 返回失败次数
+var failureLoginAttemptScript = redis.NewScript(`
+local now, window, maxFailures = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local lockout, timeout = tonumber(ARGV[4]), tonumber(ARGV[5])
+local v = redis.call('HMGET', KEYS[1], 'c', 'p', 'pt')
+local c, p, pt = (tonumber(v[1]) or 0) + 1, tonumber(v[2]) or 0, tonumber(v[3]) or 0
+if c >= maxFailures then
+	redis.call('DEL', KEYS[1])
+	redis.call('SET', KEYS[2], 1, 'PX', lockout)
+	return c
+end
+if now - pt >= timeout then
+	p = 0
+end
+redis.call('HSET', KEYS[1], 'c', c, 't', now, 'p', math.max(p - 1, 0))
+redis.call('PEXPIRE', KEYS[1], window)
+return c
+`)
+
+// releaseLoginAttemptScript 原子地释放尝试
+// KEYS[1] 失败次数；ARGV: 当前时间、尝试超时(毫秒)
+var releaseLoginAttemptScript = redis.NewScript(`
+local v = redis.call('HMGET', KEYS[1], 'p', 'pt')
+local p, pt = tonumber(v[1]) or 0, tonumber(v[2]) or 0
+if p == 0 then
+	return 0
+end
+if tonumber(ARGV[1]) - pt >= tonumber(ARGV[2]) then
+	p = 0
+end
+redis.call('HSET', KEYS[1], 'p', math.max(p - 1, 0))
+return 1
+`)
+
+// Acquire ...
+func (s *redisLoginAttemptStore) Acquire(ctx context.Context, key string, now time.Time, rule *LoginAttemptRule) (time.Duration, bool, error) {
+	res, err := acquireLoginAttemptScript.Run(ctx, s.redisCC,
+		[]string{s.genFailureKey(key), s.genLockKey(key)},
+		now.UnixMilli(), rule.Window.Milliseconds(), rule.MaxFailures,
+		rule.BaseDelay.Milliseconds(), rule.MaxDelay.Milliseconds(), rule.AttemptTimeout.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	if len(res) != 2 {
+		return 0, false, fmt.Errorf("unexpected acquire result: %v", res)
+	}
+	return time.Duration(res[1]) * time.Millisecond, res[0] == 1, nil
+}
+
+// Failure ...
+func (s *redisLoginAttemptStore) Failure(ctx context.Context, key string, now time.Time, rule *LoginAttemptRule) (int64, error) {
+	return failureLoginAttemptScript.Run(ctx, s.redisCC,
+		[]string{s.genFailureKey(key), s.genLockKey(key)},
+		now.UnixMilli(), rule.Window.Milliseconds(), rule.MaxFailures,
+		rule.LockoutDuration.Milliseconds(), rule.AttemptTimeout.Milliseconds(),
+	).Int64()
+}
+
+// Release ...
+func (s *redisLoginAttemptStore) Release(ctx context.Context, key string, now time.Time, rule *LoginAttemptRule) error {
+	return releaseLoginAttemptScript.Run(ctx, s.redisCC,
+		[]string{s.genFailureKey(key)},
+		now.UnixMilli(), rule.AttemptTimeout.Milliseconds(),
+	).Err()
+}
+
+// Reset ...
+func (s *redisLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	return s.redisCC.Del(ctx, s.genFailureKey(key), s.genLockKey(key)).Err()
+}
+
+// memoryLoginAttempt ...
+type memoryLoginAttempt struct {
+	failures       int64
+	lastFailedAt   time.Time
+	expireAt       time.Time
+	lockedUntil    time.Time
+	pending        int64
+	lastAcquiredAt time.Time
+}
+
+// memoryLoginAttemptStore ...
+type memoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]*memoryLoginAttempt
+}
+
+// NewMemoryLoginAttemptStore 基于进程内存的登录尝试存储；适用于单节点部署与测试
+func NewMemoryLoginAttemptStore() LoginAttemptStore {
+	return &memoryLoginAttemptStore{
+		attempts: make(map[string]*memoryLoginAttempt),
+	}
+}
+
+// get 清除过期的数据；调用方持有锁，修改后需写回
+func (s *memoryLoginAttemptStore) get(key string, now time.Time, rule *LoginAttemptRule) *memoryLoginAttempt {
+	attempt, ok := s.attempts[key]
+	if !ok {
+		return &memoryLoginAttempt{}
+	}
+	if !attempt.expireAt.After(now) {
+		attempt.failures, attempt.lastFailedAt, attempt.expireAt = 0, time.Time{}, time.Time{}
+		attempt.pending, attempt.lastAcquiredAt = 0, time.Time{}
+	}
+	if !attempt.lastAcquiredAt.Add(rule.AttemptTimeout).After(now) {
+		attempt.pending = 0
+	}
+	if attempt.failures == 0 && attempt.pending == 0 && !attempt.lockedUntil.After(now) {
+		delete(s.attempts, key)
+		return &memoryLoginAttempt{}
+	}
+	return attempt
+}
+
+// Acquire ...
+func (s *memoryLoginAttemptStore) Acquire(ctx context.Context, key string, now time.Time, rule *LoginAttemptRule) (time.Duration, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt := s.get(key, now, rule)
+	if attempt.lockedUntil.After(now) {
+		return attempt.lockedUntil.Sub(now), true, nil
+	}
+	if attempt.failures+attempt.pending >= rule.MaxFailures {
+		return attempt.lastAcquiredAt.Add(rule.AttemptTimeout).Sub(now), false, nil
+	}
+	if wait := attempt.lastFailedAt.Add(rule.delay(attempt.failures)).Sub(now); attempt.failures > 0 && wait > 0 {
+		return wait, false, nil
+	}
+	attempt.pending++
+	attempt.lastAcquiredAt = now
+	if attempt.expireAt.Before(now.Add(rule.AttemptTimeout)) {
+		attempt.expireAt = now.Add(max(rule.Window, rule.AttemptTimeout))
+	}
+	s.attempts[key] = attempt
+	return 0, false, nil
+}
+
+// Failure ...
+func (s *memoryLoginAttemptStore) Failure(ctx context.Context, key string, now time.Time, rule *LoginAttemptRule) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt := s.get(key, now, rule)
+	failures := attempt.failures + 1
+	if failures >= rule.MaxFailures {
+		s.attempts[key] = &memoryLoginAttempt{lockedUntil: now.Add(rule.LockoutDuration)}
+		return failures, nil
+	}
+	attempt.failures = failures
+	attempt.lastFailedAt = now
+	attempt.pending = max(attempt.pending-1, 0)
+	attempt.expireAt = now.Add(rule.Window)
+	s.attempts[key] = attempt
+	return failures, nil
+}
+
+// Release ...
+func (s *memoryLoginAttemptStore) Release(ctx context.Context, key string, now time.Time, rule *LoginAttemptRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt := s.get(key, now, rule)
+	if attempt.pending > 0 {
+		attempt.pending--
+	}
+	return nil
+}
+
+// Reset ...
+func (s *memoryLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
@@ -0,0 +1,207 @@
+package authpkg
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	contextpkg "github.com/eden-quan/go-kratos-pkg/context"
+)
+
+// go test -v -count=1 ./auth -test.run=TestLoginAttemptLimiter
+func TestLoginAttemptLimiter(t *testing.T) {
+	stores := map[string]func(t *testing.T, now func() time.Time) (LoginAttemptStore, func(d time.Duration)){
+		"redis": func(t *testing.T, now func() time.Time) (LoginAttemptStore, func(d time.Duration)) {
+			mr := miniredis.RunT(t)
+			redisCC := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { _ = redisCC.Close() })
+			return NewRedisLoginAttemptStore(redisCC, ""), mr.FastForward
+		},
+		"memory": func(t *testing.T, now func() time.Time) (LoginAttemptStore, func(d time.Duration)) {
+			return NewMemoryLoginAttemptStore(), func(time.Duration) {}
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			var (
+				mu    sync.Mutex
+				clock = time.Now()
+				now   = func() time.Time {
+					mu.Lock()
+					defer mu.Unlock()
+					return clock
+				}
+				store, fastForward = newStore(t, now)
+				advance            = func(d time.Duration) {
+					mu.Lock()
+					clock = clock.Add(d)
+					mu.Unlock()
+					fastForward(d)
+				}
+				limiter = newLoginAttemptLimiter(store, &LoginAttemptConfig{
+					MaxFailures:      3,
+					MaxFailuresPerIP: 5,
+					Window:           time.Minute * 10,
+					LockoutDuration:  time.Minute * 5,
+					BaseDelay:        time.Second,
+					MaxDelay:         time.Second * 3,
+				}, now)
+				loginType = LoginTypeEnum_PHONE_AND_PASSWORD
+				ctx       = contextpkg.SetClientIpToContext(context.Background(), "10.0.0.1")
+				retryOf   = func(err error) string {
+					return errors.FromError(err).Metadata[RetryAfterKey]
+				}
+			)
+			testLoginAttemptLimiter(t, ctx, limiter, loginType, advance, retryOf)
+		})
+	}
+}
+
+// testLoginAttemptLimiter ...
+func testLoginAttemptLimiter(t *testing.T, ctx context.Context, limiter LoginAttemptLimiter, loginType LoginTypeEnum_LoginType, advance func(d time.Duration), retryOf func(err error) string) {
+	var (
+		ipContext = func(ip string) context.Context {
+			return contextpkg.SetClientIpToContext(context.Background(), ip)
+		}
+		// fail 一次失败的登录尝试
+		fail = func(ctx context.Context, userIdentifier string) {
+			require.Nil(t, limiter.Allow(ctx, userIdentifier, loginType))
+			require.Nil(t, limiter.Failure(ctx, userIdentifier, loginType))
+		}
+		// succeed 一次成功的登录尝试
+		succeed = func(ctx context.Context, userIdentifier string) {
+			require.Nil(t, limiter.Allow(ctx, userIdentifier, loginType))
+			require.Nil(t, limiter.Success(ctx, userIdentifier, loginType))
+		}
+	)
+
+	// 渐进延迟：1s、2s
+	fail(ctx, "1")
+	err := limiter.Allow(ctx, "1", loginType)
+	require.True(t, Is(err, ErrLoginTooFrequent()))
+	require.Equal(t, "1", retryOf(err))
+	advance(time.Second)
+	fail(ctx, "1")
+	err = limiter.Allow(ctx, "1", loginType)
+	require.Equal(t, "2", retryOf(err))
+	advance(time.Second * 2)
+
+	// 其他登录类型不受影响
+	require.Nil(t, limiter.Allow(ctx, "1", LoginTypeEnum_EMAIL_AND_PASSWORD))
+	require.Nil(t, limiter.Success(ctx, "1", LoginTypeEnum_EMAIL_AND_PASSWORD))
+
+	// 锁定
+	fail(ctx, "1")
+	err = limiter.Allow(ctx, "1", loginType)
+	require.True(t, Is(err, ErrLoginLocked()))
+	require.Equal(t, "300", retryOf(err))
+	e := errors.FromError(err)
+	require.Equal(t, int32(429), e.Code)
+
+	// 管理员解锁
+	require.Nil(t, limiter.Unlock(ctx, "1", loginType))
+	succeed(ctx, "1")
+
+	// 锁定过期
+	lockCtx := ipContext("10.0.0.2")
+	fail(lockCtx, "2")
+	advance(time.Second)
+	fail(lockCtx, "2")
+	advance(time.Second * 2)
+	fail(lockCtx, "2")
+	require.True(t, Is(limiter.Allow(lockCtx, "2", loginType), ErrLoginLocked()))
+	advance(time.Minute * 5)
+	succeed(lockCtx, "2")
+
+	// 客户端ip：不同用户的失败次数累计到5次时锁定ip
+	ipCtx := ipContext("10.0.0.3")
+	for i := 0; i < 4; i++ {
+		fail(ipCtx, "ip-"+strconv.Itoa(i))
+		succeed(ipCtx, "ip-other")
+	}
+	fail(ipCtx, "ip-4")
+	err = limiter.Allow(ipCtx, "ip-other", loginType)
+	require.True(t, Is(err, ErrLoginLocked()))
+	succeed(ipContext("10.0.0.4"), "ip-other")
+	require.Nil(t, limiter.UnlockIP(ctx, "10.0.0.3", loginType))
+	succeed(ipCtx, "ip-other")
+
+	// 登录成功清除失败次数
+	successCtx := ipContext("10.0.0.5")
+	fail(successCtx, "5")
+	advance(time.Second)
+	succeed(successCtx, "5")
+	fail(successCtx, "5")
+	advance(time.Second)
+	fail(successCtx, "5")
+	advance(time.Second * 2)
+	require.Nil(t, limiter.Allow(successCtx, "5", loginType))
+
+	// 统计窗口：进行中的尝试超时，失败次数过期
+	advance(time.Minute * 10)
+	fail(successCtx, "5")
+	advance(time.Second)
+	succeed(successCtx, "5")
+
+	// 并发尝试：窗口内通过的尝试不超过最大失败次数
+	var (
+		wg        sync.WaitGroup
+		allowed   = make(chan struct{}, 10)
+		rejected  = make(chan error, 10)
+		concurCtx = ipContext("10.0.0.6")
+	)
+	for i := 0; i < cap(allowed); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := limiter.Allow(concurCtx, "6", loginType); err != nil {
+				rejected <- err
+				return
+			}
+			allowed <- struct{}{}
+		}()
+	}
+	wg.Wait()
+	close(allowed)
+	close(rejected)
+	require.Len(t, allowed, 3)
+	for err := range rejected {
+		require.True(t, Is(err, ErrLoginTooFrequent()), "%v", err)
+	}
+	for i := 0; i < 3; i++ {
+		require.Nil(t, limiter.Failure(concurCtx, "6", loginType))
+	}
+	require.True(t, Is(limiter.Allow(concurCtx, "6", loginType), ErrLoginLocked()))
+
+	// 进行中的尝试超时后释放
+	staleCtx := ipContext("10.0.0.7")
+	for i := 0; i < 3; i++ {
+		require.Nil(t, limiter.Allow(staleCtx, "7", loginType))
+	}
+	err = limiter.Allow(staleCtx, "7", loginType)
+	require.True(t, Is(err, ErrLoginTooFrequent()))
+	require.Equal(t, "30", retryOf(err))
+	advance(time.Second * 30)
+	succeed(staleCtx, "7")
+
+	// 租户隔离
+	tenantCtx := PutTenantIntoContext(ipContext("10.0.0.8"), "tenant-1")
+	fail(tenantCtx, "8")
+	advance(time.Second)
+	fail(tenantCtx, "8")
+	advance(time.Second * 2)
+	fail(tenantCtx, "8")
+	require.True(t, Is(limiter.Allow(tenantCtx, "8", loginType), ErrLoginLocked()))
+	succeed(PutTenantIntoContext(ipContext("10.0.0.8"), "tenant-2"), "8")
+	succeed(ipContext("10.0.0.8"), "8")
+	require.Nil(t, limiter.Unlock(tenantCtx, "8", loginType))
+	require.Nil(t, limiter.UnlockIP(tenantCtx, "10.0.0.8", loginType))
+	succeed(tenantCtx, "8")
+}
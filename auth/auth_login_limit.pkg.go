@@ -0,0 +1,139 @@
+package authpkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/errors"
+)
+
+// LoginLimitInfo 登录限制：令牌因其他登录被注销
+type LoginLimitInfo struct {
+	// LoginLimit 被注销令牌的登录限制
+	LoginLimit LoginLimitEnum_LoginLimit `json:"ll,omitempty"`
+	// LoginPlatform 新登录的平台
+	LoginPlatform LoginPlatformEnum_LoginPlatform `json:"lp,omitempty"`
+	// LoginType 新登录的类型
+	LoginType LoginTypeEnum_LoginType `json:"lt,omitempty"`
+	// LoginAt 新登录的时间
+	LoginAt int64 `json:"la,omitempty"`
+	// ClientIP 新登录的客户端ip
+	ClientIP string `json:"ip,omitempty"`
+	// UserAgent 新登录的客户端
+	UserAgent string `json:"ua,omitempty"`
+}
+
+// EncodeToString ...
+func (s *LoginLimitInfo) EncodeToString() (string, error) {
+	res, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("encode login limit info failed : %w", err)
+	}
+	return string(res), nil
+}
+
+// DecodeString 兼容旧版本仅存储登录限制(整数)的数据
+func (s *LoginLimitInfo) DecodeString(info string) error {
+	if !strings.HasPrefix(info, "{") {
+		ll, err := strconv.Atoi(info)
+		if err != nil {
+			return fmt.Errorf("decode login limit info failed : %w", err)
+		}
+		*s = LoginLimitInfo{LoginLimit: LoginLimitEnum_LoginLimit(int32(ll))}
+		return nil
+	}
+	err := json.Unmarshal([]byte(info), s)
+	if err != nil {
+		return fmt.Errorf("decode login limit info failed : %w", err)
+	}
+	return nil
+}
+
+// loginLimitInfoString 被注销令牌的登录限制信息
+func loginLimitInfoString(item *TokenItem, info *LoginLimitInfo) (string, error) {
+	var limitInfo LoginLimitInfo
+	if info != nil {
+		limitInfo = *info
+	}
+	if item.Payload != nil {
+		limitInfo.LoginLimit = item.Payload.LoginLimit
+	}
+	return limitInfo.EncodeToString()
+}
+
+// LoginLimitHook 登录限制回调：用户在其他地方登录，旧的令牌被注销；例：推送下线通知
+// 在签发令牌后异步调用
+type LoginLimitHook func(ctx context.Context, userIdentifier string, evictedItems []*TokenItem, info *LoginLimitInfo)
+
+// ErrLoginLimitWithInfo 登录限制的错误；元数据包含新登录的信息
+// 错误会返回给客户端，客户端ip仅保留网段，客户端仅保留设备类型
+func ErrLoginLimitWithInfo(info *LoginLimitInfo) *errors.Error {
+	e := ErrLoginLimit()
+	if info == nil {
+		return e
+	}
+	e.Metadata = map[string]string{
+		"login_limit":    info.LoginLimit.String(),
+		"login_platform": info.LoginPlatform.String(),
+		"login_type":     info.LoginType.String(),
+		"login_at":       strconv.FormatInt(info.LoginAt, 10),
+		"client_network": maskClientIP(info.ClientIP),
+		"client_device":  userAgentClass(info.UserAgent),
+	}
+	return e
+}
+
+const (
+	clientIPv4MaskBits = 24
+	clientIPv6MaskBits = 48
+)
+
+// maskClientIP 客户端ip的网段：IPv4 保留 /24，IPv6 保留 /48；无效的ip返回空
+func maskClientIP(ip string) string {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		mask := net.CIDRMask(clientIPv4MaskBits, 8*net.IPv4len)
+		return (&net.IPNet{IP: v4.Mask(mask), Mask: mask}).String()
+	}
+	mask := net.CIDRMask(clientIPv6MaskBits, 8*net.IPv6len)
+	return (&net.IPNet{IP: parsed.Mask(mask), Mask: mask}).String()
+}
+
+// 登录限制错误元数据中的客户端设备类型
+const (
+	UserAgentClassIOS     = "ios"
+	UserAgentClassAndroid = "android"
+	UserAgentClassWindows = "windows"
+	UserAgentClassMacOS   = "macos"
+	UserAgentClassLinux   = "linux"
+	UserAgentClassOther   = "other"
+)
+
+// userAgentClass 客户端的设备类型；顺序敏感：iOS 包含 Mac OS X，Android 包含 Linux
+func userAgentClass(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case ua == "":
+		return ""
+	case strings.Contains(ua, "android"), strings.Contains(ua, "okhttp"):
+		return UserAgentClassAndroid
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"),
+		strings.Contains(ua, "cfnetwork"):
+		return UserAgentClassIOS
+	case strings.Contains(ua, "windows"):
+		return UserAgentClassWindows
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os x"):
+		return UserAgentClassMacOS
+	case strings.Contains(ua, "linux"), strings.Contains(ua, "x11"):
+		return UserAgentClassLinux
+	default:
+		return UserAgentClassOther
+	}
+}
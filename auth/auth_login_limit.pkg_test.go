@@ -0,0 +1,128 @@
+package authpkg
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+
+	contextpkg "github.com/eden-quan/go-kratos-pkg/context"
+)
+
+// go test -v -count=1 ./auth -test.run=TestAuthRepo_LoginLimitInfo
+func TestAuthRepo_LoginLimitInfo(t *testing.T) {
+	type hookEvent struct {
+		userIdentifier string
+		evictedItems   []*TokenItem
+		info           *LoginLimitInfo
+	}
+	var (
+		events    = make(chan *hookEvent, 1)
+		repo, err = NewAuthRepo(newMemoryTokenManger(time.Now), log.DefaultLogger, Config{
+			SignKey: "1234567890ABCDEF",
+			LoginLimitHook: func(ctx context.Context, userIdentifier string, evictedItems []*TokenItem, info *LoginLimitInfo) {
+				events <- &hookEvent{userIdentifier: userIdentifier, evictedItems: evictedItems, info: info}
+			},
+		})
+		signIn = func(platform LoginPlatformEnum_LoginPlatform, ip, userAgent string) *Claims {
+			tr := newTestTransport("/api.user.v1.User/Login")
+			tr.reqHeader.Set(UserAgentKey, userAgent)
+			ctx := transport.NewServerContext(context.Background(), tr)
+			ctx = contextpkg.SetClientIpToContext(ctx, ip)
+
+			claims := DefaultClaims(Payload{
+				UserID:        1,
+				LoginPlatform: platform,
+				LoginType:     LoginTypeEnum_PHONE_AND_CAPTCHA,
+				LoginLimit:    LoginLimitEnum_ONLY_ONE,
+			})
+			_, _, err := repo.SignToken(ctx, claims)
+			require.Nil(t, err)
+			return claims
+		}
+	)
+	require.Nil(t, err)
+
+	first := signIn(LoginPlatformEnum_COMPUTER, "10.0.0.1", "Mozilla/5.0")
+	// 等待异步的登录限制检查
+	time.Sleep(time.Millisecond * 100)
+	second := signIn(LoginPlatformEnum_IOS, "10.0.0.2", "CFNetwork/1404")
+
+	// 回调
+	var event *hookEvent
+	select {
+	case event = <-events:
+	case <-time.After(time.Second * 3):
+		t.Fatal("login limit hook is not called")
+	}
+	require.Equal(t, "1", event.userIdentifier)
+	require.Len(t, event.evictedItems, 1)
+	require.Equal(t, first.ID, event.evictedItems[0].TokenID)
+	require.Equal(t, LoginPlatformEnum_IOS, event.info.LoginPlatform)
+	require.Equal(t, "10.0.0.2", event.info.ClientIP)
+
+	// 被注销的令牌
+	err = repo.VerifyToken(context.Background(), &jwt.Token{Claims: first})
+	require.True(t, Is(err, ErrLoginLimit()))
+	e := errors.FromError(err)
+	require.Equal(t, LoginLimitEnum_ONLY_ONE.String(), e.Metadata["login_limit"])
+	require.Equal(t, LoginPlatformEnum_IOS.String(), e.Metadata["login_platform"])
+	require.Equal(t, LoginTypeEnum_PHONE_AND_CAPTCHA.String(), e.Metadata["login_type"])
+	require.Equal(t, strconv.FormatInt(second.IssuedAt.Unix(), 10), e.Metadata["login_at"])
+	require.Equal(t, "10.0.0.0/24", e.Metadata["client_network"])
+	require.Equal(t, UserAgentClassIOS, e.Metadata["client_device"])
+	require.NotContains(t, e.Metadata, "client_ip")
+	require.NotContains(t, e.Metadata, "user_agent")
+
+	require.Nil(t, repo.VerifyToken(context.Background(), &jwt.Token{Claims: second}))
+}
+
+// go test -v -count=1 ./auth -test.run=TestLoginLimitInfo_DecodeString
+func TestLoginLimitInfo_DecodeString(t *testing.T) {
+	// 兼容旧版本
+	info := &LoginLimitInfo{}
+	require.Nil(t, info.DecodeString("2"))
+	require.Equal(t, &LoginLimitInfo{LoginLimit: LoginLimitEnum_PLATFORM_ONE}, info)
+
+	infoStr, err := (&LoginLimitInfo{LoginLimit: LoginLimitEnum_ONLY_ONE, ClientIP: "10.0.0.1"}).EncodeToString()
+	require.Nil(t, err)
+	info = &LoginLimitInfo{}
+	require.Nil(t, info.DecodeString(infoStr))
+	require.Equal(t, &LoginLimitInfo{LoginLimit: LoginLimitEnum_ONLY_ONE, ClientIP: "10.0.0.1"}, info)
+
+	require.NotNil(t, info.DecodeString("invalid"))
+}
+
+// go test -v -count=1 ./auth -test.run=TestMaskClientIP
+func TestMaskClientIP(t *testing.T) {
+	require.Equal(t, "10.0.0.0/24", maskClientIP("10.0.0.2"))
+	require.Equal(t, "192.168.1.0/24", maskClientIP(" 192.168.1.255 "))
+	require.Equal(t, "10.0.0.0/24", maskClientIP("::ffff:10.0.0.2"))
+	require.Equal(t, "2001:db8:1::/48", maskClientIP("2001:db8:1:2:3:4:5:6"))
+	require.Equal(t, "", maskClientIP(""))
+	require.Equal(t, "", maskClientIP("invalid"))
+}
+
+// go test -v -count=1 ./auth -test.run=TestUserAgentClass
+func TestUserAgentClass(t *testing.T) {
+	tests := map[string]string{
+		"":               "",
+		"CFNetwork/1404": UserAgentClassIOS,
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X) AppleWebKit/605.1.15": UserAgentClassIOS,
+		"Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36":                 UserAgentClassAndroid,
+		"okhttp/4.10.0": UserAgentClassAndroid,
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36":         UserAgentClassWindows,
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15": UserAgentClassMacOS,
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36":                   UserAgentClassLinux,
+		"curl/8.0.1": UserAgentClassOther,
+	}
+	for ua, want := range tests {
+		require.Equal(t, want, userAgentClass(ua), ua)
+	}
+}
@@ -0,0 +1,729 @@
+package authpkg
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+
+	errorpkg "github.com/eden-quan/go-kratos-pkg/error"
+	totppkg "github.com/eden-quan/go-kratos-pkg/totp"
+)
+
+// 认证方式(amr)：参考 RFC 8176
+const (
+	// AMRPassword 密码
+	AMRPassword = "pwd"
+	// AMROTP 一次性验证码：TOTP
+	AMROTP = "otp"
+	// AMRSMS 短信验证码
+	AMRSMS = "sms"
+	// AMRMultiFactor 多因素认证
+	AMRMultiFactor = "mfa"
+	// AMRRecoveryCode 恢复码；非 RFC 8176
+	AMRRecoveryCode = "rc"
+)
+
+// 认证强度(acr)
+const (
+	// ACRSingleFactor 单因素认证
+	ACRSingleFactor = "sfa"
+	// ACRMultiFactor 多因素认证
+	ACRMultiFactor = "mfa"
+)
+
+const (
+	// DefaultMFAKeyPrefix 多因素认证的缓存key前缀
+	DefaultMFAKeyPrefix RedisCacheKeyPrefix = "gs:auth:mfa:"
+	// DefaultStepUpMaxAge 多因素认证的默认有效时间
+	DefaultStepUpMaxAge = time.Minute * 5
+	// DefaultRecoveryCodeCount 恢复码的数量
+	DefaultRecoveryCodeCount = 10
+	// MFAMaxAgeKey 错误元数据：要求的多因素认证有效时间(秒)
+	MFAMaxAgeKey = "max_age"
+	// MFAACRKey 错误元数据：要求的认证强度
+	MFAACRKey = "acr"
+
+	// recoveryCodeSize 恢复码的随机字符数：xxxxx-xxxxx
+	recoveryCodeSize = 10
+	// recoveryCodeCharset 恢复码字符；不包含易混淆的字符
+	recoveryCodeCharset = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// HasAMR 是否使用了认证方式
+func (s *Claims) HasAMR(method string) bool {
+	for i := range s.AMR {
+		if s.AMR[i] == method {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMultiFactor 是否完成多因素认证
+func (s *Claims) IsMultiFactor() bool {
+	return s.ACR == ACRMultiFactor || s.HasAMR(AMRMultiFactor)
+}
+
+// TOTPCredential TOTP凭证
+type TOTPCredential struct {
+	// Secret 加密的密钥
+	Secret string `json:"secret"`
+	// Confirmed 是否已确认；未确认的凭证不能用于验证
+	Confirmed bool `json:"confirmed,omitempty"`
+	// CreatedAt 绑定时间(秒)
+	CreatedAt int64 `json:"created_at,omitempty"`
+	// ConfirmedAt 确认时间(秒)
+	ConfirmedAt int64 `json:"confirmed_at,omitempty"`
+}
+
+// EncodeToString ...
+func (s *TOTPCredential) EncodeToString() (string, error) {
+	res, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("encode totp credential failed : %w", err)
+	}
+	return string(res), nil
+}
+
+// DecodeString ...
+func (s *TOTPCredential) DecodeString(credential string) error {
+	err := json.Unmarshal([]byte(credential), s)
+	if err != nil {
+		return fmt.Errorf("decode totp credential failed : %w", err)
+	}
+	return nil
+}
+
+// TOTPEnrollment 绑定信息；返回给客户端生成二维码
+type TOTPEnrollment struct {
+	// Secret 明文密钥(base32)；用于手动输入
+	Secret string `json:"secret"`
+	// URI otpauth URI
+	URI string `json:"uri"`
+}
+
+// MFAStore 多因素认证的存储
+// 按 GetTenantFromContext 的租户隔离
+type MFAStore interface {
+	// SaveTOTP 保存凭证
+	SaveTOTP(ctx context.Context, userIdentifier string, credential *TOTPCredential) error
+	// GetTOTP 获取凭证
+	GetTOTP(ctx context.Context, userIdentifier string) (credential *TOTPCredential, isNotFound bool, err error)
+	// DeleteTOTP 删除凭证、已使用的步数与恢复码
+	DeleteTOTP(ctx context.Context, userIdentifier string) error
+	// UseTOTPCounter 记录已使用的步数；步数小于等于已使用的步数时返回false
+	UseTOTPCounter(ctx context.Context, userIdentifier string, counter uint64, ttl time.Duration) (ok bool, err error)
+	// SaveRecoveryCodes 替换恢复码的哈希
+	SaveRecoveryCodes(ctx context.Context, userIdentifier string, codeHashes []string) error
+	// UseRecoveryCode 删除恢复码的哈希；不存在时返回false
+	UseRecoveryCode(ctx context.Context, userIdentifier string, codeHash string) (ok bool, err error)
+	// CountRecoveryCodes 剩余的恢复码数量
+	CountRecoveryCodes(ctx context.Context, userIdentifier string) (int64, error)
+}
+
+// totpSecretCryptoKeyInfo HKDF info：TOTP密钥的加密密钥独立于刷新令牌
+const totpSecretCryptoKeyInfo = "gs:auth:totp-secret"
+
+// DefaultTOTPSecretCrypto TOTP密钥的认证加密：AES-256-GCM；不兼容旧格式
+func DefaultTOTPSecretCrypto() Encryptor {
+	return newAEADCrypto(RefreshCryptoVersionAESGCM, totpSecretCryptoKeyInfo, nil)
+}
+
+// MFAConfig 多因素认证配置
+type MFAConfig struct {
+	// Issuer 显示在认证应用中的名称
+	Issuer string
+	// SecretKey 加密TOTP密钥的密钥；建议与令牌的签名密钥不同
+	SecretKey string
+	// Encryptor 加密TOTP密钥；默认 DefaultTOTPSecretCrypto
+	Encryptor Encryptor
+	// Period TOTP时间步长；默认30秒
+	Period time.Duration
+	// Digits 验证码位数；默认6位
+	Digits int
+	// Skew 允许的时间偏差(步数)；默认前后各1步，0使用默认值
+	Skew uint64
+	// Algorithm HMAC算法；默认SHA1，部分认证应用仅支持SHA1
+	Algorithm totppkg.Algorithm
+	// RecoveryCodeCount 恢复码的数量；默认 DefaultRecoveryCodeCount
+	RecoveryCodeCount int
+}
+
+// MFARepo 多因素认证：TOTP与恢复码
+// 验证成功后使用 AuthRepo.StepUpToken 签发多因素认证的令牌；建议使用 LoginAttemptLimiter 限制验证失败的次数
+type MFARepo interface {
+	// EnrollTOTP 绑定：生成密钥与 otpauth URI；ConfirmTOTP 验证后生效
+	// 已确认的凭证需要先 DisableTOTP，否则返回 ErrMFAAlreadyEnrolled
+	EnrollTOTP(ctx context.Context, userIdentifier, accountName string) (*TOTPEnrollment, error)
+	// ConfirmTOTP 验证第一个验证码后生效；返回恢复码，明文仅在此时返回
+	// 已确认时返回 ErrMFAAlreadyEnrolled
+	ConfirmTOTP(ctx context.Context, userIdentifier, code string) (recoveryCodes []string, err error)
+	// VerifyTOTP 验证验证码；同一验证码只能使用一次
+	VerifyTOTP(ctx context.Context, userIdentifier, code string) error
+	// VerifyRecoveryCode 验证恢复码；每个恢复码只能使用一次
+	VerifyRecoveryCode(ctx context.Context, userIdentifier, code string) error
+	// RegenerateRecoveryCodes 重新生成恢复码；旧的恢复码失效
+	RegenerateRecoveryCodes(ctx context.Context, userIdentifier string) ([]string, error)
+	// CountRecoveryCodes 剩余的恢复码数量；用于提醒用户重新生成
+	CountRecoveryCodes(ctx context.Context, userIdentifier string) (int64, error)
+	// IsTOTPEnrolled 是否已确认绑定
+	IsTOTPEnrolled(ctx context.Context, userIdentifier string) (bool, error)
+	// DisableTOTP 解绑：删除凭证与恢复码
+	DisableTOTP(ctx context.Context, userIdentifier string) error
+}
+
+// mfaRepo ...
+type mfaRepo struct {
+	store       MFAStore
+	config      *MFAConfig
+	totpOptions []totppkg.Option
+	now         func() time.Time
+	logHandler  *log.Helper
+}
+
+// NewMFARepo 多因素认证；store NewRedisMFAStore 或 NewMemoryMFAStore
+func NewMFARepo(store MFAStore, logger log.Logger, config MFAConfig) (MFARepo, error) {
+	return newMFARepo(store, logger, config, time.Now)
+}
+
+// newMFARepo ...
+func newMFARepo(store MFAStore, logger log.Logger, config MFAConfig, now func() time.Time) (*mfaRepo, error) {
+	if store == nil {
+		return nil, fmt.Errorf("mfa store is nil")
+	}
+	if config.SecretKey == "" {
+		return nil, fmt.Errorf("mfa secret key is empty; it is required to crypto totp secret")
+	}
+	if config.Encryptor == nil {
+		config.Encryptor = DefaultTOTPSecretCrypto()
+	}
+	if config.Period <= 0 {
+		config.Period = totppkg.DefaultPeriod
+	}
+	if config.Digits <= 0 {
+		config.Digits = totppkg.DefaultDigits
+	}
+	if config.Skew == 0 {
+		config.Skew = totppkg.DefaultSkew
+	}
+	if config.Algorithm == "" {
+		config.Algorithm = totppkg.AlgorithmSHA1
+	}
+	if config.RecoveryCodeCount <= 0 {
+		config.RecoveryCodeCount = DefaultRecoveryCodeCount
+	}
+	totpOptions := []totppkg.Option{
+		totppkg.WithPeriod(config.Period),
+		totppkg.WithDigits(config.Digits),
+		totppkg.WithSkew(config.Skew),
+		totppkg.WithAlgorithm(config.Algorithm),
+	}
+	if _, err := totppkg.Counter(now(), totpOptions...); err != nil {
+		return nil, err
+	}
+	return &mfaRepo{
+		store:       store,
+		config:      &config,
+		totpOptions: totpOptions,
+		now:         now,
+		logHandler:  log.NewHelper(log.With(logger, "module", "auth/mfa")),
+	}, nil
+}
+
+// EnrollTOTP ...
+func (s *mfaRepo) EnrollTOTP(ctx context.Context, userIdentifier, accountName string) (*TOTPEnrollment, error) {
+	credential, isNotFound, err := s.store.GetTOTP(ctx, userIdentifier)
+	if err != nil {
+		return nil, fmt.Errorf("GetTOTP failed: %w", err)
+	}
+	if !isNotFound && credential.Confirmed {
+		e := ErrMFAAlreadyEnrolled()
+		return nil, errorpkg.WithStack(e)
+	}
+	secret, err := totppkg.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	uri, err := totppkg.KeyURI(s.config.Issuer, accountName, secret, s.totpOptions...)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := s.config.Encryptor.EncryptToString(secret, s.config.SecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("crypto totp secret failed: %w", err)
+	}
+	credential = &TOTPCredential{
+		Secret:    encrypted,
+		CreatedAt: s.now().Unix(),
+	}
+	if err = s.store.SaveTOTP(ctx, userIdentifier, credential); err != nil {
+		return nil, fmt.Errorf("SaveTOTP failed: %w", err)
+	}
+	return &TOTPEnrollment{Secret: secret, URI: uri}, nil
+}
+
+// ConfirmTOTP ...
+func (s *mfaRepo) ConfirmTOTP(ctx context.Context, userIdentifier, code string) ([]string, error) {
+	credential, isNotFound, err := s.store.GetTOTP(ctx, userIdentifier)
+	if err != nil {
+		return nil, fmt.Errorf("GetTOTP failed: %w", err)
+	}
+	if isNotFound {
+		e := ErrMFANotEnrolled()
+		return nil, errorpkg.WithStack(e)
+	}
+	// 已确认时不验证，避免消耗验证码
+	if credential.Confirmed {
+		e := ErrMFAAlreadyEnrolled()
+		return nil, errorpkg.WithStack(e)
+	}
+	if err = s.verifyTOTP(ctx, userIdentifier, credential, code); err != nil {
+		return nil, err
+	}
+	credential.Confirmed = true
+	credential.ConfirmedAt = s.now().Unix()
+	if err = s.store.SaveTOTP(ctx, userIdentifier, credential); err != nil {
+		return nil, fmt.Errorf("SaveTOTP failed: %w", err)
+	}
+	return s.RegenerateRecoveryCodes(ctx, userIdentifier)
+}
+
+// VerifyTOTP ...
+func (s *mfaRepo) VerifyTOTP(ctx context.Context, userIdentifier, code string) error {
+	credential, isNotFound, err := s.store.GetTOTP(ctx, userIdentifier)
+	if err != nil {
+		return fmt.Errorf("GetTOTP failed: %w", err)
+	}
+	if isNotFound || !credential.Confirmed {
+		e := ErrMFANotEnrolled()
+		return errorpkg.WithStack(e)
+	}
+	return s.verifyTOTP(ctx, userIdentifier, credential, code)
+}
+
+// verifyTOTP 验证码在时间偏差内有效，且步数大于已使用的步数
+func (s *mfaRepo) verifyTOTP(ctx context.Context, userIdentifier string, credential *TOTPCredential, code string) error {
+	secret, err := s.config.Encryptor.DecryptToString(credential.Secret, s.config.SecretKey)
+	if err != nil {
+		return fmt.Errorf("decrypt totp secret failed: %w", err)
+	}
+	counter, ok, err := totppkg.Validate(secret, code, s.now(), s.totpOptions...)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		e := ErrMFACodeInvalid()
+		return errorpkg.WithStack(e)
+	}
+	// 重放：已使用的步数需要保留至验证码超出时间偏差
+	ttl := s.config.Period * time.Duration(2*s.config.Skew+2)
+	ok, err = s.store.UseTOTPCounter(ctx, userIdentifier, counter, ttl)
+	if err != nil {
+		return fmt.Errorf("UseTOTPCounter failed: %w", err)
+	}
+	if !ok {
+		e := ErrMFACodeInvalid()
+		e.Metadata = map[string]string{"reason": "code has been used"}
+		return errorpkg.WithStack(e)
+	}
+	return nil
+}
+
+// VerifyRecoveryCode ...
+func (s *mfaRepo) VerifyRecoveryCode(ctx context.Context, userIdentifier, code string) error {
+	code = normalizeRecoveryCode(code)
+	if len(code) != recoveryCodeSize {
+		e := ErrMFACodeInvalid()
+		return errorpkg.WithStack(e)
+	}
+	ok, err := s.store.UseRecoveryCode(ctx, userIdentifier, hashRecoveryCode(code))
+	if err != nil {
+		return fmt.Errorf("UseRecoveryCode failed: %w", err)
+	}
+	if !ok {
+		e := ErrMFACodeInvalid()
+		return errorpkg.WithStack(e)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes ...
+func (s *mfaRepo) RegenerateRecoveryCodes(ctx context.Context, userIdentifier string) ([]string, error) {
+	var (
+		codes  = make([]string, s.config.RecoveryCodeCount)
+		hashes = make([]string, s.config.RecoveryCodeCount)
+	)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code[:recoveryCodeSize/2] + "-" + code[recoveryCodeSize/2:]
+		hashes[i] = hashRecoveryCode(code)
+	}
+	if err := s.store.SaveRecoveryCodes(ctx, userIdentifier, hashes); err != nil {
+		return nil, fmt.Errorf("SaveRecoveryCodes failed: %w", err)
+	}
+	return codes, nil
+}
+
+// CountRecoveryCodes ...
+func (s *mfaRepo) CountRecoveryCodes(ctx context.Context, userIdentifier string) (int64, error) {
+	return s.store.CountRecoveryCodes(ctx, userIdentifier)
+}
+
+// IsTOTPEnrolled ...
+func (s *mfaRepo) IsTOTPEnrolled(ctx context.Context, userIdentifier string) (bool, error) {
+	credential, isNotFound, err := s.store.GetTOTP(ctx, userIdentifier)
+	if err != nil || isNotFound {
+		return false, err
+	}
+	return credential.Confirmed, nil
+}
+
+// DisableTOTP ...
+func (s *mfaRepo) DisableTOTP(ctx context.Context, userIdentifier string) error {
+	return s.store.DeleteTOTP(ctx, userIdentifier)
+}
+
+// generateRecoveryCode ...
+func generateRecoveryCode() (string, error) {
+	buf := make([]byte, recoveryCodeSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate recovery code failed : %w", err)
+	}
+	for i := range buf {
+		buf[i] = recoveryCodeCharset[int(buf[i])%len(recoveryCodeCharset)]
+	}
+	return string(buf), nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空格与分隔符
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// hashRecoveryCode 恢复码为随机数且只能使用一次，使用 SHA-256 即可
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// StepUpToken 多因素认证后签发新令牌：认证强度为 ACRMultiFactor，认证时间为当前时间；旧令牌与刷新令牌加入黑名单
+// amr 本次使用的认证方式；例：AMROTP、AMRRecoveryCode
+func (s *authRepo) StepUpToken(ctx context.Context, authClaims *Claims, amr ...string) (*TokenResponse, error) {
+	if authClaims.Payload == nil || authClaims.IsServiceToken() {
+		e := ErrTokenInvalid()
+		return nil, errorpkg.WithStack(e)
+	}
+	if authClaims.IsImpersonation() {
+		e := ErrImpersonationForbidden()
+		return nil, errorpkg.WithStack(e)
+	}
+	newClaims := inheritClaims(authClaims)
+	for _, method := range append(amr, AMRMultiFactor) {
+		if !newClaims.HasAMR(method) {
+			newClaims.AMR = append(newClaims.AMR, method)
+		}
+	}
+	newClaims.ACR = ACRMultiFactor
+	newClaims.AuthTime = jwt.NewNumericDate(time.Now())
+	res, isNotFound, err := s.reissueToken(ctx, authClaims, newClaims, AuthEventStepUp, 0)
+	if err != nil {
+		return nil, err
+	}
+	if isNotFound {
+		e := ErrWhitelist()
+		return nil, errorpkg.WithStack(e)
+	}
+	return res, nil
+}
+
+// StepUpRule 需要多因素认证的操作
+type StepUpRule struct {
+	// Operation 例：/api.user.v1.User/ChangePhone
+	Operation string
+	// MaxAge 多因素认证的有效时间；0使用 DefaultStepUpMaxAge
+	MaxAge time.Duration
+}
+
+// StepUp 多因素认证中间件：配置的操作要求令牌在 MaxAge 内完成多因素认证
+// 未满足时返回 ErrMFARequired，元数据 MFAMaxAgeKey 为要求的有效时间(秒)；客户端完成多因素认证后使用新令牌重试
+// 需在 Server 之后使用；令牌信息从 GetAuthClaimsFromContext 获取
+func StepUp(rules ...*StepUpRule) middleware.Middleware {
+	maxAges := make(map[string]time.Duration, len(rules))
+	for _, rule := range rules {
+		maxAge := rule.MaxAge
+		if maxAge <= 0 {
+			maxAge = DefaultStepUpMaxAge
+		}
+		maxAges[rule.Operation] = maxAge
+	}
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok {
+				e := ErrWrongContext()
+				return nil, errorpkg.WithStack(e)
+			}
+			maxAge, ok := maxAges[tr.Operation()]
+			if !ok {
+				return handler(ctx, req)
+			}
+			authClaims, ok := GetAuthClaimsFromContext(ctx)
+			if !ok {
+				e := ErrMissingToken()
+				return nil, errorpkg.WithStack(e)
+			}
+			if authClaims.IsMultiFactor() && authClaims.AuthTime != nil && time.Since(authClaims.AuthTime.Time) <= maxAge {
+				return handler(ctx, req)
+			}
+			e := ErrMFARequired()
+			e.Metadata = map[string]string{
+				MFAACRKey:    ACRMultiFactor,
+				MFAMaxAgeKey: strconv.FormatInt(int64(maxAge/time.Second), 10),
+			}
+			return nil, errorpkg.WithStack(e)
+		}
+	}
+}
+
+// redisMFAStore ...
+type redisMFAStore struct {
+	redisCC   redis.UniversalClient
+	keyPrefix RedisCacheKeyPrefix
+}
+
+// useTOTPCounterScript 步数大于已使用的步数时记录
+// KEYS[1] 已使用的步数；ARGV[1] 步数；ARGV[2] 过期时间(毫秒)
+var useTOTPCounterScript = redis.NewScript(`
+local last = tonumber(redis.call('GET', KEYS[1]) or '-1')
+if tonumber(ARGV[1]) <= last then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// NewRedisMFAStore 基于Redis的多因素认证存储；keyPrefix 为空时使用 DefaultMFAKeyPrefix
+func NewRedisMFAStore(redisCC redis.UniversalClient, keyPrefix RedisCacheKeyPrefix) MFAStore {
+	if keyPrefix == "" {
+		keyPrefix = DefaultMFAKeyPrefix
+	}
+	return &redisMFAStore{
+		redisCC:   redisCC,
+		keyPrefix: keyPrefix,
+	}
+}
+
+// genTOTPKey ...
+func (s *redisMFAStore) genTOTPKey(ctx context.Context, userIdentifier string) string {
+	return s.keyPrefix.String() + "totp:" + tenantKey(ctx, userIdentifier)
+}
+
+// genCounterKey ...
+func (s *redisMFAStore) genCounterKey(ctx context.Context, userIdentifier string) string {
+	return s.keyPrefix.String() + "counter:" + tenantKey(ctx, userIdentifier)
+}
+
+// genRecoveryKey ...
+func (s *redisMFAStore) genRecoveryKey(ctx context.Context, userIdentifier string) string {
+	return s.keyPrefix.String() + "recovery:" + tenantKey(ctx, userIdentifier)
+}
+
+// SaveTOTP ...
+func (s *redisMFAStore) SaveTOTP(ctx context.Context, userIdentifier string, credential *TOTPCredential) error {
+	value, err := credential.EncodeToString()
+	if err != nil {
+		return err
+	}
+	return s.redisCC.Set(ctx, s.genTOTPKey(ctx, userIdentifier), value, 0).Err()
+}
+
+// GetTOTP ...
+func (s *redisMFAStore) GetTOTP(ctx context.Context, userIdentifier string) (*TOTPCredential, bool, error) {
+	value, err := s.redisCC.Get(ctx, s.genTOTPKey(ctx, userIdentifier)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, true, nil
+		}
+		return nil, false, err
+	}
+	credential := &TOTPCredential{}
+	if err = credential.DecodeString(value); err != nil {
+		return nil, false, err
+	}
+	return credential, false, nil
+}
+
+// DeleteTOTP ...
+func (s *redisMFAStore) DeleteTOTP(ctx context.Context, userIdentifier string) error {
+	return s.redisCC.Del(ctx,
+		s.genTOTPKey(ctx, userIdentifier),
+		s.genCounterKey(ctx, userIdentifier),
+		s.genRecoveryKey(ctx, userIdentifier),
+	).Err()
+}
+
+// UseTOTPCounter 比较与记录在同一个脚本中完成
+func (s *redisMFAStore) UseTOTPCounter(ctx context.Context, userIdentifier string, counter uint64, ttl time.Duration) (bool, error) {
+	key := s.genCounterKey(ctx, userIdentifier)
+	res, err := useTOTPCounterScript.Run(ctx, s.redisCC, []string{key}, counter, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// SaveRecoveryCodes ...
+func (s *redisMFAStore) SaveRecoveryCodes(ctx context.Context, userIdentifier string, codeHashes []string) error {
+	key := s.genRecoveryKey(ctx, userIdentifier)
+	members := make([]interface{}, len(codeHashes))
+	for i := range codeHashes {
+		members[i] = codeHashes[i]
+	}
+	_, err := s.redisCC.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		if len(members) > 0 {
+			pipe.SAdd(ctx, key, members...)
+		}
+		return nil
+	})
+	return err
+}
+
+// UseRecoveryCode ...
+func (s *redisMFAStore) UseRecoveryCode(ctx context.Context, userIdentifier string, codeHash string) (bool, error) {
+	n, err := s.redisCC.SRem(ctx, s.genRecoveryKey(ctx, userIdentifier), codeHash).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// CountRecoveryCodes ...
+func (s *redisMFAStore) CountRecoveryCodes(ctx context.Context, userIdentifier string) (int64, error) {
+	return s.redisCC.SCard(ctx, s.genRecoveryKey(ctx, userIdentifier)).Result()
+}
+
+// memoryMFACounter 已使用的步数
+type memoryMFACounter struct {
+	counter  uint64
+	expireAt time.Time
+}
+
+// memoryMFAStore ...
+type memoryMFAStore struct {
+	mu            sync.Mutex
+	credentials   map[string]*TOTPCredential
+	counters      map[string]*memoryMFACounter
+	recoveryCodes map[string]map[string]struct{}
+	now           func() time.Time
+}
+
+// NewMemoryMFAStore 基于进程内存的多因素认证存储；适用于单节点部署与测试
+func NewMemoryMFAStore() MFAStore {
+	return newMemoryMFAStore(time.Now)
+}
+
+// newMemoryMFAStore ...
+func newMemoryMFAStore(now func() time.Time) *memoryMFAStore {
+	return &memoryMFAStore{
+		credentials:   make(map[string]*TOTPCredential),
+		counters:      make(map[string]*memoryMFACounter),
+		recoveryCodes: make(map[string]map[string]struct{}),
+		now:           now,
+	}
+}
+
+// SaveTOTP ...
+func (s *memoryMFAStore) SaveTOTP(ctx context.Context, userIdentifier string, credential *TOTPCredential) error {
+	c := *credential
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.credentials[tenantKey(ctx, userIdentifier)] = &c
+	return nil
+}
+
+// GetTOTP ...
+func (s *memoryMFAStore) GetTOTP(ctx context.Context, userIdentifier string) (*TOTPCredential, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	credential, ok := s.credentials[tenantKey(ctx, userIdentifier)]
+	if !ok {
+		return nil, true, nil
+	}
+	c := *credential
+	return &c, false, nil
+}
+
+// DeleteTOTP ...
+func (s *memoryMFAStore) DeleteTOTP(ctx context.Context, userIdentifier string) error {
+	key := tenantKey(ctx, userIdentifier)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.credentials, key)
+	delete(s.counters, key)
+	delete(s.recoveryCodes, key)
+	return nil
+}
+
+// UseTOTPCounter ...
+func (s *memoryMFAStore) UseTOTPCounter(ctx context.Context, userIdentifier string, counter uint64, ttl time.Duration) (bool, error) {
+	var (
+		key = tenantKey(ctx, userIdentifier)
+		now = s.now()
+	)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.counters[key]; ok && now.Before(last.expireAt) && counter <= last.counter {
+		return false, nil
+	}
+	s.counters[key] = &memoryMFACounter{counter: counter, expireAt: now.Add(ttl)}
+	return true, nil
+}
+
+// SaveRecoveryCodes ...
+func (s *memoryMFAStore) SaveRecoveryCodes(ctx context.Context, userIdentifier string, codeHashes []string) error {
+	codes := make(map[string]struct{}, len(codeHashes))
+	for i := range codeHashes {
+		codes[codeHashes[i]] = struct{}{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recoveryCodes[tenantKey(ctx, userIdentifier)] = codes
+	return nil
+}
+
+// UseRecoveryCode ...
+func (s *memoryMFAStore) UseRecoveryCode(ctx context.Context, userIdentifier string, codeHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	codes := s.recoveryCodes[tenantKey(ctx, userIdentifier)]
+	if _, ok := codes[codeHash]; !ok {
+		return false, nil
+	}
+	delete(codes, codeHash)
+	return true, nil
+}
+
+// CountRecoveryCodes ...
+func (s *memoryMFAStore) CountRecoveryCodes(ctx context.Context, userIdentifier string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.recoveryCodes[tenantKey(ctx, userIdentifier)])), nil
+}
@@ -0,0 +1,222 @@
+package authpkg
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	totppkg "github.com/eden-quan/go-kratos-pkg/totp"
+)
+
+// go test -v -count=1 ./auth -test.run=TestMFARepo
+func TestMFARepo(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisCC := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer func() { _ = redisCC.Close() }()
+
+	stores := map[string]func(now func() time.Time) MFAStore{
+		"redis": func(now func() time.Time) MFAStore {
+			mr.FlushAll()
+			return NewRedisMFAStore(redisCC, "")
+		},
+		"memory": func(now func() time.Time) MFAStore {
+			return newMemoryMFAStore(now)
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			var (
+				ctx    = context.Background()
+				userID = "1"
+				clock  = time.Unix(1700000000, 0)
+				now    = func() time.Time { return clock }
+			)
+			repo, err := newMFARepo(newStore(now), log.DefaultLogger, MFAConfig{
+				Issuer:    "Example",
+				SecretKey: "1234567890ABCDEF",
+			}, now)
+			require.Nil(t, err)
+
+			// 未绑定
+			err = repo.VerifyTOTP(ctx, userID, "123456")
+			require.True(t, Is(err, ErrMFANotEnrolled()))
+			enrolled, err := repo.IsTOTPEnrolled(ctx, userID)
+			require.Nil(t, err)
+			require.False(t, enrolled)
+
+			// 绑定
+			enrollment, err := repo.EnrollTOTP(ctx, userID, "alice")
+			require.Nil(t, err)
+			require.NotEmpty(t, enrollment.Secret)
+			require.Contains(t, enrollment.URI, "otpauth://totp/Example:alice?")
+			require.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+			err = repo.VerifyTOTP(ctx, userID, "123456")
+			require.True(t, Is(err, ErrMFANotEnrolled()), "未确认")
+
+			// 密钥加密：独立于刷新令牌的加密
+			credential, _, err := repo.store.GetTOTP(ctx, userID)
+			require.Nil(t, err)
+			require.NotEqual(t, enrollment.Secret, credential.Secret)
+			secret, err := DefaultTOTPSecretCrypto().DecryptToString(credential.Secret, "1234567890ABCDEF")
+			require.Nil(t, err)
+			require.Equal(t, enrollment.Secret, secret)
+			_, err = DefaultRefreshCrypto().DecryptToString(credential.Secret, "1234567890ABCDEF")
+			require.NotNil(t, err)
+
+			// 确认：返回恢复码
+			_, err = repo.ConfirmTOTP(ctx, userID, "000000")
+			require.True(t, Is(err, ErrMFACodeInvalid()))
+			code, err := totppkg.GenerateCode(enrollment.Secret, clock)
+			require.Nil(t, err)
+			recoveryCodes, err := repo.ConfirmTOTP(ctx, userID, code)
+			require.Nil(t, err)
+			require.Len(t, recoveryCodes, DefaultRecoveryCodeCount)
+			enrolled, err = repo.IsTOTPEnrolled(ctx, userID)
+			require.Nil(t, err)
+			require.True(t, enrolled)
+			_, err = repo.EnrollTOTP(ctx, userID, "alice")
+			require.True(t, Is(err, ErrMFAAlreadyEnrolled()))
+
+			// 重复确认：不消耗验证码
+			nextCode, err := totppkg.GenerateCode(enrollment.Secret, clock.Add(totppkg.DefaultPeriod))
+			require.Nil(t, err)
+			_, err = repo.ConfirmTOTP(ctx, userID, nextCode)
+			require.True(t, Is(err, ErrMFAAlreadyEnrolled()))
+			require.Equal(t, int32(409), errors.FromError(err).Code)
+			require.Nil(t, repo.VerifyTOTP(ctx, userID, nextCode))
+
+			// 重放：同一步数或更早的步数
+			err = repo.VerifyTOTP(ctx, userID, code)
+			require.True(t, Is(err, ErrMFACodeInvalid()))
+			clock = clock.Add(totppkg.DefaultPeriod)
+			code, err = totppkg.GenerateCode(enrollment.Secret, clock.Add(-totppkg.DefaultPeriod))
+			require.Nil(t, err)
+			err = repo.VerifyTOTP(ctx, userID, code)
+			require.True(t, Is(err, ErrMFACodeInvalid()))
+
+			// 时间偏差
+			code, err = totppkg.GenerateCode(enrollment.Secret, clock.Add(totppkg.DefaultPeriod))
+			require.Nil(t, err)
+			require.Nil(t, repo.VerifyTOTP(ctx, userID, code))
+			clock = clock.Add(totppkg.DefaultPeriod * 3)
+			code, err = totppkg.GenerateCode(enrollment.Secret, clock.Add(-totppkg.DefaultPeriod*2))
+			require.Nil(t, err)
+			require.True(t, Is(repo.VerifyTOTP(ctx, userID, code), ErrMFACodeInvalid()))
+
+			// 恢复码：只能使用一次；忽略大小写与分隔符
+			require.Nil(t, repo.VerifyRecoveryCode(ctx, userID, recoveryCodes[0]))
+			require.True(t, Is(repo.VerifyRecoveryCode(ctx, userID, recoveryCodes[0]), ErrMFACodeInvalid()))
+			require.Nil(t, repo.VerifyRecoveryCode(ctx, userID, " "+strings.ToUpper(recoveryCodes[1])))
+			require.True(t, Is(repo.VerifyRecoveryCode(ctx, userID, "invalid"), ErrMFACodeInvalid()))
+
+			// 重新生成：旧的恢复码失效
+			newRecoveryCodes, err := repo.RegenerateRecoveryCodes(ctx, userID)
+			require.Nil(t, err)
+			require.True(t, Is(repo.VerifyRecoveryCode(ctx, userID, recoveryCodes[2]), ErrMFACodeInvalid()))
+			require.Nil(t, repo.VerifyRecoveryCode(ctx, userID, newRecoveryCodes[0]))
+			count, err := repo.CountRecoveryCodes(ctx, userID)
+			require.Nil(t, err)
+			require.Equal(t, int64(DefaultRecoveryCodeCount-1), count)
+
+			// 租户隔离
+			err = repo.VerifyTOTP(PutTenantIntoContext(ctx, "tenant-1"), userID, code)
+			require.True(t, Is(err, ErrMFANotEnrolled()))
+
+			// 解绑
+			require.Nil(t, repo.DisableTOTP(ctx, userID))
+			err = repo.VerifyTOTP(ctx, userID, code)
+			require.True(t, Is(err, ErrMFANotEnrolled()))
+			require.True(t, Is(repo.VerifyRecoveryCode(ctx, userID, newRecoveryCodes[1]), ErrMFACodeInvalid()))
+		})
+	}
+}
+
+// go test -v -count=1 ./auth -test.run=TestStepUp
+func TestStepUp(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		repo, err = NewAuthRepo(newMemoryTokenManger(time.Now), log.DefaultLogger, Config{SignKey: "1234567890ABCDEF"})
+		operation = "/api.user.v1.User/ChangePhone"
+		request   = func(op, token string) (*Claims, error) {
+			var authClaims *Claims
+			tr := newTestTransport(op)
+			tr.reqHeader.Set(AuthorizationKey, token)
+			_, err := middleware.Chain(
+				Server(
+					repo.JWTSigningKeyFunc,
+					WithSigningMethod(repo.JWTSigningMethod()),
+					WithClaims(repo.JWTSigningClaims),
+					WithTokenValidator(repo.VerifyToken),
+				),
+				StepUp(&StepUpRule{Operation: operation, MaxAge: time.Minute}),
+			)(func(ctx context.Context, req interface{}) (interface{}, error) {
+				authClaims, _ = GetAuthClaimsFromContext(ctx)
+				return nil, nil
+			})(transport.NewServerContext(ctx, tr), nil)
+			return authClaims, err
+		}
+	)
+	require.Nil(t, err)
+
+	claims := DefaultClaims(Payload{UserID: 1})
+	claims.AMR = []string{AMRPassword}
+	res, _, err := repo.SignToken(ctx, claims)
+	require.Nil(t, err)
+
+	// 单因素认证：其他操作不受影响
+	authClaims, err := request("/api.user.v1.User/Get", res.AccessToken)
+	require.Nil(t, err)
+	require.Equal(t, []string{AMRPassword}, authClaims.AMR)
+	require.NotNil(t, authClaims.AuthTime)
+	require.False(t, authClaims.IsMultiFactor())
+	_, err = request(operation, res.AccessToken)
+	require.True(t, Is(err, ErrMFARequired()))
+	require.Equal(t, ACRMultiFactor, errors.FromError(err).Metadata[MFAACRKey])
+	require.Equal(t, "60", errors.FromError(err).Metadata[MFAMaxAgeKey])
+
+	// 多因素认证：旧令牌失效
+	stepUp, err := repo.StepUpToken(ctx, authClaims, AMROTP)
+	require.Nil(t, err)
+	_, err = request(operation, res.AccessToken)
+	require.True(t, Is(err, ErrBlacklist()))
+	authClaims, err = request(operation, stepUp.AccessToken)
+	require.Nil(t, err)
+	require.Equal(t, []string{AMRPassword, AMROTP, AMRMultiFactor}, authClaims.AMR)
+	require.Equal(t, ACRMultiFactor, authClaims.ACR)
+	require.True(t, authClaims.IsMultiFactor())
+
+	// 刷新：保留认证方式与认证时间
+	refreshed, _, err := repo.RefreshToken(ctx, stepUp.RefreshToken)
+	require.Nil(t, err)
+	refreshedClaims, err := request("/api.user.v1.User/Get", refreshed.AccessToken)
+	require.Nil(t, err)
+	require.Equal(t, authClaims.AMR, refreshedClaims.AMR)
+	require.Equal(t, authClaims.ACR, refreshedClaims.ACR)
+	require.Equal(t, authClaims.AuthTime.Unix(), refreshedClaims.AuthTime.Unix())
+
+	// 超出有效时间
+	claims = DefaultClaims(Payload{UserID: 2})
+	claims.AMR = []string{AMRPassword, AMROTP, AMRMultiFactor}
+	claims.ACR = ACRMultiFactor
+	claims.AuthTime = jwt.NewNumericDate(time.Now().Add(-time.Minute * 2))
+	res, _, err = repo.SignToken(ctx, claims)
+	require.Nil(t, err)
+	_, err = request(operation, res.AccessToken)
+	require.True(t, Is(err, ErrMFARequired()))
+
+	// 缺少令牌信息
+	_, err = StepUp(&StepUpRule{Operation: operation})(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})(transport.NewServerContext(ctx, newTestTransport(operation)), nil)
+	require.True(t, Is(err, ErrMissingToken()))
+}
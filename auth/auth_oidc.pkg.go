@@ -0,0 +1,507 @@
+package authpkg
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	stdhttp "net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/golang-jwt/jwt/v4"
+
+	errorpkg "github.com/eden-quan/go-kratos-pkg/error"
+	headerpkg "github.com/eden-quan/go-kratos-pkg/header"
+	threadpkg "github.com/eden-quan/go-kratos-pkg/thread"
+)
+
+const (
+	// OIDCDiscoveryPath 发现文档的路由
+	OIDCDiscoveryPath = "/.well-known/openid-configuration"
+	// DefaultOIDCJWKSRefreshInterval 公钥集的缓存时间
+	DefaultOIDCJWKSRefreshInterval = time.Hour
+	// DefaultOIDCJWKSMinRefreshInterval 未知密钥id时重新获取公钥集的最小间隔
+	DefaultOIDCJWKSMinRefreshInterval = time.Minute
+	// DefaultOIDCHTTPTimeout 请求身份提供方的超时时间
+	DefaultOIDCHTTPTimeout = time.Second * 10
+)
+
+// defaultOIDCSigningAlgorithms 发现文档未声明签名算法时的默认值
+var defaultOIDCSigningAlgorithms = []string{"RS256"}
+
+// OIDCDiscovery OpenID Connect 发现文档
+type OIDCDiscovery struct {
+	Issuer                           string   `json:"issuer"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                    string   `json:"token_endpoint,omitempty"`
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint,omitempty"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported,omitempty"`
+}
+
+// OIDCClaimsMapper 身份提供方的声明转换为 Payload
+type OIDCClaimsMapper func(claims jwt.MapClaims) (*Payload, error)
+
+// OIDCConfig 外部身份提供方
+type OIDCConfig struct {
+	// Issuer 身份提供方；发现文档：Issuer + OIDCDiscoveryPath，令牌的 iss 必须与其相同
+	Issuer string
+	// Audiences 接受的 aud；例：客户端id；令牌的 aud 包含其中之一
+	Audiences []string
+	// SigningAlgorithms 接受的签名算法；默认：发现文档的 id_token_signing_alg_values_supported，否则为 RS256
+	// 不接受 none 与 HMAC
+	SigningAlgorithms []string
+	// ClaimsMapper 声明转换；默认：DefaultOIDCClaimsMapper
+	ClaimsMapper OIDCClaimsMapper
+	// HTTPClient 请求身份提供方；默认超时 DefaultOIDCHTTPTimeout
+	HTTPClient *stdhttp.Client
+	// JWKSRefreshInterval 公钥集的缓存时间；默认 DefaultOIDCJWKSRefreshInterval
+	JWKSRefreshInterval time.Duration
+	// JWKSMinRefreshInterval 未知密钥id时重新获取公钥集的最小间隔；默认 DefaultOIDCJWKSMinRefreshInterval
+	JWKSMinRefreshInterval time.Duration
+}
+
+// OIDCProvider 验证外部身份提供方签发的 access token 或 id token
+// 与本地令牌一起使用：Server(repo.JWTSigningKeyFunc, WithOIDCProviders(provider))
+type OIDCProvider interface {
+	// Issuer 身份提供方
+	Issuer() string
+	// Discovery 发现文档
+	Discovery() *OIDCDiscovery
+	// ParseToken 验证令牌；令牌的 Claims 为 *Claims，Payload 由 ClaimsMapper 转换
+	ParseToken(ctx context.Context, tokenString string) (*jwt.Token, error)
+}
+
+// oidcProvider ...
+type oidcProvider struct {
+	config     OIDCConfig
+	discovery  *OIDCDiscovery
+	algorithms []string
+	logHandler *log.Helper
+	now        func() time.Time
+
+	// keySet 公钥集；获取后整体替换，验证令牌时不加锁
+	keySet atomic.Pointer[oidcKeySet]
+
+	// mu 保护 checkedAt 与 refreshing
+	mu         sync.Mutex
+	checkedAt  time.Time
+	refreshing *oidcRefreshCall
+}
+
+// oidcKeySet 公钥集
+type oidcKeySet struct {
+	keys      map[string]*oidcKey
+	fetchedAt time.Time
+}
+
+// oidcRefreshCall 进行中的获取公钥集
+type oidcRefreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// oidcKey 公钥集中的公钥
+type oidcKey struct {
+	jwk       *JSONWebKey
+	publicKey crypto.PublicKey
+}
+
+// NewOIDCProvider 获取发现文档与公钥集；公钥集过期或出现未知的密钥id时自动刷新
+func NewOIDCProvider(ctx context.Context, config OIDCConfig, logger log.Logger) (OIDCProvider, error) {
+	return newOIDCProvider(ctx, config, logger, time.Now)
+}
+
+// newOIDCProvider ...
+func newOIDCProvider(ctx context.Context, config OIDCConfig, logger log.Logger, now func() time.Time) (*oidcProvider, error) {
+	if config.Issuer == "" {
+		return nil, fmt.Errorf("oidc issuer is empty")
+	}
+	if len(config.Audiences) == 0 {
+		return nil, fmt.Errorf("oidc audiences is empty")
+	}
+	if config.ClaimsMapper == nil {
+		config.ClaimsMapper = DefaultOIDCClaimsMapper
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &stdhttp.Client{Timeout: DefaultOIDCHTTPTimeout}
+	}
+	if config.JWKSRefreshInterval <= 0 {
+		config.JWKSRefreshInterval = DefaultOIDCJWKSRefreshInterval
+	}
+	if config.JWKSMinRefreshInterval <= 0 {
+		config.JWKSMinRefreshInterval = DefaultOIDCJWKSMinRefreshInterval
+	}
+	s := &oidcProvider{
+		config:     config,
+		logHandler: log.NewHelper(log.With(logger, "module", "auth/oidc")),
+		now:        now,
+	}
+
+	discovery := &OIDCDiscovery{}
+	if err := s.getJSON(ctx, strings.TrimSuffix(config.Issuer, "/")+OIDCDiscoveryPath, discovery); err != nil {
+		return nil, fmt.Errorf("get oidc discovery failed : %w", err)
+	}
+	if discovery.Issuer != config.Issuer {
+		return nil, fmt.Errorf("oidc issuer mismatch: expected %s, got %s", config.Issuer, discovery.Issuer)
+	}
+	if discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc jwks_uri is empty")
+	}
+	s.discovery = discovery
+
+	algorithms := config.SigningAlgorithms
+	if len(algorithms) == 0 {
+		algorithms = discovery.IDTokenSigningAlgValuesSupported
+	}
+	if len(algorithms) == 0 {
+		algorithms = defaultOIDCSigningAlgorithms
+	}
+	for _, alg := range algorithms {
+		if _, ok := jwt.GetSigningMethod(alg).(*jwt.SigningMethodHMAC); ok || alg == "none" {
+			continue
+		}
+		s.algorithms = append(s.algorithms, alg)
+	}
+	if len(s.algorithms) == 0 {
+		return nil, fmt.Errorf("oidc signing algorithms are not supported: %v", algorithms)
+	}
+
+	s.checkedAt = now()
+	if err := s.fetchKeys(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Issuer ...
+func (s *oidcProvider) Issuer() string {
+	return s.config.Issuer
+}
+
+// Discovery ...
+func (s *oidcProvider) Discovery() *OIDCDiscovery {
+	return s.discovery
+}
+
+// ParseToken ...
+func (s *oidcProvider) ParseToken(ctx context.Context, tokenString string) (*jwt.Token, error) {
+	mapClaims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(s.algorithms))
+	tokenInfo, err := parser.ParseWithClaims(tokenString, mapClaims, func(token *jwt.Token) (interface{}, error) {
+		return s.publicKey(ctx, token)
+	})
+	if err != nil {
+		return nil, parseTokenError(err)
+	}
+	if !mapClaims.VerifyIssuer(s.config.Issuer, true) {
+		e := ErrOIDCTokenInvalid()
+		e.Metadata = map[string]string{"error": "issuer mismatch"}
+		return nil, errorpkg.WithStack(e)
+	}
+	if !s.verifyAudience(mapClaims) {
+		e := ErrOIDCTokenInvalid()
+		e.Metadata = map[string]string{"error": "audience mismatch"}
+		return nil, errorpkg.WithStack(e)
+	}
+	// 解析时只在存在 exp 时校验；身份提供方的令牌必须有过期时间
+	if !mapClaims.VerifyExpiresAt(jwt.TimeFunc().Unix(), true) {
+		e := ErrOIDCTokenInvalid()
+		e.Metadata = map[string]string{"error": "exp is required"}
+		return nil, errorpkg.WithStack(e)
+	}
+
+	payload, err := s.config.ClaimsMapper(mapClaims)
+	if err != nil {
+		e := ErrOIDCTokenInvalid()
+		e.Metadata = map[string]string{"error": err.Error()}
+		return nil, errorpkg.WithStack(e)
+	}
+	claims := &Claims{Payload: payload}
+	if err = convertMapClaims(mapClaims, &claims.RegisteredClaims); err != nil {
+		e := ErrOIDCTokenInvalid()
+		e.Metadata = map[string]string{"error": err.Error()}
+		return nil, errorpkg.WithStack(e)
+	}
+	// 认证方式与强度：身份提供方的多因素认证同样满足 StepUp
+	claims.AMR = OIDCClaimStrings(mapClaims, "amr")
+	claims.ACR, _ = mapClaims["acr"].(string)
+	if authTime, ok := mapClaims["auth_time"].(float64); ok {
+		claims.AuthTime = jwt.NewNumericDate(time.Unix(int64(authTime), 0))
+	}
+	tokenInfo.Claims = claims
+	return tokenInfo, nil
+}
+
+// verifyAudience 令牌的 aud 包含其中之一
+func (s *oidcProvider) verifyAudience(claims jwt.MapClaims) bool {
+	for _, audience := range s.config.Audiences {
+		if claims.VerifyAudience(audience, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// publicKey 根据令牌的密钥id查找公钥
+// 公钥集过期时在后台重新获取，期间使用缓存的公钥集；密钥id未知时(身份提供方轮换了密钥)等待重新获取
+// 两次获取的间隔不小于 JWKSMinRefreshInterval
+func (s *oidcProvider) publicKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	keyID, _ := token.Header["kid"].(string)
+
+	keySet := s.keySet.Load()
+	if s.now().Sub(keySet.fetchedAt) >= s.config.JWKSRefreshInterval {
+		s.refreshKeys(ctx)
+	}
+	key, ok := keySet.lookup(keyID)
+	if !ok {
+		if call := s.refreshKeys(ctx); call != nil {
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if call.err != nil {
+				return nil, call.err
+			}
+			key, ok = s.keySet.Load().lookup(keyID)
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("oidc signing key not found: %s", keyID)
+	}
+	if key.jwk.Algorithm != "" && key.jwk.Algorithm != token.Method.Alg() {
+		return nil, fmt.Errorf("oidc signing key(%s) algorithm mismatch: %s", keyID, token.Method.Alg())
+	}
+	return key.publicKey, nil
+}
+
+// lookup 令牌没有密钥id时，公钥集必须只有一个公钥
+func (s *oidcKeySet) lookup(keyID string) (*oidcKey, bool) {
+	if keyID == "" {
+		if len(s.keys) != 1 {
+			return nil, false
+		}
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[keyID]
+	return key, ok
+}
+
+// refreshKeys 在后台获取公钥集；同一时间只有一个获取，返回进行中的获取
+// 距离上次获取不足 JWKSMinRefreshInterval 时不获取，返回nil
+// 获取不随请求取消；超时由 HTTPClient 控制
+func (s *oidcProvider) refreshKeys(ctx context.Context) *oidcRefreshCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refreshing != nil {
+		return s.refreshing
+	}
+	if s.now().Sub(s.checkedAt) < s.config.JWKSMinRefreshInterval {
+		return nil
+	}
+	s.checkedAt = s.now()
+	call := &oidcRefreshCall{done: make(chan struct{})}
+	s.refreshing = call
+	fetchCtx := context.WithoutCancel(ctx)
+	threadpkg.GoSafe(func() {
+		defer func() {
+			s.mu.Lock()
+			s.refreshing = nil
+			s.mu.Unlock()
+			close(call.done)
+		}()
+		// 获取失败时继续使用缓存的公钥集
+		if call.err = s.fetchKeys(fetchCtx); call.err != nil {
+			s.logHandler.WithContext(fetchCtx).Warnw("msg", "refresh oidc jwks failed", "issuer", s.config.Issuer, "err", call.err)
+		}
+	})
+	return call
+}
+
+// fetchKeys 获取公钥集；忽略无法解析的公钥与非签名用途的公钥
+func (s *oidcProvider) fetchKeys(ctx context.Context) error {
+	fetchedAt := s.now()
+	jwks := &JSONWebKeySet{}
+	if err := s.getJSON(ctx, s.discovery.JWKSURI, jwks); err != nil {
+		return fmt.Errorf("get oidc jwks failed : %w", err)
+	}
+	keys := make(map[string]*oidcKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk == nil || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		publicKey, err := jwk.PublicKey()
+		if err != nil {
+			s.logHandler.WithContext(ctx).Warnw("msg", "ignore oidc jwk", "issuer", s.config.Issuer, "kid", jwk.KeyID, "err", err)
+			continue
+		}
+		keys[jwk.KeyID] = &oidcKey{jwk: jwk, publicKey: publicKey}
+	}
+	s.keySet.Store(&oidcKeySet{keys: keys, fetchedAt: fetchedAt})
+	return nil
+}
+
+// getJSON ...
+func (s *oidcProvider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := stdhttp.NewRequestWithContext(ctx, stdhttp.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", headerpkg.ContentTypeJSON)
+	resp, err := s.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != stdhttp.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// DefaultOIDCClaimsMapper 默认的声明转换
+// OIDCSubject(iss, sub) => UserUuid；scope(或 scp) => Scopes
+// 不设置 TenantID：身份提供方的租户不一定是本地的租户；需要时使用 NewOIDCTenantClaimsMapper
+// 不设置 Roles：身份提供方的角色与组不一定是本地的角色；需要时使用 NewOIDCRoleClaimsMapper
+func DefaultOIDCClaimsMapper(claims jwt.MapClaims) (*Payload, error) {
+	issuer, _ := claims["iss"].(string)
+	if issuer == "" {
+		return nil, fmt.Errorf("oidc claim iss is empty")
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("oidc claim sub is empty")
+	}
+	payload := &Payload{
+		UserUuid:  OIDCSubject(issuer, subject),
+		TokenType: TokenTypeEnum_USER,
+		Scopes:    OIDCClaimStrings(claims, "scope"),
+	}
+	if len(payload.Scopes) == 0 {
+		payload.Scopes = OIDCClaimStrings(claims, "scp")
+	}
+	return payload, nil
+}
+
+// OIDCSubject 以身份提供方为命名空间的用户标识：iss#sub
+// 不同身份提供方的 sub 可能相同；issuer 不包含 fragment，# 不会产生歧义
+func OIDCSubject(issuer, subject string) string {
+	return issuer + "#" + subject
+}
+
+// NewOIDCTenantClaimsMapper 在 DefaultOIDCClaimsMapper 的基础上转换租户
+// tenants 身份提供方的租户(tid) => 本地的租户；不在其中的租户返回错误
+func NewOIDCTenantClaimsMapper(tenants map[string]string) OIDCClaimsMapper {
+	return func(claims jwt.MapClaims) (*Payload, error) {
+		payload, err := DefaultOIDCClaimsMapper(claims)
+		if err != nil {
+			return nil, err
+		}
+		tid, _ := claims["tid"].(string)
+		tenantID, ok := tenants[tid]
+		if tid == "" || !ok || tenantID == "" {
+			return nil, fmt.Errorf("oidc tenant is not allowed: %q", tid)
+		}
+		payload.TenantID = tenantID
+		return payload, nil
+	}
+}
+
+// NewOIDCRoleClaimsMapper 在 mapper 的基础上转换角色；mapper 为空时使用 DefaultOIDCClaimsMapper
+// roles 身份提供方的角色或组(roles、groups) => 本地的角色；不在其中的角色忽略
+func NewOIDCRoleClaimsMapper(mapper OIDCClaimsMapper, roles map[string]string) OIDCClaimsMapper {
+	if mapper == nil {
+		mapper = DefaultOIDCClaimsMapper
+	}
+	return func(claims jwt.MapClaims) (*Payload, error) {
+		payload, err := mapper(claims)
+		if err != nil {
+			return nil, err
+		}
+		payload.Roles = nil
+		seen := make(map[string]struct{})
+		for _, name := range []string{"roles", "groups"} {
+			for _, idpRole := range OIDCClaimStrings(claims, name) {
+				role, ok := roles[idpRole]
+				if !ok || role == "" {
+					continue
+				}
+				if _, ok = seen[role]; ok {
+					continue
+				}
+				seen[role] = struct{}{}
+				payload.Roles = append(payload.Roles, role)
+			}
+		}
+		return payload, nil
+	}
+}
+
+// OIDCClaimStrings 字符串数组或以空格分隔的字符串
+func OIDCClaimStrings(claims jwt.MapClaims, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return strings.Fields(value)
+	case []string:
+		return value
+	case []interface{}:
+		res := make([]string, 0, len(value))
+		for i := range value {
+			if s, ok := value[i].(string); ok && s != "" {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+// convertMapClaims ...
+func convertMapClaims(mapClaims jwt.MapClaims, v interface{}) error {
+	body, err := json.Marshal(mapClaims)
+	if err != nil {
+		return fmt.Errorf("encode oidc claims failed : %w", err)
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decode oidc claims failed : %w", err)
+	}
+	return nil
+}
+
+// oidcProviderOf 根据令牌的 iss 选择身份提供方；未匹配时为本地令牌
+func oidcProviderOf(providers []OIDCProvider, tokenString string) OIDCProvider {
+	if len(providers) == 0 {
+		return nil
+	}
+	mapClaims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, mapClaims); err != nil {
+		return nil
+	}
+	issuer, _ := mapClaims["iss"].(string)
+	return oidcProviderOfIssuer(providers, issuer)
+}
+
+// oidcProviderOfIssuer ...
+func oidcProviderOfIssuer(providers []OIDCProvider, issuer string) OIDCProvider {
+	if issuer == "" {
+		return nil
+	}
+	for _, provider := range providers {
+		if provider.Issuer() == issuer {
+			return provider
+		}
+	}
+	return nil
+}
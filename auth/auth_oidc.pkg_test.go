@@ -0,0 +1,364 @@
+package authpkg
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// testIdP 身份提供方
+type testIdP struct {
+	t      *testing.T
+	server *httptest.Server
+	// jwksRequests 获取公钥集的次数
+	jwksRequests int32
+	// unavailable 公钥集不可用
+	unavailable int32
+	// blocked 获取公钥集的请求阻塞至关闭
+	blocked atomic.Pointer[chan struct{}]
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PrivateKey
+}
+
+// newTestIdP ...
+func newTestIdP(t *testing.T) *testIdP {
+	idp := &testIdP{t: t, keys: make(map[string]*rsa.PrivateKey)}
+	mux := stdhttp.NewServeMux()
+	mux.HandleFunc(OIDCDiscoveryPath, func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		_ = json.NewEncoder(w).Encode(&OIDCDiscovery{
+			Issuer:                           idp.server.URL,
+			JWKSURI:                          idp.server.URL + JWKSPath,
+			IDTokenSigningAlgValuesSupported: []string{"RS256"},
+		})
+	})
+	mux.Handle(JWKSPath, JWKSHandler(func() (*JSONWebKeySet, error) {
+		atomic.AddInt32(&idp.jwksRequests, 1)
+		if blocked := idp.blocked.Load(); blocked != nil {
+			<-*blocked
+		}
+		if atomic.LoadInt32(&idp.unavailable) == 1 {
+			return nil, errors.New("unavailable")
+		}
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		jwks := &JSONWebKeySet{}
+		for keyID, key := range idp.keys {
+			jwk, err := NewJSONWebKey(keyID, "RS256", &key.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+		return jwks, nil
+	}))
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	idp.rotate("1")
+	return idp
+}
+
+// rotate 新增签名密钥
+func (s *testIdP) rotate(keyID string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(s.t, err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[keyID] = key
+}
+
+// sign 签发令牌
+func (s *testIdP) sign(keyID string, claims jwt.MapClaims) string {
+	s.mu.Lock()
+	key := s.keys[keyID]
+	s.mu.Unlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	res, err := token.SignedString(key)
+	require.Nil(s.t, err)
+	return res
+}
+
+// claims ...
+func (s *testIdP) claims(audience interface{}) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":   s.server.URL,
+		"sub":   "user-1",
+		"aud":   audience,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"roles": []string{"admin"},
+		"scope": "openid profile",
+	}
+}
+
+// go test -v -count=1 ./auth -test.run=TestOIDCProvider
+func TestOIDCProvider(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		idp     = newTestIdP(t)
+		mu      sync.Mutex
+		clock   = time.Now()
+		advance = func(d time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			clock = clock.Add(d)
+		}
+		jwksRequests = func() int32 {
+			return atomic.LoadInt32(&idp.jwksRequests)
+		}
+	)
+	provider, err := newOIDCProvider(ctx, OIDCConfig{
+		Issuer:    idp.server.URL,
+		Audiences: []string{"web", "app"},
+	}, log.DefaultLogger, func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return clock
+	})
+	require.Nil(t, err)
+	require.Equal(t, idp.server.URL+JWKSPath, provider.Discovery().JWKSURI)
+	require.Equal(t, int32(1), atomic.LoadInt32(&idp.jwksRequests))
+
+	// 声明转换
+	tokenInfo, err := provider.ParseToken(ctx, idp.sign("1", idp.claims([]string{"other", "app"})))
+	require.Nil(t, err)
+	claims := tokenInfo.Claims.(*Claims)
+	require.Equal(t, idp.server.URL, claims.Issuer)
+	require.Equal(t, "user-1", claims.Subject)
+	require.Equal(t, OIDCSubject(idp.server.URL, "user-1"), claims.Payload.UserIdentifier())
+	require.Empty(t, claims.Payload.TenantID)
+	require.Empty(t, claims.Payload.Roles)
+	require.Equal(t, []string{"openid", "profile"}, claims.Payload.Scopes)
+
+	// aud 与 iss
+	_, err = provider.ParseToken(ctx, idp.sign("1", idp.claims("other")))
+	require.True(t, Is(err, ErrOIDCTokenInvalid()))
+	other := idp.claims("web")
+	other["iss"] = "https://other.example.com"
+	_, err = provider.ParseToken(ctx, idp.sign("1", other))
+	require.True(t, Is(err, ErrOIDCTokenInvalid()))
+
+	// 过期
+	expired := idp.claims("web")
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	_, err = provider.ParseToken(ctx, idp.sign("1", expired))
+	require.True(t, Is(err, ErrTokenExpired()))
+	noExpire := idp.claims("web")
+	delete(noExpire, "exp")
+	_, err = provider.ParseToken(ctx, idp.sign("1", noExpire))
+	require.True(t, Is(err, ErrOIDCTokenInvalid()))
+
+	// 不接受 HMAC 与 none
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.claims("web"))
+	forged.Header["kid"] = "1"
+	forgedToken, err := forged.SignedString([]byte("secret"))
+	require.Nil(t, err)
+	_, err = provider.ParseToken(ctx, forgedToken)
+	require.True(t, Is(err, ErrTokenParseFail()))
+	noneToken, err := jwt.NewWithClaims(jwt.SigningMethodNone, idp.claims("web")).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.Nil(t, err)
+	_, err = provider.ParseToken(ctx, noneToken)
+	require.True(t, Is(err, ErrTokenParseFail()))
+
+	// 轮换密钥：未知的密钥id在最小间隔内不重新获取
+	idp.rotate("2")
+	_, err = provider.ParseToken(ctx, idp.sign("2", idp.claims("web")))
+	require.True(t, Is(err, ErrTokenParseFail()))
+	require.Equal(t, int32(1), jwksRequests())
+	advance(DefaultOIDCJWKSMinRefreshInterval)
+	_, err = provider.ParseToken(ctx, idp.sign("2", idp.claims("web")))
+	require.Nil(t, err)
+	require.Equal(t, int32(2), jwksRequests())
+	idp.rotate("3")
+	_, err = provider.ParseToken(ctx, idp.sign("3", idp.claims("web")))
+	require.True(t, Is(err, ErrTokenParseFail()))
+	require.Equal(t, int32(2), jwksRequests())
+
+	// 缓存过期后在后台刷新；刷新失败时使用缓存
+	advance(DefaultOIDCJWKSRefreshInterval)
+	_, err = provider.ParseToken(ctx, idp.sign("1", idp.claims("web")))
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		_, err := provider.ParseToken(ctx, idp.sign("3", idp.claims("web")))
+		return err == nil
+	}, time.Second*3, time.Millisecond*10)
+	require.Equal(t, int32(3), jwksRequests())
+	atomic.StoreInt32(&idp.unavailable, 1)
+	advance(DefaultOIDCJWKSRefreshInterval)
+	_, err = provider.ParseToken(ctx, idp.sign("1", idp.claims("web")))
+	require.Nil(t, err)
+	require.Eventually(t, func() bool { return jwksRequests() == 4 }, time.Second*3, time.Millisecond*10)
+	atomic.StoreInt32(&idp.unavailable, 0)
+
+	// 获取公钥集时不阻塞使用缓存的公钥验证；并发的未知密钥id只获取一次
+	blocked := make(chan struct{})
+	idp.blocked.Store(&blocked)
+	idp.rotate("4")
+	advance(DefaultOIDCJWKSRefreshInterval)
+	_, err = provider.ParseToken(ctx, idp.sign("1", idp.claims("web")))
+	require.Nil(t, err)
+	require.Eventually(t, func() bool { return jwksRequests() == 5 }, time.Second*3, time.Millisecond*10)
+	_, err = provider.ParseToken(ctx, idp.sign("2", idp.claims("web")))
+	require.Nil(t, err)
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+	_, err = provider.ParseToken(timeoutCtx, idp.sign("4", idp.claims("web")))
+	cancel()
+	require.NotNil(t, err)
+	var wg sync.WaitGroup
+	errCh := make(chan error, 4)
+	for i := 0; i < cap(errCh); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := provider.ParseToken(ctx, idp.sign("4", idp.claims("web")))
+			errCh <- err
+		}()
+	}
+	idp.blocked.Store(nil)
+	close(blocked)
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		require.Nil(t, err)
+	}
+	require.Equal(t, int32(5), jwksRequests())
+
+	// 发现文档的 issuer 不一致
+	_, err = NewOIDCProvider(ctx, OIDCConfig{Issuer: idp.server.URL + "/", Audiences: []string{"web"}}, log.DefaultLogger)
+	require.NotNil(t, err)
+	_, err = NewOIDCProvider(ctx, OIDCConfig{Issuer: idp.server.URL}, log.DefaultLogger)
+	require.NotNil(t, err)
+}
+
+// go test -v -count=1 ./auth -test.run=TestServer_OIDC
+func TestServer_OIDC(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		idp       = newTestIdP(t)
+		repo, err = NewAuthRepo(newMemoryTokenManger(time.Now), log.DefaultLogger, Config{
+			SignKey:   "1234567890ABCDEF",
+			Lifetimes: []*TokenLifetime{{AccessTokenExpire: time.Hour * 2, RenewWindow: time.Hour * 2}},
+		})
+	)
+	require.Nil(t, err)
+	provider, err := NewOIDCProvider(ctx, OIDCConfig{
+		Issuer:    idp.server.URL,
+		Audiences: []string{"web"},
+		ClaimsMapper: func(claims jwt.MapClaims) (*Payload, error) {
+			payload, err := DefaultOIDCClaimsMapper(claims)
+			if err != nil {
+				return nil, err
+			}
+			payload.LoginType = LoginTypeEnum_GOOGLE_OAUTH
+			return payload, nil
+		},
+	}, log.DefaultLogger)
+	require.Nil(t, err)
+
+	call := func(token string) (*testTransport, *Claims, error) {
+		tr := newTestTransport("/api.user.v1.User/Get")
+		tr.reqHeader.Set(AuthorizationKey, FormatToken(BearerWord, token))
+		var claims *Claims
+		_, err := Server(
+			repo.JWTSigningKeyFunc,
+			WithSigningMethod(repo.JWTSigningMethod()),
+			WithClaims(repo.JWTSigningClaims),
+			WithTokenValidator(repo.VerifyToken),
+			WithTokenRenewer(repo),
+			WithOIDCProviders(provider),
+		)(func(ctx context.Context, req interface{}) (interface{}, error) {
+			claims, _ = GetAuthClaimsFromContext(ctx)
+			return nil, nil
+		})(transport.NewServerContext(ctx, tr), nil)
+		return tr, claims, err
+	}
+
+	// 本地令牌
+	res, _, err := repo.SignToken(ctx, DefaultClaims(Payload{UserID: 1}))
+	require.Nil(t, err)
+	_, claims, err := call(res.AccessToken)
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), claims.Payload.UserID)
+
+	// 身份提供方的令牌：不经过本地令牌的验证与续期
+	tr, claims, err := call(idp.sign("1", idp.claims("web")))
+	require.Nil(t, err)
+	require.Equal(t, idp.server.URL+"#user-1", claims.Payload.UserUuid)
+	require.Equal(t, LoginTypeEnum_GOOGLE_OAUTH, claims.Payload.LoginType)
+	require.Empty(t, tr.replyHeader.Get(RenewAccessTokenKey))
+	_, _, err = call(idp.sign("1", idp.claims("other")))
+	require.True(t, Is(err, ErrOIDCTokenInvalid()))
+
+	// 未知的 iss 按本地令牌验证
+	unknown := idp.claims("web")
+	unknown["iss"] = "https://other.example.com"
+	_, _, err = call(idp.sign("1", unknown))
+	require.True(t, Is(err, ErrTokenParseFail()))
+}
+
+// go test -v -count=1 ./auth -test.run=TestOIDCClaimsMapper
+func TestOIDCClaimsMapper(t *testing.T) {
+	claims := jwt.MapClaims{
+		"iss": "https://idp.example.com",
+		"sub": "user-1",
+		"tid": "idp-tenant-1",
+	}
+
+	// 默认：不使用身份提供方的租户；用户标识以身份提供方为命名空间
+	payload, err := DefaultOIDCClaimsMapper(claims)
+	require.Nil(t, err)
+	require.Equal(t, "https://idp.example.com#user-1", payload.UserUuid)
+	require.Empty(t, payload.TenantID)
+	other := jwt.MapClaims{"iss": "https://other.example.com", "sub": "user-1"}
+	otherPayload, err := DefaultOIDCClaimsMapper(other)
+	require.Nil(t, err)
+	require.NotEqual(t, payload.UserIdentifier(), otherPayload.UserIdentifier())
+	_, err = DefaultOIDCClaimsMapper(jwt.MapClaims{"sub": "user-1"})
+	require.NotNil(t, err)
+	_, err = DefaultOIDCClaimsMapper(jwt.MapClaims{"iss": "https://idp.example.com"})
+	require.NotNil(t, err)
+
+	// 租户白名单
+	mapper := NewOIDCTenantClaimsMapper(map[string]string{"idp-tenant-1": "tenant-1"})
+	payload, err = mapper(claims)
+	require.Nil(t, err)
+	require.Equal(t, "tenant-1", payload.TenantID)
+	_, err = mapper(jwt.MapClaims{"iss": "https://idp.example.com", "sub": "user-1", "tid": "idp-tenant-2"})
+	require.NotNil(t, err)
+	_, err = mapper(jwt.MapClaims{"iss": "https://idp.example.com", "sub": "user-1"})
+	require.NotNil(t, err)
+
+	// 角色白名单：默认不使用身份提供方的角色
+	roleClaims := jwt.MapClaims{
+		"iss":    "https://idp.example.com",
+		"sub":    "user-1",
+		"tid":    "idp-tenant-1",
+		"roles":  []interface{}{"Admin", "Owner"},
+		"groups": "engineering admins",
+	}
+	payload, err = DefaultOIDCClaimsMapper(roleClaims)
+	require.Nil(t, err)
+	require.Empty(t, payload.Roles)
+	roleMapper := NewOIDCRoleClaimsMapper(mapper, map[string]string{"Admin": "admin", "admins": "admin", "engineering": "developer"})
+	payload, err = roleMapper(roleClaims)
+	require.Nil(t, err)
+	require.Equal(t, []string{"admin", "developer"}, payload.Roles)
+	require.Equal(t, "tenant-1", payload.TenantID)
+	payload, err = NewOIDCRoleClaimsMapper(nil, map[string]string{"Admin": "admin"})(jwt.MapClaims{"iss": "https://idp.example.com", "sub": "user-1", "roles": "Owner"})
+	require.Nil(t, err)
+	require.Empty(t, payload.Roles)
+}
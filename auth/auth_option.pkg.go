@@ -2,6 +2,7 @@ package authpkg
 
 import (
 	"context"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
@@ -18,9 +19,22 @@ type options struct {
 	claims             func() jwt.Claims
 	tokenHeader        map[string]interface{}
 	tokenValidatorFunc TokenValidateFunc
+	routeMatcher       RouteMatcher
+	tokenRenewer       TokenRenewer
+	oidcProviders      []OIDCProvider
+
+	tokenExtractors     []TokenExtractor
+	authorizationHeader string
+	authorizationScheme string
+
+	clientMode         ClientMode
+	serviceName        string
+	serviceTokenExpire time.Duration
+	serviceTokenFunc   ServiceTokenFunc
 }
 
 // WithSigningMethod with signing method option.
+// Server 校验令牌的签名方法与其属于同一类型(HMAC/RSA/RSA-PSS/ECDSA/Ed25519)
 func WithSigningMethod(method jwt.SigningMethod) Option {
 	return func(o *options) {
 		o.signingMethod = method
@@ -49,3 +63,71 @@ func WithTokenValidator(tokenValidator TokenValidateFunc) Option {
 		o.tokenValidatorFunc = tokenValidator
 	}
 }
+
+// WithRouteMatcher 根据 operation 选择认证模式；仅用于 Server
+func WithRouteMatcher(matcher RouteMatcher) Option {
+	return func(o *options) {
+		o.routeMatcher = matcher
+	}
+}
+
+// WithTokenExtractors 依次尝试提取令牌；仅用于 Server
+// 默认：Authorization 请求头，兼容带有或不带 Bearer 的令牌
+func WithTokenExtractors(extractors ...TokenExtractor) Option {
+	return func(o *options) {
+		o.tokenExtractors = extractors
+	}
+}
+
+// WithAuthorizationFormat 令牌的请求头与 scheme；仅用于 Client
+// 默认：Authorization 请求头，不带 scheme；例：WithAuthorizationFormat(AuthorizationKey, BearerWord)
+func WithAuthorizationFormat(headerKey, scheme string) Option {
+	return func(o *options) {
+		o.authorizationHeader = headerKey
+		o.authorizationScheme = scheme
+	}
+}
+
+// WithClientMode 令牌模式；仅用于 Client
+func WithClientMode(mode ClientMode) Option {
+	return func(o *options) {
+		o.clientMode = mode
+	}
+}
+
+// WithServiceName 调用方服务名称；仅用于 Client
+func WithServiceName(name string) Option {
+	return func(o *options) {
+		o.serviceName = name
+	}
+}
+
+// WithServiceTokenExpire 服务令牌的有效期；仅用于 Client
+func WithServiceTokenExpire(expire time.Duration) Option {
+	return func(o *options) {
+		o.serviceTokenExpire = expire
+	}
+}
+
+// WithServiceTokenFunc 获取服务令牌；用于 ClientModeServiceCredential，默认签发服务令牌
+func WithServiceTokenFunc(f ServiceTokenFunc) Option {
+	return func(o *options) {
+		o.serviceTokenFunc = f
+	}
+}
+
+// WithTokenRenewer 滑动会话；仅用于 Server
+// 令牌在续期窗口内时签发新令牌，通过响应头 RenewAccessTokenKey、RenewRefreshTokenKey 返回；续期失败不影响当前请求
+func WithTokenRenewer(renewer TokenRenewer) Option {
+	return func(o *options) {
+		o.tokenRenewer = renewer
+	}
+}
+
+// WithOIDCProviders 接受外部身份提供方签发的令牌；仅用于 Server
+// 根据令牌的 iss 选择身份提供方，未匹配时按本地令牌验证；外部令牌不使用 WithTokenValidator 与 WithTokenRenewer
+func WithOIDCProviders(providers ...OIDCProvider) Option {
+	return func(o *options) {
+		o.oidcProviders = providers
+	}
+}
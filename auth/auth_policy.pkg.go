@@ -0,0 +1,309 @@
+package authpkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+
+	threadpkg "github.com/eden-quan/go-kratos-pkg/thread"
+)
+
+const (
+	// PolicyWildcard 通配符
+	PolicyWildcard = "*"
+	// DefaultPolicyRedisKey 授权策略的缓存key
+	DefaultPolicyRedisKey = "gs:auth:policy"
+	// DefaultPolicyRefreshInterval 授权策略的刷新间隔
+	DefaultPolicyRefreshInterval = time.Minute
+)
+
+// PolicyRule 授权规则
+type PolicyRule struct {
+	// Operation kratos operation；例：/api.user.v1.User/Delete
+	// 支持通配符：* 匹配所有；/api.user.v1.User/* 匹配服务的所有方法
+	Operation string `json:"operation"`
+	// Roles 需要其中任一角色；包括继承的角色
+	Roles []string `json:"roles,omitempty"`
+	// Permissions 需要所有权限；令牌的 Scopes 与角色的权限
+	Permissions []string `json:"permissions,omitempty"`
+	// TokenTypes 需要其中任一令牌类型
+	TokenTypes []TokenTypeEnum_TokenType `json:"token_types,omitempty"`
+}
+
+// Policy 授权策略
+// 匹配规则：精确匹配优先，其次为最长的通配符前缀；未匹配的 operation 由中间件决定是否放行
+type Policy struct {
+	Rules []*PolicyRule `json:"rules"`
+	// RoleInherits 角色继承；例：admin => [editor]，admin 拥有 editor 的角色与权限
+	RoleInherits map[string][]string `json:"role_inherits,omitempty"`
+	// RolePermissions 角色的权限；支持通配符：user:* 匹配 user:delete
+	RolePermissions map[string][]string `json:"role_permissions,omitempty"`
+
+	compileOnce sync.Once
+	exactRules  map[string]*PolicyRule
+	prefixRules []*PolicyRule
+}
+
+// ParsePolicy 解析JSON格式的授权策略
+func ParsePolicy(data []byte) (*Policy, error) {
+	policy := &Policy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("decode auth policy failed : %w", err)
+	}
+	for i := range policy.Rules {
+		if policy.Rules[i] == nil || policy.Rules[i].Operation == "" {
+			return nil, fmt.Errorf("auth policy rule[%d]: operation is empty", i)
+		}
+	}
+	return policy, nil
+}
+
+// compile ...
+func (s *Policy) compile() {
+	s.compileOnce.Do(func() {
+		s.exactRules = make(map[string]*PolicyRule, len(s.Rules))
+		for i := range s.Rules {
+			rule := s.Rules[i]
+			if rule == nil {
+				continue
+			}
+			if strings.HasSuffix(rule.Operation, PolicyWildcard) {
+				s.prefixRules = append(s.prefixRules, rule)
+				continue
+			}
+			if _, ok := s.exactRules[rule.Operation]; !ok {
+				s.exactRules[rule.Operation] = rule
+			}
+		}
+		sort.SliceStable(s.prefixRules, func(i, j int) bool {
+			return len(s.prefixRules[i].Operation) > len(s.prefixRules[j].Operation)
+		})
+	})
+}
+
+// Rule 匹配 operation 的规则
+func (s *Policy) Rule(operation string) (*PolicyRule, bool) {
+	s.compile()
+	if rule, ok := s.exactRules[operation]; ok {
+		return rule, true
+	}
+	for i := range s.prefixRules {
+		if matchWildcard(s.prefixRules[i].Operation, operation) {
+			return s.prefixRules[i], true
+		}
+	}
+	return nil, false
+}
+
+// Authorize 校验授权
+// matched 是否有匹配的规则；allowed 是否允许访问
+func (s *Policy) Authorize(operation string, payload *Payload) (matched, allowed bool) {
+	rule, ok := s.Rule(operation)
+	if !ok {
+		return false, false
+	}
+	if payload == nil {
+		return true, false
+	}
+	return true, s.allow(rule, payload)
+}
+
+// allow ...
+func (s *Policy) allow(rule *PolicyRule, payload *Payload) bool {
+	if len(rule.TokenTypes) > 0 {
+		var ok bool
+		for i := range rule.TokenTypes {
+			if rule.TokenTypes[i] == payload.TokenType {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	roles := s.Roles(payload.Roles)
+	if len(rule.Roles) > 0 {
+		var ok bool
+		for i := range rule.Roles {
+			if _, ok = roles[rule.Roles[i]]; ok {
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	if len(rule.Permissions) > 0 {
+		granted := s.Permissions(roles, payload.Scopes)
+		for i := range rule.Permissions {
+			if !matchAnyWildcard(granted, rule.Permissions[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Roles 角色及其继承的角色
+func (s *Policy) Roles(roles []string) map[string]struct{} {
+	var (
+		res   = make(map[string]struct{}, len(roles))
+		queue = append([]string{}, roles...)
+	)
+	for len(queue) > 0 {
+		role := queue[0]
+		queue = queue[1:]
+		if _, ok := res[role]; ok {
+			continue
+		}
+		res[role] = struct{}{}
+		queue = append(queue, s.RoleInherits[role]...)
+	}
+	return res
+}
+
+// Permissions 角色的权限与令牌的权限
+func (s *Policy) Permissions(roles map[string]struct{}, scopes []string) []string {
+	permissions := append([]string{}, scopes...)
+	for role := range roles {
+		permissions = append(permissions, s.RolePermissions[role]...)
+	}
+	return permissions
+}
+
+// matchWildcard pattern 以*结尾时匹配前缀
+func matchWildcard(pattern, value string) bool {
+	if pattern == PolicyWildcard {
+		return true
+	}
+	if strings.HasSuffix(pattern, PolicyWildcard) {
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, PolicyWildcard))
+	}
+	return pattern == value
+}
+
+// matchAnyWildcard ...
+func matchAnyWildcard(patterns []string, value string) bool {
+	for i := range patterns {
+		if matchWildcard(patterns[i], value) {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicySource 授权策略来源
+type PolicySource interface {
+	// Policy 当前的授权策略；返回的策略不可修改
+	Policy(ctx context.Context) (*Policy, error)
+}
+
+// staticPolicySource 静态配置
+type staticPolicySource struct {
+	policy *Policy
+}
+
+// NewStaticPolicySource 静态配置的授权策略
+func NewStaticPolicySource(policy *Policy) PolicySource {
+	if policy == nil {
+		policy = &Policy{}
+	}
+	return &staticPolicySource{policy: policy}
+}
+
+// Policy ...
+func (s *staticPolicySource) Policy(ctx context.Context) (*Policy, error) {
+	return s.policy, nil
+}
+
+// redisPolicySource 基于Redis的授权策略
+type redisPolicySource struct {
+	logHandler *log.Helper
+	redisCC    redis.UniversalClient
+	key        string
+
+	policy    atomic.Pointer[Policy]
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// NewRedisPolicySource 基于Redis的授权策略：策略以JSON格式存储在 key 中，定时刷新
+// 刷新失败时继续使用上一次的策略；key 不存在时为空策略
+func NewRedisPolicySource(redisCC redis.UniversalClient, key string, refreshInterval time.Duration, logger log.Logger) (source PolicySource, cleanup func(), err error) {
+	if key == "" {
+		key = DefaultPolicyRedisKey
+	}
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultPolicyRefreshInterval
+	}
+	s := &redisPolicySource{
+		logHandler: log.NewHelper(log.With(logger, "module", "auth/policy")),
+		redisCC:    redisCC,
+		key:        key,
+		closeCh:    make(chan struct{}),
+	}
+	if err = s.refresh(context.Background()); err != nil {
+		return nil, nil, err
+	}
+	threadpkg.GoSafe(func() {
+		s.refreshLoop(refreshInterval)
+	})
+	return s, s.close, nil
+}
+
+// Policy ...
+func (s *redisPolicySource) Policy(ctx context.Context) (*Policy, error) {
+	return s.policy.Load(), nil
+}
+
+// refresh ...
+func (s *redisPolicySource) refresh(ctx context.Context) error {
+	data, err := s.redisCC.Get(ctx, s.key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			return fmt.Errorf("load auth policy failed : %w", err)
+		}
+		s.logHandler.WithContext(ctx).Warnw("msg", "auth policy not found", "key", s.key)
+		data = []byte("{}")
+	}
+	policy, err := ParsePolicy(data)
+	if err != nil {
+		return err
+	}
+	s.policy.Store(policy)
+	return nil
+}
+
+// refreshLoop ...
+func (s *redisPolicySource) refreshLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closeCh:
+			return
+		case <-ticker.C:
+			if err := s.refresh(context.Background()); err != nil {
+				s.logHandler.Errorw("msg", "refresh auth policy failed", "err", err)
+			}
+		}
+	}
+}
+
+// close ...
+func (s *redisPolicySource) close() {
+	s.closeOnce.Do(func() {
+		close(s.closeCh)
+	})
+}
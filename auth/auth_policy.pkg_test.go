@@ -0,0 +1,168 @@
+package authpkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// testPolicyJSON ...
+const testPolicyJSON = `{
+	"rules": [
+		{"operation": "/api.user.v1.User/Delete", "roles": ["admin"]},
+		{"operation": "/api.user.v1.User/Update", "permissions": ["user:update"]},
+		{"operation": "/api.user.v1.User/*", "token_types": [2]},
+		{"operation": "/api.admin.v1.*", "token_types": [1], "permissions": ["admin:read", "admin:write"]}
+	],
+	"role_inherits": {"admin": ["editor"], "editor": ["viewer"], "guest": ["anonymous"], "anonymous": ["guest"]},
+	"role_permissions": {"editor": ["user:*"], "viewer": ["admin:read"]}
+}`
+
+// go test -v -count=1 ./auth -test.run=TestPolicy_Authorize
+func TestPolicy_Authorize(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicyJSON))
+	require.Nil(t, err)
+
+	tests := []struct {
+		name        string
+		operation   string
+		payload     *Payload
+		wantMatched bool
+		wantAllowed bool
+	}{
+		{
+			name:      "#unmatched",
+			operation: "/api.order.v1.Order/Get",
+			payload:   &Payload{},
+		},
+		{
+			name:        "#no_payload",
+			operation:   "/api.user.v1.User/Get",
+			wantMatched: true,
+		},
+		{
+			name:        "#role",
+			operation:   "/api.user.v1.User/Delete",
+			payload:     &Payload{Roles: []string{"admin"}},
+			wantMatched: true,
+			wantAllowed: true,
+		},
+		{
+			name:        "#role_denied",
+			operation:   "/api.user.v1.User/Delete",
+			payload:     &Payload{Roles: []string{"editor"}},
+			wantMatched: true,
+		},
+		{
+			name:        "#inherited_role_permission_wildcard",
+			operation:   "/api.user.v1.User/Update",
+			payload:     &Payload{Roles: []string{"admin"}},
+			wantMatched: true,
+			wantAllowed: true,
+		},
+		{
+			name:        "#scope",
+			operation:   "/api.user.v1.User/Update",
+			payload:     &Payload{Scopes: []string{"user:update"}},
+			wantMatched: true,
+			wantAllowed: true,
+		},
+		{
+			name:        "#permission_denied",
+			operation:   "/api.user.v1.User/Update",
+			payload:     &Payload{Roles: []string{"viewer"}, Scopes: []string{"user:read"}},
+			wantMatched: true,
+		},
+		{
+			name:        "#operation_wildcard",
+			operation:   "/api.user.v1.User/Get",
+			payload:     &Payload{TokenType: TokenTypeEnum_USER},
+			wantMatched: true,
+			wantAllowed: true,
+		},
+		{
+			name:        "#token_type_denied",
+			operation:   "/api.user.v1.User/Get",
+			payload:     &Payload{TokenType: TokenTypeEnum_ADMIN},
+			wantMatched: true,
+		},
+		{
+			name:        "#all_permissions",
+			operation:   "/api.admin.v1.Admin/List",
+			payload:     &Payload{TokenType: TokenTypeEnum_ADMIN, Roles: []string{"viewer"}, Scopes: []string{"admin:*"}},
+			wantMatched: true,
+			wantAllowed: true,
+		},
+		{
+			name:        "#missing_permission",
+			operation:   "/api.admin.v1.Admin/List",
+			payload:     &Payload{TokenType: TokenTypeEnum_ADMIN, Roles: []string{"editor"}, Scopes: []string{"admin:read"}},
+			wantMatched: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, allowed := policy.Authorize(tt.operation, tt.payload)
+			require.Equal(t, tt.wantMatched, matched)
+			require.Equal(t, tt.wantAllowed, allowed)
+		})
+	}
+
+	// 循环继承
+	roles := policy.Roles([]string{"guest"})
+	require.Len(t, roles, 2)
+
+	// 无效的策略
+	_, err = ParsePolicy([]byte(`{"rules": [{"roles": ["admin"]}]}`))
+	require.NotNil(t, err)
+}
+
+// go test -v -count=1 ./auth -test.run=TestNewRedisPolicySource
+func TestNewRedisPolicySource(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		mr      = miniredis.RunT(t)
+		redisCC = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		payload = &Payload{Roles: []string{"editor"}}
+	)
+	defer func() { _ = redisCC.Close() }()
+
+	// key 不存在
+	source, cleanup, err := NewRedisPolicySource(redisCC, "", time.Millisecond*10, log.DefaultLogger)
+	require.Nil(t, err)
+	defer cleanup()
+	policy, err := source.Policy(ctx)
+	require.Nil(t, err)
+	matched, _ := policy.Authorize("/api.user.v1.User/Delete", payload)
+	require.False(t, matched)
+
+	// 刷新
+	require.Nil(t, mr.Set(DefaultPolicyRedisKey, testPolicyJSON))
+	require.Eventually(t, func() bool {
+		policy, err = source.Policy(ctx)
+		if err != nil {
+			return false
+		}
+		matched, _ = policy.Authorize("/api.user.v1.User/Delete", payload)
+		return matched
+	}, time.Second*3, time.Millisecond*10)
+
+	// 刷新失败时使用上一次的策略
+	require.Nil(t, mr.Set(DefaultPolicyRedisKey, "invalid"))
+	time.Sleep(time.Millisecond * 50)
+	policy, err = source.Policy(ctx)
+	require.Nil(t, err)
+	matched, allowed := policy.Authorize("/api.user.v1.User/Update", payload)
+	require.True(t, matched)
+	require.True(t, allowed)
+
+	// 初始化失败
+	require.Nil(t, mr.Set("invalid", "invalid"))
+	_, _, err = NewRedisPolicySource(redisCC, "invalid", time.Second, log.DefaultLogger)
+	require.NotNil(t, err)
+}
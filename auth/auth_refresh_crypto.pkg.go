@@ -0,0 +1,191 @@
+package authpkg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+
+	aespkg "github.com/eden-quan/go-kratos-pkg/aes"
+)
+
+// RefreshCryptoVersion 刷新令牌的加密格式版本：密文的第一个字节
+type RefreshCryptoVersion byte
+
+const (
+	// RefreshCryptoVersionAESGCM AES-256-GCM
+	RefreshCryptoVersionAESGCM RefreshCryptoVersion = 1
+	// RefreshCryptoVersionXChaCha20Poly1305 XChaCha20-Poly1305
+	RefreshCryptoVersionXChaCha20Poly1305 RefreshCryptoVersion = 2
+)
+
+// refreshCryptoKeyInfo HKDF info：从签名密钥派生独立的加密密钥
+const refreshCryptoKeyInfo = "gs:auth:refresh-token"
+
+// String ...
+func (v RefreshCryptoVersion) String() string {
+	switch v {
+	case RefreshCryptoVersionAESGCM:
+		return "AES-256-GCM"
+	case RefreshCryptoVersionXChaCha20Poly1305:
+		return "XChaCha20-Poly1305"
+	}
+	return fmt.Sprintf("RefreshCryptoVersion(%d)", byte(v))
+}
+
+// aeadCrypto 认证加密：版本(1字节) + 随机数 + 密文
+type aeadCrypto struct {
+	version      RefreshCryptoVersion
+	keyInfo      string
+	legacy       Encryptor
+	legacySunset time.Time
+	now          func() time.Time
+}
+
+// AEADCryptoOption ...
+type AEADCryptoOption func(*aeadCryptoOptions)
+
+// aeadCryptoOptions ...
+type aeadCryptoOptions struct {
+	legacySunset time.Time
+}
+
+// WithLegacySunset 兼容旧格式的截止时间：之后拒绝旧格式的密文
+// 建议设置为所有实例升级的时间 + 刷新令牌的最长有效期
+func WithLegacySunset(sunset time.Time) AEADCryptoOption {
+	return func(o *aeadCryptoOptions) {
+		o.legacySunset = sunset
+	}
+}
+
+// NewAEADCrypto 刷新令牌的认证加密；加密密钥由 HKDF-SHA256 从密钥派生
+// version 加密使用的格式；解密支持所有版本，切换版本后旧的刷新令牌仍然有效
+// legacy 兼容旧格式的刷新令牌；例：aespkg.NewCBCCipher()，为空时不兼容
+// 未设置 WithLegacySunset 时始终兼容旧格式
+func NewAEADCrypto(version RefreshCryptoVersion, legacy Encryptor, opts ...AEADCryptoOption) (Encryptor, error) {
+	if _, err := newRefreshAEAD(version, deriveCryptoKey("", refreshCryptoKeyInfo)); err != nil {
+		return nil, err
+	}
+	return newAEADCrypto(version, refreshCryptoKeyInfo, legacy, opts...), nil
+}
+
+// newAEADCrypto keyInfo 区分用途，不同用途的密文不能互相解密
+func newAEADCrypto(version RefreshCryptoVersion, keyInfo string, legacy Encryptor, opts ...AEADCryptoOption) *aeadCrypto {
+	o := &aeadCryptoOptions{}
+	for i := range opts {
+		opts[i](o)
+	}
+	return &aeadCrypto{
+		version:      version,
+		keyInfo:      keyInfo,
+		legacy:       legacy,
+		legacySunset: o.legacySunset,
+		now:          time.Now,
+	}
+}
+
+// DefaultRefreshCrypto AES-256-GCM
+// 使用 WithLegacySunset 设置截止时间时，兼容 AES-CBC 格式的刷新令牌直至截止时间；未设置时不兼容
+func DefaultRefreshCrypto(opts ...AEADCryptoOption) Encryptor {
+	o := &aeadCryptoOptions{}
+	for i := range opts {
+		opts[i](o)
+	}
+	var legacy Encryptor
+	if !o.legacySunset.IsZero() {
+		legacy = aespkg.NewCBCCipher()
+	}
+	return newAEADCrypto(RefreshCryptoVersionAESGCM, refreshCryptoKeyInfo, legacy, opts...)
+}
+
+// EncryptToString ...
+func (s *aeadCrypto) EncryptToString(plaintext, key string) (string, error) {
+	aead, err := newRefreshAEAD(s.version, deriveCryptoKey(key, s.keyInfo))
+	if err != nil {
+		return "", err
+	}
+	header := []byte{byte(s.version)}
+	out := make([]byte, len(header)+aead.NonceSize(), len(header)+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	copy(out, header)
+	if _, err = io.ReadFull(rand.Reader, out[len(header):]); err != nil {
+		return "", fmt.Errorf("generate nonce failed : %w", err)
+	}
+	out = aead.Seal(out, out[len(header):], []byte(plaintext), header)
+	return base64.RawURLEncoding.EncodeToString(out), nil
+}
+
+// DecryptToString 版本未知或验证失败时，在截止时间前使用 legacy 解密
+func (s *aeadCrypto) DecryptToString(ciphertext, key string) (string, error) {
+	plaintext, err := s.decrypt(ciphertext, key)
+	if err == nil {
+		return plaintext, nil
+	}
+	if s.legacyEnabled() {
+		if plaintext, legacyErr := s.legacy.DecryptToString(ciphertext, key); legacyErr == nil {
+			return plaintext, nil
+		}
+	}
+	return "", err
+}
+
+// legacyEnabled 是否兼容旧格式
+func (s *aeadCrypto) legacyEnabled() bool {
+	if s.legacy == nil {
+		return false
+	}
+	return s.legacySunset.IsZero() || s.now().Before(s.legacySunset)
+}
+
+// decrypt ...
+func (s *aeadCrypto) decrypt(ciphertext, key string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("decode ciphertext failed : %w", err)
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("ciphertext is empty")
+	}
+	aead, err := newRefreshAEAD(RefreshCryptoVersion(data[0]), deriveCryptoKey(key, s.keyInfo))
+	if err != nil {
+		return "", err
+	}
+	header, data := data[:1], data[1:]
+	if len(data) < aead.NonceSize()+aead.Overhead() {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], header)
+	if err != nil {
+		return "", fmt.Errorf("decrypt ciphertext failed : %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// deriveCryptoKey HKDF-SHA256 派生32字节的密钥
+func deriveCryptoKey(key, info string) []byte {
+	derived := make([]byte, 32)
+	// 输出长度远小于 255*HashLen，不会返回错误
+	_, _ = io.ReadFull(hkdf.New(sha256.New, []byte(key), nil, []byte(info)), derived)
+	return derived
+}
+
+// newRefreshAEAD ...
+func newRefreshAEAD(version RefreshCryptoVersion, key []byte) (cipher.AEAD, error) {
+	switch version {
+	case RefreshCryptoVersionAESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case RefreshCryptoVersionXChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	}
+	return nil, fmt.Errorf("unsupported refresh crypto version: %s", version)
+}
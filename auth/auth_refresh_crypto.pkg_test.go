@@ -0,0 +1,127 @@
+package authpkg
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/stretchr/testify/require"
+
+	aespkg "github.com/eden-quan/go-kratos-pkg/aes"
+)
+
+// go test -v -count=1 ./auth -test.run=TestAEADCrypto
+func TestAEADCrypto(t *testing.T) {
+	const (
+		key       = "1234567890ABCDEF"
+		plaintext = `{"jti":"1"}`
+	)
+	_, err := NewAEADCrypto(RefreshCryptoVersion(0), nil)
+	require.NotNil(t, err)
+	gcm, err := NewAEADCrypto(RefreshCryptoVersionAESGCM, nil)
+	require.Nil(t, err)
+	xchacha, err := NewAEADCrypto(RefreshCryptoVersionXChaCha20Poly1305, nil)
+	require.Nil(t, err)
+
+	for _, crypto := range []Encryptor{gcm, xchacha} {
+		ciphertext, err := crypto.EncryptToString(plaintext, key)
+		require.Nil(t, err)
+		// 解密支持所有版本
+		for _, other := range []Encryptor{gcm, xchacha} {
+			res, err := other.DecryptToString(ciphertext, key)
+			require.Nil(t, err)
+			require.Equal(t, plaintext, res)
+		}
+
+		// 密钥错误
+		_, err = crypto.DecryptToString(ciphertext, "other")
+		require.NotNil(t, err)
+
+		// 篡改密文
+		data, err := base64.RawURLEncoding.DecodeString(ciphertext)
+		require.Nil(t, err)
+		data[len(data)-1] ^= 1
+		_, err = crypto.DecryptToString(base64.RawURLEncoding.EncodeToString(data), key)
+		require.NotNil(t, err)
+	}
+
+	// 版本
+	ciphertext, err := xchacha.EncryptToString(plaintext, key)
+	require.Nil(t, err)
+	data, _ := base64.RawURLEncoding.DecodeString(ciphertext)
+	require.Equal(t, byte(RefreshCryptoVersionXChaCha20Poly1305), data[0])
+
+	// 兼容 AES-CBC
+	legacyCiphertext, err := aespkg.NewCBCCipher().EncryptToString(plaintext, key)
+	require.Nil(t, err)
+	_, err = gcm.DecryptToString(legacyCiphertext, key)
+	require.NotNil(t, err)
+	res, err := DefaultRefreshCrypto(WithLegacySunset(time.Now().Add(time.Hour))).DecryptToString(legacyCiphertext, key)
+	require.Nil(t, err)
+	require.Equal(t, plaintext, res)
+	_, err = DefaultRefreshCrypto(WithLegacySunset(time.Now().Add(time.Hour))).DecryptToString("invalid", key)
+	require.NotNil(t, err)
+
+	// 未设置截止时间时不兼容
+	_, err = DefaultRefreshCrypto().DecryptToString(legacyCiphertext, key)
+	require.NotNil(t, err)
+
+	// 截止时间后拒绝 AES-CBC
+	sunset := time.Now().Add(time.Hour)
+	crypto := newAEADCrypto(RefreshCryptoVersionAESGCM, refreshCryptoKeyInfo, aespkg.NewCBCCipher(), WithLegacySunset(sunset))
+	crypto.now = func() time.Time { return sunset.Add(-time.Second) }
+	res, err = crypto.DecryptToString(legacyCiphertext, key)
+	require.Nil(t, err)
+	require.Equal(t, plaintext, res)
+	crypto.now = func() time.Time { return sunset }
+	_, err = crypto.DecryptToString(legacyCiphertext, key)
+	require.NotNil(t, err)
+	ciphertext, err = crypto.EncryptToString(plaintext, key)
+	require.Nil(t, err)
+	res, err = crypto.DecryptToString(ciphertext, key)
+	require.Nil(t, err)
+	require.Equal(t, plaintext, res)
+	_, err = DefaultRefreshCrypto(WithLegacySunset(time.Now())).DecryptToString(legacyCiphertext, key)
+	require.NotNil(t, err)
+}
+
+// go test -v -count=1 ./auth -test.run=TestAuthRepo_RefreshCrypto
+func TestAuthRepo_RefreshCrypto(t *testing.T) {
+	var (
+		ctx         = context.Background()
+		tokenManger = newMemoryTokenManger(time.Now)
+		newRepo     = func(crypto Encryptor, sunset time.Time) AuthRepo {
+			repo, err := NewAuthRepo(tokenManger, log.DefaultLogger, Config{
+				SignKey:             "1234567890ABCDEF",
+				RefreshCrypto:       crypto,
+				RefreshLegacySunset: sunset,
+			})
+			require.Nil(t, err)
+			return repo
+		}
+		legacyRepo = newRepo(aespkg.NewCBCCipher(), time.Time{})
+		repo       = newRepo(nil, time.Now().Add(time.Hour))
+	)
+
+	// 升级前签发的刷新令牌：未配置截止时间时不兼容
+	legacy, _, err := legacyRepo.SignToken(ctx, DefaultClaims(Payload{UserID: 1}))
+	require.Nil(t, err)
+	_, err = newRepo(nil, time.Time{}).DecodeRefreshToken(ctx, legacy.RefreshToken)
+	require.NotNil(t, err)
+	_, err = newRepo(nil, time.Now().Add(-time.Second)).DecodeRefreshToken(ctx, legacy.RefreshToken)
+	require.NotNil(t, err)
+	refreshed, _, err := repo.RefreshToken(ctx, legacy.RefreshToken)
+	require.Nil(t, err)
+
+	// 新的刷新令牌使用认证加密
+	data, err := base64.RawURLEncoding.DecodeString(refreshed.RefreshToken)
+	require.Nil(t, err)
+	require.Equal(t, byte(RefreshCryptoVersionAESGCM), data[0])
+	claims, err := repo.DecodeRefreshToken(ctx, refreshed.RefreshToken)
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), claims.Payload.UserID)
+	_, err = legacyRepo.DecodeRefreshToken(ctx, refreshed.RefreshToken)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,188 @@
+package authpkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// RenewAccessTokenKey 响应头：续期的令牌
+	RenewAccessTokenKey = "X-Renew-Access-Token"
+	// RenewRefreshTokenKey 响应头：续期的刷新令牌
+	RenewRefreshTokenKey = "X-Renew-Refresh-Token"
+	// DefaultRenewGracePeriod 续期后旧令牌的默认宽限期
+	DefaultRenewGracePeriod = time.Second * 30
+)
+
+// TokenLifetime 令牌有效期
+type TokenLifetime struct {
+	// TokenType 令牌类型；UNSPECIFIED 匹配所有类型
+	TokenType TokenTypeEnum_TokenType
+	// LoginPlatform 登录平台；UNSPECIFIED 匹配所有平台
+	LoginPlatform LoginPlatformEnum_LoginPlatform
+	// AccessTokenExpire 令牌有效期；为0时使用 TokenExpireDuration
+	AccessTokenExpire time.Duration
+	// RefreshTokenExpire 刷新令牌有效期；为0时使用 RefreshTokenExpire
+	RefreshTokenExpire time.Duration
+	// RenewWindow 续期窗口：令牌剩余有效期小于该值时自动续期；为0时不续期
+	RenewWindow time.Duration
+}
+
+// DefaultTokenLifetime 默认有效期
+func DefaultTokenLifetime() *TokenLifetime {
+	return &TokenLifetime{
+		AccessTokenExpire:  TokenExpireDuration,
+		RefreshTokenExpire: RefreshTokenExpire,
+	}
+}
+
+// maxTokenLifetime 配置的令牌与刷新令牌有效期的最大值，包括默认有效期
+func maxTokenLifetime(lifetimes []*TokenLifetime) time.Duration {
+	defaultLifetime := DefaultTokenLifetime()
+	res := max(defaultLifetime.AccessTokenExpire, defaultLifetime.RefreshTokenExpire)
+	for _, lifetime := range lifetimes {
+		if lifetime != nil {
+			res = max(res, lifetime.AccessTokenExpire, lifetime.RefreshTokenExpire)
+		}
+	}
+	return res
+}
+
+// TokenRenewer 令牌续期；参考 WithTokenRenewer
+type TokenRenewer interface {
+	// RenewToken 令牌在续期窗口内时签发新令牌，旧令牌失效；不需要续期时返回nil
+	RenewToken(ctx context.Context, jwtToken *jwt.Token) (*TokenResponse, error)
+}
+
+// TokenLifetime 令牌有效期
+// 匹配顺序：令牌类型与登录平台、令牌类型、登录平台、默认配置(均为 UNSPECIFIED)
+func (s *authRepo) TokenLifetime(payload *Payload) *TokenLifetime {
+	var (
+		res   *TokenLifetime
+		score = -1
+	)
+	for _, lifetime := range s.config.Lifetimes {
+		if lifetime == nil {
+			continue
+		}
+		var lifetimeScore int
+		if lifetime.TokenType != TokenTypeEnum_UNSPECIFIED {
+			if payload == nil || lifetime.TokenType != payload.TokenType {
+				continue
+			}
+			lifetimeScore += 2
+		}
+		if lifetime.LoginPlatform != LoginPlatformEnum_UNSPECIFIED {
+			if payload == nil || lifetime.LoginPlatform != payload.LoginPlatform {
+				continue
+			}
+			lifetimeScore++
+		}
+		if lifetimeScore > score {
+			res, score = lifetime, lifetimeScore
+		}
+	}
+
+	lifetime := DefaultTokenLifetime()
+	if res != nil {
+		if res.AccessTokenExpire > 0 {
+			lifetime.AccessTokenExpire = res.AccessTokenExpire
+		}
+		if res.RefreshTokenExpire > 0 {
+			lifetime.RefreshTokenExpire = res.RefreshTokenExpire
+		}
+		lifetime.TokenType = res.TokenType
+		lifetime.LoginPlatform = res.LoginPlatform
+		lifetime.RenewWindow = res.RenewWindow
+	}
+	return lifetime
+}
+
+// RenewToken 滑动会话：令牌在续期窗口内时签发新令牌，旧令牌与刷新令牌加入黑名单
+// 旧令牌在宽限期(Config.RenewGracePeriod)内仍然有效；并发的请求只有一个续期，其他请求继续使用旧令牌
+func (s *authRepo) RenewToken(ctx context.Context, jwtToken *jwt.Token) (*TokenResponse, error) {
+	authClaims, ok := jwtToken.Claims.(*Claims)
+	if !ok || authClaims.Payload == nil || authClaims.ExpiresAt == nil || authClaims.IsServiceToken() || authClaims.IsImpersonation() {
+		return nil, nil
+	}
+	lifetime := s.TokenLifetime(authClaims.Payload)
+	if lifetime.RenewWindow <= 0 || time.Until(authClaims.ExpiresAt.Time) > lifetime.RenewWindow {
+		return nil, nil
+	}
+
+	res, err := s.renewToken(ctx, authClaims)
+	if err != nil {
+		s.logHandler.WithContext(ctx).Errorw("msg", "renewToken failed", "err", err)
+		return nil, err
+	}
+	return res, nil
+}
+
+// renewToken ...
+func (s *authRepo) renewToken(ctx context.Context, authClaims *Claims) (*TokenResponse, error) {
+	res, _, err := s.reissueToken(ctx, authClaims, inheritClaims(authClaims), AuthEventRenew, s.renewGracePeriod())
+	return res, err
+}
+
+// renewGracePeriod ...
+func (s *authRepo) renewGracePeriod() time.Duration {
+	switch {
+	case s.config.RenewGracePeriod == 0:
+		return DefaultRenewGracePeriod
+	case s.config.RenewGracePeriod < 0:
+		return 0
+	}
+	return s.config.RenewGracePeriod
+}
+
+// inheritClaims 续期、刷新与多因素认证签发的新令牌：保持授权信息、令牌家族与认证方式
+func inheritClaims(authClaims *Claims) *Claims {
+	newClaims := DefaultClaims(*authClaims.Payload)
+	newClaims.FamilyID = authClaims.FamilyID
+	newClaims.AMR = append([]string(nil), authClaims.AMR...)
+	newClaims.ACR = authClaims.ACR
+	newClaims.AuthTime = authClaims.AuthTime
+	return newClaims
+}
+
+// reissueToken 旧令牌与刷新令牌加入黑名单，签发新令牌；旧令牌已续期或已注销时返回 isNotFound
+// grace 旧令牌的宽限期；参考 TokenManger.RetireTokens
+func (s *authRepo) reissueToken(ctx context.Context, authClaims, newClaims *Claims, eventType AuthEventType, grace time.Duration) (res *TokenResponse, isNotFound bool, err error) {
+	ctx = tenantContext(ctx, authClaims.Payload)
+	userIdentifier := authClaims.Payload.UserIdentifier()
+
+	// 已续期或已注销
+	accessItem, isNotFound, err := s.tokenManger.GetToken(ctx, userIdentifier, authClaims.ID)
+	if err != nil {
+		return nil, false, fmt.Errorf("GetToken failed: %w", err)
+	}
+	if isNotFound {
+		return nil, true, nil
+	}
+
+	// 旧令牌加入黑名单：原子地使用旧令牌，并发的请求只有一个签发新令牌
+	retireList := []*TokenItem{accessItem}
+	refreshItem, isRefreshNotFound, err := s.tokenManger.GetToken(ctx, userIdentifier, accessItem.RefreshTokenID)
+	if err != nil {
+		return nil, false, fmt.Errorf("GetToken failed: %w", err)
+	}
+	if !isRefreshNotFound {
+		retireList = append(retireList, refreshItem)
+	}
+	claimed, err := s.tokenManger.RetireTokens(ctx, userIdentifier, retireList, grace)
+	if err != nil {
+		return nil, false, fmt.Errorf("RetireTokens failed: %w", err)
+	}
+	if !claimed {
+		return nil, true, nil
+	}
+
+	res, _, err = s.signToken(ctx, newClaims, eventType)
+	if err != nil {
+		return nil, false, err
+	}
+	return res, false, nil
+}
@@ -0,0 +1,193 @@
+package authpkg
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -count=1 ./auth -test.run=TestAuthRepo_TokenLifetime
+func TestAuthRepo_TokenLifetime(t *testing.T) {
+	repo, err := NewAuthRepo(newMemoryTokenManger(time.Now), log.DefaultLogger, Config{
+		SignKey: "1234567890ABCDEF",
+		Lifetimes: []*TokenLifetime{
+			{TokenType: TokenTypeEnum_ADMIN, AccessTokenExpire: time.Hour * 2, RenewWindow: time.Minute * 30},
+			{TokenType: TokenTypeEnum_ADMIN, LoginPlatform: LoginPlatformEnum_IOS, AccessTokenExpire: time.Hour},
+			{LoginPlatform: LoginPlatformEnum_IOS, AccessTokenExpire: time.Hour * 24, RefreshTokenExpire: time.Hour * 24 * 30},
+		},
+	})
+	require.Nil(t, err)
+
+	tests := []struct {
+		name    string
+		payload *Payload
+		want    *TokenLifetime
+	}{
+		{
+			name:    "#default",
+			payload: &Payload{TokenType: TokenTypeEnum_USER},
+			want:    DefaultTokenLifetime(),
+		},
+		{
+			name:    "#token_type",
+			payload: &Payload{TokenType: TokenTypeEnum_ADMIN, LoginPlatform: LoginPlatformEnum_ANDROID},
+			want: &TokenLifetime{
+				TokenType:          TokenTypeEnum_ADMIN,
+				AccessTokenExpire:  time.Hour * 2,
+				RefreshTokenExpire: RefreshTokenExpire,
+				RenewWindow:        time.Minute * 30,
+			},
+		},
+		{
+			name:    "#token_type_and_login_platform",
+			payload: &Payload{TokenType: TokenTypeEnum_ADMIN, LoginPlatform: LoginPlatformEnum_IOS},
+			want: &TokenLifetime{
+				TokenType:          TokenTypeEnum_ADMIN,
+				LoginPlatform:      LoginPlatformEnum_IOS,
+				AccessTokenExpire:  time.Hour,
+				RefreshTokenExpire: RefreshTokenExpire,
+			},
+		},
+		{
+			name:    "#login_platform",
+			payload: &Payload{TokenType: TokenTypeEnum_USER, LoginPlatform: LoginPlatformEnum_IOS},
+			want: &TokenLifetime{
+				LoginPlatform:      LoginPlatformEnum_IOS,
+				AccessTokenExpire:  time.Hour * 24,
+				RefreshTokenExpire: time.Hour * 24 * 30,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, repo.TokenLifetime(tt.payload))
+		})
+	}
+
+	// 签发令牌时使用配置的有效期
+	claims := DefaultClaims(Payload{UserID: 1, LoginPlatform: LoginPlatformEnum_IOS})
+	_, items, err := repo.SignToken(context.Background(), claims)
+	require.Nil(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Hour*24), claims.ExpiresAt.Time, time.Second*2)
+	require.InDelta(t, time.Now().Add(time.Hour*24).Unix(), items[0].ExpiredAt, 2)
+	require.InDelta(t, time.Now().Add(time.Hour*24*30).Unix(), items[1].ExpiredAt, 2)
+
+	// 指定的过期时间
+	claims = DefaultClaims(Payload{UserID: 1, LoginPlatform: LoginPlatformEnum_IOS})
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Minute))
+	_, items, err = repo.SignToken(context.Background(), claims)
+	require.Nil(t, err)
+	require.InDelta(t, time.Now().Add(time.Minute).Unix(), items[0].ExpiredAt, 2)
+}
+
+// go test -v -count=1 ./auth -test.run=TestServer_TokenRenewer
+func TestServer_TokenRenewer(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		clock     = time.Now()
+		repo, err = NewAuthRepo(newMemoryTokenManger(func() time.Time { return clock }), log.DefaultLogger, Config{
+			SignKey:   "1234567890ABCDEF",
+			Lifetimes: []*TokenLifetime{{AccessTokenExpire: time.Hour * 2, RenewWindow: time.Hour}},
+		})
+		server = func(opts ...Option) middleware.Middleware {
+			return Server(
+				repo.JWTSigningKeyFunc,
+				append([]Option{
+					WithSigningMethod(repo.JWTSigningMethod()),
+					WithClaims(repo.JWTSigningClaims),
+					WithTokenValidator(repo.VerifyToken),
+				}, opts...)...,
+			)
+		}
+		call = func(token string) (*testTransport, error) {
+			tr := newTestTransport("/api.user.v1.User/Get")
+			tr.reqHeader.Set(AuthorizationKey, token)
+			_, err := server(WithTokenRenewer(repo))(func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})(transport.NewServerContext(ctx, tr), nil)
+			return tr, err
+		}
+		signToken = func(expire time.Duration) *TokenResponse {
+			claims := DefaultClaims(Payload{UserID: 1})
+			claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(expire))
+			res, _, err := repo.SignToken(ctx, claims)
+			require.Nil(t, err)
+			return res
+		}
+	)
+	require.Nil(t, err)
+
+	// 不在续期窗口内
+	tr, err := call(signToken(time.Hour * 2).AccessToken)
+	require.Nil(t, err)
+	require.Empty(t, tr.replyHeader.Get(RenewAccessTokenKey))
+
+	// 续期
+	old := signToken(time.Minute * 30)
+	tr, err = call(old.AccessToken)
+	require.Nil(t, err)
+	renewed := tr.replyHeader.Get(RenewAccessTokenKey)
+	require.NotEmpty(t, renewed)
+	require.NotEmpty(t, tr.replyHeader.Get(RenewRefreshTokenKey))
+	newClaims, err := repo.DecodeAccessToken(ctx, renewed)
+	require.Nil(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Hour*2), newClaims.ExpiresAt.Time, time.Second*2)
+
+	// 宽限期内旧令牌仍然有效，不重复续期；新令牌有效
+	tr, err = call(old.AccessToken)
+	require.Nil(t, err)
+	require.Empty(t, tr.replyHeader.Get(RenewAccessTokenKey))
+	tr, err = call(renewed)
+	require.Nil(t, err)
+	require.Empty(t, tr.replyHeader.Get(RenewAccessTokenKey))
+
+	// 宽限期后旧令牌失效
+	clock = clock.Add(DefaultRenewGracePeriod + time.Second)
+	_, err = call(old.AccessToken)
+	require.True(t, Is(err, ErrBlacklist()))
+
+	// 并发的请求只有一个续期
+	var (
+		wg         sync.WaitGroup
+		start      = make(chan struct{})
+		concurrent = signToken(time.Minute * 30)
+		renewCh    = make(chan string, 8)
+	)
+	for i := 0; i < cap(renewCh); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			tr, err := call(concurrent.AccessToken)
+			if err != nil {
+				renewCh <- "error: " + err.Error()
+				return
+			}
+			renewCh <- tr.replyHeader.Get(RenewAccessTokenKey)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(renewCh)
+	var renewedCount int
+	for token := range renewCh {
+		require.NotContains(t, token, "error: ")
+		if token != "" {
+			renewedCount++
+		}
+	}
+	require.Equal(t, 1, renewedCount)
+
+	// 续期的令牌属于同一令牌家族
+	_, _, err = repo.RefreshToken(ctx, old.RefreshToken)
+	require.True(t, Is(err, ErrRefreshTokenReused()))
+	_, err = call(renewed)
+	require.True(t, Is(err, ErrBlacklist()))
+}
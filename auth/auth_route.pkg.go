@@ -0,0 +1,141 @@
+package authpkg
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// AuthMode 认证模式
+type AuthMode int
+
+const (
+	// AuthModeRequired 必须认证：缺少令牌或令牌无效时拒绝请求
+	AuthModeRequired AuthMode = iota
+	// AuthModeOptional 可选认证：令牌有效时将授权信息放入上下文；不会拒绝请求
+	AuthModeOptional
+	// AuthModePublic 公开：不解析令牌
+	AuthModePublic
+)
+
+// String ...
+func (s AuthMode) String() string {
+	switch s {
+	case AuthModeRequired:
+		return "required"
+	case AuthModeOptional:
+		return "optional"
+	case AuthModePublic:
+		return "public"
+	}
+	return fmt.Sprintf("AuthMode(%d)", int(s))
+}
+
+// RouteMatchType 路由匹配方式
+type RouteMatchType int
+
+const (
+	// RouteMatchExact 精确匹配 operation
+	RouteMatchExact RouteMatchType = iota
+	// RouteMatchPrefix 匹配 operation 前缀
+	RouteMatchPrefix
+	// RouteMatchRegex 正则匹配 operation
+	RouteMatchRegex
+)
+
+// RouteRule 路由规则
+type RouteRule struct {
+	MatchType RouteMatchType `json:"match_type"`
+	// Pattern operation、前缀或正则；例：/api.user.v1.User/Login
+	Pattern string   `json:"pattern"`
+	Mode    AuthMode `json:"mode"`
+}
+
+// ExactRoute 精确匹配
+func ExactRoute(operation string, mode AuthMode) *RouteRule {
+	return &RouteRule{MatchType: RouteMatchExact, Pattern: operation, Mode: mode}
+}
+
+// PrefixRoute 前缀匹配
+func PrefixRoute(prefix string, mode AuthMode) *RouteRule {
+	return &RouteRule{MatchType: RouteMatchPrefix, Pattern: prefix, Mode: mode}
+}
+
+// RegexRoute 正则匹配
+func RegexRoute(expr string, mode AuthMode) *RouteRule {
+	return &RouteRule{MatchType: RouteMatchRegex, Pattern: expr, Mode: mode}
+}
+
+// RouteMatcher 根据 operation 选择认证模式
+type RouteMatcher interface {
+	Match(operation string) AuthMode
+}
+
+// routeMatcher ...
+type routeMatcher struct {
+	defaultMode AuthMode
+	exactRules  map[string]AuthMode
+	prefixRules []*RouteRule
+	regexRules  []*regexRouteRule
+}
+
+// regexRouteRule ...
+type regexRouteRule struct {
+	regex *regexp.Regexp
+	mode  AuthMode
+}
+
+// NewRouteMatcher 路由匹配
+// 匹配顺序：精确匹配、最长前缀、正则(按配置顺序)；均未匹配时使用 defaultMode
+func NewRouteMatcher(defaultMode AuthMode, rules ...*RouteRule) (RouteMatcher, error) {
+	m := &routeMatcher{
+		defaultMode: defaultMode,
+		exactRules:  make(map[string]AuthMode),
+	}
+	for i := range rules {
+		rule := rules[i]
+		if rule == nil {
+			continue
+		}
+		switch rule.MatchType {
+		case RouteMatchExact:
+			if _, ok := m.exactRules[rule.Pattern]; ok {
+				return nil, fmt.Errorf("duplicate route rule: %s", rule.Pattern)
+			}
+			m.exactRules[rule.Pattern] = rule.Mode
+		case RouteMatchPrefix:
+			m.prefixRules = append(m.prefixRules, rule)
+		case RouteMatchRegex:
+			regex, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("compile route rule failed: %w", err)
+			}
+			m.regexRules = append(m.regexRules, &regexRouteRule{regex: regex, mode: rule.Mode})
+		default:
+			return nil, fmt.Errorf("unsupported route match type: %d", rule.MatchType)
+		}
+	}
+	sort.SliceStable(m.prefixRules, func(i, j int) bool {
+		return len(m.prefixRules[i].Pattern) > len(m.prefixRules[j].Pattern)
+	})
+	return m, nil
+}
+
+// Match ...
+func (s *routeMatcher) Match(operation string) AuthMode {
+	if mode, ok := s.exactRules[operation]; ok {
+		return mode
+	}
+	for i := range s.prefixRules {
+		if strings.HasPrefix(operation, s.prefixRules[i].Pattern) {
+			return s.prefixRules[i].Mode
+		}
+	}
+	for i := range s.regexRules {
+		if s.regexRules[i].regex.MatchString(operation) {
+			return s.regexRules[i].mode
+		}
+	}
+	return s.defaultMode
+}
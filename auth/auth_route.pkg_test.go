@@ -0,0 +1,109 @@
+package authpkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -count=1 ./auth -test.run=TestNewRouteMatcher
+func TestNewRouteMatcher(t *testing.T) {
+	matcher, err := NewRouteMatcher(AuthModeRequired,
+		ExactRoute("/api.user.v1.User/Login", AuthModePublic),
+		PrefixRoute("/grpc.health.v1.Health/", AuthModePublic),
+		PrefixRoute("/api.article.v1.Article/", AuthModeOptional),
+		PrefixRoute("/api.article.v1.Article/Delete", AuthModeRequired),
+		RegexRoute(`^/api\.\w+\.v1\.\w+/List\w*$`, AuthModeOptional),
+	)
+	require.Nil(t, err)
+
+	tests := []struct {
+		operation string
+		want      AuthMode
+	}{
+		{operation: "/api.user.v1.User/Login", want: AuthModePublic},
+		{operation: "/api.user.v1.User/LoginByEmail", want: AuthModeRequired},
+		{operation: "/grpc.health.v1.Health/Check", want: AuthModePublic},
+		{operation: "/api.article.v1.Article/Get", want: AuthModeOptional},
+		{operation: "/api.article.v1.Article/DeleteAll", want: AuthModeRequired},
+		{operation: "/api.order.v1.Order/ListOrders", want: AuthModeOptional},
+		{operation: "/api.order.v1.Order/Get", want: AuthModeRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			require.Equal(t, tt.want, matcher.Match(tt.operation))
+		})
+	}
+
+	_, err = NewRouteMatcher(AuthModeRequired, RegexRoute(`(`, AuthModePublic))
+	require.NotNil(t, err)
+	_, err = NewRouteMatcher(AuthModeRequired, ExactRoute("/a", AuthModePublic), ExactRoute("/a", AuthModeOptional))
+	require.NotNil(t, err)
+}
+
+// go test -v -count=1 ./auth -test.run=TestServer_RouteMatcher
+func TestServer_RouteMatcher(t *testing.T) {
+	repo, _ := newTestAuthRepo(t)
+	matcher, err := NewRouteMatcher(AuthModeRequired,
+		ExactRoute("/api.user.v1.User/Login", AuthModePublic),
+		ExactRoute("/api.article.v1.Article/Get", AuthModeOptional),
+	)
+	require.Nil(t, err)
+	server := Server(
+		repo.JWTSigningKeyFunc,
+		WithSigningMethod(repo.JWTSigningMethod()),
+		WithClaims(repo.JWTSigningClaims),
+		WithTokenValidator(repo.VerifyToken),
+		WithRouteMatcher(matcher),
+	)
+
+	signed, _, err := repo.SignToken(context.Background(), DefaultClaims(Payload{UserID: 1}))
+	require.Nil(t, err)
+
+	var handler middleware.Handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+		_, ok := GetAuthClaimsFromContext(ctx)
+		return ok, nil
+	}
+	call := func(operation, token string) (hasClaims bool, err error) {
+		tr := newTestTransport(operation)
+		if token != "" {
+			tr.reqHeader.Set(AuthorizationKey, token)
+		}
+		reply, err := server(handler)(transport.NewServerContext(context.Background(), tr), nil)
+		if err != nil {
+			return false, err
+		}
+		return reply.(bool), nil
+	}
+
+	// 公开
+	hasClaims, err := call("/api.user.v1.User/Login", "")
+	require.Nil(t, err)
+	require.False(t, hasClaims)
+	hasClaims, err = call("/api.user.v1.User/Login", signed.AccessToken)
+	require.Nil(t, err)
+	require.False(t, hasClaims)
+
+	// 可选
+	hasClaims, err = call("/api.article.v1.Article/Get", "")
+	require.Nil(t, err)
+	require.False(t, hasClaims)
+	hasClaims, err = call("/api.article.v1.Article/Get", "invalid")
+	require.Nil(t, err)
+	require.False(t, hasClaims)
+	hasClaims, err = call("/api.article.v1.Article/Get", signed.AccessToken)
+	require.Nil(t, err)
+	require.True(t, hasClaims)
+
+	// 必须
+	_, err = call("/api.article.v1.Article/Delete", "")
+	require.True(t, Is(err, ErrMissingToken()))
+	_, err = call("/api.article.v1.Article/Delete", "invalid")
+	require.NotNil(t, err)
+	hasClaims, err = call("/api.article.v1.Article/Delete", signed.AccessToken)
+	require.Nil(t, err)
+	require.True(t, hasClaims)
+}
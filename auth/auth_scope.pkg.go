@@ -0,0 +1,285 @@
+package authpkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	errorpkg "github.com/eden-quan/go-kratos-pkg/error"
+)
+
+// ScopeDefaultPolicy 未声明授权规则的方法的默认策略
+type ScopeDefaultPolicy int
+
+const (
+	// ScopeDefaultAuthenticated 需要令牌；不检查权限与角色
+	ScopeDefaultAuthenticated ScopeDefaultPolicy = iota
+	// ScopeDefaultAllow 放行
+	ScopeDefaultAllow
+	// ScopeDefaultDeny 拒绝
+	ScopeDefaultDeny
+)
+
+// String ...
+func (s ScopeDefaultPolicy) String() string {
+	switch s {
+	case ScopeDefaultAuthenticated:
+		return "authenticated"
+	case ScopeDefaultAllow:
+		return "allow"
+	case ScopeDefaultDeny:
+		return "deny"
+	}
+	return fmt.Sprintf("ScopeDefaultPolicy(%d)", int(s))
+}
+
+// ScopeRegistry 方法的授权规则：从 protobuf 描述符的方法选项 (pkg.authpkg.auth) 读取
+// 同时实现 RouteMatcher：公开方法不解析令牌；参考 WithRouteMatcher
+type ScopeRegistry interface {
+	RouteMatcher
+	// Rule 方法的授权规则；operation 例：/api.user.v1.User/Delete
+	Rule(operation string) (*AuthRule, bool)
+	// DefaultPolicy 未声明授权规则的方法的默认策略
+	DefaultPolicy() ScopeDefaultPolicy
+}
+
+// ScopeRegistryOption ...
+type ScopeRegistryOption func(*scopeRegistryOptions)
+
+// scopeRegistryOptions ...
+type scopeRegistryOptions struct {
+	files         *protoregistry.Files
+	defaultPolicy ScopeDefaultPolicy
+}
+
+// WithScopeFiles 读取的描述符；默认 protoregistry.GlobalFiles，即程序中所有已生成的 proto
+func WithScopeFiles(files *protoregistry.Files) ScopeRegistryOption {
+	return func(o *scopeRegistryOptions) {
+		o.files = files
+	}
+}
+
+// WithScopeDefaultPolicy 未声明授权规则的方法的默认策略；默认 ScopeDefaultAuthenticated
+func WithScopeDefaultPolicy(policy ScopeDefaultPolicy) ScopeRegistryOption {
+	return func(o *scopeRegistryOptions) {
+		o.defaultPolicy = policy
+	}
+}
+
+// scopeRegistry ...
+type scopeRegistry struct {
+	rules         map[string]*AuthRule
+	defaultPolicy ScopeDefaultPolicy
+}
+
+// NewScopeRegistry 启动时读取所有服务方法的授权规则
+func NewScopeRegistry(opts ...ScopeRegistryOption) (ScopeRegistry, error) {
+	o := &scopeRegistryOptions{
+		files:         protoregistry.GlobalFiles,
+		defaultPolicy: ScopeDefaultAuthenticated,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	registry := &scopeRegistry{
+		rules:         make(map[string]*AuthRule),
+		defaultPolicy: o.defaultPolicy,
+	}
+	var err error
+	o.files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		services := fd.Services()
+		for i := 0; i < services.Len(); i++ {
+			methods := services.Get(i).Methods()
+			for j := 0; j < methods.Len(); j++ {
+				method := methods.Get(j)
+				rule, ok, ruleErr := methodAuthRule(method)
+				if ruleErr != nil {
+					err = fmt.Errorf("read auth rule of %s failed : %w", method.FullName(), ruleErr)
+					return false
+				}
+				if ok {
+					registry.rules[scopeOperation(method)] = rule
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// scopeOperation kratos operation：/包名.服务名/方法名
+func scopeOperation(method protoreflect.MethodDescriptor) string {
+	return "/" + string(method.Parent().FullName()) + "/" + string(method.Name())
+}
+
+// methodAuthRule 方法选项中的授权规则
+// 动态描述符的方法选项可能不是 descriptorpb.MethodOptions，或扩展保存在未知字段中：重新解析
+func methodAuthRule(method protoreflect.MethodDescriptor) (*AuthRule, bool, error) {
+	options := method.Options()
+	if options == nil {
+		return nil, false, nil
+	}
+	methodOptions, ok := options.(*descriptorpb.MethodOptions)
+	if !ok || (!proto.HasExtension(methodOptions, E_Auth) && len(methodOptions.ProtoReflect().GetUnknown()) > 0) {
+		data, err := proto.Marshal(options)
+		if err != nil {
+			return nil, false, err
+		}
+		methodOptions = &descriptorpb.MethodOptions{}
+		err = proto.UnmarshalOptions{Resolver: protoregistry.GlobalTypes}.Unmarshal(data, methodOptions)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	if !proto.HasExtension(methodOptions, E_Auth) {
+		return nil, false, nil
+	}
+	rule, ok := proto.GetExtension(methodOptions, E_Auth).(*AuthRule)
+	if !ok || rule == nil {
+		return nil, false, nil
+	}
+	return rule, true, nil
+}
+
+// Rule ...
+func (s *scopeRegistry) Rule(operation string) (*AuthRule, bool) {
+	rule, ok := s.rules[operation]
+	return rule, ok
+}
+
+// DefaultPolicy ...
+func (s *scopeRegistry) DefaultPolicy() ScopeDefaultPolicy {
+	return s.defaultPolicy
+}
+
+// Match 公开方法不解析令牌；默认放行的方法令牌可选
+func (s *scopeRegistry) Match(operation string) AuthMode {
+	if rule, ok := s.rules[operation]; ok {
+		if rule.GetPublic() {
+			return AuthModePublic
+		}
+		return AuthModeRequired
+	}
+	if s.defaultPolicy == ScopeDefaultAllow {
+		return AuthModeOptional
+	}
+	return AuthModeRequired
+}
+
+// ScopeAuthorizationOption ...
+type ScopeAuthorizationOption func(*scopeAuthorizationOptions)
+
+// scopeAuthorizationOptions ...
+type scopeAuthorizationOptions struct {
+	policySource PolicySource
+}
+
+// WithScopePolicySource 角色继承：使用授权策略的 RoleInherits 展开令牌的角色，与 Authorization 一致
+// 未配置时仅匹配令牌的角色
+func WithScopePolicySource(source PolicySource) ScopeAuthorizationOption {
+	return func(o *scopeAuthorizationOptions) {
+		o.policySource = source
+	}
+}
+
+// ScopeAuthorization 授权中间件：根据方法声明的授权规则校验令牌的权限(Scopes)与角色(Roles)
+// 需在 Server 之后使用；令牌信息从 GetAuthClaimsFromContext 获取
+// 缺少令牌时返回 ErrMissingToken，缺少权限或角色时返回 ErrPermissionDenied，元数据 scopes 为需要的权限
+func ScopeAuthorization(registry ScopeRegistry, opts ...ScopeAuthorizationOption) middleware.Middleware {
+	o := &scopeAuthorizationOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok {
+				e := ErrWrongContext()
+				return nil, errorpkg.WithStack(e)
+			}
+			var payload *Payload
+			if authClaims, ok := GetAuthClaimsFromContext(ctx); ok {
+				payload = authClaims.Payload
+			}
+
+			rule, ok := registry.Rule(tr.Operation())
+			if !ok {
+				switch registry.DefaultPolicy() {
+				case ScopeDefaultAllow:
+					return handler(ctx, req)
+				case ScopeDefaultDeny:
+					e := ErrPermissionDenied()
+					e.Metadata = map[string]string{"operation": tr.Operation()}
+					return nil, errorpkg.WithStack(e)
+				}
+				if payload == nil {
+					e := ErrMissingToken()
+					return nil, errorpkg.WithStack(e)
+				}
+				return handler(ctx, req)
+			}
+
+			if rule.GetPublic() {
+				return handler(ctx, req)
+			}
+			if payload == nil {
+				e := ErrMissingToken()
+				return nil, errorpkg.WithStack(e)
+			}
+			var policy *Policy
+			if o.policySource != nil && len(rule.GetRoles()) > 0 {
+				var err error
+				if policy, err = o.policySource.Policy(ctx); err != nil {
+					e := ErrPermissionDenied()
+					e.Metadata = map[string]string{"operation": tr.Operation(), "error": err.Error()}
+					return nil, errorpkg.WithStack(e)
+				}
+			}
+			if !allowScopeRule(rule, payload, policy) {
+				e := ErrPermissionDenied()
+				e.Metadata = map[string]string{"operation": tr.Operation()}
+				if len(rule.GetScopes()) > 0 {
+					e.Metadata["scopes"] = strings.Join(rule.GetScopes(), " ")
+				}
+				return nil, errorpkg.WithStack(e)
+			}
+			return handler(ctx, req)
+		}
+	}
+}
+
+// allowScopeRule 需要其中任一角色，且需要所有权限；policy 不为空时角色包括继承的角色
+func allowScopeRule(rule *AuthRule, payload *Payload, policy *Policy) bool {
+	if roles := rule.GetRoles(); len(roles) > 0 {
+		if policy == nil {
+			policy = &Policy{}
+		}
+		granted := policy.Roles(payload.Roles)
+		var ok bool
+		for i := range roles {
+			if _, ok = granted[roles[i]]; ok {
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	for _, scope := range rule.GetScopes() {
+		if !matchAnyWildcard(payload.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
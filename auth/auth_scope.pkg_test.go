@@ -0,0 +1,176 @@
+package authpkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// newTestScopeFiles 动态描述符：服务 test.scope.v1.Demo
+// unknown 扩展保存在未知字段中；例：未注册扩展类型时解析的描述符
+func newTestScopeFiles(t *testing.T, rules map[string]*AuthRule, unknown bool) *protoregistry.Files {
+	service := &descriptorpb.ServiceDescriptorProto{Name: proto.String("Demo")}
+	for _, name := range []string{"Get", "Delete", "Ping", "List"} {
+		method := &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(name),
+			InputType:  proto.String(".test.scope.v1.Empty"),
+			OutputType: proto.String(".test.scope.v1.Empty"),
+		}
+		if rule, ok := rules[name]; ok {
+			options := &descriptorpb.MethodOptions{}
+			proto.SetExtension(options, E_Auth, rule)
+			if unknown {
+				data, err := proto.Marshal(options)
+				require.Nil(t, err)
+				options = &descriptorpb.MethodOptions{}
+				require.Nil(t, proto.UnmarshalOptions{Resolver: &protoregistry.Types{}}.Unmarshal(data, options))
+				require.False(t, proto.HasExtension(options, E_Auth))
+			}
+			method.Options = options
+		}
+		service.Method = append(service.Method, method)
+	}
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("test/scope.proto"),
+		Package:     proto.String("test.scope.v1"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Empty")}},
+		Service:     []*descriptorpb.ServiceDescriptorProto{service},
+	}, nil)
+	require.Nil(t, err)
+	files := &protoregistry.Files{}
+	require.Nil(t, files.RegisterFile(fd))
+	return files
+}
+
+// go test -v -count=1 ./auth -test.run=TestScopeRegistry
+func TestScopeRegistry(t *testing.T) {
+	rules := map[string]*AuthRule{
+		"Get":    {Scopes: []string{"user:read"}},
+		"Delete": {Scopes: []string{"user:delete"}, Roles: []string{"admin"}},
+		"Ping":   {Public: true},
+	}
+	for _, unknown := range []bool{false, true} {
+		registry, err := NewScopeRegistry(WithScopeFiles(newTestScopeFiles(t, rules, unknown)))
+		require.Nil(t, err)
+
+		rule, ok := registry.Rule("/test.scope.v1.Demo/Delete")
+		require.True(t, ok)
+		require.Equal(t, []string{"user:delete"}, rule.GetScopes())
+		require.Equal(t, []string{"admin"}, rule.GetRoles())
+		_, ok = registry.Rule("/test.scope.v1.Demo/List")
+		require.False(t, ok)
+
+		require.Equal(t, AuthModeRequired, registry.Match("/test.scope.v1.Demo/Get"))
+		require.Equal(t, AuthModePublic, registry.Match("/test.scope.v1.Demo/Ping"))
+		require.Equal(t, AuthModeRequired, registry.Match("/test.scope.v1.Demo/List"))
+	}
+
+	// 默认读取已生成的 proto
+	registry, err := NewScopeRegistry(WithScopeDefaultPolicy(ScopeDefaultAllow))
+	require.Nil(t, err)
+	require.Equal(t, ScopeDefaultAllow, registry.DefaultPolicy())
+	require.Equal(t, AuthModeOptional, registry.Match("/test.scope.v1.Demo/List"))
+}
+
+// go test -v -count=1 ./auth -test.run=TestScopeAuthorization
+func TestScopeAuthorization(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		files = newTestScopeFiles(t, map[string]*AuthRule{
+			"Get":    {Scopes: []string{"user:read"}},
+			"Delete": {Scopes: []string{"user:delete"}, Roles: []string{"admin"}},
+			"Ping":   {Public: true},
+		}, false)
+		repo, err = NewAuthRepo(newMemoryTokenManger(time.Now), log.DefaultLogger, Config{SignKey: "1234567890ABCDEF"})
+		request   = func(registry ScopeRegistry, method, token string, opts ...ScopeAuthorizationOption) error {
+			tr := newTestTransport("/test.scope.v1.Demo/" + method)
+			if token != "" {
+				tr.reqHeader.Set(AuthorizationKey, token)
+			}
+			_, err := middleware.Chain(
+				Server(
+					repo.JWTSigningKeyFunc,
+					WithSigningMethod(repo.JWTSigningMethod()),
+					WithClaims(repo.JWTSigningClaims),
+					WithTokenValidator(repo.VerifyToken),
+					WithRouteMatcher(registry),
+				),
+				ScopeAuthorization(registry, opts...),
+			)(func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})(transport.NewServerContext(ctx, tr), nil)
+			return err
+		}
+		signToken = func(payload Payload) string {
+			res, _, err := repo.SignToken(ctx, DefaultClaims(payload))
+			require.Nil(t, err)
+			return res.AccessToken
+		}
+	)
+	require.Nil(t, err)
+	registry, err := NewScopeRegistry(WithScopeFiles(files))
+	require.Nil(t, err)
+
+	var (
+		reader = signToken(Payload{UserID: 1, Scopes: []string{"user:read"}})
+		admin  = signToken(Payload{UserID: 2, Roles: []string{"admin"}, Scopes: []string{"user:*"}})
+		editor = signToken(Payload{UserID: 3, Roles: []string{"editor"}, Scopes: []string{"user:*"}})
+	)
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		want   error
+	}{
+		{name: "#scope", method: "Get", token: reader},
+		{name: "#wildcard_scope", method: "Get", token: admin},
+		{name: "#missing_scope", method: "Delete", token: reader, want: ErrPermissionDenied()},
+		{name: "#scope_and_role", method: "Delete", token: admin},
+		{name: "#missing_role", method: "Delete", token: editor, want: ErrPermissionDenied()},
+		{name: "#missing_token", method: "Get", want: ErrMissingToken()},
+		{name: "#public", method: "Ping"},
+		{name: "#default_authenticated", method: "List", token: reader},
+		{name: "#default_authenticated_missing_token", method: "List", want: ErrMissingToken()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := request(registry, tt.method, tt.token)
+			if tt.want == nil {
+				require.Nil(t, err)
+				return
+			}
+			require.True(t, Is(err, tt.want), "%v", err)
+		})
+	}
+	err = request(registry, "Delete", reader)
+	require.Equal(t, "user:delete", errors.FromError(err).Metadata["scopes"])
+
+	// 默认策略
+	allowRegistry, err := NewScopeRegistry(WithScopeFiles(files), WithScopeDefaultPolicy(ScopeDefaultAllow))
+	require.Nil(t, err)
+	require.Nil(t, request(allowRegistry, "List", ""))
+	require.Nil(t, request(allowRegistry, "List", reader))
+	denyRegistry, err := NewScopeRegistry(WithScopeFiles(files), WithScopeDefaultPolicy(ScopeDefaultDeny))
+	require.Nil(t, err)
+	require.True(t, Is(request(denyRegistry, "List", admin), ErrPermissionDenied()))
+	require.Nil(t, request(denyRegistry, "Get", reader))
+
+	// 角色继承：与授权策略一致
+	superAdmin := signToken(Payload{UserID: 4, Roles: []string{"super_admin"}, Scopes: []string{"user:*"}})
+	require.True(t, Is(request(registry, "Delete", superAdmin), ErrPermissionDenied()))
+	source := NewStaticPolicySource(&Policy{RoleInherits: map[string][]string{"super_admin": {"admin"}}})
+	require.Nil(t, request(registry, "Delete", superAdmin, WithScopePolicySource(source)))
+	require.Nil(t, request(registry, "Delete", admin, WithScopePolicySource(source)))
+	require.True(t, Is(request(registry, "Delete", editor, WithScopePolicySource(source)), ErrPermissionDenied()))
+}
@@ -0,0 +1,158 @@
+package authpkg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	errorpkg "github.com/eden-quan/go-kratos-pkg/error"
+	uuidpkg "github.com/eden-quan/go-kratos-pkg/uuid"
+)
+
+// DefaultServiceTokenExpire 服务令牌的默认有效期
+const DefaultServiceTokenExpire = time.Minute * 5
+
+// ClientMode 客户端令牌模式
+type ClientMode int
+
+const (
+	// ClientModeSign 使用 WithClaims 签发令牌
+	ClientModeSign ClientMode = iota
+	// ClientModeForward 转发当前请求的用户令牌；需在 Server 之后使用
+	ClientModeForward
+	// ClientModeOnBehalfOf 签发短期的服务令牌，携带调用方服务名称与当前用户的授权信息
+	// 当前请求没有用户时，为服务发起的调用
+	ClientModeOnBehalfOf
+	// ClientModeServiceCredential 服务凭证：缓存服务令牌，在过期前刷新
+	ClientModeServiceCredential
+)
+
+// ServiceTokenFunc 获取服务令牌；例：从认证中心获取
+type ServiceTokenFunc func(ctx context.Context) (token string, expiresAt time.Time, err error)
+
+// NewServiceClaims 服务令牌
+// payload 为空时为服务发起的调用；否则为服务代表用户的调用
+func NewServiceClaims(service string, payload *Payload, expire time.Duration) *Claims {
+	var p Payload
+	if payload != nil {
+		p = *payload
+	} else {
+		p.TokenType = TokenTypeEnum_SERVICE
+	}
+	now := time.Now()
+	return &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(expire)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        uuidpkg.NewUUID(),
+		},
+		Service: service,
+		Payload: &p,
+	}
+}
+
+// IsServiceToken 是否为服务令牌
+func (s *Claims) IsServiceToken() bool {
+	return s.Service != ""
+}
+
+// IsUserInitiated 是否为用户发起的调用：用户令牌或服务代表用户的令牌
+func (s *Claims) IsUserInitiated() bool {
+	return s.Payload != nil && s.Payload.TokenType != TokenTypeEnum_SERVICE
+}
+
+// IsServiceInitiated 是否为服务发起的调用
+func (s *Claims) IsServiceInitiated() bool {
+	return s.IsServiceToken() && !s.IsUserInitiated()
+}
+
+// IsUserInitiated 当前请求是否为用户发起的调用
+func IsUserInitiated(ctx context.Context) bool {
+	authClaims, ok := GetAuthClaimsFromContext(ctx)
+	return ok && authClaims.IsUserInitiated()
+}
+
+// IsServiceInitiated 当前请求是否为服务发起的调用
+func IsServiceInitiated(ctx context.Context) bool {
+	authClaims, ok := GetAuthClaimsFromContext(ctx)
+	return ok && authClaims.IsServiceInitiated()
+}
+
+// GetCallerServiceFromContext 调用方服务名称
+func GetCallerServiceFromContext(ctx context.Context) (string, bool) {
+	authClaims, ok := GetAuthClaimsFromContext(ctx)
+	if !ok || !authClaims.IsServiceToken() {
+		return "", false
+	}
+	return authClaims.Service, true
+}
+
+// forwardClientToken 当前请求的用户令牌
+func forwardClientToken(ctx context.Context) (string, error) {
+	token, ok := GetAuthTokenFromContext(ctx)
+	if !ok {
+		e := ErrMissingToken()
+		return "", errorpkg.WithStack(e)
+	}
+	return token, nil
+}
+
+// newOnBehalfOfClaims 代表当前用户的服务令牌
+// 携带用户时，一并传递代理人、认证强度与令牌代数；下游据此执行代理限制、MFA 要求与代数校验
+func newOnBehalfOfClaims(ctx context.Context, o *options) *Claims {
+	authClaims, ok := GetAuthClaimsFromContext(ctx)
+	if !ok || !authClaims.IsUserInitiated() {
+		return NewServiceClaims(o.serviceName, nil, o.serviceTokenExpire)
+	}
+	claims := NewServiceClaims(o.serviceName, authClaims.Payload, o.serviceTokenExpire)
+	claims.FamilyID = authClaims.FamilyID
+	claims.Generation = authClaims.Generation
+	claims.AMR = append([]string(nil), authClaims.AMR...)
+	claims.ACR = authClaims.ACR
+	claims.AuthTime = authClaims.AuthTime
+	if authClaims.Actor != nil {
+		actor := *authClaims.Actor
+		claims.Actor = &actor
+	}
+	return claims
+}
+
+// serviceCredentialToken 服务凭证
+func serviceCredentialToken(ctx context.Context, customKeyFunc KeyFunc, o *options) (string, time.Time, error) {
+	if o.serviceTokenFunc != nil {
+		return o.serviceTokenFunc(ctx)
+	}
+	claims := NewServiceClaims(o.serviceName, nil, o.serviceTokenExpire)
+	token, err := signClientToken(ctx, customKeyFunc, o, claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, claims.ExpiresAt.Time, nil
+}
+
+// serviceCredential 缓存的服务令牌
+type serviceCredential struct {
+	mu          sync.Mutex
+	token       string
+	refreshedAt time.Time
+	expiresAt   time.Time
+}
+
+// Token 剩余有效期不足五分之一时刷新
+func (s *serviceCredential) Token(ctx context.Context, refresh func(ctx context.Context) (string, time.Time, error)) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.token != "" && now.Before(s.expiresAt.Add(-s.expiresAt.Sub(s.refreshedAt)/5)) {
+		return s.token, nil
+	}
+	token, expiresAt, err := refresh(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token, s.refreshedAt, s.expiresAt = token, now, expiresAt
+	return token, nil
+}
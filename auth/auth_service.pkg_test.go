@@ -0,0 +1,199 @@
+package authpkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -count=1 ./auth -test.run=TestClient_ServiceIdentity
+func TestClient_ServiceIdentity(t *testing.T) {
+	var (
+		repo, _ = newTestAuthRepo(t)
+		server  = Server(
+			repo.JWTSigningKeyFunc,
+			WithSigningMethod(repo.JWTSigningMethod()),
+			WithClaims(repo.JWTSigningClaims),
+			WithTokenValidator(repo.VerifyToken),
+		)
+		// serve 服务端：返回授权信息的上下文
+		serve = func(token string) (context.Context, error) {
+			tr := newTestTransport("/api.user.v1.User/Get")
+			tr.reqHeader.Set(AuthorizationKey, token)
+			var serverCtx context.Context
+			_, err := server(func(ctx context.Context, req interface{}) (interface{}, error) {
+				serverCtx = ctx
+				return nil, nil
+			})(transport.NewServerContext(context.Background(), tr), nil)
+			return serverCtx, err
+		}
+		// call 客户端：返回请求头中的令牌
+		call = func(ctx context.Context, opts ...Option) (string, error) {
+			tr := newTestTransport("/api.order.v1.Order/List")
+			_, err := Client(repo.JWTSigningKeyFunc, opts...)(func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})(transport.NewClientContext(ctx, tr), nil)
+			return tr.reqHeader.Get(AuthorizationKey), err
+		}
+	)
+	signed, _, err := repo.SignToken(context.Background(), DefaultClaims(Payload{UserID: 1, TokenType: TokenTypeEnum_USER}))
+	require.Nil(t, err)
+	userCtx, err := serve(signed.AccessToken)
+	require.Nil(t, err)
+	require.True(t, IsUserInitiated(userCtx))
+	require.False(t, IsServiceInitiated(userCtx))
+	_, ok := GetCallerServiceFromContext(userCtx)
+	require.False(t, ok)
+
+	// 转发
+	token, err := call(userCtx, WithClientMode(ClientModeForward))
+	require.Nil(t, err)
+	require.Equal(t, signed.AccessToken, token)
+	_, err = call(context.Background(), WithClientMode(ClientModeForward))
+	require.True(t, Is(err, ErrMissingToken()))
+
+	// 代表用户
+	token, err = call(userCtx, WithClientMode(ClientModeOnBehalfOf), WithServiceName("order-service"))
+	require.Nil(t, err)
+	downstreamCtx, err := serve(token)
+	require.Nil(t, err)
+	require.True(t, IsUserInitiated(downstreamCtx))
+	service, ok := GetCallerServiceFromContext(downstreamCtx)
+	require.True(t, ok)
+	require.Equal(t, "order-service", service)
+	authClaims, _ := GetAuthClaimsFromContext(downstreamCtx)
+	require.Equal(t, uint64(1), authClaims.Payload.UserID)
+	require.WithinDuration(t, time.Now().Add(DefaultServiceTokenExpire), authClaims.ExpiresAt.Time, time.Second*2)
+
+	// 没有用户时为服务发起的调用
+	token, err = call(context.Background(), WithClientMode(ClientModeOnBehalfOf), WithServiceName("order-service"))
+	require.Nil(t, err)
+	downstreamCtx, err = serve(token)
+	require.Nil(t, err)
+	require.True(t, IsServiceInitiated(downstreamCtx))
+
+	// 服务凭证：缓存
+	credentialClient := Client(repo.JWTSigningKeyFunc,
+		WithClientMode(ClientModeServiceCredential),
+		WithServiceName("job-service"),
+	)
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		tr := newTestTransport("/api.order.v1.Order/List")
+		_, err = credentialClient(func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})(transport.NewClientContext(userCtx, tr), nil)
+		require.Nil(t, err)
+		tokens = append(tokens, tr.reqHeader.Get(AuthorizationKey))
+	}
+	require.Equal(t, tokens[0], tokens[1])
+	downstreamCtx, err = serve(tokens[0])
+	require.Nil(t, err)
+	require.True(t, IsServiceInitiated(downstreamCtx))
+	service, _ = GetCallerServiceFromContext(downstreamCtx)
+	require.Equal(t, "job-service", service)
+}
+
+// go test -v -count=1 ./auth -test.run=TestClient_OnBehalfOfClaims
+func TestClient_OnBehalfOfClaims(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		repo, _ = newTestAuthRepo(t)
+		server  = Server(
+			repo.JWTSigningKeyFunc,
+			WithSigningMethod(repo.JWTSigningMethod()),
+			WithClaims(repo.JWTSigningClaims),
+			WithTokenValidator(repo.VerifyToken),
+		)
+		serve = func(token string) (*Claims, error) {
+			tr := newTestTransport("/api.user.v1.User/Get")
+			tr.reqHeader.Set(AuthorizationKey, token)
+			var authClaims *Claims
+			_, err := server(func(ctx context.Context, req interface{}) (interface{}, error) {
+				authClaims, _ = GetAuthClaimsFromContext(ctx)
+				return nil, nil
+			})(transport.NewServerContext(ctx, tr), nil)
+			return authClaims, err
+		}
+		call = func(userClaims *Claims) string {
+			tr := newTestTransport("/api.order.v1.Order/List")
+			_, err := Client(repo.JWTSigningKeyFunc, WithClientMode(ClientModeOnBehalfOf), WithServiceName("order-service"))(func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})(transport.NewClientContext(PutAuthClaimsIntoContext(ctx, userClaims), tr), nil)
+			require.Nil(t, err)
+			return tr.reqHeader.Get(AuthorizationKey)
+		}
+	)
+
+	// 代理人与认证强度随令牌传递
+	adminClaims := DefaultClaims(Payload{UserID: 1, TokenType: TokenTypeEnum_ADMIN})
+	_, _, err := repo.SignToken(ctx, adminClaims)
+	require.Nil(t, err)
+	res, err := repo.ImpersonateToken(ctx, &ImpersonateParam{
+		Actor:  adminClaims,
+		Target: Payload{UserID: 2, TokenType: TokenTypeEnum_USER},
+		Reason: "ticket-1",
+	})
+	require.Nil(t, err)
+	impersonationClaims, err := serve(res.AccessToken)
+	require.Nil(t, err)
+	impersonationClaims.AMR = []string{AMRPassword, AMROTP}
+	impersonationClaims.ACR = AMRMultiFactor
+	downstreamClaims, err := serve(call(impersonationClaims))
+	require.Nil(t, err)
+	require.True(t, downstreamClaims.IsServiceToken())
+	require.True(t, downstreamClaims.IsImpersonation())
+	require.Equal(t, impersonationClaims.Actor, downstreamClaims.Actor)
+	require.Equal(t, impersonationClaims.AMR, downstreamClaims.AMR)
+	require.Equal(t, impersonationClaims.ACR, downstreamClaims.ACR)
+	require.Equal(t, impersonationClaims.Generation, downstreamClaims.Generation)
+	require.Equal(t, impersonationClaims.FamilyID, downstreamClaims.FamilyID)
+
+	// 提升令牌代数后，代表用户的服务令牌失效
+	userClaims := DefaultClaims(Payload{UserID: 3, TokenType: TokenTypeEnum_USER})
+	signed, _, err := repo.SignToken(ctx, userClaims)
+	require.Nil(t, err)
+	userClaims, err = serve(signed.AccessToken)
+	require.Nil(t, err)
+	token := call(userClaims)
+	_, err = serve(token)
+	require.Nil(t, err)
+	_, err = repo.BumpTokenGeneration(ctx, userClaims.Payload.UserIdentifier())
+	require.Nil(t, err)
+	_, err = serve(token)
+	require.True(t, Is(err, ErrTokenGenerationRevoked()))
+
+	// 服务发起的调用不受影响
+	serviceToken := call(NewServiceClaims("job-service", nil, DefaultServiceTokenExpire))
+	_, err = serve(serviceToken)
+	require.Nil(t, err)
+}
+
+// go test -v -count=1 ./auth -test.run=TestServiceCredential_Token
+func TestServiceCredential_Token(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		credential = &serviceCredential{}
+		refreshed  int
+		expire     = time.Second * 10
+	)
+	refresh := func(ctx context.Context) (string, time.Time, error) {
+		refreshed++
+		return "token", time.Now().Add(expire), nil
+	}
+	_, err := credential.Token(ctx, refresh)
+	require.Nil(t, err)
+	_, err = credential.Token(ctx, refresh)
+	require.Nil(t, err)
+	require.Equal(t, 1, refreshed)
+
+	// 剩余有效期不足五分之一
+	credential.expiresAt = time.Now().Add(expire / 10)
+	credential.refreshedAt = credential.expiresAt.Add(-expire)
+	_, err = credential.Token(ctx, refresh)
+	require.Nil(t, err)
+	require.Equal(t, 2, refreshed)
+}
@@ -0,0 +1,172 @@
+package authpkg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/transport"
+
+	contextpkg "github.com/eden-quan/go-kratos-pkg/context"
+)
+
+// UserAgentKey 请求头
+const UserAgentKey = "User-Agent"
+
+// Session 登录会话
+type Session struct {
+	TokenID        string
+	RefreshTokenID string
+	FamilyID       string
+	LoginPlatform  LoginPlatformEnum_LoginPlatform
+	LoginType      LoginTypeEnum_LoginType
+	// IssuedAt 签发时间
+	IssuedAt int64
+	// ExpiredAt 过期时间；刷新令牌的过期时间
+	ExpiredAt int64
+	// ClientIP 签发令牌时的客户端ip
+	ClientIP string
+	// UserAgent 签发令牌时的客户端
+	UserAgent string
+	// IsCurrent 是否为当前请求的会话
+	IsCurrent bool
+	// Actor 代理登录的管理员；非代理登录时为空
+	Actor string
+}
+
+// clientInfoFromContext 客户端ip与User-Agent
+func clientInfoFromContext(ctx context.Context) (clientIP, userAgent string) {
+	if ip, ok := contextpkg.GetClientIpFromContext(ctx); ok {
+		clientIP = ip
+	} else {
+		clientIP = contextpkg.ClientIP(ctx)
+	}
+	if tr, ok := transport.FromServerContext(ctx); ok {
+		userAgent = tr.RequestHeader().Get(UserAgentKey)
+	}
+	return clientIP, userAgent
+}
+
+// ListSessions 登录会话
+func (s *authRepo) ListSessions(ctx context.Context, userIdentifier string) ([]*Session, error) {
+	allTokens, err := s.tokenManger.GetAllTokens(ctx, userIdentifier)
+	if err != nil {
+		return nil, fmt.Errorf("GetAllTokens failed: %w", err)
+	}
+
+	var (
+		nowUnix    = time.Now().Unix()
+		sessionMap = make(map[string]*Session)
+		currentID  string
+	)
+	if authClaims, ok := GetAuthClaimsFromContext(ctx); ok {
+		currentID = authClaims.ID
+	}
+	for iKey := range allTokens {
+		item := allTokens[iKey]
+		if item.ExpiredAt <= nowUnix {
+			continue
+		}
+		session, ok := sessionMap[item.TokenID]
+		if !ok {
+			session = &Session{
+				TokenID:        item.TokenID,
+				RefreshTokenID: item.RefreshTokenID,
+				FamilyID:       item.FamilyID,
+				IssuedAt:       item.IssuedAt,
+				ClientIP:       item.ClientIP,
+				UserAgent:      item.UserAgent,
+				IsCurrent:      item.TokenID == currentID,
+				Actor:          item.Actor,
+			}
+			if item.Payload != nil {
+				session.LoginPlatform = item.Payload.LoginPlatform
+				session.LoginType = item.Payload.LoginType
+			}
+			sessionMap[item.TokenID] = session
+		}
+		if item.ExpiredAt > session.ExpiredAt {
+			session.ExpiredAt = item.ExpiredAt
+		}
+	}
+
+	sessions := make([]*Session, 0, len(sessionMap))
+	for _, session := range sessionMap {
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].IssuedAt != sessions[j].IssuedAt {
+			return sessions[i].IssuedAt > sessions[j].IssuedAt
+		}
+		return sessions[i].TokenID < sessions[j].TokenID
+	})
+	return sessions, nil
+}
+
+// RevokeSession 注销登录会话：令牌与刷新令牌加入黑名单
+func (s *authRepo) RevokeSession(ctx context.Context, userIdentifier string, tokenID string) error {
+	return s.revokeSessions(ctx, userIdentifier, "revoke_session", func(item *TokenItem) bool {
+		return item.TokenID == tokenID
+	})
+}
+
+// RevokeOtherSessions 注销其他登录会话
+func (s *authRepo) RevokeOtherSessions(ctx context.Context, authClaims *Claims) error {
+	ctx = tenantContext(ctx, authClaims.Payload)
+	return s.revokeSessions(ctx, authClaims.Payload.UserIdentifier(), "revoke_other_sessions", func(item *TokenItem) bool {
+		return item.TokenID != authClaims.ID
+	})
+}
+
+// RevokeAllSessions 注销所有登录会话
+// 先增加令牌代数：注销过程中并发签发的令牌同样失效
+func (s *authRepo) RevokeAllSessions(ctx context.Context, userIdentifier string) error {
+	if _, err := s.BumpTokenGeneration(ctx, userIdentifier); err != nil {
+		return err
+	}
+	return s.revokeSessions(ctx, userIdentifier, "revoke_all_sessions", func(item *TokenItem) bool {
+		return true
+	})
+}
+
+// revokeSessions 注销匹配的令牌；reason 审计事件的注销方式
+func (s *authRepo) revokeSessions(ctx context.Context, userIdentifier, reason string, match func(item *TokenItem) bool) error {
+	allTokens, err := s.tokenManger.GetAllTokens(ctx, userIdentifier)
+	if err != nil {
+		return fmt.Errorf("GetAllTokens failed: %w", err)
+	}
+	var blacklist []*TokenItem
+	for iKey := range allTokens {
+		if match(allTokens[iKey]) {
+			blacklist = append(blacklist, allTokens[iKey])
+		}
+	}
+	if err := s.tokenManger.AddBlacklist(ctx, userIdentifier, blacklist); err != nil {
+		return fmt.Errorf("AddBlacklist failed: %w", err)
+	}
+	for _, item := range blacklist {
+		if item.IsRefreshToken {
+			continue
+		}
+		event := newAuthEvent(AuthEventRevoke, AuthEventOutcomeSuccess, item.TokenID, item.Payload)
+		event.UserIdentifier = userIdentifier
+		event.FamilyID = item.FamilyID
+		event.Reason = reason
+		s.emitEvent(ctx, event)
+	}
+	return nil
+}
+
+// BumpTokenGeneration 令牌代数加一；此前签发的令牌在验证与刷新时失效，无需逐个加入黑名单
+func (s *authRepo) BumpTokenGeneration(ctx context.Context, userIdentifier string) (uint64, error) {
+	generation, err := s.tokenManger.BumpTokenGeneration(ctx, userIdentifier)
+	if err != nil {
+		return 0, fmt.Errorf("BumpTokenGeneration failed: %w", err)
+	}
+	event := newAuthEvent(AuthEventRevoke, AuthEventOutcomeSuccess, "", nil)
+	event.UserIdentifier = userIdentifier
+	event.Reason = "bump_token_generation"
+	s.emitEvent(ctx, event)
+	return generation, nil
+}
@@ -0,0 +1,131 @@
+package authpkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+
+	contextpkg "github.com/eden-quan/go-kratos-pkg/context"
+)
+
+// go test -v -count=1 ./auth -test.run=TestAuthRepo_Sessions
+func TestAuthRepo_Sessions(t *testing.T) {
+	var (
+		repo, _ = newTestAuthRepo(t)
+		payload = Payload{UserID: 1, LoginType: LoginTypeEnum_PHONE_AND_PASSWORD}
+		signIn  = func(platform LoginPlatformEnum_LoginPlatform, ip, userAgent string) *Claims {
+			tr := newTestTransport("/api.user.v1.User/Login")
+			tr.reqHeader.Set(UserAgentKey, userAgent)
+			ctx := transport.NewServerContext(context.Background(), tr)
+			ctx = contextpkg.SetClientIpToContext(ctx, ip)
+
+			p := payload
+			p.LoginPlatform = platform
+			claims := DefaultClaims(p)
+			_, _, err := repo.SignToken(ctx, claims)
+			require.Nil(t, err)
+			return claims
+		}
+		isValid = func(claims *Claims) bool {
+			return repo.VerifyToken(context.Background(), &jwt.Token{Claims: claims}) == nil
+		}
+	)
+	computer := signIn(LoginPlatformEnum_COMPUTER, "10.0.0.1", "Mozilla/5.0")
+	android := signIn(LoginPlatformEnum_ANDROID, "10.0.0.2", "okhttp/4.9")
+	ios := signIn(LoginPlatformEnum_IOS, "10.0.0.3", "CFNetwork/1404")
+
+	// 当前会话
+	ctx := PutAuthClaimsIntoContext(context.Background(), android)
+	sessions, err := repo.ListSessions(ctx, payload.UserIdentifier())
+	require.Nil(t, err)
+	require.Len(t, sessions, 3)
+	for _, session := range sessions {
+		require.Equal(t, session.TokenID == android.ID, session.IsCurrent)
+		require.Equal(t, LoginTypeEnum_PHONE_AND_PASSWORD, session.LoginType)
+		require.NotZero(t, session.IssuedAt)
+		require.NotZero(t, session.ExpiredAt)
+		require.NotEmpty(t, session.RefreshTokenID)
+		if session.TokenID == computer.ID {
+			require.Equal(t, LoginPlatformEnum_COMPUTER, session.LoginPlatform)
+			require.Equal(t, "10.0.0.1", session.ClientIP)
+			require.Equal(t, "Mozilla/5.0", session.UserAgent)
+		}
+	}
+
+	// 注销一个会话
+	require.Nil(t, repo.RevokeSession(ctx, payload.UserIdentifier(), computer.ID))
+	require.False(t, isValid(computer))
+	sessions, err = repo.ListSessions(ctx, payload.UserIdentifier())
+	require.Nil(t, err)
+	require.Len(t, sessions, 2)
+
+	// 注销其他会话
+	require.Nil(t, repo.RevokeOtherSessions(ctx, android))
+	require.True(t, isValid(android))
+	require.False(t, isValid(ios))
+	sessions, err = repo.ListSessions(ctx, payload.UserIdentifier())
+	require.Nil(t, err)
+	require.Len(t, sessions, 1)
+	require.True(t, sessions[0].IsCurrent)
+
+	// 退出所有设备
+	require.Nil(t, repo.RevokeAllSessions(ctx, payload.UserIdentifier()))
+	err = repo.VerifyToken(ctx, &jwt.Token{Claims: android})
+	require.True(t, errors.Is(err, ErrBlacklist()))
+	sessions, err = repo.ListSessions(ctx, payload.UserIdentifier())
+	require.Nil(t, err)
+	require.Empty(t, sessions)
+}
+
+// go test -v -count=1 ./auth -test.run=TestAuthRepo_BumpTokenGeneration
+func TestAuthRepo_BumpTokenGeneration(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		repo, _ = newTestAuthRepo(t)
+		payload = Payload{UserID: 1, LoginPlatform: LoginPlatformEnum_COMPUTER}
+		signIn  = func() (*TokenResponse, *Claims) {
+			claims := DefaultClaims(payload)
+			res, _, err := repo.SignToken(ctx, claims)
+			require.Nil(t, err)
+			return res, claims
+		}
+		verify = func(claims *Claims) error {
+			return repo.VerifyToken(ctx, &jwt.Token{Claims: claims})
+		}
+	)
+	res1, claims1 := signIn()
+	_, claims2 := signIn()
+	require.Zero(t, claims1.Generation)
+	require.Nil(t, verify(claims1))
+	require.Nil(t, verify(claims2))
+
+	// 此前签发的令牌与刷新令牌全部失效
+	generation, err := repo.BumpTokenGeneration(ctx, payload.UserIdentifier())
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), generation)
+	require.True(t, errors.Is(verify(claims1), ErrTokenGenerationRevoked()))
+	require.True(t, errors.Is(verify(claims2), ErrTokenGenerationRevoked()))
+	_, _, err = repo.RefreshToken(ctx, res1.RefreshToken)
+	require.True(t, errors.Is(err, ErrTokenGenerationRevoked()))
+
+	// 新的令牌与刷新后的令牌使用当前代数
+	res3, claims3 := signIn()
+	require.Equal(t, uint64(1), claims3.Generation)
+	require.Nil(t, verify(claims3))
+	res4, _, err := repo.RefreshToken(ctx, res3.RefreshToken)
+	require.Nil(t, err)
+	claims4, err := repo.DecodeAccessToken(ctx, res4.AccessToken)
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), claims4.Generation)
+	require.Nil(t, verify(claims4))
+
+	// 注销所有会话同时增加代数
+	require.Nil(t, repo.RevokeAllSessions(ctx, payload.UserIdentifier()))
+	require.NotNil(t, verify(claims4))
+	_, claims5 := signIn()
+	require.Equal(t, uint64(2), claims5.Generation)
+}
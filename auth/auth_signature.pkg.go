@@ -0,0 +1,355 @@
+package authpkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	stdhttp "net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/proto"
+
+	errorpkg "github.com/eden-quan/go-kratos-pkg/error"
+	uuidpkg "github.com/eden-quan/go-kratos-pkg/uuid"
+)
+
+const (
+	// SignatureKeyIDKey 请求头：密钥id
+	SignatureKeyIDKey = "X-Signature-Key-Id"
+	// SignatureTimestampKey 请求头：签名时间(秒)
+	SignatureTimestampKey = "X-Signature-Timestamp"
+	// SignatureNonceKey 请求头：随机数；用于防止重放
+	SignatureNonceKey = "X-Signature-Nonce"
+	// SignatureKey 请求头：签名
+	SignatureKey = "X-Signature"
+
+	// DefaultSignatureMaxSkew 签名时间与服务器时间的最大偏差
+	DefaultSignatureMaxSkew = time.Minute * 5
+	// DefaultSignatureNonceKeyPrefix 随机数的缓存key前缀
+	DefaultSignatureNonceKeyPrefix RedisCacheKeyPrefix = "gs:auth:nonce:"
+
+	// signatureGRPCMethod gRPC请求的签名方法
+	signatureGRPCMethod = "GRPC"
+)
+
+// SignatureKeyFunc 根据密钥id获取密钥；密钥不存在时返回nil
+// 轮换密钥时，新旧密钥同时有效
+type SignatureKeyFunc func(ctx context.Context, keyID string) (secret []byte, err error)
+
+// StaticSignatureKeys 固定的密钥：密钥id => 密钥
+func StaticSignatureKeys(keys map[string]string) SignatureKeyFunc {
+	return func(ctx context.Context, keyID string) ([]byte, error) {
+		secret, ok := keys[keyID]
+		if !ok {
+			return nil, nil
+		}
+		return []byte(secret), nil
+	}
+}
+
+// NonceStore 随机数存储：防止重放
+type NonceStore interface {
+	// SaveNonce 记录随机数；已存在时返回false
+	SaveNonce(ctx context.Context, nonce string, expiration time.Duration) (ok bool, err error)
+}
+
+// SignatureOption is signature middleware option.
+type SignatureOption func(*signatureOptions)
+
+// signatureOptions ...
+type signatureOptions struct {
+	maxSkew    time.Duration
+	nonceStore NonceStore
+	now        func() time.Time
+}
+
+// WithSignatureMaxSkew 签名时间与服务器时间的最大偏差；默认 DefaultSignatureMaxSkew
+func WithSignatureMaxSkew(maxSkew time.Duration) SignatureOption {
+	return func(o *signatureOptions) {
+		o.maxSkew = maxSkew
+	}
+}
+
+// WithSignatureNonceStore 随机数存储：NewRedisNonceStore 或 NewMemoryNonceStore；未设置时不检查重放
+func WithSignatureNonceStore(nonceStore NonceStore) SignatureOption {
+	return func(o *signatureOptions) {
+		o.nonceStore = nonceStore
+	}
+}
+
+// newSignatureOptions ...
+func newSignatureOptions(opts []SignatureOption) *signatureOptions {
+	o := &signatureOptions{
+		maxSkew: DefaultSignatureMaxSkew,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// SignatureClient 请求签名：使用密钥签名 方法、路径、查询参数、请求体的哈希、时间与随机数
+// HTTP 请求使用原始请求体；gRPC 请求使用 proto 序列化的请求
+func SignatureClient(keyID, secret string, opts ...SignatureOption) middleware.Middleware {
+	o := newSignatureOptions(opts)
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := transport.FromClientContext(ctx)
+			if !ok {
+				e := ErrWrongContext()
+				return nil, errorpkg.WithStack(e)
+			}
+			var (
+				timestamp = strconv.FormatInt(o.now().Unix(), 10)
+				nonce     = uuidpkg.NewUUID()
+			)
+			canonical, err := canonicalSignatureRequest(tr, req, timestamp, nonce)
+			if err != nil {
+				return nil, err
+			}
+			tr.RequestHeader().Set(SignatureKeyIDKey, keyID)
+			tr.RequestHeader().Set(SignatureTimestampKey, timestamp)
+			tr.RequestHeader().Set(SignatureNonceKey, nonce)
+			tr.RequestHeader().Set(SignatureKey, signRequest([]byte(secret), canonical))
+			return handler(ctx, req)
+		}
+	}
+}
+
+// SignatureServer 验证请求签名：拒绝签名错误、时间超出范围与重放的请求
+func SignatureServer(keyFunc SignatureKeyFunc, opts ...SignatureOption) middleware.Middleware {
+	o := newSignatureOptions(opts)
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok {
+				e := ErrWrongContext()
+				return nil, errorpkg.WithStack(e)
+			}
+			if err := verifySignature(ctx, tr, req, keyFunc, o); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}
+	}
+}
+
+// verifySignature ...
+func verifySignature(ctx context.Context, tr transport.Transporter, req interface{}, keyFunc SignatureKeyFunc, o *signatureOptions) error {
+	var (
+		keyID     = tr.RequestHeader().Get(SignatureKeyIDKey)
+		timestamp = tr.RequestHeader().Get(SignatureTimestampKey)
+		nonce     = tr.RequestHeader().Get(SignatureNonceKey)
+		signature = tr.RequestHeader().Get(SignatureKey)
+	)
+	if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+		e := ErrSignatureMissing()
+		return errorpkg.WithStack(e)
+	}
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		e := ErrSignatureInvalid()
+		return errorpkg.WithStack(e)
+	}
+	if skew := o.now().Sub(time.Unix(signedAt, 0)); skew > o.maxSkew || skew < -o.maxSkew {
+		e := ErrSignatureExpired()
+		return errorpkg.WithStack(e)
+	}
+	secret, err := keyFunc(ctx, keyID)
+	if err != nil {
+		e := ErrSignatureInvalid()
+		e.Metadata = map[string]string{"error": err.Error()}
+		return errorpkg.WithStack(e)
+	}
+	if len(secret) == 0 {
+		e := ErrSignatureInvalid()
+		return errorpkg.WithStack(e)
+	}
+	canonical, err := canonicalSignatureRequest(tr, req, timestamp, nonce)
+	if err != nil {
+		return err
+	}
+	expected := signRequest(secret, canonical)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		e := ErrSignatureInvalid()
+		return errorpkg.WithStack(e)
+	}
+	// 签名验证通过后记录随机数，避免伪造的请求占用随机数
+	if o.nonceStore == nil {
+		return nil
+	}
+	ok, err := o.nonceStore.SaveNonce(ctx, keyID+":"+nonce, o.maxSkew*2)
+	if err != nil {
+		e := ErrSignatureInvalid()
+		e.Metadata = map[string]string{"error": err.Error()}
+		return errorpkg.WithStack(e)
+	}
+	if !ok {
+		e := ErrSignatureReplayed()
+		return errorpkg.WithStack(e)
+	}
+	return nil
+}
+
+// signRequest HMAC-SHA256
+func signRequest(secret []byte, canonical string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// canonicalSignatureRequest 签名内容：方法、路径、查询参数、请求体的哈希、时间、随机数；以换行分隔
+func canonicalSignatureRequest(tr transport.Transporter, req interface{}, timestamp, nonce string) (string, error) {
+	var (
+		method = signatureGRPCMethod
+		path   = tr.Operation()
+		query  string
+		body   []byte
+		err    error
+	)
+	if ht, ok := tr.(http.Transporter); ok && ht.Request() != nil {
+		r := ht.Request()
+		method = r.Method
+		path = r.URL.EscapedPath()
+		query = CanonicalQuery(r.URL.Query())
+		body, err = readRequestBody(r)
+	} else if msg, ok := req.(proto.Message); ok {
+		body, err = proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	}
+	if err != nil {
+		e := ErrSignatureInvalid()
+		e.Metadata = map[string]string{"error": err.Error()}
+		return "", errorpkg.WithStack(e)
+	}
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		method,
+		path,
+		query,
+		hex.EncodeToString(bodyHash[:]),
+		timestamp,
+		nonce,
+	}, "\n"), nil
+}
+
+// CanonicalQuery 查询参数按名称与值排序
+func CanonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf strings.Builder
+	for _, k := range keys {
+		vs := append([]string(nil), values[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			if buf.Len() > 0 {
+				buf.WriteByte('&')
+			}
+			buf.WriteString(url.QueryEscape(k))
+			buf.WriteByte('=')
+			buf.WriteString(url.QueryEscape(v))
+		}
+	}
+	return buf.String()
+}
+
+// readRequestBody 读取请求体并重置
+func readRequestBody(r *stdhttp.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == stdhttp.NoBody {
+		return nil, nil
+	}
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("get request body failed : %w", err)
+		}
+		defer func() { _ = body.Close() }()
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("read request body failed : %w", err)
+	}
+	return data, nil
+}
+
+// redisNonceStore ...
+type redisNonceStore struct {
+	redisCC   redis.UniversalClient
+	keyPrefix RedisCacheKeyPrefix
+}
+
+// NewRedisNonceStore 基于Redis的随机数存储；keyPrefix 为空时使用 DefaultSignatureNonceKeyPrefix
+func NewRedisNonceStore(redisCC redis.UniversalClient, keyPrefix RedisCacheKeyPrefix) NonceStore {
+	if keyPrefix == "" {
+		keyPrefix = DefaultSignatureNonceKeyPrefix
+	}
+	return &redisNonceStore{
+		redisCC:   redisCC,
+		keyPrefix: keyPrefix,
+	}
+}
+
+// SaveNonce ...
+func (s *redisNonceStore) SaveNonce(ctx context.Context, nonce string, expiration time.Duration) (bool, error) {
+	return s.redisCC.SetNX(ctx, s.keyPrefix.String()+nonce, 1, expiration).Result()
+}
+
+// memoryNonceStore ...
+type memoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryNonceStore 基于进程内存的随机数存储；适用于单节点部署与测试
+func NewMemoryNonceStore() NonceStore {
+	return newMemoryNonceStore(time.Now)
+}
+
+// newMemoryNonceStore ...
+func newMemoryNonceStore(now func() time.Time) *memoryNonceStore {
+	return &memoryNonceStore{
+		nonces: make(map[string]time.Time),
+		now:    now,
+	}
+}
+
+// SaveNonce 定期清除过期的随机数
+func (s *memoryNonceStore) SaveNonce(ctx context.Context, nonce string, expiration time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if !now.Before(s.nextSweep) {
+		for k, expireAt := range s.nonces {
+			if !expireAt.After(now) {
+				delete(s.nonces, k)
+			}
+		}
+		s.nextSweep = now.Add(expiration)
+	}
+	if expireAt, ok := s.nonces[nonce]; ok && expireAt.After(now) {
+		return false, nil
+	}
+	s.nonces[nonce] = now.Add(expiration)
+	return true, nil
+}
@@ -0,0 +1,125 @@
+package authpkg
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// go test -v -count=1 ./auth -test.run=TestSignature
+func TestSignature(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		mr      = miniredis.RunT(t)
+		redisCC = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		keys    = StaticSignatureKeys(map[string]string{
+			"old": "old-secret",
+			"new": "new-secret",
+		})
+		server = SignatureServer(keys, WithSignatureNonceStore(NewRedisNonceStore(redisCC, "")))
+		next   = func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+		// signHTTP 签名HTTP请求；返回服务端的请求
+		signHTTP = func(keyID, secret, method, target, body string) *testHTTPTransport {
+			r := httptest.NewRequest(method, target, strings.NewReader(body))
+			_, err := SignatureClient(keyID, secret)(next)(transport.NewClientContext(ctx, newTestHTTPTransport(r)), nil)
+			require.Nil(t, err)
+			serverRequest := httptest.NewRequest(method, target, strings.NewReader(body))
+			serverRequest.Header = r.Header.Clone()
+			return newTestHTTPTransport(serverRequest)
+		}
+		verify = func(tr transport.Transporter, req interface{}) error {
+			_, err := server(next)(transport.NewServerContext(ctx, tr), req)
+			return err
+		}
+	)
+	defer func() { _ = redisCC.Close() }()
+
+	// 多个有效的密钥
+	require.Nil(t, verify(signHTTP("old", "old-secret", "POST", "/v1/callback?b=2&a=1&a=0", `{"id":1}`), nil))
+	require.Nil(t, verify(signHTTP("new", "new-secret", "POST", "/v1/callback", `{"id":1}`), nil))
+	require.True(t, Is(verify(signHTTP("unknown", "new-secret", "POST", "/v1/callback", ""), nil), ErrSignatureInvalid()))
+	require.True(t, Is(verify(signHTTP("new", "old-secret", "POST", "/v1/callback", ""), nil), ErrSignatureInvalid()))
+
+	// 请求被篡改
+	tampers := []func(tr *testHTTPTransport){
+		func(tr *testHTTPTransport) { tr.request.Method = "PUT" },
+		func(tr *testHTTPTransport) { tr.request.URL.Path = "/v1/other" },
+		func(tr *testHTTPTransport) { tr.request.URL.RawQuery = "a=2" },
+		func(tr *testHTTPTransport) {
+			tr.request.Body = httptest.NewRequest("POST", "/", strings.NewReader(`{"id":2}`)).Body
+		},
+		func(tr *testHTTPTransport) { tr.reqHeader.Set(SignatureNonceKey, "other") },
+	}
+	for i, tamper := range tampers {
+		tr := signHTTP("new", "new-secret", "POST", "/v1/callback?a=1", `{"id":1}`)
+		tamper(tr)
+		require.True(t, Is(verify(tr, nil), ErrSignatureInvalid()), i)
+	}
+
+	// 缺少签名
+	require.True(t, Is(verify(newTestHTTPTransport(httptest.NewRequest("GET", "/v1/callback", nil)), nil), ErrSignatureMissing()))
+
+	// 重放
+	tr := signHTTP("new", "new-secret", "POST", "/v1/callback", `{"id":1}`)
+	replay := newTestHTTPTransport(httptest.NewRequest("POST", "/v1/callback", strings.NewReader(`{"id":1}`)))
+	replay.request.Header = tr.request.Header.Clone()
+	replay.reqHeader = testHeader(replay.request.Header)
+	require.Nil(t, verify(tr, nil))
+	require.True(t, Is(verify(replay, nil), ErrSignatureReplayed()))
+
+	// 时间超出范围
+	tr = signHTTP("new", "new-secret", "GET", "/v1/callback", "")
+	stale := SignatureServer(keys, WithSignatureMaxSkew(time.Minute))
+	_, err := stale(next)(transport.NewServerContext(ctx, tr), nil)
+	require.Nil(t, err)
+	signedAt, _ := strconv.ParseInt(tr.reqHeader.Get(SignatureTimestampKey), 10, 64)
+	tr.reqHeader.Set(SignatureTimestampKey, strconv.FormatInt(signedAt-120, 10))
+	_, err = stale(next)(transport.NewServerContext(ctx, tr), nil)
+	require.True(t, Is(err, ErrSignatureExpired()))
+
+	// gRPC：使用 proto 序列化的请求
+	grpcTr := newTestTransport("/api.user.v1.User/Callback")
+	_, err = SignatureClient("new", "new-secret")(next)(transport.NewClientContext(ctx, grpcTr), wrapperspb.String("hello"))
+	require.Nil(t, err)
+	require.True(t, Is(verify(grpcTr, wrapperspb.String("world")), ErrSignatureInvalid()))
+	require.Nil(t, verify(grpcTr, wrapperspb.String("hello")))
+}
+
+// go test -v -count=1 ./auth -test.run=TestCanonicalQuery
+func TestCanonicalQuery(t *testing.T) {
+	values, err := url.ParseQuery("b=2&a=z&a=1&c=a+b&d=")
+	require.Nil(t, err)
+	require.Equal(t, "a=1&a=z&b=2&c=a+b&d=", CanonicalQuery(values))
+	require.Equal(t, "", CanonicalQuery(nil))
+}
+
+// go test -v -count=1 ./auth -test.run=TestMemoryNonceStore
+func TestMemoryNonceStore(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		clock = time.Now()
+		store = newMemoryNonceStore(func() time.Time { return clock })
+	)
+	ok, err := store.SaveNonce(ctx, "1", time.Minute)
+	require.Nil(t, err)
+	require.True(t, ok)
+	ok, _ = store.SaveNonce(ctx, "1", time.Minute)
+	require.False(t, ok)
+
+	clock = clock.Add(time.Minute)
+	ok, _ = store.SaveNonce(ctx, "2", time.Minute)
+	require.True(t, ok)
+	require.Len(t, store.nonces, 1)
+	ok, _ = store.SaveNonce(ctx, "1", time.Minute)
+	require.True(t, ok)
+}
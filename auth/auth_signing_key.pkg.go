@@ -0,0 +1,172 @@
+package authpkg
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+
+	rsapkg "github.com/eden-quan/go-kratos-pkg/rsa"
+)
+
+// signingKey 签名密钥
+type signingKey struct {
+	// signKey 签名密钥：HMAC为[]byte，非对称签名为私钥；仅验证的服务为nil
+	signKey interface{}
+	// verifyKey 验证密钥：HMAC为[]byte，非对称签名为公钥
+	verifyKey interface{}
+}
+
+// newSigningKey 根据签名方法生成密钥
+// HMAC 使用 secret；RSA/ECDSA/Ed25519 使用PEM格式的私钥签名、公钥验证
+// 非对称签名仅配置公钥时，只能验证令牌，不能签发令牌
+func newSigningKey(method jwt.SigningMethod, secret string, privateKey, publicKey []byte) (*signingKey, error) {
+	if method == nil {
+		method = jwt.SigningMethodHS256
+	}
+	key := &signingKey{}
+	if IsHMACSigningMethod(method) {
+		if secret == "" {
+			return nil, fmt.Errorf("sign key is empty")
+		}
+		key.signKey = []byte(secret)
+		key.verifyKey = []byte(secret)
+		return key, nil
+	}
+
+	if len(privateKey) == 0 && len(publicKey) == 0 {
+		return nil, fmt.Errorf("private key and public key are empty; signing method: %s", method.Alg())
+	}
+	if len(privateKey) > 0 {
+		priKey, err := ParsePrivateKey(method, privateKey)
+		if err != nil {
+			return nil, err
+		}
+		key.signKey = priKey
+		key.verifyKey = publicKeyOf(priKey)
+	}
+	if len(publicKey) > 0 {
+		pubKey, err := ParsePublicKey(method, publicKey)
+		if err != nil {
+			return nil, err
+		}
+		// 同时配置私钥与公钥：公钥必须与私钥匹配，否则签发的令牌无法验证
+		if key.verifyKey != nil && !isSamePublicKey(key.verifyKey, pubKey) {
+			return nil, fmt.Errorf("public key does not match private key; signing method: %s", method.Alg())
+		}
+		key.verifyKey = pubKey
+	}
+	return key, nil
+}
+
+// canSign 是否可签发令牌
+func (s *signingKey) canSign() bool {
+	return s.signKey != nil
+}
+
+// IsHMACSigningMethod 是否为HMAC签名方法
+func IsHMACSigningMethod(method jwt.SigningMethod) bool {
+	_, ok := method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// IsSameSigningMethodFamily 签名方法是否属于同一类型(HMAC/RSA/RSA-PSS/ECDSA/Ed25519)
+func IsSameSigningMethodFamily(a, b jwt.SigningMethod) bool {
+	switch a.(type) {
+	case *jwt.SigningMethodHMAC:
+		_, ok := b.(*jwt.SigningMethodHMAC)
+		return ok
+	case *jwt.SigningMethodRSAPSS:
+		_, ok := b.(*jwt.SigningMethodRSAPSS)
+		return ok
+	case *jwt.SigningMethodRSA:
+		_, ok := b.(*jwt.SigningMethodRSA)
+		return ok
+	case *jwt.SigningMethodECDSA:
+		_, ok := b.(*jwt.SigningMethodECDSA)
+		return ok
+	case *jwt.SigningMethodEd25519:
+		_, ok := b.(*jwt.SigningMethodEd25519)
+		return ok
+	}
+	return false
+}
+
+// ParsePrivateKey 解析PEM格式的私钥
+// RSA 优先使用 rsapkg 解析(PKCS1)，失败时再尝试PKCS8
+func ParsePrivateKey(method jwt.SigningMethod, key []byte) (crypto.PrivateKey, error) {
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if priKey, err := rsapkg.ParserPrivateKey(key); err == nil {
+			return priKey, nil
+		}
+		priKey, err := jwt.ParseRSAPrivateKeyFromPEM(key)
+		if err != nil {
+			return nil, fmt.Errorf("parse rsa private key failed: %w", err)
+		}
+		return priKey, nil
+	case *jwt.SigningMethodECDSA:
+		priKey, err := jwt.ParseECPrivateKeyFromPEM(key)
+		if err != nil {
+			return nil, fmt.Errorf("parse ecdsa private key failed: %w", err)
+		}
+		return priKey, nil
+	case *jwt.SigningMethodEd25519:
+		priKey, err := jwt.ParseEdPrivateKeyFromPEM(key)
+		if err != nil {
+			return nil, fmt.Errorf("parse ed25519 private key failed: %w", err)
+		}
+		return priKey, nil
+	}
+	return nil, fmt.Errorf("unsupported signing method: %s", method.Alg())
+}
+
+// ParsePublicKey 解析PEM格式的公钥
+// RSA 优先使用 rsapkg 解析(PKIX)，失败时再尝试证书与PKCS1
+func ParsePublicKey(method jwt.SigningMethod, key []byte) (crypto.PublicKey, error) {
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if pubKey, err := rsapkg.ParserPublicKey(key); err == nil {
+			return pubKey, nil
+		}
+		pubKey, err := jwt.ParseRSAPublicKeyFromPEM(key)
+		if err != nil {
+			return nil, fmt.Errorf("parse rsa public key failed: %w", err)
+		}
+		return pubKey, nil
+	case *jwt.SigningMethodECDSA:
+		pubKey, err := jwt.ParseECPublicKeyFromPEM(key)
+		if err != nil {
+			return nil, fmt.Errorf("parse ecdsa public key failed: %w", err)
+		}
+		return pubKey, nil
+	case *jwt.SigningMethodEd25519:
+		pubKey, err := jwt.ParseEdPublicKeyFromPEM(key)
+		if err != nil {
+			return nil, fmt.Errorf("parse ed25519 public key failed: %w", err)
+		}
+		return pubKey, nil
+	}
+	return nil, fmt.Errorf("unsupported signing method: %s", method.Alg())
+}
+
+// isSamePublicKey 是否为同一公钥
+func isSamePublicKey(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
+
+// publicKeyOf 私钥对应的公钥
+func publicKeyOf(priKey crypto.PrivateKey) crypto.PublicKey {
+	switch k := priKey.(type) {
+	case *rsa.PrivateKey:
+		return &k.PublicKey
+	case *ecdsa.PrivateKey:
+		return &k.PublicKey
+	case ed25519.PrivateKey:
+		return k.Public()
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package authpkg
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	rsapkg "github.com/eden-quan/go-kratos-pkg/rsa"
+)
+
+// genKeyPairPEM 生成PEM格式的密钥对
+func genKeyPairPEM(t testing.TB, method jwt.SigningMethod) (priKey, pubKey []byte) {
+	var (
+		priDer, pubDer []byte
+		err            error
+	)
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		priKey, pubKey, err = rsapkg.GenRsaKey()
+		require.Nil(t, err)
+		return priKey, pubKey
+	case *jwt.SigningMethodECDSA:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.Nil(t, err)
+		priDer, err = x509.MarshalECPrivateKey(key)
+		require.Nil(t, err)
+		pubDer, err = x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.Nil(t, err)
+		priKey = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: priDer})
+	case *jwt.SigningMethodEd25519:
+		pub, pri, err := ed25519.GenerateKey(rand.Reader)
+		require.Nil(t, err)
+		priDer, err = x509.MarshalPKCS8PrivateKey(pri)
+		require.Nil(t, err)
+		pubDer, err = x509.MarshalPKIXPublicKey(pub)
+		require.Nil(t, err)
+		priKey = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priDer})
+	}
+	pubKey = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDer})
+	return priKey, pubKey
+}
+
+// go test -v -count=1 ./auth -test.run=TestAuthRepo_AsymmetricSigningMethod
+func TestAuthRepo_AsymmetricSigningMethod(t *testing.T) {
+	methods := []jwt.SigningMethod{
+		jwt.SigningMethodRS256,
+		jwt.SigningMethodPS256,
+		jwt.SigningMethodES256,
+		jwt.SigningMethodEdDSA,
+	}
+	for _, method := range methods {
+		t.Run(method.Alg(), func(t *testing.T) {
+			priKey, pubKey := genKeyPairPEM(t, method)
+			ctx := context.Background()
+
+			// 签发服务
+			signer, err := NewAuthRepo(NewTokenManger(&redis.Client{}, nil), log.DefaultLogger, Config{
+				SigningMethod:  method,
+				SignKey:        "1234567890ABCDEF",
+				SignPrivateKey: priKey,
+			})
+			require.Nil(t, err)
+			require.Equal(t, method, signer.JWTSigningMethod())
+
+			// 仅验证的服务
+			verifier, err := NewAuthRepo(NewTokenManger(&redis.Client{}, nil), log.DefaultLogger, Config{
+				SigningMethod: method,
+				SignPublicKey: pubKey,
+			})
+			require.Nil(t, err)
+
+			claims := DefaultClaims(Payload{UserID: 1, TokenType: TokenTypeEnum_USER})
+			tokenString, err := jwt.NewWithClaims(method, claims).SignedString(signer.(*authRepo).keyRing.current().signKey)
+			require.Nil(t, err)
+
+			for _, repo := range []AuthRepo{signer, verifier} {
+				got, err := repo.DecodeAccessToken(ctx, tokenString)
+				require.Nil(t, err)
+				require.Equal(t, claims.ID, got.ID)
+				require.Equal(t, claims.Payload.UserID, got.Payload.UserID)
+			}
+
+			// 仅验证的服务不能签发令牌
+			_, _, err = verifier.SignToken(ctx, DefaultClaims(Payload{UserID: 1}))
+			require.NotNil(t, err)
+
+			// 私钥与公钥匹配
+			_, err = newSigningKey(method, "", priKey, pubKey)
+			require.Nil(t, err)
+			// 私钥与公钥不匹配
+			_, otherPubKey := genKeyPairPEM(t, method)
+			_, err = newSigningKey(method, "", priKey, otherPubKey)
+			require.NotNil(t, err)
+
+			// 拒绝其他类型签名方法的令牌：例如使用公钥作为HMAC密钥
+			forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(pubKey)
+			require.Nil(t, err)
+			_, err = verifier.DecodeAccessToken(ctx, forged)
+			require.NotNil(t, err)
+		})
+	}
+}
+
+// go test -v -count=1 ./auth -test.run=TestIsSameSigningMethodFamily
+func TestIsSameSigningMethodFamily(t *testing.T) {
+	require.True(t, IsSameSigningMethodFamily(jwt.SigningMethodHS256, jwt.SigningMethodHS512))
+	require.True(t, IsSameSigningMethodFamily(jwt.SigningMethodRS256, jwt.SigningMethodRS384))
+	require.True(t, IsSameSigningMethodFamily(jwt.SigningMethodES256, jwt.SigningMethodES512))
+	require.False(t, IsSameSigningMethodFamily(jwt.SigningMethodHS256, jwt.SigningMethodRS256))
+	require.False(t, IsSameSigningMethodFamily(jwt.SigningMethodRS256, jwt.SigningMethodPS256))
+	require.False(t, IsSameSigningMethodFamily(jwt.SigningMethodEdDSA, jwt.SigningMethodES256))
+}
@@ -0,0 +1,87 @@
+package authpkg
+
+import (
+	"context"
+	"fmt"
+)
+
+// TenantConfig 租户配置；未配置的租户使用默认配置
+type TenantConfig struct {
+	// SignKey HMAC签名密钥；与 Config.SignKey 相同，刷新令牌仍使用 Config.SignKey 加密
+	SignKey string
+	// SignPrivateKey 非对称签名的私钥(PEM)
+	SignPrivateKey []byte
+	// SignPublicKey 非对称签名的公钥(PEM)
+	SignPublicKey []byte
+	// SigningKeys 密钥环；参考 Config.SigningKeys
+	SigningKeys []*SigningKey
+	// CurrentSigningKeyID 当前签名密钥id
+	CurrentSigningKeyID string
+	// LoginLimit 登录限制策略：签发令牌时覆盖 Payload.LoginLimit；为空时使用令牌的登录限制
+	LoginLimit *LoginLimitEnum_LoginLimit
+}
+
+// hasSigningKey 是否配置了签名密钥；未配置时使用默认密钥环
+func (s *TenantConfig) hasSigningKey() bool {
+	return s.SignKey != "" || len(s.SignPrivateKey) > 0 || len(s.SignPublicKey) > 0 || len(s.SigningKeys) > 0
+}
+
+// newTenantKeyRings 租户的密钥环
+func newTenantKeyRings(config *Config) (map[string]*keyRing, error) {
+	rings := make(map[string]*keyRing, len(config.Tenants))
+	for tenantID, tenant := range config.Tenants {
+		if tenant == nil || !tenant.hasSigningKey() {
+			continue
+		}
+		ring, err := newKeyRing(&Config{
+			SigningMethod:       config.SigningMethod,
+			SignKey:             tenant.SignKey,
+			SignPrivateKey:      tenant.SignPrivateKey,
+			SignPublicKey:       tenant.SignPublicKey,
+			SigningKeys:         tenant.SigningKeys,
+			CurrentSigningKeyID: tenant.CurrentSigningKeyID,
+			Lifetimes:           config.Lifetimes,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("tenant(%s): %w", tenantID, err)
+		}
+		rings[tenantID] = ring
+	}
+	return rings, nil
+}
+
+// contextTenant context.Context key
+type contextTenant struct{}
+
+// PutTenantIntoContext 设置当前租户；例：根据域名或请求头识别租户
+// 管理其他租户的用户时，使用此方法指定租户
+func PutTenantIntoContext(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, contextTenant{}, tenantID)
+}
+
+// GetTenantFromContext 当前租户：PutTenantIntoContext 设置的租户，否则为令牌的租户
+func GetTenantFromContext(ctx context.Context) (string, bool) {
+	if tenantID, ok := ctx.Value(contextTenant{}).(string); ok && tenantID != "" {
+		return tenantID, true
+	}
+	if authClaims, ok := GetAuthClaimsFromContext(ctx); ok && authClaims.Payload != nil && authClaims.Payload.TenantID != "" {
+		return authClaims.Payload.TenantID, true
+	}
+	return "", false
+}
+
+// tenantContext 令牌的租户写入上下文；令牌管理使用上下文的租户作为key的命名空间
+func tenantContext(ctx context.Context, payload *Payload) context.Context {
+	if payload == nil || payload.TenantID == "" {
+		return ctx
+	}
+	return PutTenantIntoContext(ctx, payload.TenantID)
+}
+
+// tenantKey 租户命名空间：租户:key；没有租户时为key
+func tenantKey(ctx context.Context, key string) string {
+	if tenantID, ok := GetTenantFromContext(ctx); ok {
+		return tenantID + ":" + key
+	}
+	return key
+}
@@ -0,0 +1,123 @@
+package authpkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -count=1 ./auth -test.run=TestAuthRepo_Tenant
+func TestAuthRepo_Tenant(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		onlyOne   = LoginLimitEnum_ONLY_ONE
+		repo, err = NewAuthRepo(newMemoryTokenManger(time.Now), log.DefaultLogger, Config{
+			SignKey: "1234567890ABCDEF",
+			Tenants: map[string]*TenantConfig{
+				"a": {SignKey: "tenant-a-sign-key", LoginLimit: &onlyOne},
+				"b": {},
+			},
+		})
+		signIn = func(ctx context.Context, payload Payload) (*TokenResponse, *Claims) {
+			claims := DefaultClaims(payload)
+			res, _, err := repo.SignToken(ctx, claims)
+			require.Nil(t, err)
+			return res, claims
+		}
+		call = func(token string) (string, error) {
+			tr := newTestTransport("/api.user.v1.User/Get")
+			tr.reqHeader.Set(AuthorizationKey, token)
+			var tenantID string
+			_, err := Server(
+				repo.JWTSigningKeyFunc,
+				WithSigningMethod(repo.JWTSigningMethod()),
+				WithClaims(repo.JWTSigningClaims),
+				WithTokenValidator(repo.VerifyToken),
+			)(func(ctx context.Context, req interface{}) (interface{}, error) {
+				tenantID, _ = GetTenantFromContext(ctx)
+				return nil, nil
+			})(transport.NewServerContext(ctx, tr), nil)
+			return tenantID, err
+		}
+	)
+	require.Nil(t, err)
+
+	// 租户来自上下文
+	resA, claimsA := signIn(PutTenantIntoContext(ctx, "a"), Payload{UserID: 1})
+	require.Equal(t, "a", claimsA.Payload.TenantID)
+	resB, claimsB := signIn(ctx, Payload{UserID: 1, TenantID: "b"})
+	resDefault, _ := signIn(ctx, Payload{UserID: 1})
+
+	tenantID, err := call(resA.AccessToken)
+	require.Nil(t, err)
+	require.Equal(t, "a", tenantID)
+	tenantID, err = call(resB.AccessToken)
+	require.Nil(t, err)
+	require.Equal(t, "b", tenantID)
+	tenantID, err = call(resDefault.AccessToken)
+	require.Nil(t, err)
+	require.Empty(t, tenantID)
+
+	// 租户的签名密钥
+	_, err = jwt.ParseWithClaims(resA.AccessToken, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte("1234567890ABCDEF"), nil
+	})
+	require.NotNil(t, err)
+	forged := DefaultClaims(Payload{UserID: 1, TenantID: "a"})
+	forged.ID = claimsA.ID
+	forgedToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, forged).SignedString([]byte("1234567890ABCDEF"))
+	require.Nil(t, err)
+	_, err = call(forgedToken)
+	require.True(t, Is(err, ErrTokenParseFail()))
+
+	// 租户的登录限制策略
+	require.Equal(t, LoginLimitEnum_ONLY_ONE, claimsA.Payload.LoginLimit)
+	require.Equal(t, LoginLimitEnum_UNLIMITED, claimsB.Payload.LoginLimit)
+
+	// 注销会话不影响其他租户的相同用户
+	require.Nil(t, repo.RevokeAllSessions(PutTenantIntoContext(ctx, "b"), "1"))
+	_, err = call(resB.AccessToken)
+	require.True(t, Is(err, ErrBlacklist()))
+	_, err = call(resA.AccessToken)
+	require.Nil(t, err)
+	_, err = call(resDefault.AccessToken)
+	require.Nil(t, err)
+
+	// 刷新令牌保持租户
+	refreshed, _, err := repo.RefreshToken(ctx, resA.RefreshToken)
+	require.Nil(t, err)
+	tenantID, err = call(refreshed.AccessToken)
+	require.Nil(t, err)
+	require.Equal(t, "a", tenantID)
+	sessions, err := repo.ListSessions(PutTenantIntoContext(ctx, "a"), "1")
+	require.Nil(t, err)
+	require.Len(t, sessions, 1)
+}
+
+// go test -v -count=1 ./auth -test.run=TestGetTenantFromContext
+func TestGetTenantFromContext(t *testing.T) {
+	ctx := context.Background()
+	_, ok := GetTenantFromContext(ctx)
+	require.False(t, ok)
+
+	ctx = PutAuthClaimsIntoContext(ctx, &Claims{Payload: &Payload{TenantID: "a"}})
+	tenantID, ok := GetTenantFromContext(ctx)
+	require.True(t, ok)
+	require.Equal(t, "a", tenantID)
+
+	// 指定的租户优先
+	tenantID, _ = GetTenantFromContext(PutTenantIntoContext(ctx, "b"))
+	require.Equal(t, "b", tenantID)
+
+	// 租户的密钥配置错误
+	_, err := NewAuthRepo(newMemoryTokenManger(time.Now), log.DefaultLogger, Config{
+		SignKey: "1234567890ABCDEF",
+		Tenants: map[string]*TenantConfig{"a": {SigningKeys: []*SigningKey{{KeyID: "1", Secret: "1"}, {KeyID: "1", Secret: "2"}}}},
+	})
+	require.NotNil(t, err)
+}
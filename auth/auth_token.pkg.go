@@ -4,10 +4,8 @@ import (
 	"context"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
-	"github.com/redis/go-redis/v9"
 	"time"
 
-	aespkg "github.com/eden-quan/go-kratos-pkg/aes"
 	threadpkg "github.com/eden-quan/go-kratos-pkg/thread"
 	uuidpkg "github.com/eden-quan/go-kratos-pkg/uuid"
 	"github.com/go-kratos/kratos/v2/log"
@@ -32,57 +30,168 @@ type AuthRepo interface {
 	JWTSigningKeyFunc(ctx context.Context) jwt.Keyfunc
 	JWTSigningMethod() jwt.SigningMethod
 	JWTSigningClaims() jwt.Claims
+	// JWKS 公钥集；用于发布非对称签名的验证公钥，参考 JWKSHandler
+	JWKS() (*JSONWebKeySet, error)
 
 	// SignToken 签证Token
 	// @Param signKey 拼接在原来的signKey上
 	SignToken(ctx context.Context, authClaims *Claims) (*TokenResponse, []*TokenItem, error)
 	DecodeAccessToken(ctx context.Context, accessToken string) (*Claims, error)
 	DecodeRefreshToken(ctx context.Context, refreshToken string) (*Claims, error)
+	// RefreshToken 使用刷新令牌换取新的令牌；旧的令牌加入黑名单
+	// 已使用过的刷新令牌再次使用时，视为令牌被盗用，注销整个令牌家族
+	RefreshToken(ctx context.Context, refreshToken string) (*TokenResponse, []*TokenItem, error)
 
 	VerifyToken(ctx context.Context, jwtToken *jwt.Token) error
+
+	// ListSessions 用户的登录会话；按登录时间倒序
+	ListSessions(ctx context.Context, userIdentifier string) ([]*Session, error)
+	// RevokeSession 注销一个登录会话
+	RevokeSession(ctx context.Context, userIdentifier string, tokenID string) error
+	// RevokeOtherSessions 注销当前会话以外的所有登录会话
+	RevokeOtherSessions(ctx context.Context, authClaims *Claims) error
+	// RevokeAllSessions 注销所有登录会话
+	RevokeAllSessions(ctx context.Context, userIdentifier string) error
+	// BumpTokenGeneration 令牌代数加一：一次写入使用户此前签发的所有令牌失效；例：修改密码、禁用账号
+	BumpTokenGeneration(ctx context.Context, userIdentifier string) (uint64, error)
+
+	// StepUpToken 多因素认证后签发新令牌；参考 StepUp
+	StepUpToken(ctx context.Context, authClaims *Claims, amr ...string) (*TokenResponse, error)
+	// ImpersonateToken 代理登录：签发被代理用户的短期令牌，不能刷新；参考 BlockImpersonation
+	ImpersonateToken(ctx context.Context, param *ImpersonateParam) (*TokenResponse, error)
+
+	// TokenLifetime 令牌有效期
+	TokenLifetime(payload *Payload) *TokenLifetime
+	// RenewToken 滑动会话：令牌在续期窗口内时签发新令牌；参考 WithTokenRenewer
+	RenewToken(ctx context.Context, jwtToken *jwt.Token) (*TokenResponse, error)
 }
 
 // Config ...
 type Config struct {
-	SigningMethod      *jwt.SigningMethodHMAC
-	SignKey            string
-	RefreshCrypto      Encryptor
-	AuthCacheKeyPrefix *AuthCacheKeyPrefix
+	// SigningMethod 签名方法：HMAC(HS256...)、RSA(RS256...)、RSA-PSS(PS256...)、ECDSA(ES256...)、Ed25519(EdDSA)
+	SigningMethod jwt.SigningMethod
+	// SignKey HMAC签名密钥；同时用于派生刷新令牌的加密密钥
+	SignKey string
+	// SignPrivateKey 非对称签名的私钥(PEM)；用于签发令牌
+	SignPrivateKey []byte
+	// SignPublicKey 非对称签名的公钥(PEM)；用于验证令牌，为空时从私钥导出
+	// 仅配置公钥的服务只能验证令牌，不能签发令牌
+	SignPublicKey []byte
+	// SigningKeys 密钥环；配置后忽略 SignPrivateKey/SignPublicKey，HMAC 使用 SigningKey.Secret 签名
+	// 轮换密钥：添加新密钥并设置为当前密钥，旧密钥设置 RetiredAt
+	SigningKeys []*SigningKey
+	// CurrentSigningKeyID 当前签名密钥id；为空时使用第一个未退役的密钥
+	CurrentSigningKeyID string
+	// RefreshCrypto 刷新令牌的加密；默认 DefaultRefreshCrypto
+	RefreshCrypto Encryptor
+	// RefreshLegacySunset 默认的 RefreshCrypto 兼容旧格式(AES-CBC)刷新令牌的截止时间；为零时不兼容
+	// 固定的切换时间，不随重启变化；建议为所有实例升级的时间 + 刷新令牌的最长有效期
+	RefreshLegacySunset time.Time
+	// Lifetimes 按令牌类型与登录平台配置有效期与续期窗口；未匹配时使用 DefaultTokenLifetime
+	Lifetimes []*TokenLifetime
+	// RenewGracePeriod 续期后旧令牌的宽限期：并发的请求仍然可以使用旧令牌；为0时使用 DefaultRenewGracePeriod，小于0时立即失效
+	RenewGracePeriod time.Duration
+	// LoginLimitHook 登录限制回调：旧的令牌因新登录被注销时调用
+	LoginLimitHook LoginLimitHook
+	// SyncLoginLimit 签发令牌时同步检查登录限制与删除过期令牌；默认在后台执行，不增加签发的耗时
+	// 例：签发令牌后立即退出的命令行工具
+	SyncLoginLimit bool
+	// Tenants 租户配置：租户id => 签名密钥与登录限制策略
+	Tenants map[string]*TenantConfig
+	// EventSink 审计事件：签发、刷新、注销、黑名单命中、登录限制；例：NewLogAuthEventSink、NewRedisAuthEventSink
+	EventSink AuthEventSink
 }
 
 // authRepo ...
 type authRepo struct {
 	logHandler  *log.Helper
 	config      *Config
+	keyRing     *keyRing
 	tokenManger TokenManger
+	// tenantKeyRings 租户的密钥环
+	tenantKeyRings map[string]*keyRing
 }
 
 // NewAuthRepo ...
-func NewAuthRepo(redisCC redis.UniversalClient, logger log.Logger, config Config) (AuthRepo, error) {
-	if config.SignKey == "" {
-		return nil, fmt.Errorf("sign key is empty")
+// tokenManger 令牌管理：NewTokenManger(Redis) 或 NewMemoryTokenManger(单节点部署与测试)
+func NewAuthRepo(tokenManger TokenManger, logger log.Logger, config Config) (AuthRepo, error) {
+	if tokenManger == nil {
+		return nil, fmt.Errorf("token manger is nil")
 	}
 	if config.SigningMethod == nil {
 		config.SigningMethod = jwt.SigningMethodHS256
 	}
+	ring, err := newKeyRing(&config)
+	if err != nil {
+		return nil, err
+	}
+	if ring.current().canSign() && config.SignKey == "" {
+		return nil, fmt.Errorf("sign key is empty; it is required to crypto refresh token")
+	}
 	if config.RefreshCrypto == nil {
-		config.RefreshCrypto = aespkg.NewCBCCipher()
+		config.RefreshCrypto = DefaultRefreshCrypto(WithLegacySunset(config.RefreshLegacySunset))
+	}
+	tenantRings, err := newTenantKeyRings(&config)
+	if err != nil {
+		return nil, err
+	}
+	for tenantID, tenantRing := range tenantRings {
+		if tenantRing.current().canSign() && config.SignKey == "" {
+			return nil, fmt.Errorf("tenant(%s): sign key is empty; it is required to crypto refresh token", tenantID)
+		}
 	}
-	config.AuthCacheKeyPrefix = CheckAuthCacheKeyPrefix(config.AuthCacheKeyPrefix)
 	return &authRepo{
-		logHandler:  log.NewHelper(log.With(logger, "module", "auth/repo")),
-		config:      &config,
-		tokenManger: NewTokenManger(redisCC, config.AuthCacheKeyPrefix),
+		logHandler:     log.NewHelper(log.With(logger, "module", "auth/repo")),
+		config:         &config,
+		keyRing:        ring,
+		tokenManger:    tokenManger,
+		tenantKeyRings: tenantRings,
 	}, nil
 }
 
+// keyRingOf 租户的密钥环；未配置时使用默认密钥环
+func (s *authRepo) keyRingOf(tenantID string) *keyRing {
+	if ring, ok := s.tenantKeyRings[tenantID]; ok {
+		return ring
+	}
+	return s.keyRing
+}
+
 // JWTSigningKeyFunc 密钥 jwt.Keyfunc
+// 根据令牌的租户选择密钥环，根据令牌头部的 kid 选择密钥；HMAC 返回密钥，非对称签名返回公钥
 func (s *authRepo) JWTSigningKeyFunc(ctx context.Context) jwt.Keyfunc {
 	return func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.config.SignKey), nil
+		if !IsSameSigningMethodFamily(s.keyRing.method, token.Method) {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		ring := s.keyRing
+		if authClaims, ok := token.Claims.(*Claims); ok && authClaims.Payload != nil {
+			ring = s.keyRingOf(authClaims.Payload.TenantID)
+		}
+		key, err := ring.lookup(keyIDFromToken(token), time.Now())
+		if err != nil {
+			return nil, err
+		}
+		return key.verifyKey, nil
 	}
 }
 
+// JWKS 公钥集；HMAC密钥不会发布，不包含租户的密钥
+func (s *authRepo) JWKS() (*JSONWebKeySet, error) {
+	jwks := &JSONWebKeySet{Keys: []*JSONWebKey{}}
+	if IsHMACSigningMethod(s.keyRing.method) {
+		return jwks, nil
+	}
+	for _, key := range s.keyRing.verifyKeys(time.Now()) {
+		jwk, err := NewJSONWebKey(key.keyID, s.keyRing.method.Alg(), key.verifyKey)
+		if err != nil {
+			return nil, err
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks, nil
+}
+
 // JWTSigningMethod 签名方法
 func (s *authRepo) JWTSigningMethod() jwt.SigningMethod {
 	return s.config.SigningMethod
@@ -94,58 +203,131 @@ func (s *authRepo) JWTSigningClaims() jwt.Claims {
 }
 
 // SignToken ...
+// 令牌没有租户时，使用 GetTenantFromContext 的租户
 func (s *authRepo) SignToken(ctx context.Context, authClaims *Claims) (*TokenResponse, []*TokenItem, error) {
+	return s.signToken(ctx, authClaims, AuthEventSignIn)
+}
+
+// signToken 签发令牌；eventType 审计事件类型：登录、刷新或续期
+func (s *authRepo) signToken(ctx context.Context, authClaims *Claims, eventType AuthEventType) (res *TokenResponse, tokenItems []*TokenItem, err error) {
+	// 租户
+	if authClaims.Payload.TenantID == "" {
+		authClaims.Payload.TenantID, _ = GetTenantFromContext(ctx)
+	}
+	ctx = tenantContext(ctx, authClaims.Payload)
+	if tenant := s.config.Tenants[authClaims.Payload.TenantID]; tenant != nil && tenant.LoginLimit != nil {
+		authClaims.Payload.LoginLimit = *tenant.LoginLimit
+	}
+
 	// token
 	if authClaims.ID == "" {
 		authClaims.ID = uuidpkg.NewUUID()
 	}
-	tokenString, err := jwt.NewWithClaims(s.config.SigningMethod, authClaims).SignedString([]byte(s.config.SignKey))
-	if err != nil {
-		return nil, nil, fmt.Errorf("sign token failed: %w", err)
+	if authClaims.FamilyID == "" {
+		authClaims.FamilyID = authClaims.ID
 	}
-
-	// refresh token
-	refreshClaims := DefaultRefreshClaims(authClaims)
-	refreshClaimsStr, err := refreshClaims.EncodeToString()
+	if authClaims.IssuedAt == nil {
+		authClaims.IssuedAt = jwt.NewNumericDate(time.Now())
+	}
+	if authClaims.AuthTime == nil {
+		authClaims.AuthTime = authClaims.IssuedAt
+	}
+	lifetime := s.TokenLifetime(authClaims.Payload)
+	if authClaims.ExpiresAt == nil {
+		authClaims.ExpiresAt = jwt.NewNumericDate(authClaims.IssuedAt.Time.Add(lifetime.AccessTokenExpire))
+	}
+	defer func() {
+		event := newAuthEvent(eventType, AuthEventOutcomeSuccess, authClaims.ID, authClaims.Payload)
+		event.FamilyID = authClaims.FamilyID
+		if authClaims.IsImpersonation() {
+			event.Actor = authClaims.Actor.Subject
+		}
+		if err != nil {
+			event.Outcome = AuthEventOutcomeFailure
+			event.Reason = err.Error()
+		}
+		s.emitEvent(ctx, event)
+	}()
+	signingKey := s.keyRingOf(authClaims.Payload.TenantID).current()
+	if !signingKey.canSign() {
+		return nil, nil, fmt.Errorf("sign token failed: private key is missing")
+	}
+	// 令牌代数：签发与保存之间令牌代数增加时，令牌在验证时失效
+	// 不使用验证缓存：缓存的令牌代数可能小于当前值，签发的令牌在缓存过期后失效
+	authClaims.Generation, err = unwrapTokenManger(s.tokenManger).GetTokenGeneration(ctx, authClaims.Payload.UserIdentifier())
 	if err != nil {
-		return nil, nil, fmt.Errorf("encode refresh claims failed: %w", err)
+		return nil, nil, fmt.Errorf("GetTokenGeneration failed: %w", err)
 	}
-	refreshToken, err := s.config.RefreshCrypto.EncryptToString(refreshClaimsStr, s.config.SignKey)
+	token := jwt.NewWithClaims(s.config.SigningMethod, authClaims)
+	if signingKey.keyID != "" {
+		token.Header[KeyIDHeader] = signingKey.keyID
+	}
+	tokenString, err := token.SignedString(signingKey.signKey)
 	if err != nil {
-		return nil, nil, fmt.Errorf("crypto refresh claims failed: %w", err)
+		return nil, nil, fmt.Errorf("sign token failed: %w", err)
 	}
 
 	// 存储
 	var (
-		userIdentifier = authClaims.Payload.UserIdentifier()
-		tokenItems     = []*TokenItem{
-			{
-				TokenID:        authClaims.ID,
-				RefreshTokenID: refreshClaims.ID,
-				ExpiredAt:      authClaims.ExpiresAt.Time.Unix(),
-				IsRefreshToken: false,
-				Payload:        authClaims.Payload,
-			},
-			{
-				TokenID:        authClaims.ID,
-				RefreshTokenID: refreshClaims.ID,
-				ExpiredAt:      refreshClaims.ExpiresAt.Time.Unix(),
-				IsRefreshToken: true,
-				Payload:        refreshClaims.Payload,
-			},
+		userIdentifier      = authClaims.Payload.UserIdentifier()
+		clientIP, userAgent = clientInfoFromContext(ctx)
+		accessItem          = &TokenItem{
+			TokenID:        authClaims.ID,
+			ExpiredAt:      authClaims.ExpiresAt.Time.Unix(),
+			IsRefreshToken: false,
+			FamilyID:       authClaims.FamilyID,
+			IssuedAt:       authClaims.IssuedAt.Time.Unix(),
+			ClientIP:       clientIP,
+			UserAgent:      userAgent,
+			Payload:        authClaims.Payload,
 		}
+		refreshToken string
 	)
+	tokenItems = []*TokenItem{accessItem}
+
+	// refresh token：代理登录的令牌不能刷新
+	if authClaims.IsImpersonation() {
+		accessItem.Actor = authClaims.Actor.Subject
+	} else {
+		refreshClaims := DefaultRefreshClaims(authClaims)
+		refreshClaims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(lifetime.RefreshTokenExpire))
+		refreshClaimsStr, err := refreshClaims.EncodeToString()
+		if err != nil {
+			return nil, nil, fmt.Errorf("encode refresh claims failed: %w", err)
+		}
+		refreshToken, err = s.config.RefreshCrypto.EncryptToString(refreshClaimsStr, s.config.SignKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("crypto refresh claims failed: %w", err)
+		}
+		accessItem.RefreshTokenID = refreshClaims.ID
+		tokenItems = append(tokenItems, &TokenItem{
+			TokenID:        authClaims.ID,
+			RefreshTokenID: refreshClaims.ID,
+			ExpiredAt:      refreshClaims.ExpiresAt.Time.Unix(),
+			IsRefreshToken: true,
+			FamilyID:       refreshClaims.FamilyID,
+			IssuedAt:       authClaims.IssuedAt.Time.Unix(),
+			ClientIP:       clientIP,
+			UserAgent:      userAgent,
+			Payload:        refreshClaims.Payload,
+		})
+	}
 	err = s.tokenManger.SaveTokens(ctx, userIdentifier, tokenItems)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// 登录限制
-	threadpkg.GoSafe(func() {
-		s.afterSignToken(ctx, authClaims)
-	})
+	// 登录限制：不随请求取消
+	asyncCtx := context.WithoutCancel(ctx)
+	if s.config.SyncLoginLimit {
+		s.afterSignToken(asyncCtx, authClaims)
+	} else {
+		threadpkg.GoSafe(func() {
+			s.afterSignToken(asyncCtx, authClaims)
+		})
+	}
 
-	res := &TokenResponse{
+	res = &TokenResponse{
 		AccessToken:  tokenString,
 		RefreshToken: refreshToken,
 	}
@@ -191,59 +373,134 @@ func (s *authRepo) deleteExpireTokens(ctx context.Context, authClaims *Claims) e
 }
 
 // checkLoginLimit 检查登录限制
+// 并发登录时，每次登录的检查都只保留最新的令牌：注销更早的令牌，存在更新的令牌时注销当前令牌
 func (s *authRepo) checkLoginLimit(ctx context.Context, authClaims *Claims) error {
-	if authClaims.Payload.LoginLimit == LoginLimitEnum_UNLIMITED {
+	if authClaims.Payload.LoginLimit == LoginLimitEnum_UNLIMITED || authClaims.IsImpersonation() {
 		return nil
 	}
-	userIdentifier := authClaims.Payload.UserIdentifier()
-	allTokens, err := s.tokenManger.GetAllTokens(ctx, userIdentifier)
+	var (
+		userIdentifier      = authClaims.Payload.UserIdentifier()
+		clientIP, userAgent = clientInfoFromContext(ctx)
+		info                = &LoginLimitInfo{
+			LoginPlatform: authClaims.Payload.LoginPlatform,
+			LoginType:     authClaims.Payload.LoginType,
+			ClientIP:      clientIP,
+			UserAgent:     userAgent,
+		}
+	)
+	if authClaims.IssuedAt != nil {
+		info.LoginAt = authClaims.IssuedAt.Time.Unix()
+	}
+	evictions, err := s.tokenManger.EvictLoginLimit(ctx, userIdentifier, func(allTokens map[string]*TokenItem) []*LoginLimitEviction {
+		return loginLimitEvictions(authClaims, info, allTokens)
+	})
 	if err != nil {
-		return fmt.Errorf("GetAllTokens failed: %w", err)
+		return fmt.Errorf("EvictLoginLimit failed: %w", err)
+	}
+
+	for _, eviction := range evictions {
+		limitList := loginLimitItems(eviction.Items)
+		for _, item := range limitList {
+			event := newAuthEvent(AuthEventLoginLimitEvicted, AuthEventOutcomeSuccess, item.TokenID, item.Payload)
+			event.FamilyID = item.FamilyID
+			event.Reason = authClaims.Payload.LoginLimit.String()
+			event.ClientIP, event.UserAgent = eviction.Info.ClientIP, eviction.Info.UserAgent
+			s.emitEvent(ctx, event)
+		}
+		if s.config.LoginLimitHook != nil {
+			s.config.LoginLimitHook(ctx, userIdentifier, limitList, eviction.Info)
+		}
+	}
+	return nil
+}
+
+// loginLimitEvictions 选择登录限制注销的令牌
+// 存在更新的令牌时注销当前令牌，更早的令牌由更新的登录注销；否则注销所有更早的令牌
+func loginLimitEvictions(authClaims *Claims, info *LoginLimitInfo, allTokens map[string]*TokenItem) []*LoginLimitEviction {
+	current, ok := allTokens[authClaims.ID]
+	if !ok || current.IsRefreshToken {
+		// 已被注销
+		return nil
 	}
 
 	var (
-		blacklist []*TokenItem
-		limitList []*TokenItem
+		olderItems []*TokenItem
+		newest     *TokenItem
 	)
-	for iKey := range allTokens {
-		// 不检查刷新token
-		if allTokens[iKey].IsRefreshToken {
+	for _, item := range allTokens {
+		// 不检查刷新token、代理登录的令牌与同一个令牌家族(续期宽限期内的旧令牌)；跳过自己
+		if item.IsRefreshToken || item.Actor != "" || item.TokenID == current.TokenID ||
+			(item.FamilyID != "" && item.FamilyID == current.FamilyID) {
 			continue
 		}
-		// 跳过自己
-		if allTokens[iKey].TokenID == authClaims.ID {
+		if !isLoginLimited(authClaims.Payload, item) {
 			continue
 		}
-
-		isLimit := false
-		switch authClaims.Payload.LoginLimit {
-		case LoginLimitEnum_ONLY_ONE:
-			// 同一账户仅允许登录一次
-			isLimit = true
-		case LoginLimitEnum_PLATFORM_ONE:
-			// 同一账户每个平台都可登录一次
-			if authClaims.Payload.LoginPlatform == allTokens[iKey].Payload.LoginPlatform {
-				isLimit = true
-			}
-		}
-		if isLimit {
-			blacklist = append(blacklist, allTokens[iKey])
-			limitList = append(limitList, allTokens[iKey])
-			if item, ok := allTokens[allTokens[iKey].RefreshTokenID]; ok {
-				blacklist = append(blacklist, item)
+		if isNewerTokenItem(item, current) {
+			if newest == nil || isNewerTokenItem(item, newest) {
+				newest = item
 			}
+			continue
 		}
+		olderItems = append(olderItems, item, allTokens[item.RefreshTokenID])
 	}
 
-	// 添加黑名单
-	if err := s.tokenManger.AddBlacklist(ctx, userIdentifier, blacklist); err != nil {
-		return fmt.Errorf("AddBlacklist failed: %w", err)
+	if newest != nil {
+		return []*LoginLimitEviction{{
+			Items: nonNilTokenItems(current, allTokens[current.RefreshTokenID]),
+			Info:  loginLimitInfoOf(newest),
+		}}
+	}
+	if len(olderItems) == 0 {
+		return nil
 	}
-	// 添加登录限制
-	if err := s.tokenManger.AddLoginLimit(ctx, limitList); err != nil {
-		return fmt.Errorf("AddLoginLimit failed: %w", err)
+	return []*LoginLimitEviction{{Items: nonNilTokenItems(olderItems...), Info: info}}
+}
+
+// isLoginLimited 令牌是否受当前登录的登录限制
+func isLoginLimited(payload *Payload, item *TokenItem) bool {
+	switch payload.LoginLimit {
+	case LoginLimitEnum_ONLY_ONE:
+		// 同一账户仅允许登录一次
+		return true
+	case LoginLimitEnum_PLATFORM_ONE:
+		// 同一账户每个平台都可登录一次
+		return item.Payload != nil && item.Payload.LoginPlatform == payload.LoginPlatform
 	}
-	return nil
+	return false
+}
+
+// isNewerTokenItem 签发时间更晚；签发时间相同时比较令牌id，保证并发的检查结果一致
+func isNewerTokenItem(item, other *TokenItem) bool {
+	if item.IssuedAt != other.IssuedAt {
+		return item.IssuedAt > other.IssuedAt
+	}
+	return item.TokenID > other.TokenID
+}
+
+// loginLimitInfoOf 令牌的登录信息
+func loginLimitInfoOf(item *TokenItem) *LoginLimitInfo {
+	info := &LoginLimitInfo{
+		LoginAt:   item.IssuedAt,
+		ClientIP:  item.ClientIP,
+		UserAgent: item.UserAgent,
+	}
+	if item.Payload != nil {
+		info.LoginPlatform = item.Payload.LoginPlatform
+		info.LoginType = item.Payload.LoginType
+	}
+	return info
+}
+
+// nonNilTokenItems ...
+func nonNilTokenItems(tokenItems ...*TokenItem) []*TokenItem {
+	res := make([]*TokenItem, 0, len(tokenItems))
+	for i := range tokenItems {
+		if tokenItems[i] != nil {
+			res = append(res, tokenItems[i])
+		}
+	}
+	return res
 }
 
 // DecodeAccessToken ...
@@ -281,33 +538,183 @@ func (s *authRepo) DecodeRefreshToken(ctx context.Context, refreshToken string)
 	return claims, err
 }
 
+// RefreshToken 刷新令牌
+func (s *authRepo) RefreshToken(ctx context.Context, refreshToken string) (*TokenResponse, []*TokenItem, error) {
+	refreshClaims, err := s.DecodeRefreshToken(ctx, refreshToken)
+	if err != nil || refreshClaims.Payload == nil {
+		e := ErrRefreshTokenInvalid()
+		if err != nil {
+			e.Metadata = map[string]string{"err": err.Error()}
+		}
+		event := newAuthEvent(AuthEventRefresh, AuthEventOutcomeFailure, "", nil)
+		event.Reason = e.Reason
+		s.emitEvent(ctx, event)
+		return nil, nil, e
+	}
+	ctx = tenantContext(ctx, refreshClaims.Payload)
+	userIdentifier := refreshClaims.Payload.UserIdentifier()
+
+	// 重复使用：注销令牌家族
+	isBlacklist, err := s.tokenManger.IsBlacklist(ctx, userIdentifier, refreshClaims.ID)
+	if err != nil {
+		e := ErrInvalidClaims()
+		e.Metadata = map[string]string{"err": err.Error()}
+		return nil, nil, e
+	}
+	if isBlacklist {
+		return nil, nil, s.refreshTokenReused(ctx, refreshClaims)
+	}
+
+	// 令牌代数
+	if err = s.verifyTokenGeneration(ctx, refreshClaims, AuthEventRefresh); err != nil {
+		return nil, nil, err
+	}
+
+	// 白名单
+	refreshItem, isNotFound, err := s.tokenManger.GetToken(ctx, userIdentifier, refreshClaims.ID)
+	if err != nil {
+		e := ErrInvalidClaims()
+		e.Metadata = map[string]string{"err": err.Error()}
+		return nil, nil, e
+	}
+	if isNotFound {
+		// 并发刷新：其他请求在黑名单检查后使用了刷新令牌
+		isBlacklist, err = s.tokenManger.IsBlacklist(ctx, userIdentifier, refreshClaims.ID)
+		if err != nil {
+			e := ErrInvalidClaims()
+			e.Metadata = map[string]string{"err": err.Error()}
+			return nil, nil, e
+		}
+		if isBlacklist {
+			return nil, nil, s.refreshTokenReused(ctx, refreshClaims)
+		}
+	}
+	if isNotFound || !refreshItem.IsRefreshToken {
+		e := ErrRefreshTokenInvalid()
+		event := newAuthEvent(AuthEventRefresh, AuthEventOutcomeFailure, refreshClaims.ID, refreshClaims.Payload)
+		event.FamilyID = refreshClaims.FamilyID
+		event.Reason = e.Reason
+		s.emitEvent(ctx, event)
+		return nil, nil, e
+	}
+
+	// 旧令牌加入黑名单：原子地使用刷新令牌，并发刷新时只有一个请求成功，其他请求视为重复使用
+	retireList := []*TokenItem{refreshItem}
+	accessItem, isNotFound, err := s.tokenManger.GetToken(ctx, userIdentifier, refreshItem.TokenID)
+	if err != nil {
+		e := ErrInvalidClaims()
+		e.Metadata = map[string]string{"err": err.Error()}
+		return nil, nil, e
+	}
+	if !isNotFound {
+		retireList = append(retireList, accessItem)
+	}
+	claimed, err := s.tokenManger.RetireTokens(ctx, userIdentifier, retireList, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("RetireTokens failed: %w", err)
+	}
+	if !claimed {
+		return nil, nil, s.refreshTokenReused(ctx, refreshClaims)
+	}
+
+	// 签发新令牌：保持授权信息、令牌家族与认证方式
+	return s.signToken(ctx, inheritClaims(refreshClaims), AuthEventRefresh)
+}
+
+// refreshTokenReused 重复使用刷新令牌：注销令牌家族
+func (s *authRepo) refreshTokenReused(ctx context.Context, refreshClaims *Claims) error {
+	event := newAuthEvent(AuthEventRefreshReused, AuthEventOutcomeDenied, refreshClaims.ID, refreshClaims.Payload)
+	event.FamilyID = refreshClaims.FamilyID
+	s.emitEvent(ctx, event)
+	if err := s.revokeTokenFamily(ctx, refreshClaims.Payload.UserIdentifier(), refreshClaims.FamilyID); err != nil {
+		s.logHandler.WithContext(ctx).Errorw("msg", "revokeTokenFamily failed", "err", err)
+	}
+	return ErrRefreshTokenReused()
+}
+
+// revokeTokenFamily 注销令牌家族；未记录令牌家族的旧令牌，注销用户所有令牌
+func (s *authRepo) revokeTokenFamily(ctx context.Context, userIdentifier, familyID string) error {
+	return s.revokeSessions(ctx, userIdentifier, "refresh_token_reused", func(item *TokenItem) bool {
+		return familyID == "" || item.FamilyID == familyID
+	})
+}
+
 // VerifyToken 验证令牌
 func (s *authRepo) VerifyToken(ctx context.Context, jwtToken *jwt.Token) error {
 	authClaims, ok := jwtToken.Claims.(*Claims)
-	if !ok {
+	if !ok || authClaims.Payload == nil {
 		return ErrTokenInvalid()
 	}
+	ctx = tenantContext(ctx, authClaims.Payload)
+	userIdentifier := authClaims.Payload.UserIdentifier()
 
 	// 黑名单
-	isBlacklist, err := s.tokenManger.IsBlacklist(ctx, authClaims.ID)
+	isBlacklist, err := s.tokenManger.IsBlacklist(ctx, userIdentifier, authClaims.ID)
 	if err != nil {
 		e := ErrInvalidClaims()
 		e.Metadata = map[string]string{"err": err.Error()}
 		return e
 	}
 	if isBlacklist {
-		return ErrBlacklist()
+		// 登录限制：在其他地方登录
+		e := ErrBlacklist()
+		info, isNotFound, err := s.tokenManger.GetLoginLimit(ctx, userIdentifier, authClaims.ID)
+		if err == nil && !isNotFound {
+			e = ErrLoginLimitWithInfo(info)
+		}
+		event := newAuthEvent(AuthEventBlacklistHit, AuthEventOutcomeDenied, authClaims.ID, authClaims.Payload)
+		event.FamilyID = authClaims.FamilyID
+		event.Reason = e.Reason
+		s.emitEvent(ctx, event)
+		return e
+	}
+
+	// 服务令牌不保存在白名单中；代表用户的服务令牌仍校验用户的令牌代数
+	if authClaims.IsServiceToken() {
+		if !authClaims.IsUserInitiated() {
+			return nil
+		}
+		return s.verifyTokenGeneration(ctx, authClaims, AuthEventGenerationRevoked)
+	}
+
+	// 令牌代数
+	if err = s.verifyTokenGeneration(ctx, authClaims, AuthEventGenerationRevoked); err != nil {
+		return err
 	}
 
 	// 白名单
-	isExist, err := s.tokenManger.IsExistToken(ctx, authClaims.Payload.UserIdentifier(), authClaims.ID)
+	isExist, err := s.tokenManger.IsExistToken(ctx, userIdentifier, authClaims.ID)
 	if err != nil {
 		e := ErrInvalidClaims()
 		e.Metadata = map[string]string{"err": err.Error()}
 		return e
 	}
 	if !isExist {
-		return ErrWhitelist()
+		e := ErrWhitelist()
+		event := newAuthEvent(AuthEventWhitelistMiss, AuthEventOutcomeDenied, authClaims.ID, authClaims.Payload)
+		event.FamilyID = authClaims.FamilyID
+		event.Reason = e.Reason
+		s.emitEvent(ctx, event)
+		return e
 	}
 	return nil
 }
+
+// verifyTokenGeneration 令牌代数小于用户当前的令牌代数时，令牌已注销
+func (s *authRepo) verifyTokenGeneration(ctx context.Context, authClaims *Claims, eventType AuthEventType) error {
+	generation, err := s.tokenManger.GetTokenGeneration(ctx, authClaims.Payload.UserIdentifier())
+	if err != nil {
+		e := ErrInvalidClaims()
+		e.Metadata = map[string]string{"err": err.Error()}
+		return e
+	}
+	if authClaims.Generation >= generation {
+		return nil
+	}
+	e := ErrTokenGenerationRevoked()
+	event := newAuthEvent(eventType, AuthEventOutcomeDenied, authClaims.ID, authClaims.Payload)
+	event.FamilyID = authClaims.FamilyID
+	event.Reason = e.Reason
+	s.emitEvent(ctx, event)
+	return e
+}
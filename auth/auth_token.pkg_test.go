@@ -1,18 +1,25 @@
 package authpkg
 
 import (
+	"context"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/errors"
 	"github.com/go-kratos/kratos/v2/log"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
 )
 
 // go test -v -count=1 ./auth -test.run=TestNewAuthRepo
 func TestNewAuthRepo(t *testing.T) {
 	type args struct {
-		redisCC redis.UniversalClient
-		logger  log.Logger
-		config  Config
+		tokenManger TokenManger
+		logger      log.Logger
+		config      Config
 	}
 	tests := []struct {
 		name    string
@@ -23,17 +30,37 @@ func TestNewAuthRepo(t *testing.T) {
 		{
 			name: "#test",
 			args: args{
-				redisCC: &redis.Client{},
-				logger:  log.DefaultLogger,
+				tokenManger: NewTokenManger(&redis.Client{}, nil),
+				logger:      log.DefaultLogger,
 				config: Config{
 					SignKey: "abc",
 				},
 			},
 		},
+		{
+			name: "#memory",
+			args: args{
+				tokenManger: newMemoryTokenManger(time.Now),
+				logger:      log.DefaultLogger,
+				config: Config{
+					SignKey: "abc",
+				},
+			},
+		},
+		{
+			name: "#token_manger_is_nil",
+			args: args{
+				logger: log.DefaultLogger,
+				config: Config{
+					SignKey: "abc",
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := NewAuthRepo(tt.args.redisCC, tt.args.logger, tt.args.config)
+			got, err := NewAuthRepo(tt.args.tokenManger, tt.args.logger, tt.args.config)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewAuthRepo() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -47,3 +74,96 @@ func TestNewAuthRepo(t *testing.T) {
 	t.Log("testdata: ", ERROR_UNAUTHORIZED.String())
 	t.Log("testdata: ", ErrBlacklist())
 }
+
+// newTestAuthRepo 使用 miniredis 的 AuthRepo
+func newTestAuthRepo(t testing.TB) (AuthRepo, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	redisCC := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = redisCC.Close() })
+	repo, err := NewAuthRepo(NewTokenManger(redisCC, nil), log.DefaultLogger, Config{
+		SignKey: "1234567890ABCDEF",
+	})
+	require.Nil(t, err)
+	return repo, mr
+}
+
+// go test -v -count=1 ./auth -test.run=TestAuthRepo_RefreshToken
+func TestAuthRepo_RefreshToken(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		repo, _ = newTestAuthRepo(t)
+		payload = Payload{UserID: 1, LoginPlatform: LoginPlatformEnum_IOS, TokenType: TokenTypeEnum_USER}
+	)
+	first, firstItems, err := repo.SignToken(ctx, DefaultClaims(payload))
+	require.Nil(t, err)
+	familyID := firstItems[0].FamilyID
+	require.NotEmpty(t, familyID)
+
+	// 刷新：保持授权信息与令牌家族，旧令牌失效
+	second, secondItems, err := repo.RefreshToken(ctx, first.RefreshToken)
+	require.Nil(t, err)
+	require.NotEqual(t, first.AccessToken, second.AccessToken)
+	require.Equal(t, familyID, secondItems[0].FamilyID)
+	require.Equal(t, payload, *secondItems[0].Payload)
+
+	firstClaims, err := repo.DecodeAccessToken(ctx, first.AccessToken)
+	require.Nil(t, err)
+	err = repo.VerifyToken(ctx, &jwt.Token{Claims: firstClaims})
+	require.True(t, errors.Is(err, ErrBlacklist()))
+
+	secondClaims, err := repo.DecodeAccessToken(ctx, second.AccessToken)
+	require.Nil(t, err)
+	require.Nil(t, repo.VerifyToken(ctx, &jwt.Token{Claims: secondClaims}))
+
+	// 另一个登录的令牌不属于该令牌家族
+	other, _, err := repo.SignToken(ctx, DefaultClaims(payload))
+	require.Nil(t, err)
+
+	// 重复使用刷新令牌：注销令牌家族
+	_, _, err = repo.RefreshToken(ctx, first.RefreshToken)
+	require.True(t, errors.Is(err, ErrRefreshTokenReused()))
+	err = repo.VerifyToken(ctx, &jwt.Token{Claims: secondClaims})
+	require.True(t, errors.Is(err, ErrBlacklist()))
+	_, _, err = repo.RefreshToken(ctx, second.RefreshToken)
+	require.True(t, errors.Is(err, ErrRefreshTokenReused()))
+
+	otherClaims, err := repo.DecodeAccessToken(ctx, other.AccessToken)
+	require.Nil(t, err)
+	require.Nil(t, repo.VerifyToken(ctx, &jwt.Token{Claims: otherClaims}))
+
+	// 并发刷新：只有一个请求使用刷新令牌，其他请求视为重复使用
+	concurrent, _, err := repo.SignToken(ctx, DefaultClaims(payload))
+	require.Nil(t, err)
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		errCh = make(chan error, 8)
+	)
+	for i := 0; i < cap(errCh); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, _, err := repo.RefreshToken(ctx, concurrent.RefreshToken)
+			errCh <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errCh)
+	var succeeded int
+	for err := range errCh {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		require.True(t, errors.Is(err, ErrRefreshTokenReused()), "%v", err)
+	}
+	require.Equal(t, 1, succeeded)
+
+	// 无效的刷新令牌
+	_, _, err = repo.RefreshToken(ctx, "invalid")
+	require.True(t, errors.Is(err, ErrRefreshTokenInvalid()))
+	_, _, err = repo.RefreshToken(ctx, other.AccessToken)
+	require.True(t, errors.Is(err, ErrRefreshTokenInvalid()))
+}
@@ -2,12 +2,15 @@ package authpkg
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
-	"github.com/redis/go-redis/v9"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
-	threadpkg "github.com/eden-quan/go-kratos-pkg/thread"
+	"github.com/redis/go-redis/v9"
 )
 
 // RedisCacheKeyPrefix ...
@@ -23,6 +26,62 @@ const (
 	DefaultAuthTokenKeyPrefix  RedisCacheKeyPrefix = "gs:auth:token:"
 )
 
+// maxLoginLimitRetries 并发修改用户令牌时，登录限制的最大重试次数
+const maxLoginLimitRetries = 10
+
+// TokenGenerationField 令牌代数：与令牌保存在同一个哈希中，GetAllTokens 不返回
+const TokenGenerationField = "__generation"
+
+// saveTokensScript 保存令牌并设置过期时间；过期时间作用于整个哈希，只延长不缩短
+// KEYS[1] 用户令牌；ARGV[1] 过期时间(毫秒)，0为不设置；ARGV[2:] 字段与值
+var saveTokensScript = redis.NewScript(`
+local expire = tonumber(ARGV[1])
+redis.call('HSET', KEYS[1], unpack(ARGV, 2))
+if expire > 0 then
+	local ttl = redis.call('PTTL', KEYS[1])
+	if ttl < expire then
+		redis.call('PEXPIRE', KEYS[1], expire)
+	end
+end
+return 1
+`)
+
+// retireTokensScript 注销令牌：加入黑名单、记录登录限制信息与删除令牌在同一个脚本中完成
+// KEYS[1] 用户令牌；KEYS[2:] 黑名单与登录限制
+// ARGV[1] 用户令牌的指纹，为空时不检查；ARGV[2] 为1时 KEYS[2] 已存在则不执行(SET NX)
+// ARGV[3] 删除的字段数量n；ARGV[4] 更新的字段数量m；其后依次为删除的字段、更新的字段与值
+// 最后依次为 KEYS[2:] 的值与过期时间(毫秒)，0为不设置
+// 返回0：用户令牌已被并发修改(指纹不一致)或 KEYS[2] 已存在，未执行
+var retireTokensScript = redis.NewScript(`
+if ARGV[1] ~= '' then
+	local fields = redis.call('HKEYS', KEYS[1])
+	table.sort(fields)
+	if redis.sha1hex(table.concat(fields, '\n')) ~= ARGV[1] then
+		return 0
+	end
+end
+if ARGV[2] == '1' and redis.call('EXISTS', KEYS[2]) == 1 then
+	return 0
+end
+local n, m = tonumber(ARGV[3]), tonumber(ARGV[4])
+if n > 0 then
+	redis.call('HDEL', KEYS[1], unpack(ARGV, 5, 4 + n))
+end
+if m > 0 then
+	redis.call('HSET', KEYS[1], unpack(ARGV, 5 + n, 4 + n + 2 * m))
+end
+local i = 5 + n + 2 * m
+for k = 2, #KEYS do
+	if tonumber(ARGV[i + 1]) > 0 then
+		redis.call('SET', KEYS[k], ARGV[i], 'PX', ARGV[i + 1])
+	else
+		redis.call('SET', KEYS[k], ARGV[i])
+	end
+	i = i + 2
+end
+return 1
+`)
+
 // AuthCacheKeyPrefix ...
 type AuthCacheKeyPrefix struct {
 	TokensKeyPrefix     RedisCacheKeyPrefix // 用户令牌
@@ -36,6 +95,7 @@ func CheckAuthCacheKeyPrefix(keyPrefix *AuthCacheKeyPrefix) *AuthCacheKeyPrefix
 		return &AuthCacheKeyPrefix{
 			TokensKeyPrefix:     DefaultAuthTokenKeyPrefix,
 			BlackTokenKeyPrefix: DefaultBlackTokenKeyPrefix,
+			LimitTokenKeyPrefix: DefaultLoginLimitKeyPrefix,
 		}
 	}
 	if keyPrefix.TokensKeyPrefix == "" {
@@ -53,6 +113,8 @@ func CheckAuthCacheKeyPrefix(keyPrefix *AuthCacheKeyPrefix) *AuthCacheKeyPrefix
 var _ TokenManger = (*tokenManger)(nil)
 
 // TokenManger ...
+// 令牌按 GetTenantFromContext 的租户隔离
+// 黑名单与登录限制按用户存储：Redis 集群中与用户令牌位于同一个slot，注销令牌在同一个脚本中原子地完成
 type TokenManger interface {
 	SaveTokens(ctx context.Context, userIdentifier string, tokenItems []*TokenItem) error
 	DeleteTokens(ctx context.Context, userIdentifier string, tokenItems []*TokenItem) error
@@ -60,15 +122,42 @@ type TokenManger interface {
 	GetAllTokens(ctx context.Context, userIdentifier string) (map[string]*TokenItem, error)
 	IsExistToken(ctx context.Context, userIdentifier string, tokenID string) (bool, error)
 	AddBlacklist(ctx context.Context, userIdentifier string, tokenItems []*TokenItem) error
-	IsBlacklist(ctx context.Context, tokenID string) (bool, error)
-	AddLoginLimit(ctx context.Context, tokenItems []*TokenItem) error
-	IsLoginLimit(ctx context.Context, tokenID string) (bool, LoginLimitEnum_LoginLimit, error)
+	// RetireTokens 原子地注销令牌：第一个令牌不在黑名单中时，将令牌加入黑名单并删除
+	// grace 宽限期：令牌(不包括刷新令牌)在宽限期内仍然有效，有效期缩短至宽限期结束；为0时立即失效
+	// 返回 false：第一个令牌已在黑名单中，未执行；例：并发刷新时只有一个请求使用刷新令牌
+	RetireTokens(ctx context.Context, userIdentifier string, tokenItems []*TokenItem, grace time.Duration) (bool, error)
+	IsBlacklist(ctx context.Context, userIdentifier string, tokenID string) (bool, error)
+	AddLoginLimit(ctx context.Context, userIdentifier string, tokenItems []*TokenItem) error
+	IsLoginLimit(ctx context.Context, userIdentifier string, tokenID string) (bool, LoginLimitEnum_LoginLimit, error)
+	// AddLoginLimitInfo 登录限制；记录导致令牌被注销的新登录信息
+	AddLoginLimitInfo(ctx context.Context, userIdentifier string, tokenItems []*TokenItem, info *LoginLimitInfo) error
+	// GetLoginLimit 登录限制信息
+	GetLoginLimit(ctx context.Context, userIdentifier string, tokenID string) (info *LoginLimitInfo, isNotFound bool, err error)
+	// EvictLoginLimit 登录限制：原子地读取用户的所有令牌，注销 selector 选择的令牌并记录登录限制信息
+	// 并发修改用户令牌时重新读取并选择；返回已注销的令牌
+	EvictLoginLimit(ctx context.Context, userIdentifier string, selector LoginLimitSelector) ([]*LoginLimitEviction, error)
+	// GetTokenGeneration 用户的令牌代数；未设置时为0
+	GetTokenGeneration(ctx context.Context, userIdentifier string) (uint64, error)
+	// BumpTokenGeneration 令牌代数加一并返回新的代数；此前签发的令牌全部失效
+	BumpTokenGeneration(ctx context.Context, userIdentifier string) (uint64, error)
 }
 
+// LoginLimitEviction 登录限制注销的令牌
+type LoginLimitEviction struct {
+	// Items 注销的令牌与其刷新令牌；令牌记录登录限制信息
+	Items []*TokenItem
+	// Info 导致令牌被注销的新登录
+	Info *LoginLimitInfo
+}
+
+// LoginLimitSelector 根据用户的所有令牌选择需要注销的令牌
+type LoginLimitSelector func(allTokens map[string]*TokenItem) []*LoginLimitEviction
+
 // tokenManger ...
 type tokenManger struct {
 	redisCC            redis.UniversalClient
 	authCacheKeyPrefix *AuthCacheKeyPrefix
+	now                func() time.Time
 }
 
 // NewTokenManger ...
@@ -77,31 +166,32 @@ func NewTokenManger(redisCC redis.UniversalClient, authCacheKeyPrefix *AuthCache
 	return &tokenManger{
 		redisCC:            redisCC,
 		authCacheKeyPrefix: authCacheKeyPrefix,
+		now:                time.Now,
 	}
 }
 
-// SaveTokens ...
+// SaveTokens 保存令牌与设置过期时间在同一个脚本中完成
 func (s *tokenManger) SaveTokens(ctx context.Context, userIdentifier string, tokenItems []*TokenItem) error {
 	if len(tokenItems) == 0 {
 		return nil
 	}
 
 	var (
-		kvs     = make([]interface{}, 0, 2*len(tokenItems))
-		nowUnix = time.Now().Unix()
+		args    = make([]interface{}, 1, 1+2*len(tokenItems))
+		nowUnix = s.now().Unix()
 		expire  = time.Duration(0)
 	)
 	for i := range tokenItems {
 		if tokenItems[i].IsRefreshToken {
-			kvs = append(kvs, tokenItems[i].RefreshTokenID)
+			args = append(args, tokenItems[i].RefreshTokenID)
 		} else {
-			kvs = append(kvs, tokenItems[i].TokenID)
+			args = append(args, tokenItems[i].TokenID)
 		}
 		itemStr, err := tokenItems[i].EncodeToString()
 		if err != nil {
 			return fmt.Errorf("encode token item failed: %w", err)
 		}
-		kvs = append(kvs, itemStr)
+		args = append(args, itemStr)
 
 		// 过期时间
 		if ex := s.calcExpireTime(tokenItems[i].ExpiredAt, nowUnix); ex > expire {
@@ -109,14 +199,9 @@ func (s *tokenManger) SaveTokens(ctx context.Context, userIdentifier string, tok
 		}
 	}
 
-	key := s.genTokensKey(userIdentifier)
-	if err := s.redisCC.HSet(ctx, key, kvs...).Err(); err != nil {
-		return err
-	}
-	threadpkg.GoSafe(func() {
-		_ = s.redisCC.Expire(ctx, key, expire)
-	})
-	return nil
+	args[0] = expire.Milliseconds()
+	key := s.genTokensKey(ctx, userIdentifier)
+	return saveTokensScript.Run(ctx, s.redisCC, []string{key}, args...).Err()
 }
 
 // calcExpireTime ...
@@ -131,43 +216,30 @@ func (s *tokenManger) calcExpireTime(expireAt, nowUnix int64) time.Duration {
 	return time.Second
 }
 
-// AddBlacklist ...
+// AddBlacklist 加入黑名单与删除令牌在同一个脚本中完成
 func (s *tokenManger) AddBlacklist(ctx context.Context, userIdentifier string, tokenItems []*TokenItem) error {
 	if len(tokenItems) == 0 {
 		return nil
 	}
-
-	var (
-		tokensKey = s.genTokensKey(userIdentifier)
-		hashKeys  = make([]string, 0, len(tokenItems))
-		nowUnix   = time.Now().Unix()
-	)
-
-	pipe := s.redisCC.Pipeline()
-	for i := range tokenItems {
-		var blackKey = ""
-		if tokenItems[i].IsRefreshToken {
-			hashKeys = append(hashKeys, tokenItems[i].RefreshTokenID)
-			blackKey = s.genBlackTokenKey(tokenItems[i].RefreshTokenID)
-		} else {
-			hashKeys = append(hashKeys, tokenItems[i].TokenID)
-			blackKey = s.genBlackTokenKey(tokenItems[i].TokenID)
-		}
-		// 加入黑名单
-		d := s.calcExpireTime(tokenItems[i].ExpiredAt, nowUnix)
-		if err := pipe.Set(ctx, blackKey, 0, d).Err(); err != nil {
-			return err
-		}
-	}
-	if err := pipe.HDel(ctx, tokensKey, hashKeys...).Err(); err != nil {
+	args := s.newRetireTokensArgs(ctx, userIdentifier)
+	if err := args.addBlacklist(tokenItems, 0); err != nil {
 		return err
 	}
+	_, err := s.retireTokens(ctx, args, "")
+	return err
+}
 
-	_, err := pipe.Exec(ctx)
-	if err != nil {
-		return err
+// RetireTokens 检查第一个令牌的黑名单与注销令牌在同一个脚本中完成
+func (s *tokenManger) RetireTokens(ctx context.Context, userIdentifier string, tokenItems []*TokenItem, grace time.Duration) (bool, error) {
+	if len(tokenItems) == 0 {
+		return false, nil
 	}
-	return nil
+	args := s.newRetireTokensArgs(ctx, userIdentifier)
+	args.claim = true
+	if err := args.addBlacklist(tokenItems, grace); err != nil {
+		return false, err
+	}
+	return s.retireTokens(ctx, args, "")
 }
 
 // DeleteTokens ...
@@ -177,7 +249,7 @@ func (s *tokenManger) DeleteTokens(ctx context.Context, userIdentifier string, t
 	}
 
 	var (
-		tokensKey = s.genTokensKey(userIdentifier)
+		tokensKey = s.genTokensKey(ctx, userIdentifier)
 		hashKeys  = make([]string, 0, len(tokenItems))
 	)
 
@@ -195,56 +267,190 @@ func (s *tokenManger) DeleteTokens(ctx context.Context, userIdentifier string, t
 }
 
 // AddLoginLimit ...
-func (s *tokenManger) AddLoginLimit(ctx context.Context, tokenItems []*TokenItem) error {
+func (s *tokenManger) AddLoginLimit(ctx context.Context, userIdentifier string, tokenItems []*TokenItem) error {
+	return s.AddLoginLimitInfo(ctx, userIdentifier, tokenItems, nil)
+}
+
+// AddLoginLimitInfo ...
+func (s *tokenManger) AddLoginLimitInfo(ctx context.Context, userIdentifier string, tokenItems []*TokenItem, info *LoginLimitInfo) error {
 	if len(tokenItems) == 0 {
 		return nil
 	}
-	var (
-		nowUnix = time.Now().Unix()
-	)
+	args := s.newRetireTokensArgs(ctx, userIdentifier)
+	if err := args.addLoginLimitInfo(tokenItems, info); err != nil {
+		return err
+	}
+	_, err := s.retireTokens(ctx, args, "")
+	return err
+}
+
+// EvictLoginLimit 乐观锁：读取用户令牌并选择，在脚本中检查用户令牌的指纹后注销令牌；用户令牌被并发修改时重试
+func (s *tokenManger) EvictLoginLimit(ctx context.Context, userIdentifier string, selector LoginLimitSelector) ([]*LoginLimitEviction, error) {
+	tokensKey := s.genTokensKey(ctx, userIdentifier)
+	for i := 0; i < maxLoginLimitRetries; i++ {
+		tokens, err := s.redisCC.HGetAll(ctx, tokensKey).Result()
+		if err != nil {
+			return nil, err
+		}
+		allTokens, err := decodeTokenItems(tokens)
+		if err != nil {
+			return nil, err
+		}
+		evictions := selector(allTokens)
+		if len(evictions) == 0 {
+			return nil, nil
+		}
+		args := s.newRetireTokensArgs(ctx, userIdentifier)
+		for _, eviction := range evictions {
+			if err = args.addBlacklist(eviction.Items, 0); err != nil {
+				return nil, err
+			}
+			if err = args.addLoginLimitInfo(loginLimitItems(eviction.Items), eviction.Info); err != nil {
+				return nil, err
+			}
+		}
+		ok, err := s.retireTokens(ctx, args, tokensFingerprint(tokens))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return evictions, nil
+		}
+	}
+	return nil, fmt.Errorf("evict login limit failed: tokens are modified concurrently")
+}
 
-	pipe := s.redisCC.Pipeline()
+// retireTokensArgs 注销令牌的脚本参数；参考 retireTokensScript
+type retireTokensArgs struct {
+	manger *tokenManger
+	// blackKeyPrefix、limitKeyPrefix 用户的黑名单与登录限制的key前缀
+	blackKeyPrefix string
+	limitKeyPrefix string
+	nowUnix        int64
+	// claim 第一个黑名单已存在时不执行
+	claim  bool
+	keys   []string
+	fields []interface{}
+	// updates 更新的字段与值
+	updates []interface{}
+	values  []interface{}
+}
+
+// newRetireTokensArgs ...
+func (s *tokenManger) newRetireTokensArgs(ctx context.Context, userIdentifier string) *retireTokensArgs {
+	return &retireTokensArgs{
+		manger:         s,
+		blackKeyPrefix: s.genBlackTokenKey(ctx, userIdentifier, ""),
+		limitKeyPrefix: s.genLimitTokenKey(ctx, userIdentifier, ""),
+		nowUnix:        s.now().Unix(),
+		keys:           []string{s.genTokensKey(ctx, userIdentifier)},
+	}
+}
+
+// addBlacklist 加入黑名单并删除令牌；宽限期内的令牌缩短有效期，不删除
+func (s *retireTokensArgs) addBlacklist(tokenItems []*TokenItem, grace time.Duration) error {
 	for i := range tokenItems {
-		limitKey := s.genLimitTokenKey(tokenItems[i].TokenID)
-		d := s.calcExpireTime(tokenItems[i].ExpiredAt, nowUnix)
-		if err := pipe.Set(ctx, limitKey, tokenItems[i].Payload.LoginLimit, d).Err(); err != nil {
+		field := tokenItemFields(tokenItems[i : i+1])[0]
+		d := s.manger.calcExpireTime(tokenItems[i].ExpiredAt, s.nowUnix)
+		notBefore, graceItem, ok := graceTokenItem(tokenItems[i], s.nowUnix, grace)
+		s.keys = append(s.keys, s.blackKeyPrefix+field)
+		s.values = append(s.values, notBefore, d.Milliseconds())
+		if !ok {
+			s.fields = append(s.fields, field)
+			continue
+		}
+		itemStr, err := graceItem.EncodeToString()
+		if err != nil {
+			return fmt.Errorf("encode token item failed: %w", err)
+		}
+		s.updates = append(s.updates, field, itemStr)
+	}
+	return nil
+}
+
+// addLoginLimitInfo ...
+func (s *retireTokensArgs) addLoginLimitInfo(tokenItems []*TokenItem, info *LoginLimitInfo) error {
+	for i := range tokenItems {
+		infoStr, err := loginLimitInfoString(tokenItems[i], info)
+		if err != nil {
 			return err
 		}
+		d := s.manger.calcExpireTime(tokenItems[i].ExpiredAt, s.nowUnix)
+		s.keys = append(s.keys, s.limitKeyPrefix+tokenItems[i].TokenID)
+		s.values = append(s.values, infoStr, d.Milliseconds())
 	}
-	_, err := pipe.Exec(ctx)
+	return nil
+}
+
+// retireTokens 执行注销令牌的脚本；fingerprint 为空时不检查用户令牌
+func (s *tokenManger) retireTokens(ctx context.Context, args *retireTokensArgs, fingerprint string) (bool, error) {
+	claim := 0
+	if args.claim {
+		claim = 1
+	}
+	argv := make([]interface{}, 0, 4+len(args.fields)+len(args.updates)+len(args.values))
+	argv = append(argv, fingerprint, claim, len(args.fields), len(args.updates)/2)
+	argv = append(argv, args.fields...)
+	argv = append(argv, args.updates...)
+	argv = append(argv, args.values...)
+	res, err := retireTokensScript.Run(ctx, s.redisCC, args.keys, argv...).Int()
 	if err != nil {
-		return err
+		return false, err
 	}
-	return nil
+	return res == 1, nil
 }
 
-// IsBlacklist ...
-func (s *tokenManger) IsBlacklist(ctx context.Context, tokenID string) (bool, error) {
-	blackKey := s.genBlackTokenKey(tokenID)
-	i, err := s.redisCC.Exists(ctx, blackKey).Result()
+// tokensFingerprint 用户令牌的指纹：排序后的字段的 SHA1；与 retireTokensScript 一致
+func tokensFingerprint(tokens map[string]string) string {
+	fields := make([]string, 0, len(tokens))
+	for field := range tokens {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	sum := sha1.Sum([]byte(strings.Join(fields, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsBlacklist 黑名单的值为生效时间；参考 isBlacklistValue
+func (s *tokenManger) IsBlacklist(ctx context.Context, userIdentifier string, tokenID string) (bool, error) {
+	blackKey := s.genBlackTokenKey(ctx, userIdentifier, tokenID)
+	value, err := s.redisCC.Get(ctx, blackKey).Result()
 	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
 		return false, err
 	}
-	return i > 0, nil
+	return isBlacklistValue(value, s.now().Unix()), nil
 }
 
 // IsLoginLimit ...
-func (s *tokenManger) IsLoginLimit(ctx context.Context, tokenID string) (bool, LoginLimitEnum_LoginLimit, error) {
-	limitKey := s.genLimitTokenKey(tokenID)
-	loginLimitStr, err := s.redisCC.Get(ctx, limitKey).Result()
+func (s *tokenManger) IsLoginLimit(ctx context.Context, userIdentifier string, tokenID string) (bool, LoginLimitEnum_LoginLimit, error) {
+	info, isNotFound, err := s.GetLoginLimit(ctx, userIdentifier, tokenID)
+	if err != nil || isNotFound {
+		return false, LoginLimitEnum_UNLIMITED, err
+	}
+	return true, info.LoginLimit, nil
+}
+
+// GetLoginLimit ...
+func (s *tokenManger) GetLoginLimit(ctx context.Context, userIdentifier string, tokenID string) (info *LoginLimitInfo, isNotFound bool, err error) {
+	limitKey := s.genLimitTokenKey(ctx, userIdentifier, tokenID)
+	infoStr, err := s.redisCC.Get(ctx, limitKey).Result()
 	if err != nil {
 		if err == redis.Nil {
-			err = nil
+			return info, true, nil
 		}
-		return false, LoginLimitEnum_UNLIMITED, err
+		return info, false, err
 	}
-	ll, _ := strconv.Atoi(loginLimitStr)
-	return true, LoginLimitEnum_LoginLimit(int32(ll)), nil
+	info = &LoginLimitInfo{}
+	err = info.DecodeString(infoStr)
+	return info, false, err
 }
 
 // GetToken ...
 func (s *tokenManger) GetToken(ctx context.Context, userIdentifier string, tokenID string) (item *TokenItem, isNotFound bool, err error) {
-	key := s.genTokensKey(userIdentifier)
+	key := s.genTokensKey(ctx, userIdentifier)
 	res, err := s.redisCC.HGet(ctx, key, tokenID).Result()
 	if err != nil {
 		if err == redis.Nil {
@@ -263,24 +469,55 @@ func (s *tokenManger) GetToken(ctx context.Context, userIdentifier string, token
 
 // IsExistToken ...
 func (s *tokenManger) IsExistToken(ctx context.Context, userIdentifier string, tokenID string) (bool, error) {
-	key := s.genTokensKey(userIdentifier)
+	key := s.genTokensKey(ctx, userIdentifier)
 
 	return s.redisCC.HExists(ctx, key, tokenID).Result()
 }
 
 // GetAllTokens ...
 func (s *tokenManger) GetAllTokens(ctx context.Context, userIdentifier string) (map[string]*TokenItem, error) {
-	key := s.genTokensKey(userIdentifier)
+	key := s.genTokensKey(ctx, userIdentifier)
 	tokens, err := s.redisCC.HGetAll(ctx, key).Result()
 	if err != nil {
 		return nil, err
 	}
 
-	var items = make(map[string]*TokenItem)
+	return decodeTokenItems(tokens)
+}
+
+// GetTokenGeneration ...
+func (s *tokenManger) GetTokenGeneration(ctx context.Context, userIdentifier string) (uint64, error) {
+	key := s.genTokensKey(ctx, userIdentifier)
+	generation, err := s.redisCC.HGet(ctx, key, TokenGenerationField).Uint64()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return generation, nil
+}
+
+// BumpTokenGeneration 令牌代数与令牌保存在同一个哈希中，随哈希过期；此时用户的令牌均已过期
+// 用户没有令牌时创建不过期的哈希，下次保存令牌时设置过期时间
+func (s *tokenManger) BumpTokenGeneration(ctx context.Context, userIdentifier string) (uint64, error) {
+	key := s.genTokensKey(ctx, userIdentifier)
+	generation, err := s.redisCC.HIncrBy(ctx, key, TokenGenerationField, 1).Result()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(generation), nil
+}
+
+// decodeTokenItems 不包括令牌代数
+func decodeTokenItems(tokens map[string]string) (map[string]*TokenItem, error) {
+	var items = make(map[string]*TokenItem, len(tokens))
 	for iKey := range tokens {
+		if iKey == TokenGenerationField {
+			continue
+		}
 		item := &TokenItem{}
-		err = item.DecodeString(tokens[iKey])
-		if err != nil {
+		if err := item.DecodeString(tokens[iKey]); err != nil {
 			return nil, err
 		}
 		items[iKey] = item
@@ -288,17 +525,67 @@ func (s *tokenManger) GetAllTokens(ctx context.Context, userIdentifier string) (
 	return items, nil
 }
 
-// genTokensKey ...
-func (s *tokenManger) genTokensKey(userIdentifier string) string {
-	return s.authCacheKeyPrefix.TokensKeyPrefix.String() + userIdentifier
+// graceTokenItem 宽限期内仍然有效的令牌：不包括刷新令牌
+// 返回黑名单的生效时间(秒)与缩短有效期的令牌；不使用宽限期时生效时间为0，立即生效
+func graceTokenItem(item *TokenItem, nowUnix int64, grace time.Duration) (int64, *TokenItem, bool) {
+	if grace <= 0 || item.IsRefreshToken {
+		return 0, nil, false
+	}
+	notBefore := nowUnix + int64((grace+time.Second-1)/time.Second)
+	graceItem := *item
+	if graceItem.ExpiredAt == 0 || graceItem.ExpiredAt > notBefore {
+		graceItem.ExpiredAt = notBefore
+	}
+	return notBefore, &graceItem, true
 }
 
-// genBlackTokenKey ...
-func (s *tokenManger) genBlackTokenKey(tokenID string) string {
-	return s.authCacheKeyPrefix.BlackTokenKeyPrefix.String() + tokenID
+// isBlacklistValue 黑名单的值为生效时间(秒)：0为立即生效；无法解析时视为已生效
+func isBlacklistValue(value string, nowUnix int64) bool {
+	notBefore, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return true
+	}
+	return notBefore <= nowUnix
 }
 
-// genLimitTokenKey ...
-func (s *tokenManger) genLimitTokenKey(tokenID string) string {
-	return s.authCacheKeyPrefix.LimitTokenKeyPrefix.String() + tokenID
+// loginLimitItems 记录登录限制信息的令牌：不包括刷新令牌
+func loginLimitItems(tokenItems []*TokenItem) []*TokenItem {
+	items := make([]*TokenItem, 0, len(tokenItems))
+	for i := range tokenItems {
+		if !tokenItems[i].IsRefreshToken {
+			items = append(items, tokenItems[i])
+		}
+	}
+	return items
+}
+
+// genTokensKey 按租户隔离：前缀[租户:]用户
+func (s *tokenManger) genTokensKey(ctx context.Context, userIdentifier string) string {
+	return s.authCacheKeyPrefix.TokensKeyPrefix.String() + tenantKey(ctx, userIdentifier)
+}
+
+// genBlackTokenKey 前缀{用户令牌的hash tag}:令牌id；与用户令牌位于同一个slot
+func (s *tokenManger) genBlackTokenKey(ctx context.Context, userIdentifier, tokenID string) string {
+	return s.authCacheKeyPrefix.BlackTokenKeyPrefix.String() + s.genUserHashTag(ctx, userIdentifier) + ":" + tokenID
+}
+
+// genLimitTokenKey 前缀{用户令牌的hash tag}:令牌id；与用户令牌位于同一个slot
+func (s *tokenManger) genLimitTokenKey(ctx context.Context, userIdentifier, tokenID string) string {
+	return s.authCacheKeyPrefix.LimitTokenKeyPrefix.String() + s.genUserHashTag(ctx, userIdentifier) + ":" + tokenID
+}
+
+// genUserHashTag 用户令牌的 hash tag：{用户令牌key}
+// 用户令牌的key不包含 hash tag，集群按整个key计算slot；以整个key作为 hash tag 时位于同一个slot，无需修改用户令牌的key
+func (s *tokenManger) genUserHashTag(ctx context.Context, userIdentifier string) string {
+	return "{" + redisHashTag(s.genTokensKey(ctx, userIdentifier)) + "}"
+}
+
+// redisHashTag 集群计算slot的部分：第一个 { 与其后第一个 } 之间的非空内容；否则为整个key
+func redisHashTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+	return key
 }
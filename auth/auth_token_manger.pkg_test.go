@@ -0,0 +1,660 @@
+package authpkg
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	uuidpkg "github.com/eden-quan/go-kratos-pkg/uuid"
+)
+
+// tokenMangerHarness 令牌管理的测试环境
+type tokenMangerHarness struct {
+	tokenManger TokenManger
+	// advance 时间前进
+	advance func(d time.Duration)
+}
+
+// newRedisTokenMangerHarness Redis(miniredis)
+func newRedisTokenMangerHarness(t *testing.T) *tokenMangerHarness {
+	var (
+		mr      = miniredis.RunT(t)
+		redisCC = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		tm      = NewTokenManger(redisCC, nil).(*tokenManger)
+		mu      sync.Mutex
+		offset  time.Duration
+	)
+	t.Cleanup(func() { _ = redisCC.Close() })
+	tm.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return time.Now().Add(offset)
+	}
+	return &tokenMangerHarness{
+		tokenManger: tm,
+		advance: func(d time.Duration) {
+			mu.Lock()
+			offset += d
+			mu.Unlock()
+			mr.FastForward(d)
+		},
+	}
+}
+
+// newMemoryTokenMangerHarness 内存
+func newMemoryTokenMangerHarness(t *testing.T) *tokenMangerHarness {
+	var (
+		mu  sync.Mutex
+		now = time.Now()
+	)
+	m := newMemoryTokenManger(func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	})
+	return &tokenMangerHarness{
+		tokenManger: m,
+		advance: func(d time.Duration) {
+			mu.Lock()
+			now = now.Add(d)
+			mu.Unlock()
+			m.cleanup()
+		},
+	}
+}
+
+// newTestTokenItems 令牌与刷新令牌
+func newTestTokenItems(payload *Payload, expire, refreshExpire time.Duration) []*TokenItem {
+	var (
+		tokenID        = uuidpkg.NewUUID()
+		refreshTokenID = uuidpkg.NewUUID()
+		nowUnix        = time.Now().Unix()
+	)
+	return []*TokenItem{
+		{
+			TokenID:        tokenID,
+			RefreshTokenID: refreshTokenID,
+			ExpiredAt:      nowUnix + int64(expire/time.Second),
+			FamilyID:       tokenID,
+			IssuedAt:       nowUnix,
+			Payload:        payload,
+		},
+		{
+			TokenID:        tokenID,
+			RefreshTokenID: refreshTokenID,
+			ExpiredAt:      nowUnix + int64(refreshExpire/time.Second),
+			IsRefreshToken: true,
+			FamilyID:       tokenID,
+			IssuedAt:       nowUnix,
+			Payload:        payload,
+		},
+	}
+}
+
+// go test -v -count=1 ./auth -test.run=TestCheckAuthCacheKeyPrefix
+func TestCheckAuthCacheKeyPrefix(t *testing.T) {
+	want := &AuthCacheKeyPrefix{
+		TokensKeyPrefix:     DefaultAuthTokenKeyPrefix,
+		BlackTokenKeyPrefix: DefaultBlackTokenKeyPrefix,
+		LimitTokenKeyPrefix: DefaultLoginLimitKeyPrefix,
+	}
+	require.Equal(t, want, CheckAuthCacheKeyPrefix(nil))
+	require.Equal(t, want, CheckAuthCacheKeyPrefix(&AuthCacheKeyPrefix{}))
+
+	// 默认前缀：登录限制不写入根键空间
+	mr := miniredis.RunT(t)
+	redisCC := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer func() { _ = redisCC.Close() }()
+	items := newTestTokenItems(&Payload{UserID: 1}, time.Hour, time.Hour*2)
+	require.Nil(t, NewTokenManger(redisCC, nil).AddLoginLimit(context.Background(), "1", items[:1]))
+	require.True(t, mr.Exists(DefaultLoginLimitKeyPrefix.String()+"{"+DefaultAuthTokenKeyPrefix.String()+"1}:"+items[0].TokenID))
+	require.False(t, mr.Exists(items[0].TokenID))
+}
+
+// go test -v -count=1 ./auth -test.run=TestTokenManger_HashTag
+func TestTokenManger_HashTag(t *testing.T) {
+	require.Equal(t, "gs:auth:token:1", redisHashTag("gs:auth:token:1"))
+	require.Equal(t, "1", redisHashTag("gs:auth:token:{1}"))
+	require.Equal(t, "gs:auth:token:{}1", redisHashTag("gs:auth:token:{}1"))
+
+	// 集群：用户的所有key位于同一个slot
+	var (
+		mr      = miniredis.RunT(t)
+		redisCC = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		ctx     = PutTenantIntoContext(context.Background(), "tenant-1")
+		tm      = NewTokenManger(redisCC, nil)
+		items   = newTestTokenItems(&Payload{UserID: 1}, time.Hour, time.Hour*2)
+		others  = newTestTokenItems(&Payload{UserID: 1}, time.Hour, time.Hour*2)
+		remains = newTestTokenItems(&Payload{UserID: 1}, time.Hour, time.Hour*2)
+	)
+	defer func() { _ = redisCC.Close() }()
+	require.Nil(t, tm.SaveTokens(ctx, "1", append(append(items, others...), remains...)))
+	_, err := tm.EvictLoginLimit(ctx, "1", func(allTokens map[string]*TokenItem) []*LoginLimitEviction {
+		return []*LoginLimitEviction{{Items: items, Info: &LoginLimitInfo{}}}
+	})
+	require.Nil(t, err)
+	require.Nil(t, tm.AddBlacklist(ctx, "1", others))
+	keys := mr.Keys()
+	require.Len(t, keys, 6)
+	require.Contains(t, keys, DefaultAuthTokenKeyPrefix.String()+"tenant-1:1")
+	for _, key := range keys {
+		require.Equal(t, DefaultAuthTokenKeyPrefix.String()+"tenant-1:1", redisHashTag(key), key)
+	}
+}
+
+// go test -v -count=1 ./auth -test.run=TestTokenManger_EvictLoginLimitRetry
+func TestTokenManger_EvictLoginLimitRetry(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		tm      = newRedisTokenMangerHarness(t).tokenManger
+		items   = newTestTokenItems(&Payload{UserID: 1}, time.Hour, time.Hour*2)
+		newer   = newTestTokenItems(&Payload{UserID: 1}, time.Hour, time.Hour*2)
+		selects int
+	)
+	require.Nil(t, tm.SaveTokens(ctx, "1", items))
+
+	// 选择后用户令牌被并发修改：不执行，重新读取并选择
+	evictions, err := tm.EvictLoginLimit(ctx, "1", func(allTokens map[string]*TokenItem) []*LoginLimitEviction {
+		selects++
+		if selects == 1 {
+			require.Len(t, allTokens, 2)
+			require.Nil(t, tm.SaveTokens(ctx, "1", newer))
+		} else {
+			require.Len(t, allTokens, 4)
+		}
+		return []*LoginLimitEviction{{Items: items, Info: &LoginLimitInfo{}}}
+	})
+	require.Nil(t, err)
+	require.Len(t, evictions, 1)
+	require.Equal(t, 2, selects)
+	allTokens, err := tm.GetAllTokens(ctx, "1")
+	require.Nil(t, err)
+	require.Len(t, allTokens, 2)
+	require.Contains(t, allTokens, newer[0].TokenID)
+}
+
+// go test -v -count=1 ./auth -test.run=TestTokenManger_Conformance
+func TestTokenManger_Conformance(t *testing.T) {
+	harnesses := map[string]func(t *testing.T) *tokenMangerHarness{
+		"redis":  newRedisTokenMangerHarness,
+		"memory": newMemoryTokenMangerHarness,
+		"cached": newCachedTokenMangerHarness,
+	}
+	for name, newHarness := range harnesses {
+		t.Run(name, func(t *testing.T) {
+			testTokenMangerConformance(t, newHarness)
+		})
+	}
+}
+
+// testTokenMangerConformance 令牌管理的一致性测试
+func testTokenMangerConformance(t *testing.T, newHarness func(t *testing.T) *tokenMangerHarness) {
+	var (
+		ctx     = context.Background()
+		payload = &Payload{UserID: 1, LoginPlatform: LoginPlatformEnum_IOS, LoginLimit: LoginLimitEnum_PLATFORM_ONE}
+		userID  = payload.UserIdentifier()
+	)
+
+	t.Run("save_and_get", func(t *testing.T) {
+		tm := newHarness(t).tokenManger
+		items := newTestTokenItems(payload, time.Hour, time.Hour*2)
+		require.Nil(t, tm.SaveTokens(ctx, userID, items))
+		require.Nil(t, tm.SaveTokens(ctx, userID, nil))
+
+		item, isNotFound, err := tm.GetToken(ctx, userID, items[0].TokenID)
+		require.Nil(t, err)
+		require.False(t, isNotFound)
+		require.Equal(t, items[0], item)
+
+		item, isNotFound, err = tm.GetToken(ctx, userID, items[1].RefreshTokenID)
+		require.Nil(t, err)
+		require.False(t, isNotFound)
+		require.Equal(t, items[1], item)
+
+		_, isNotFound, err = tm.GetToken(ctx, userID, "not-found")
+		require.Nil(t, err)
+		require.True(t, isNotFound)
+		_, isNotFound, err = tm.GetToken(ctx, "not-found", items[0].TokenID)
+		require.Nil(t, err)
+		require.True(t, isNotFound)
+
+		allTokens, err := tm.GetAllTokens(ctx, userID)
+		require.Nil(t, err)
+		require.Equal(t, map[string]*TokenItem{
+			items[0].TokenID:        items[0],
+			items[1].RefreshTokenID: items[1],
+		}, allTokens)
+		allTokens, err = tm.GetAllTokens(ctx, "not-found")
+		require.Nil(t, err)
+		require.Empty(t, allTokens)
+
+		isExist, err := tm.IsExistToken(ctx, userID, items[0].TokenID)
+		require.Nil(t, err)
+		require.True(t, isExist)
+		isExist, err = tm.IsExistToken(ctx, userID, "not-found")
+		require.Nil(t, err)
+		require.False(t, isExist)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		tm := newHarness(t).tokenManger
+		items := newTestTokenItems(payload, time.Hour, time.Hour*2)
+		others := newTestTokenItems(payload, time.Hour, time.Hour*2)
+		require.Nil(t, tm.SaveTokens(ctx, userID, append(items, others...)))
+		require.Nil(t, tm.DeleteTokens(ctx, userID, nil))
+		require.Nil(t, tm.DeleteTokens(ctx, userID, items))
+
+		allTokens, err := tm.GetAllTokens(ctx, userID)
+		require.Nil(t, err)
+		require.Len(t, allTokens, 2)
+		isBlacklist, err := tm.IsBlacklist(ctx, userID, items[0].TokenID)
+		require.Nil(t, err)
+		require.False(t, isBlacklist)
+
+		require.Nil(t, tm.DeleteTokens(ctx, userID, others))
+		allTokens, err = tm.GetAllTokens(ctx, userID)
+		require.Nil(t, err)
+		require.Empty(t, allTokens)
+	})
+
+	t.Run("blacklist", func(t *testing.T) {
+		tm := newHarness(t).tokenManger
+		items := newTestTokenItems(payload, time.Hour, time.Hour*2)
+		others := newTestTokenItems(payload, time.Hour, time.Hour*2)
+		require.Nil(t, tm.SaveTokens(ctx, userID, append(items, others...)))
+		require.Nil(t, tm.AddBlacklist(ctx, userID, nil))
+		require.Nil(t, tm.AddBlacklist(ctx, userID, items))
+
+		for _, tokenID := range []string{items[0].TokenID, items[1].RefreshTokenID} {
+			isBlacklist, err := tm.IsBlacklist(ctx, userID, tokenID)
+			require.Nil(t, err)
+			require.True(t, isBlacklist)
+			isExist, err := tm.IsExistToken(ctx, userID, tokenID)
+			require.Nil(t, err)
+			require.False(t, isExist)
+		}
+		isBlacklist, err := tm.IsBlacklist(ctx, userID, others[0].TokenID)
+		require.Nil(t, err)
+		require.False(t, isBlacklist)
+		isExist, err := tm.IsExistToken(ctx, userID, others[0].TokenID)
+		require.Nil(t, err)
+		require.True(t, isExist)
+	})
+
+	t.Run("retire_tokens", func(t *testing.T) {
+		tm := newHarness(t).tokenManger
+		items := newTestTokenItems(payload, time.Hour, time.Hour*2)
+		require.Nil(t, tm.SaveTokens(ctx, userID, items))
+		// 刷新令牌在前：只能使用一次
+		retireList := []*TokenItem{items[1], items[0]}
+		claimed, err := tm.RetireTokens(ctx, userID, nil, 0)
+		require.Nil(t, err)
+		require.False(t, claimed)
+		claimed, err = tm.RetireTokens(ctx, userID, retireList, 0)
+		require.Nil(t, err)
+		require.True(t, claimed)
+		for _, tokenID := range []string{items[0].TokenID, items[1].RefreshTokenID} {
+			isBlacklist, err := tm.IsBlacklist(ctx, userID, tokenID)
+			require.Nil(t, err)
+			require.True(t, isBlacklist)
+		}
+		allTokens, err := tm.GetAllTokens(ctx, userID)
+		require.Nil(t, err)
+		require.Empty(t, allTokens)
+		claimed, err = tm.RetireTokens(ctx, userID, retireList, 0)
+		require.Nil(t, err)
+		require.False(t, claimed)
+	})
+
+	t.Run("retire_tokens_grace", func(t *testing.T) {
+		h := newHarness(t)
+		tm := h.tokenManger
+		items := newTestTokenItems(payload, time.Hour, time.Hour*2)
+		require.Nil(t, tm.SaveTokens(ctx, userID, items))
+		claimed, err := tm.RetireTokens(ctx, userID, items, time.Minute)
+		require.Nil(t, err)
+		require.True(t, claimed)
+		claimed, err = tm.RetireTokens(ctx, userID, items, time.Minute)
+		require.Nil(t, err)
+		require.False(t, claimed)
+
+		// 宽限期内令牌仍然有效，有效期缩短；刷新令牌立即失效
+		isBlacklist, err := tm.IsBlacklist(ctx, userID, items[0].TokenID)
+		require.Nil(t, err)
+		require.False(t, isBlacklist)
+		item, isNotFound, err := tm.GetToken(ctx, userID, items[0].TokenID)
+		require.Nil(t, err)
+		require.False(t, isNotFound)
+		require.LessOrEqual(t, item.ExpiredAt, time.Now().Add(time.Minute+time.Second).Unix())
+		isBlacklist, err = tm.IsBlacklist(ctx, userID, items[1].RefreshTokenID)
+		require.Nil(t, err)
+		require.True(t, isBlacklist)
+		isExist, err := tm.IsExistToken(ctx, userID, items[1].RefreshTokenID)
+		require.Nil(t, err)
+		require.False(t, isExist)
+
+		// 宽限期后失效
+		h.advance(time.Minute * 2)
+		isBlacklist, err = tm.IsBlacklist(ctx, userID, items[0].TokenID)
+		require.Nil(t, err)
+		require.True(t, isBlacklist)
+	})
+
+	t.Run("login_limit", func(t *testing.T) {
+		tm := newHarness(t).tokenManger
+		items := newTestTokenItems(payload, time.Hour, time.Hour*2)
+		require.Nil(t, tm.AddLoginLimit(ctx, userID, nil))
+		require.Nil(t, tm.AddLoginLimit(ctx, userID, items[:1]))
+
+		isLimit, loginLimit, err := tm.IsLoginLimit(ctx, userID, items[0].TokenID)
+		require.Nil(t, err)
+		require.True(t, isLimit)
+		require.Equal(t, LoginLimitEnum_PLATFORM_ONE, loginLimit)
+
+		isLimit, loginLimit, err = tm.IsLoginLimit(ctx, userID, "not-found")
+		require.Nil(t, err)
+		require.False(t, isLimit)
+		require.Equal(t, LoginLimitEnum_UNLIMITED, loginLimit)
+	})
+
+	t.Run("login_limit_info", func(t *testing.T) {
+		tm := newHarness(t).tokenManger
+		items := newTestTokenItems(payload, time.Hour, time.Hour*2)
+		info := &LoginLimitInfo{
+			LoginLimit:    LoginLimitEnum_ONLY_ONE,
+			LoginPlatform: LoginPlatformEnum_ANDROID,
+			LoginType:     LoginTypeEnum_PHONE_AND_PASSWORD,
+			LoginAt:       time.Now().Unix(),
+			ClientIP:      "10.0.0.1",
+			UserAgent:     "okhttp/4.9",
+		}
+		require.Nil(t, tm.AddLoginLimitInfo(ctx, userID, nil, info))
+		require.Nil(t, tm.AddLoginLimitInfo(ctx, userID, items[:1], info))
+
+		got, isNotFound, err := tm.GetLoginLimit(ctx, userID, items[0].TokenID)
+		require.Nil(t, err)
+		require.False(t, isNotFound)
+		want := *info
+		want.LoginLimit = payload.LoginLimit
+		require.Equal(t, &want, got)
+		isLimit, loginLimit, err := tm.IsLoginLimit(ctx, userID, items[0].TokenID)
+		require.Nil(t, err)
+		require.True(t, isLimit)
+		require.Equal(t, payload.LoginLimit, loginLimit)
+
+		_, isNotFound, err = tm.GetLoginLimit(ctx, userID, "not-found")
+		require.Nil(t, err)
+		require.True(t, isNotFound)
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		h := newHarness(t)
+		tm := h.tokenManger
+		items := newTestTokenItems(payload, time.Minute, time.Minute*10)
+		require.Nil(t, tm.SaveTokens(ctx, userID, items))
+		blackItems := newTestTokenItems(payload, time.Minute, time.Minute*10)
+		require.Nil(t, tm.AddBlacklist(ctx, userID, blackItems))
+		require.Nil(t, tm.AddLoginLimit(ctx, userID, blackItems[:1]))
+
+		// 过期时间作用于整个哈希：令牌已过期，但刷新令牌未过期
+		h.advance(time.Minute * 2)
+		isExist, err := tm.IsExistToken(ctx, userID, items[0].TokenID)
+		require.Nil(t, err)
+		require.True(t, isExist)
+		isBlacklist, err := tm.IsBlacklist(ctx, userID, blackItems[0].TokenID)
+		require.Nil(t, err)
+		require.False(t, isBlacklist)
+		isBlacklist, err = tm.IsBlacklist(ctx, userID, blackItems[1].RefreshTokenID)
+		require.Nil(t, err)
+		require.True(t, isBlacklist)
+		isLimit, _, err := tm.IsLoginLimit(ctx, userID, blackItems[0].TokenID)
+		require.Nil(t, err)
+		require.False(t, isLimit)
+
+		h.advance(time.Minute * 10)
+		allTokens, err := tm.GetAllTokens(ctx, userID)
+		require.Nil(t, err)
+		require.Empty(t, allTokens)
+		isBlacklist, err = tm.IsBlacklist(ctx, userID, blackItems[1].RefreshTokenID)
+		require.Nil(t, err)
+		require.False(t, isBlacklist)
+	})
+
+	t.Run("expire_extend", func(t *testing.T) {
+		h := newHarness(t)
+		tm := h.tokenManger
+		items := newTestTokenItems(payload, time.Hour, time.Hour*2)
+		require.Nil(t, tm.SaveTokens(ctx, userID, items))
+		// 有效期较短的令牌不缩短哈希的过期时间
+		require.Nil(t, tm.SaveTokens(ctx, userID, newTestTokenItems(payload, time.Minute, time.Minute*10)))
+		h.advance(time.Minute * 30)
+		isExist, err := tm.IsExistToken(ctx, userID, items[1].RefreshTokenID)
+		require.Nil(t, err)
+		require.True(t, isExist)
+		h.advance(time.Hour * 2)
+		isExist, err = tm.IsExistToken(ctx, userID, items[1].RefreshTokenID)
+		require.Nil(t, err)
+		require.False(t, isExist)
+	})
+
+	t.Run("evict_login_limit", func(t *testing.T) {
+		tm := newHarness(t).tokenManger
+		var (
+			items  = newTestTokenItems(payload, time.Hour, time.Hour*2)
+			others = newTestTokenItems(payload, time.Hour, time.Hour*2)
+			info   = &LoginLimitInfo{LoginPlatform: LoginPlatformEnum_IOS, ClientIP: "10.0.0.1"}
+		)
+		require.Nil(t, tm.SaveTokens(ctx, userID, append(items, others...)))
+
+		evictions, err := tm.EvictLoginLimit(ctx, userID, func(allTokens map[string]*TokenItem) []*LoginLimitEviction {
+			require.Len(t, allTokens, 4)
+			return []*LoginLimitEviction{{Items: []*TokenItem{allTokens[items[0].TokenID], allTokens[items[1].RefreshTokenID]}, Info: info}}
+		})
+		require.Nil(t, err)
+		require.Len(t, evictions, 1)
+
+		for _, tokenID := range []string{items[0].TokenID, items[1].RefreshTokenID} {
+			isBlacklist, err := tm.IsBlacklist(ctx, userID, tokenID)
+			require.Nil(t, err)
+			require.True(t, isBlacklist)
+		}
+		got, isNotFound, err := tm.GetLoginLimit(ctx, userID, items[0].TokenID)
+		require.Nil(t, err)
+		require.False(t, isNotFound)
+		require.Equal(t, "10.0.0.1", got.ClientIP)
+		require.Equal(t, payload.LoginLimit, got.LoginLimit)
+		_, isNotFound, err = tm.GetLoginLimit(ctx, userID, items[1].RefreshTokenID)
+		require.Nil(t, err)
+		require.True(t, isNotFound)
+		allTokens, err := tm.GetAllTokens(ctx, userID)
+		require.Nil(t, err)
+		require.Len(t, allTokens, 2)
+
+		// 没有选择的令牌
+		evictions, err = tm.EvictLoginLimit(ctx, userID, func(allTokens map[string]*TokenItem) []*LoginLimitEviction {
+			return nil
+		})
+		require.Nil(t, err)
+		require.Empty(t, evictions)
+	})
+
+	t.Run("token_generation", func(t *testing.T) {
+		h := newHarness(t)
+		tm := h.tokenManger
+		generation, err := tm.GetTokenGeneration(ctx, userID)
+		require.Nil(t, err)
+		require.Zero(t, generation)
+		// 用户没有令牌
+		generation, err = tm.BumpTokenGeneration(ctx, userID)
+		require.Nil(t, err)
+		require.Equal(t, uint64(1), generation)
+
+		items := newTestTokenItems(payload, time.Hour, time.Hour*2)
+		require.Nil(t, tm.SaveTokens(ctx, userID, items))
+		generation, err = tm.BumpTokenGeneration(ctx, userID)
+		require.Nil(t, err)
+		require.Equal(t, uint64(2), generation)
+		generation, err = tm.GetTokenGeneration(ctx, userID)
+		require.Nil(t, err)
+		require.Equal(t, uint64(2), generation)
+		// 不作为令牌返回
+		allTokens, err := tm.GetAllTokens(ctx, userID)
+		require.Nil(t, err)
+		require.Len(t, allTokens, 2)
+		// 删除令牌后保留
+		require.Nil(t, tm.DeleteTokens(ctx, userID, items))
+		generation, err = tm.GetTokenGeneration(ctx, userID)
+		require.Nil(t, err)
+		require.Equal(t, uint64(2), generation)
+		// 租户隔离
+		generation, err = tm.GetTokenGeneration(PutTenantIntoContext(ctx, "tenant-1"), userID)
+		require.Nil(t, err)
+		require.Zero(t, generation)
+		// 随用户令牌的哈希过期
+		h.advance(time.Hour * 3)
+		generation, err = tm.GetTokenGeneration(ctx, userID)
+		require.Nil(t, err)
+		require.Zero(t, generation)
+	})
+
+	t.Run("tenant", func(t *testing.T) {
+		tm := newHarness(t).tokenManger
+		var (
+			tenantA = PutTenantIntoContext(ctx, "a")
+			tenantB = PutTenantIntoContext(ctx, "b")
+			items   = newTestTokenItems(payload, time.Hour, time.Hour*2)
+		)
+		require.Nil(t, tm.SaveTokens(tenantA, userID, items))
+		require.Nil(t, tm.AddBlacklist(tenantA, userID, newTestTokenItems(payload, time.Hour, time.Hour*2)))
+		require.Nil(t, tm.AddLoginLimit(tenantA, userID, items[:1]))
+
+		// 相同的用户id在不同租户中相互隔离
+		for _, other := range []context.Context{ctx, tenantB} {
+			allTokens, err := tm.GetAllTokens(other, userID)
+			require.Nil(t, err)
+			require.Empty(t, allTokens)
+			isLimit, _, err := tm.IsLoginLimit(other, userID, items[0].TokenID)
+			require.Nil(t, err)
+			require.False(t, isLimit)
+		}
+		allTokens, err := tm.GetAllTokens(tenantA, userID)
+		require.Nil(t, err)
+		require.Len(t, allTokens, 2)
+		isLimit, _, err := tm.IsLoginLimit(tenantA, userID, items[0].TokenID)
+		require.Nil(t, err)
+		require.True(t, isLimit)
+
+		require.Nil(t, tm.AddBlacklist(tenantB, userID, items))
+		isBlacklist, err := tm.IsBlacklist(tenantA, userID, items[0].TokenID)
+		require.Nil(t, err)
+		require.False(t, isBlacklist)
+		isBlacklist, err = tm.IsBlacklist(tenantB, userID, items[0].TokenID)
+		require.Nil(t, err)
+		require.True(t, isBlacklist)
+	})
+
+	t.Run("auth_repo", func(t *testing.T) {
+		repo, err := NewAuthRepo(newHarness(t).tokenManger, log.DefaultLogger, Config{SignKey: "1234567890ABCDEF"})
+		require.Nil(t, err)
+		first, _, err := repo.SignToken(ctx, DefaultClaims(*payload))
+		require.Nil(t, err)
+		second, _, err := repo.RefreshToken(ctx, first.RefreshToken)
+		require.Nil(t, err)
+		claims, err := repo.DecodeAccessToken(ctx, second.AccessToken)
+		require.Nil(t, err)
+		sessions, err := repo.ListSessions(ctx, userID)
+		require.Nil(t, err)
+		require.Len(t, sessions, 1)
+		require.Equal(t, claims.ID, sessions[0].TokenID)
+	})
+}
+
+// go test -v -count=1 ./auth -test.run=TestAuthRepo_ConcurrentLoginLimit
+func TestAuthRepo_ConcurrentLoginLimit(t *testing.T) {
+	harnesses := map[string]func(t *testing.T) *tokenMangerHarness{
+		"redis":  newRedisTokenMangerHarness,
+		"memory": newMemoryTokenMangerHarness,
+		"cached": newCachedTokenMangerHarness,
+	}
+	for name, newHarness := range harnesses {
+		t.Run(name, func(t *testing.T) {
+			var (
+				ctx       = context.Background()
+				repo, err = NewAuthRepo(newHarness(t).tokenManger, log.DefaultLogger, Config{SignKey: "1234567890ABCDEF", SyncLoginLimit: true})
+				logins    = 4
+			)
+			require.Nil(t, err)
+
+			for round := 0; round < 20; round++ {
+				var (
+					wg     sync.WaitGroup
+					start  = make(chan struct{})
+					errCh  = make(chan error, logins)
+					claims = make([]*Claims, logins)
+				)
+				for i := 0; i < logins; i++ {
+					claims[i] = DefaultClaims(Payload{UserID: uint64(round + 1), LoginLimit: LoginLimitEnum_ONLY_ONE})
+					wg.Add(1)
+					go func(claims *Claims) {
+						defer wg.Done()
+						<-start
+						_, _, err := repo.SignToken(ctx, claims)
+						errCh <- err
+					}(claims[i])
+				}
+				close(start)
+				wg.Wait()
+				close(errCh)
+				for err := range errCh {
+					require.Nil(t, err, "round %d", round)
+				}
+
+				// 同步检查登录限制：签发返回后仅保留最新的令牌
+				newest := claims[0]
+				for _, c := range claims[1:] {
+					if isNewerTokenItem(&TokenItem{TokenID: c.ID, IssuedAt: c.IssuedAt.Unix()}, &TokenItem{TokenID: newest.ID, IssuedAt: newest.IssuedAt.Unix()}) {
+						newest = c
+					}
+				}
+				for i := range claims {
+					err := repo.VerifyToken(ctx, &jwt.Token{Claims: claims[i]})
+					if claims[i] == newest {
+						require.Nil(t, err, "round %d", round)
+						continue
+					}
+					require.True(t, Is(err, ErrLoginLimit()), "round %d: %v", round, err)
+				}
+				sessions, err := repo.ListSessions(ctx, strconv.Itoa(round+1))
+				require.Nil(t, err)
+				require.Len(t, sessions, 1)
+				require.Equal(t, newest.ID, sessions[0].TokenID)
+			}
+		})
+	}
+}
+
+// go test -v -count=1 ./auth -test.run=TestNewMemoryTokenManger
+func TestNewMemoryTokenManger(t *testing.T) {
+	tm, cleanup := NewMemoryTokenManger(time.Millisecond * 10)
+	defer cleanup()
+
+	items := newTestTokenItems(&Payload{UserID: 1}, -time.Second, -time.Second)
+	require.Nil(t, tm.AddBlacklist(context.Background(), "1", items))
+
+	// 后台清理
+	m := tm.(*memoryTokenManger)
+	require.Eventually(t, func() bool {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		return len(m.blacklist) == 0
+	}, time.Second*3, time.Millisecond*10)
+	cleanup()
+}
@@ -0,0 +1,475 @@
+package authpkg
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMemoryCleanupInterval 内存令牌管理的默认清理间隔
+	DefaultMemoryCleanupInterval = time.Minute
+)
+
+var _ TokenManger = (*memoryTokenManger)(nil)
+
+// memoryEntry 带有过期时间的值
+type memoryEntry struct {
+	value    string
+	expireAt time.Time // 零值：永不过期
+}
+
+// memoryHash 带有过期时间的哈希
+type memoryHash struct {
+	fields   map[string]string
+	expireAt time.Time // 零值：永不过期
+}
+
+// memoryTokenManger 基于进程内存的令牌管理；适用于单节点部署与测试
+// 与 Redis 的实现保持一致：令牌按用户存储在哈希中，过期时间作用于整个哈希
+type memoryTokenManger struct {
+	mu         sync.RWMutex
+	tokens     map[string]*memoryHash
+	blacklist  map[string]*memoryEntry
+	loginLimit map[string]*memoryEntry
+
+	now       func() time.Time
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// NewMemoryTokenManger 基于进程内存的令牌管理
+// cleanupInterval 后台清理过期数据的间隔；cleanup 停止后台清理
+func NewMemoryTokenManger(cleanupInterval time.Duration) (tm TokenManger, cleanup func()) {
+	m := newMemoryTokenManger(time.Now)
+	if cleanupInterval <= 0 {
+		cleanupInterval = DefaultMemoryCleanupInterval
+	}
+	go m.cleanupLoop(cleanupInterval)
+	return m, m.close
+}
+
+// newMemoryTokenManger ...
+func newMemoryTokenManger(now func() time.Time) *memoryTokenManger {
+	return &memoryTokenManger{
+		tokens:     make(map[string]*memoryHash),
+		blacklist:  make(map[string]*memoryEntry),
+		loginLimit: make(map[string]*memoryEntry),
+		now:        now,
+		closeCh:    make(chan struct{}),
+	}
+}
+
+// close ...
+func (s *memoryTokenManger) close() {
+	s.closeOnce.Do(func() {
+		close(s.closeCh)
+	})
+}
+
+// cleanupLoop ...
+func (s *memoryTokenManger) cleanupLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closeCh:
+			return
+		case <-ticker.C:
+			s.cleanup()
+		}
+	}
+}
+
+// cleanup 清理过期数据
+func (s *memoryTokenManger) cleanup() {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, hash := range s.tokens {
+		if isExpired(hash.expireAt, now) {
+			delete(s.tokens, key)
+		}
+	}
+	for key, entry := range s.blacklist {
+		if isExpired(entry.expireAt, now) {
+			delete(s.blacklist, key)
+		}
+	}
+	for key, entry := range s.loginLimit {
+		if isExpired(entry.expireAt, now) {
+			delete(s.loginLimit, key)
+		}
+	}
+}
+
+// isExpired ...
+func isExpired(expireAt, now time.Time) bool {
+	return !expireAt.IsZero() && !now.Before(expireAt)
+}
+
+// expireAt 与 tokenManger.calcExpireTime 一致；零值永不过期
+func (s *memoryTokenManger) expireAt(expiredAt int64, now time.Time) time.Time {
+	if expiredAt == 0 {
+		return time.Time{}
+	}
+	t := time.Duration(expiredAt-now.Unix()) * time.Second
+	if t <= 0 {
+		t = time.Second
+	}
+	return now.Add(t)
+}
+
+// getHash 未过期的哈希；key 为 tenantKey(ctx, userIdentifier)。调用方持有锁
+func (s *memoryTokenManger) getHash(key string, now time.Time) (*memoryHash, bool) {
+	hash, ok := s.tokens[key]
+	if !ok || isExpired(hash.expireAt, now) {
+		return nil, false
+	}
+	return hash, true
+}
+
+// getEntry 未过期的值；调用方持有锁
+func (s *memoryTokenManger) getEntry(entries map[string]*memoryEntry, key string, now time.Time) (*memoryEntry, bool) {
+	entry, ok := entries[key]
+	if !ok || isExpired(entry.expireAt, now) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// deleteFields 删除哈希字段；与 Redis 一致，字段为空时删除哈希。调用方持有锁
+func (s *memoryTokenManger) deleteFields(key string, fields []string, now time.Time) {
+	hash, ok := s.getHash(key, now)
+	if !ok {
+		return
+	}
+	for i := range fields {
+		delete(hash.fields, fields[i])
+	}
+	if len(hash.fields) == 0 {
+		delete(s.tokens, key)
+	}
+}
+
+// SaveTokens ...
+func (s *memoryTokenManger) SaveTokens(ctx context.Context, userIdentifier string, tokenItems []*TokenItem) error {
+	if len(tokenItems) == 0 {
+		return nil
+	}
+
+	var (
+		now      = s.now()
+		fields   = make(map[string]string, len(tokenItems))
+		expireAt time.Time
+	)
+	for i := range tokenItems {
+		itemStr, err := tokenItems[i].EncodeToString()
+		if err != nil {
+			return err
+		}
+		if tokenItems[i].IsRefreshToken {
+			fields[tokenItems[i].RefreshTokenID] = itemStr
+		} else {
+			fields[tokenItems[i].TokenID] = itemStr
+		}
+
+		// 过期时间
+		if ex := s.expireAt(tokenItems[i].ExpiredAt, now); ex.After(expireAt) {
+			expireAt = ex
+		}
+	}
+
+	key := tenantKey(ctx, userIdentifier)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash, ok := s.getHash(key, now)
+	if !ok {
+		hash = &memoryHash{fields: make(map[string]string, len(fields))}
+		s.tokens[key] = hash
+	}
+	for field, value := range fields {
+		hash.fields[field] = value
+	}
+	// 与 Redis 一致：过期时间只延长不缩短
+	if !expireAt.IsZero() && (hash.expireAt.IsZero() || hash.expireAt.Before(expireAt)) {
+		hash.expireAt = expireAt
+	}
+	return nil
+}
+
+// DeleteTokens ...
+func (s *memoryTokenManger) DeleteTokens(ctx context.Context, userIdentifier string, tokenItems []*TokenItem) error {
+	if len(tokenItems) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteFields(tenantKey(ctx, userIdentifier), tokenItemFields(tokenItems), s.now())
+	return nil
+}
+
+// GetToken ...
+func (s *memoryTokenManger) GetToken(ctx context.Context, userIdentifier string, tokenID string) (item *TokenItem, isNotFound bool, err error) {
+	s.mu.RLock()
+	var value string
+	hash, ok := s.getHash(tenantKey(ctx, userIdentifier), s.now())
+	if ok {
+		value, ok = hash.fields[tokenID]
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return item, true, nil
+	}
+	item = &TokenItem{}
+	err = item.DecodeString(value)
+	return item, false, err
+}
+
+// GetAllTokens ...
+func (s *memoryTokenManger) GetAllTokens(ctx context.Context, userIdentifier string) (map[string]*TokenItem, error) {
+	s.mu.RLock()
+	fields := make(map[string]string)
+	if hash, ok := s.getHash(tenantKey(ctx, userIdentifier), s.now()); ok {
+		for field, value := range hash.fields {
+			fields[field] = value
+		}
+	}
+	s.mu.RUnlock()
+
+	return decodeTokenItems(fields)
+}
+
+// IsExistToken ...
+func (s *memoryTokenManger) IsExistToken(ctx context.Context, userIdentifier string, tokenID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hash, ok := s.getHash(tenantKey(ctx, userIdentifier), s.now())
+	if !ok {
+		return false, nil
+	}
+	_, ok = hash.fields[tokenID]
+	return ok, nil
+}
+
+// AddBlacklist ...
+func (s *memoryTokenManger) AddBlacklist(ctx context.Context, userIdentifier string, tokenItems []*TokenItem) error {
+	if len(tokenItems) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addBlacklist(ctx, userIdentifier, tokenItems, 0, s.now())
+}
+
+// RetireTokens 在同一个锁内检查第一个令牌的黑名单与注销令牌
+func (s *memoryTokenManger) RetireTokens(ctx context.Context, userIdentifier string, tokenItems []*TokenItem, grace time.Duration) (bool, error) {
+	if len(tokenItems) == 0 {
+		return false, nil
+	}
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.getEntry(s.blacklist, memoryUserTokenKey(ctx, userIdentifier, tokenItemFields(tokenItems[:1])[0]), now); ok {
+		return false, nil
+	}
+	if err := s.addBlacklist(ctx, userIdentifier, tokenItems, grace, now); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// addBlacklist 加入黑名单并删除令牌；宽限期内的令牌缩短有效期，不删除。调用方持有锁
+func (s *memoryTokenManger) addBlacklist(ctx context.Context, userIdentifier string, tokenItems []*TokenItem, grace time.Duration, now time.Time) error {
+	var (
+		key    = tenantKey(ctx, userIdentifier)
+		fields = make([]string, 0, len(tokenItems))
+	)
+	for i := range tokenItems {
+		field := tokenItemFields(tokenItems[i : i+1])[0]
+		notBefore, graceItem, ok := graceTokenItem(tokenItems[i], now.Unix(), grace)
+		s.blacklist[memoryUserTokenKey(ctx, userIdentifier, field)] = &memoryEntry{
+			value:    strconv.FormatInt(notBefore, 10),
+			expireAt: s.expireAt(tokenItems[i].ExpiredAt, now),
+		}
+		if !ok {
+			fields = append(fields, field)
+			continue
+		}
+		itemStr, err := graceItem.EncodeToString()
+		if err != nil {
+			return err
+		}
+		if hash, ok := s.getHash(key, now); ok {
+			if _, ok = hash.fields[field]; ok {
+				hash.fields[field] = itemStr
+			}
+		}
+	}
+	s.deleteFields(key, fields, now)
+	return nil
+}
+
+// IsBlacklist ...
+func (s *memoryTokenManger) IsBlacklist(ctx context.Context, userIdentifier string, tokenID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := s.now()
+	entry, ok := s.getEntry(s.blacklist, memoryUserTokenKey(ctx, userIdentifier, tokenID), now)
+	return ok && isBlacklistValue(entry.value, now.Unix()), nil
+}
+
+// AddLoginLimit ...
+func (s *memoryTokenManger) AddLoginLimit(ctx context.Context, userIdentifier string, tokenItems []*TokenItem) error {
+	return s.AddLoginLimitInfo(ctx, userIdentifier, tokenItems, nil)
+}
+
+// AddLoginLimitInfo ...
+func (s *memoryTokenManger) AddLoginLimitInfo(ctx context.Context, userIdentifier string, tokenItems []*TokenItem, info *LoginLimitInfo) error {
+	if len(tokenItems) == 0 {
+		return nil
+	}
+	entries, err := s.loginLimitEntries(ctx, userIdentifier, tokenItems, info, s.now())
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, entry := range entries {
+		s.loginLimit[key] = entry
+	}
+	return nil
+}
+
+// loginLimitEntries ...
+func (s *memoryTokenManger) loginLimitEntries(ctx context.Context, userIdentifier string, tokenItems []*TokenItem, info *LoginLimitInfo, now time.Time) (map[string]*memoryEntry, error) {
+	entries := make(map[string]*memoryEntry, len(tokenItems))
+	for i := range tokenItems {
+		infoStr, err := loginLimitInfoString(tokenItems[i], info)
+		if err != nil {
+			return nil, err
+		}
+		entries[memoryUserTokenKey(ctx, userIdentifier, tokenItems[i].TokenID)] = &memoryEntry{
+			value:    infoStr,
+			expireAt: s.expireAt(tokenItems[i].ExpiredAt, now),
+		}
+	}
+	return entries, nil
+}
+
+// EvictLoginLimit 在同一个锁内读取、选择并注销令牌
+func (s *memoryTokenManger) EvictLoginLimit(ctx context.Context, userIdentifier string, selector LoginLimitSelector) ([]*LoginLimitEviction, error) {
+	var (
+		now    = s.now()
+		key    = tenantKey(ctx, userIdentifier)
+		fields = make(map[string]string)
+	)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hash, ok := s.getHash(key, now); ok {
+		for field, value := range hash.fields {
+			fields[field] = value
+		}
+	}
+	allTokens, err := decodeTokenItems(fields)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		evictions = selector(allTokens)
+		entries   = make([]map[string]*memoryEntry, len(evictions))
+	)
+	for i, eviction := range evictions {
+		if entries[i], err = s.loginLimitEntries(ctx, userIdentifier, loginLimitItems(eviction.Items), eviction.Info, now); err != nil {
+			return nil, err
+		}
+	}
+	for i, eviction := range evictions {
+		if err = s.addBlacklist(ctx, userIdentifier, eviction.Items, 0, now); err != nil {
+			return nil, err
+		}
+		for limitKey, entry := range entries[i] {
+			s.loginLimit[limitKey] = entry
+		}
+	}
+	return evictions, nil
+}
+
+// GetTokenGeneration ...
+func (s *memoryTokenManger) GetTokenGeneration(ctx context.Context, userIdentifier string) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hash, ok := s.getHash(tenantKey(ctx, userIdentifier), s.now())
+	if !ok {
+		return 0, nil
+	}
+	return parseTokenGeneration(hash.fields[TokenGenerationField])
+}
+
+// BumpTokenGeneration 与 Redis 一致：令牌代数保存在用户令牌的哈希中
+func (s *memoryTokenManger) BumpTokenGeneration(ctx context.Context, userIdentifier string) (uint64, error) {
+	key := tenantKey(ctx, userIdentifier)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash, ok := s.getHash(key, s.now())
+	if !ok {
+		hash = &memoryHash{fields: make(map[string]string)}
+		s.tokens[key] = hash
+	}
+	generation, err := parseTokenGeneration(hash.fields[TokenGenerationField])
+	if err != nil {
+		return 0, err
+	}
+	generation++
+	hash.fields[TokenGenerationField] = strconv.FormatUint(generation, 10)
+	return generation, nil
+}
+
+// parseTokenGeneration ...
+func parseTokenGeneration(value string) (uint64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// IsLoginLimit ...
+func (s *memoryTokenManger) IsLoginLimit(ctx context.Context, userIdentifier string, tokenID string) (bool, LoginLimitEnum_LoginLimit, error) {
+	info, isNotFound, err := s.GetLoginLimit(ctx, userIdentifier, tokenID)
+	if err != nil || isNotFound {
+		return false, LoginLimitEnum_UNLIMITED, err
+	}
+	return true, info.LoginLimit, nil
+}
+
+// GetLoginLimit ...
+func (s *memoryTokenManger) GetLoginLimit(ctx context.Context, userIdentifier string, tokenID string) (info *LoginLimitInfo, isNotFound bool, err error) {
+	s.mu.RLock()
+	entry, ok := s.getEntry(s.loginLimit, memoryUserTokenKey(ctx, userIdentifier, tokenID), s.now())
+	s.mu.RUnlock()
+	if !ok {
+		return info, true, nil
+	}
+	info = &LoginLimitInfo{}
+	err = info.DecodeString(entry.value)
+	return info, false, err
+}
+
+// memoryUserTokenKey 黑名单与登录限制的key：与 Redis 一致按用户存储
+func memoryUserTokenKey(ctx context.Context, userIdentifier, tokenID string) string {
+	return tenantKey(ctx, userIdentifier) + ":" + tokenID
+}
+
+// tokenItemFields 令牌在哈希中的字段
+func tokenItemFields(tokenItems []*TokenItem) []string {
+	fields := make([]string, 0, len(tokenItems))
+	for i := range tokenItems {
+		if tokenItems[i].IsRefreshToken {
+			fields = append(fields, tokenItems[i].RefreshTokenID)
+		} else {
+			fields = append(fields, tokenItems[i].TokenID)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,430 @@
+package authpkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+
+	threadpkg "github.com/eden-quan/go-kratos-pkg/thread"
+)
+
+const (
+	// DefaultVerifyCachePositiveTTL 有效令牌的缓存时间；失效通知丢失时，注销的令牌最多在此时间内仍然有效
+	DefaultVerifyCachePositiveTTL = time.Second * 10
+	// DefaultVerifyCacheNegativeTTL 黑名单与不存在的令牌的缓存时间
+	DefaultVerifyCacheNegativeTTL = time.Minute
+	// DefaultVerifyCacheMaxEntries 缓存的最大数量
+	DefaultVerifyCacheMaxEntries = 100000
+	// DefaultVerifyCacheChannel 失效通知的 Redis 频道
+	DefaultVerifyCacheChannel = "gs:auth:verify:invalidate"
+)
+
+// VerifyCacheStats 缓存统计
+type VerifyCacheStats struct {
+	// Hits 命中次数
+	Hits uint64
+	// Misses 未命中次数
+	Misses uint64
+	// Invalidations 失效的缓存数量：本实例注销与其他实例通知
+	Invalidations uint64
+}
+
+// VerifyCacheInvalidator 跨实例的缓存失效通知
+type VerifyCacheInvalidator interface {
+	// Publish 通知所有实例令牌已失效；keys 为 tenantKey(ctx, tokenID)
+	Publish(ctx context.Context, keys []string) error
+	// Subscribe 接收失效通知；cleanup 停止接收
+	Subscribe(ctx context.Context, handler func(keys []string)) (cleanup func(), err error)
+}
+
+// VerifyCacheOption ...
+type VerifyCacheOption func(*verifyCacheOptions)
+
+// verifyCacheOptions ...
+type verifyCacheOptions struct {
+	positiveTTL time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+	invalidator VerifyCacheInvalidator
+}
+
+// WithVerifyCacheTTL 有效令牌(positive)与黑名单、不存在的令牌(negative)的缓存时间
+func WithVerifyCacheTTL(positive, negative time.Duration) VerifyCacheOption {
+	return func(o *verifyCacheOptions) {
+		o.positiveTTL = positive
+		o.negativeTTL = negative
+	}
+}
+
+// WithVerifyCacheMaxEntries 缓存的最大数量；超出时清理过期的缓存，仍然超出时清空
+func WithVerifyCacheMaxEntries(maxEntries int) VerifyCacheOption {
+	return func(o *verifyCacheOptions) {
+		o.maxEntries = maxEntries
+	}
+}
+
+// WithVerifyCacheInvalidator 跨实例的失效通知；例：NewRedisVerifyCacheInvalidator
+// 未配置时仅本实例的注销使缓存失效，其他实例在缓存时间内仍然使用缓存
+func WithVerifyCacheInvalidator(invalidator VerifyCacheInvalidator) VerifyCacheOption {
+	return func(o *verifyCacheOptions) {
+		o.invalidator = invalidator
+	}
+}
+
+// CachedTokenManger 缓存令牌验证结果的令牌管理
+type CachedTokenManger interface {
+	TokenManger
+	// Stats 缓存统计
+	Stats() VerifyCacheStats
+}
+
+var _ CachedTokenManger = (*cachedTokenManger)(nil)
+
+// verifyCacheEntry ...
+type verifyCacheEntry struct {
+	// userIdentifier IsBlacklist 与 IsExistToken 的用户
+	userIdentifier string
+	value          bool
+	generation     uint64
+	expireAt       time.Time
+}
+
+// cachedTokenManger 进程内缓存 IsBlacklist、IsExistToken 与 GetTokenGeneration 的结果
+type cachedTokenManger struct {
+	TokenManger
+	opts       *verifyCacheOptions
+	logHandler *log.Helper
+	now        func() time.Time
+
+	mu         sync.Mutex
+	blacklist  map[string]*verifyCacheEntry
+	exist      map[string]*verifyCacheEntry
+	generation map[string]*verifyCacheEntry
+
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+// NewCachedTokenManger 缓存令牌验证(IsBlacklist、IsExistToken、GetTokenGeneration)的结果，减少每个请求的 Redis 访问
+// 本实例注销令牌时立即失效，并通过 VerifyCacheInvalidator 通知其他实例；cleanup 停止接收失效通知
+func NewCachedTokenManger(tokenManger TokenManger, logger log.Logger, opts ...VerifyCacheOption) (tm CachedTokenManger, cleanup func(), err error) {
+	return newCachedTokenManger(tokenManger, logger, time.Now, opts...)
+}
+
+// newCachedTokenManger ...
+func newCachedTokenManger(tokenManger TokenManger, logger log.Logger, now func() time.Time, opts ...VerifyCacheOption) (*cachedTokenManger, func(), error) {
+	if tokenManger == nil {
+		return nil, nil, fmt.Errorf("token manger is nil")
+	}
+	o := &verifyCacheOptions{
+		positiveTTL: DefaultVerifyCachePositiveTTL,
+		negativeTTL: DefaultVerifyCacheNegativeTTL,
+		maxEntries:  DefaultVerifyCacheMaxEntries,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	s := &cachedTokenManger{
+		TokenManger: tokenManger,
+		opts:        o,
+		logHandler:  log.NewHelper(log.With(logger, "module", "auth/verify_cache")),
+		now:         now,
+		blacklist:   make(map[string]*verifyCacheEntry),
+		exist:       make(map[string]*verifyCacheEntry),
+		generation:  make(map[string]*verifyCacheEntry),
+	}
+	cleanup := func() {}
+	if o.invalidator != nil {
+		var err error
+		cleanup, err = o.invalidator.Subscribe(context.Background(), s.invalidateLocal)
+		if err != nil {
+			return nil, nil, fmt.Errorf("subscribe verify cache invalidation failed : %w", err)
+		}
+	}
+	return s, cleanup, nil
+}
+
+// Stats ...
+func (s *cachedTokenManger) Stats() VerifyCacheStats {
+	return VerifyCacheStats{
+		Hits:          s.hits.Load(),
+		Misses:        s.misses.Load(),
+		Invalidations: s.invalidations.Load(),
+	}
+}
+
+// IsBlacklist ...
+func (s *cachedTokenManger) IsBlacklist(ctx context.Context, userIdentifier string, tokenID string) (bool, error) {
+	key := tenantKey(ctx, tokenID)
+	if entry, ok := s.get(s.blacklist, key, userIdentifier); ok {
+		return entry.value, nil
+	}
+	isBlacklist, err := s.TokenManger.IsBlacklist(ctx, userIdentifier, tokenID)
+	if err != nil {
+		return false, err
+	}
+	s.set(s.blacklist, key, &verifyCacheEntry{userIdentifier: userIdentifier, value: isBlacklist}, !isBlacklist)
+	return isBlacklist, nil
+}
+
+// IsExistToken ...
+func (s *cachedTokenManger) IsExistToken(ctx context.Context, userIdentifier string, tokenID string) (bool, error) {
+	key := tenantKey(ctx, tokenID)
+	if entry, ok := s.get(s.exist, key, userIdentifier); ok {
+		return entry.value, nil
+	}
+	isExist, err := s.TokenManger.IsExistToken(ctx, userIdentifier, tokenID)
+	if err != nil {
+		return false, err
+	}
+	s.set(s.exist, key, &verifyCacheEntry{userIdentifier: userIdentifier, value: isExist}, isExist)
+	return isExist, nil
+}
+
+// GetTokenGeneration 使用有效令牌的缓存时间
+func (s *cachedTokenManger) GetTokenGeneration(ctx context.Context, userIdentifier string) (uint64, error) {
+	key := generationCacheKey(ctx, userIdentifier)
+	if entry, ok := s.get(s.generation, key, ""); ok {
+		return entry.generation, nil
+	}
+	generation, err := s.TokenManger.GetTokenGeneration(ctx, userIdentifier)
+	if err != nil {
+		return 0, err
+	}
+	s.set(s.generation, key, &verifyCacheEntry{generation: generation}, true)
+	return generation, nil
+}
+
+// uncached 不使用缓存的令牌管理
+func (s *cachedTokenManger) uncached() TokenManger {
+	return s.TokenManger
+}
+
+// uncachedTokenManger 缓存验证结果的令牌管理
+type uncachedTokenManger interface {
+	uncached() TokenManger
+}
+
+// unwrapTokenManger 不使用缓存的令牌管理；签发令牌需要最新的令牌代数，缓存仅用于验证
+func unwrapTokenManger(tokenManger TokenManger) TokenManger {
+	for {
+		tm, ok := tokenManger.(uncachedTokenManger)
+		if !ok {
+			return tokenManger
+		}
+		tokenManger = tm.uncached()
+	}
+}
+
+// BumpTokenGeneration ...
+func (s *cachedTokenManger) BumpTokenGeneration(ctx context.Context, userIdentifier string) (uint64, error) {
+	generation, err := s.TokenManger.BumpTokenGeneration(ctx, userIdentifier)
+	if err != nil {
+		return 0, err
+	}
+	s.invalidate(ctx, []string{generationCacheKey(ctx, userIdentifier)})
+	return generation, nil
+}
+
+// SaveTokens 新的令牌使本实例的缓存失效
+func (s *cachedTokenManger) SaveTokens(ctx context.Context, userIdentifier string, tokenItems []*TokenItem) error {
+	if err := s.TokenManger.SaveTokens(ctx, userIdentifier, tokenItems); err != nil {
+		return err
+	}
+	s.invalidateLocal(tokenItemKeys(ctx, tokenItems))
+	return nil
+}
+
+// DeleteTokens ...
+func (s *cachedTokenManger) DeleteTokens(ctx context.Context, userIdentifier string, tokenItems []*TokenItem) error {
+	if err := s.TokenManger.DeleteTokens(ctx, userIdentifier, tokenItems); err != nil {
+		return err
+	}
+	s.invalidate(ctx, tokenItemKeys(ctx, tokenItems))
+	return nil
+}
+
+// AddBlacklist ...
+func (s *cachedTokenManger) AddBlacklist(ctx context.Context, userIdentifier string, tokenItems []*TokenItem) error {
+	if err := s.TokenManger.AddBlacklist(ctx, userIdentifier, tokenItems); err != nil {
+		return err
+	}
+	s.invalidate(ctx, tokenItemKeys(ctx, tokenItems))
+	return nil
+}
+
+// RetireTokens ...
+// 宽限期内的令牌不在黑名单中；宽限期后最多在有效令牌的缓存时间内仍然有效
+func (s *cachedTokenManger) RetireTokens(ctx context.Context, userIdentifier string, tokenItems []*TokenItem, grace time.Duration) (bool, error) {
+	ok, err := s.TokenManger.RetireTokens(ctx, userIdentifier, tokenItems, grace)
+	if err != nil || !ok {
+		return ok, err
+	}
+	s.invalidate(ctx, tokenItemKeys(ctx, tokenItems))
+	return true, nil
+}
+
+// EvictLoginLimit ...
+func (s *cachedTokenManger) EvictLoginLimit(ctx context.Context, userIdentifier string, selector LoginLimitSelector) ([]*LoginLimitEviction, error) {
+	evictions, err := s.TokenManger.EvictLoginLimit(ctx, userIdentifier, selector)
+	if err != nil {
+		return nil, err
+	}
+	var items []*TokenItem
+	for _, eviction := range evictions {
+		items = append(items, eviction.Items...)
+	}
+	s.invalidate(ctx, tokenItemKeys(ctx, items))
+	return evictions, nil
+}
+
+// get 未过期的缓存；IsBlacklist 与 IsExistToken 的缓存需要匹配用户
+func (s *cachedTokenManger) get(entries map[string]*verifyCacheEntry, key, userIdentifier string) (*verifyCacheEntry, bool) {
+	s.mu.Lock()
+	entry, ok := entries[key]
+	if ok && (entry.userIdentifier != userIdentifier || !s.now().Before(entry.expireAt)) {
+		ok = false
+	}
+	s.mu.Unlock()
+	if !ok {
+		s.misses.Add(1)
+		return nil, false
+	}
+	s.hits.Add(1)
+	return entry, true
+}
+
+// set positive 为有效令牌的结果
+func (s *cachedTokenManger) set(entries map[string]*verifyCacheEntry, key string, entry *verifyCacheEntry, positive bool) {
+	ttl := s.opts.negativeTTL
+	if positive {
+		ttl = s.opts.positiveTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.opts.maxEntries > 0 && s.lenLocked() >= s.opts.maxEntries {
+		s.sweepLocked(now)
+	}
+	entry.expireAt = now.Add(ttl)
+	entries[key] = entry
+}
+
+// lenLocked 缓存数量；调用方持有锁
+func (s *cachedTokenManger) lenLocked() int {
+	return len(s.blacklist) + len(s.exist) + len(s.generation)
+}
+
+// sweepLocked 清理过期的缓存；仍然超出最大数量时清空；调用方持有锁
+func (s *cachedTokenManger) sweepLocked(now time.Time) {
+	all := []map[string]*verifyCacheEntry{s.blacklist, s.exist, s.generation}
+	for _, entries := range all {
+		for key, entry := range entries {
+			if !now.Before(entry.expireAt) {
+				delete(entries, key)
+			}
+		}
+	}
+	if s.lenLocked() >= s.opts.maxEntries {
+		for _, entries := range all {
+			clear(entries)
+		}
+	}
+}
+
+// invalidate 本实例失效并通知其他实例；通知失败时其他实例在缓存时间后失效
+func (s *cachedTokenManger) invalidate(ctx context.Context, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	s.invalidateLocal(keys)
+	if s.opts.invalidator == nil {
+		return
+	}
+	if err := s.opts.invalidator.Publish(ctx, keys); err != nil {
+		s.logHandler.WithContext(ctx).Errorw("msg", "publish verify cache invalidation failed", "err", err)
+	}
+}
+
+// invalidateLocal ...
+func (s *cachedTokenManger) invalidateLocal(keys []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.blacklist, key)
+		delete(s.exist, key)
+		delete(s.generation, key)
+	}
+	s.invalidations.Add(uint64(len(keys)))
+}
+
+// tokenItemKeys 缓存的key：tenantKey(ctx, tokenID)
+func tokenItemKeys(ctx context.Context, tokenItems []*TokenItem) []string {
+	fields := tokenItemFields(tokenItems)
+	for i := range fields {
+		fields[i] = tenantKey(ctx, fields[i])
+	}
+	return fields
+}
+
+// generationCacheKey 令牌代数的缓存key：与令牌id区分
+func generationCacheKey(ctx context.Context, userIdentifier string) string {
+	return TokenGenerationField + ":" + tenantKey(ctx, userIdentifier)
+}
+
+// redisVerifyCacheInvalidator ...
+type redisVerifyCacheInvalidator struct {
+	redisCC    redis.UniversalClient
+	channel    string
+	logHandler *log.Helper
+}
+
+// NewRedisVerifyCacheInvalidator 基于 Redis 发布订阅的失效通知；channel 为空时使用 DefaultVerifyCacheChannel
+func NewRedisVerifyCacheInvalidator(redisCC redis.UniversalClient, channel string, logger log.Logger) VerifyCacheInvalidator {
+	if channel == "" {
+		channel = DefaultVerifyCacheChannel
+	}
+	return &redisVerifyCacheInvalidator{
+		redisCC:    redisCC,
+		channel:    channel,
+		logHandler: log.NewHelper(log.With(logger, "module", "auth/verify_cache")),
+	}
+}
+
+// Publish 一条消息包含多个key，以换行分隔
+func (s *redisVerifyCacheInvalidator) Publish(ctx context.Context, keys []string) error {
+	return s.redisCC.Publish(ctx, s.channel, strings.Join(keys, "\n")).Err()
+}
+
+// Subscribe 订阅成功后返回；断线时 go-redis 自动重新订阅
+func (s *redisVerifyCacheInvalidator) Subscribe(ctx context.Context, handler func(keys []string)) (func(), error) {
+	pubsub := s.redisCC.Subscribe(ctx, s.channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+	ch := pubsub.Channel()
+	threadpkg.GoSafe(func() {
+		for msg := range ch {
+			if msg.Payload == "" {
+				continue
+			}
+			handler(strings.Split(msg.Payload, "\n"))
+		}
+	})
+	return func() {
+		if err := pubsub.Close(); err != nil {
+			s.logHandler.Errorw("msg", "close verify cache subscription failed", "err", err)
+		}
+	}, nil
+}
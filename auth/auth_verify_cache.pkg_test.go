@@ -0,0 +1,275 @@
+package authpkg
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// newCachedTokenMangerHarness 缓存验证结果的内存令牌管理
+func newCachedTokenMangerHarness(t *testing.T) *tokenMangerHarness {
+	var (
+		mu    sync.Mutex
+		clock = time.Now()
+		now   = func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return clock
+		}
+		m = newMemoryTokenManger(now)
+	)
+	tm, cleanup, err := newCachedTokenManger(m, log.DefaultLogger, now)
+	require.Nil(t, err)
+	t.Cleanup(cleanup)
+	return &tokenMangerHarness{
+		tokenManger: tm,
+		advance: func(d time.Duration) {
+			mu.Lock()
+			clock = clock.Add(d)
+			mu.Unlock()
+			m.cleanup()
+		},
+	}
+}
+
+// go test -v -count=1 ./auth -test.run=TestCachedTokenManger
+func TestCachedTokenManger(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		mr      = miniredis.RunT(t)
+		redisCC = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		clock   = time.Now()
+		now     = func() time.Time { return clock }
+		payload = &Payload{UserID: 1, LoginPlatform: LoginPlatformEnum_IOS}
+		userID  = payload.UserIdentifier()
+		items   = newTestTokenItems(payload, time.Hour, time.Hour*2)
+		tokenID = items[0].TokenID
+		// 两个实例：缓存时间足够长，只能通过失效通知失效
+		newInstance = func() *cachedTokenManger {
+			tm, cleanup, err := newCachedTokenManger(NewTokenManger(redisCC, nil), log.DefaultLogger, now,
+				WithVerifyCacheTTL(time.Hour, time.Minute),
+				WithVerifyCacheInvalidator(NewRedisVerifyCacheInvalidator(redisCC, "", log.DefaultLogger)),
+			)
+			require.Nil(t, err)
+			t.Cleanup(cleanup)
+			return tm
+		}
+		instance1 = newInstance()
+		instance2 = newInstance()
+	)
+	defer func() { _ = redisCC.Close() }()
+	require.Nil(t, instance1.SaveTokens(ctx, userID, items))
+
+	// 未命中后缓存
+	for i := 0; i < 3; i++ {
+		isBlacklist, err := instance2.IsBlacklist(ctx, userID, tokenID)
+		require.Nil(t, err)
+		require.False(t, isBlacklist)
+		isExist, err := instance2.IsExistToken(ctx, userID, tokenID)
+		require.Nil(t, err)
+		require.True(t, isExist)
+	}
+	require.Equal(t, VerifyCacheStats{Hits: 4, Misses: 2}, instance2.Stats())
+
+	// 其他用户不使用缓存
+	isExist, err := instance2.IsExistToken(ctx, "2", tokenID)
+	require.Nil(t, err)
+	require.False(t, isExist)
+
+	// 其他实例注销：失效通知
+	require.Nil(t, instance1.AddBlacklist(ctx, userID, items))
+	require.GreaterOrEqual(t, instance1.Stats().Invalidations, uint64(2))
+	require.Eventually(t, func() bool {
+		return instance2.Stats().Invalidations >= 2
+	}, time.Second, time.Millisecond*10)
+	isBlacklist, err := instance2.IsBlacklist(ctx, userID, tokenID)
+	require.Nil(t, err)
+	require.True(t, isBlacklist)
+	isExist, err = instance2.IsExistToken(ctx, userID, tokenID)
+	require.Nil(t, err)
+	require.False(t, isExist)
+
+	// 缓存过期
+	hits := instance2.Stats().Hits
+	_, err = instance2.IsBlacklist(ctx, userID, tokenID)
+	require.Nil(t, err)
+	require.Equal(t, hits+1, instance2.Stats().Hits)
+	clock = clock.Add(time.Minute)
+	_, err = instance2.IsBlacklist(ctx, userID, tokenID)
+	require.Nil(t, err)
+	require.Equal(t, hits+1, instance2.Stats().Hits)
+
+	// 租户隔离
+	isBlacklist, err = instance2.IsBlacklist(PutTenantIntoContext(ctx, "tenant-1"), userID, tokenID)
+	require.Nil(t, err)
+	require.False(t, isBlacklist)
+
+	// 令牌代数
+	generation, err := instance2.GetTokenGeneration(ctx, userID)
+	require.Nil(t, err)
+	require.Zero(t, generation)
+	generation, err = instance1.BumpTokenGeneration(ctx, userID)
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), generation)
+	require.Eventually(t, func() bool {
+		generation, err := instance2.GetTokenGeneration(ctx, userID)
+		return err == nil && generation == 1
+	}, time.Second, time.Millisecond*10)
+
+	// 最大数量
+	tm, _, err := newCachedTokenManger(newMemoryTokenManger(now), log.DefaultLogger, now, WithVerifyCacheMaxEntries(2))
+	require.Nil(t, err)
+	for _, id := range []string{"a", "b", "c"} {
+		_, err = tm.IsBlacklist(ctx, userID, id)
+		require.Nil(t, err)
+	}
+	require.LessOrEqual(t, len(tm.blacklist), 2)
+}
+
+// go test -v -count=1 ./auth -test.run=TestServer_CachedTokenManger
+func TestServer_CachedTokenManger(t *testing.T) {
+	var (
+		ctx         = context.Background()
+		tm, _, err  = NewCachedTokenManger(newMemoryTokenManger(time.Now), log.DefaultLogger)
+		repo, err2  = NewAuthRepo(tm, log.DefaultLogger, Config{SignKey: "1234567890ABCDEF"})
+		verifyToken = func(token string) error {
+			tr := newTestTransport("/api.user.v1.User/Get")
+			tr.reqHeader.Set(AuthorizationKey, token)
+			_, err := Server(
+				repo.JWTSigningKeyFunc,
+				WithSigningMethod(repo.JWTSigningMethod()),
+				WithClaims(repo.JWTSigningClaims),
+				WithTokenValidator(repo.VerifyToken),
+			)(func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})(transport.NewServerContext(ctx, tr), nil)
+			return err
+		}
+	)
+	require.Nil(t, err)
+	require.Nil(t, err2)
+
+	claims := DefaultClaims(Payload{UserID: 1})
+	res, _, err := repo.SignToken(ctx, claims)
+	require.Nil(t, err)
+	require.Nil(t, verifyToken(res.AccessToken))
+	require.Nil(t, verifyToken(res.AccessToken))
+	// 签发不使用缓存的令牌代数：第一次验证时缓存
+	require.Equal(t, uint64(3), tm.Stats().Hits)
+
+	// 注销后立即失效
+	require.Nil(t, repo.RevokeSession(ctx, "1", claims.ID))
+	require.True(t, Is(verifyToken(res.AccessToken), ErrBlacklist()))
+}
+
+// go test -v -count=1 ./auth -test.run=TestAuthRepo_CachedTokenGeneration
+func TestAuthRepo_CachedTokenGeneration(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		mr      = miniredis.RunT(t)
+		redisCC = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		mu      sync.Mutex
+		clock   = time.Now()
+		now     = func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return clock
+		}
+		payload = Payload{UserID: 1}
+	)
+	t.Cleanup(func() { _ = redisCC.Close() })
+	// 没有失效通知：其他实例增加令牌代数后，本实例的缓存在缓存时间内为旧值
+	tm, cleanup, err := newCachedTokenManger(NewTokenManger(redisCC, nil), log.DefaultLogger, now)
+	require.Nil(t, err)
+	t.Cleanup(cleanup)
+	repo, err := NewAuthRepo(tm, log.DefaultLogger, Config{SignKey: "1234567890ABCDEF"})
+	require.Nil(t, err)
+
+	claims := DefaultClaims(payload)
+	_, _, err = repo.SignToken(ctx, claims)
+	require.Nil(t, err)
+	require.Nil(t, repo.VerifyToken(ctx, &jwt.Token{Claims: claims}))
+	generation, err := tm.GetTokenGeneration(ctx, payload.UserIdentifier())
+	require.Nil(t, err)
+	require.Equal(t, uint64(0), generation)
+
+	// 其他实例增加令牌代数
+	current, err := NewTokenManger(redisCC, nil).BumpTokenGeneration(ctx, payload.UserIdentifier())
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), current)
+	generation, err = tm.GetTokenGeneration(ctx, payload.UserIdentifier())
+	require.Nil(t, err)
+	require.Equal(t, uint64(0), generation, "缓存的旧值")
+
+	// 签发使用当前的令牌代数；缓存过期后仍然有效
+	renewed := DefaultClaims(payload)
+	_, _, err = repo.SignToken(ctx, renewed)
+	require.Nil(t, err)
+	require.Equal(t, current, renewed.Generation)
+	mu.Lock()
+	clock = clock.Add(DefaultVerifyCachePositiveTTL)
+	mu.Unlock()
+	require.Nil(t, repo.VerifyToken(ctx, &jwt.Token{Claims: renewed}))
+	require.True(t, Is(repo.VerifyToken(ctx, &jwt.Token{Claims: claims}), ErrTokenGenerationRevoked()))
+}
+
+// go test -v -count=1 ./auth -bench=BenchmarkServer_VerifyToken -run=BenchmarkServer_VerifyToken
+func BenchmarkServer_VerifyToken(b *testing.B) {
+	mr := miniredis.RunT(b)
+	redisCC := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer func() { _ = redisCC.Close() }()
+
+	cached, cleanup, err := NewCachedTokenManger(NewTokenManger(redisCC, nil), log.DefaultLogger,
+		WithVerifyCacheInvalidator(NewRedisVerifyCacheInvalidator(redisCC, "", log.DefaultLogger)),
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer cleanup()
+
+	tokenMangers := []struct {
+		name        string
+		tokenManger TokenManger
+	}{
+		{name: "uncached", tokenManger: NewTokenManger(redisCC, nil)},
+		{name: "cached", tokenManger: cached},
+	}
+	for _, item := range tokenMangers {
+		b.Run(item.name, func(b *testing.B) {
+			repo, err := NewAuthRepo(item.tokenManger, log.DefaultLogger, Config{SignKey: "1234567890ABCDEF"})
+			if err != nil {
+				b.Fatal(err)
+			}
+			res, _, err := repo.SignToken(context.Background(), DefaultClaims(Payload{UserID: 1}))
+			if err != nil {
+				b.Fatal(err)
+			}
+			handler := Server(
+				repo.JWTSigningKeyFunc,
+				WithSigningMethod(repo.JWTSigningMethod()),
+				WithClaims(repo.JWTSigningClaims),
+				WithTokenValidator(repo.VerifyToken),
+			)(func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})
+			tr := newTestTransport("/api.user.v1.User/Get")
+			tr.reqHeader.Set(AuthorizationKey, res.AccessToken)
+			ctx := transport.NewServerContext(context.Background(), tr)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := handler(ctx, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
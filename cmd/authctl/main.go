@@ -0,0 +1,529 @@
+// authctl 令牌检查与签发工具
+//
+// 用法：authctl [全局参数] <命令> [参数]
+//
+//	authctl -sign-key xxx decode -token eyJ...
+//	authctl -sign-key xxx refresh -token ...
+//	authctl -redis 127.0.0.1:6379 status -token eyJ...
+//	authctl -redis 127.0.0.1:6379 sessions -user 1
+//	authctl -redis 127.0.0.1:6379 revoke -user 1 -all
+//	authctl -sign-key xxx -env DEVELOP -mint-allow-redis 127.0.0.1:6379 mint -payload '{"uid":1,"tt":2}'
+//
+// 全局参数可使用环境变量：AUTHCTL_SIGN_KEY、AUTHCTL_REDIS_ADDR、AUTHCTL_REDIS_PASSWORD、AUTHCTL_ENV、AUTHCTL_MINT_ALLOW_REDIS
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+
+	apppkg "github.com/eden-quan/go-kratos-pkg/app"
+	authpkg "github.com/eden-quan/go-kratos-pkg/auth"
+)
+
+// errUsage 参数错误；打印用法
+var errUsage = errors.New("usage")
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, errUsage) {
+			_, _ = fmt.Fprintln(os.Stderr, "authctl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// config 全局参数
+type config struct {
+	signKey       string
+	signingMethod string
+	privateKey    string
+	publicKey     string
+	redisAddr     string
+	redisPassword string
+	redisDB       int
+	tenant        string
+	env           string
+	// mintAllowRedis 允许签发测试令牌的Redis地址
+	mintAllowRedis string
+}
+
+// command 子命令
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, c *cli, args []string) error
+}
+
+// commands ...
+var commands = []*command{
+	{name: "decode", usage: "解析访问令牌；配置密钥时验证签名", run: runDecode},
+	{name: "verify", usage: "验证访问令牌：签名、有效期、黑名单、令牌代数与白名单", run: runVerify},
+	{name: "refresh", usage: "解密刷新令牌", run: runRefresh},
+	{name: "status", usage: "令牌的白名单、黑名单与登录限制状态", run: runStatus},
+	{name: "sessions", usage: "用户的登录会话", run: runSessions},
+	{name: "revoke", usage: "注销令牌、用户的所有会话或增加令牌代数", run: runRevoke},
+	{name: "mint", usage: "签发测试令牌；仅限非生产环境且Redis地址在 -mint-allow-redis 中", run: runMint},
+}
+
+// cli ...
+type cli struct {
+	conf    *config
+	stdout  io.Writer
+	stderr  io.Writer
+	redisCC redis.UniversalClient
+	tm      authpkg.TokenManger
+	repo    authpkg.AuthRepo
+}
+
+// run 解析全局参数并执行子命令
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	conf := &config{}
+	fs := flag.NewFlagSet("authctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&conf.signKey, "sign-key", os.Getenv("AUTHCTL_SIGN_KEY"), "HMAC签名密钥；同时用于解密刷新令牌")
+	fs.StringVar(&conf.signingMethod, "signing-method", "HS256", "签名方法；例：HS256、RS256、ES256、EdDSA")
+	fs.StringVar(&conf.privateKey, "private-key", "", "非对称签名的私钥文件(PEM)")
+	fs.StringVar(&conf.publicKey, "public-key", "", "非对称签名的公钥文件(PEM)")
+	fs.StringVar(&conf.redisAddr, "redis", envOrDefault("AUTHCTL_REDIS_ADDR", "127.0.0.1:6379"), "Redis地址")
+	fs.StringVar(&conf.redisPassword, "redis-password", os.Getenv("AUTHCTL_REDIS_PASSWORD"), "Redis密码")
+	fs.IntVar(&conf.redisDB, "redis-db", 0, "Redis数据库")
+	fs.StringVar(&conf.tenant, "tenant", "", "租户id")
+	fs.StringVar(&conf.env, "env", envOrDefault("AUTHCTL_ENV", string(apppkg.RuntimeEnvProduction)), "运行环境：LOCAL、DEVELOP、TESTING、PREVIEW、PRODUCTION")
+	fs.StringVar(&conf.mintAllowRedis, "mint-allow-redis", os.Getenv("AUTHCTL_MINT_ALLOW_REDIS"), "允许签发测试令牌的Redis地址，以逗号分隔；默认不允许")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(stderr, "usage: authctl [flags] <command> [command flags]")
+		_, _ = fmt.Fprintln(stderr, "\ncommands:")
+		for _, cmd := range commands {
+			_, _ = fmt.Fprintf(stderr, "  %-10s %s\n", cmd.name, cmd.usage)
+		}
+		_, _ = fmt.Fprintln(stderr, "\nflags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == fs.Arg(0) {
+			cmd = commands[i]
+		}
+	}
+	if cmd == nil {
+		fs.Usage()
+		return fmt.Errorf("unknown command : %s", fs.Arg(0))
+	}
+
+	c, err := newCLI(conf, stdout, stderr)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.redisCC.Close() }()
+	if conf.tenant != "" {
+		ctx = authpkg.PutTenantIntoContext(ctx, conf.tenant)
+	}
+	return cmd.run(ctx, c, fs.Args()[1:])
+}
+
+// newCLI ...
+func newCLI(conf *config, stdout, stderr io.Writer) (*cli, error) {
+	signingMethod := jwt.GetSigningMethod(conf.signingMethod)
+	if signingMethod == nil {
+		return nil, fmt.Errorf("unsupported signing method : %s", conf.signingMethod)
+	}
+	authConfig := authpkg.Config{
+		SigningMethod: signingMethod,
+		SignKey:       conf.signKey,
+		// 签发令牌后立即退出：同步检查登录限制，否则后台的检查随进程退出而丢失
+		SyncLoginLimit: true,
+	}
+	if conf.privateKey != "" {
+		pem, err := os.ReadFile(conf.privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("read private key failed : %w", err)
+		}
+		authConfig.SignPrivateKey = pem
+	}
+	if conf.publicKey != "" {
+		pem, err := os.ReadFile(conf.publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("read public key failed : %w", err)
+		}
+		authConfig.SignPublicKey = pem
+	}
+
+	// 不使用Redis的命令不会建立连接
+	redisCC := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:    []string{conf.redisAddr},
+		Password: conf.redisPassword,
+		DB:       conf.redisDB,
+	})
+	var (
+		tm     = authpkg.NewTokenManger(redisCC, nil)
+		logger = log.NewFilter(log.NewStdLogger(stderr), log.FilterLevel(log.LevelWarn))
+		repo   authpkg.AuthRepo
+	)
+	if hasSigningKey(conf) {
+		var err error
+		repo, err = authpkg.NewAuthRepo(tm, logger, authConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &cli{
+		conf:    conf,
+		stdout:  stdout,
+		stderr:  stderr,
+		redisCC: redisCC,
+		tm:      tm,
+		repo:    repo,
+	}, nil
+}
+
+// hasSigningKey 是否配置了密钥
+func hasSigningKey(conf *config) bool {
+	return conf.signKey != "" || conf.privateKey != "" || conf.publicKey != ""
+}
+
+// authRepo 需要密钥的命令
+func (c *cli) authRepo() (authpkg.AuthRepo, error) {
+	if c.repo == nil {
+		return nil, fmt.Errorf("signing key is required; set -sign-key, -private-key or -public-key")
+	}
+	return c.repo, nil
+}
+
+// print JSON
+func (c *cli) print(v interface{}) error {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// newFlagSet 子命令参数
+func (c *cli) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("authctl "+name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	return fs
+}
+
+// parseFlags ...
+func parseFlags(fs *flag.FlagSet, args []string, required ...string) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	for _, name := range required {
+		if fs.Lookup(name).Value.String() == "" {
+			_, _ = fmt.Fprintf(fs.Output(), "-%s is required\n", name)
+			fs.Usage()
+			return errUsage
+		}
+	}
+	return nil
+}
+
+// decodeResult ...
+type decodeResult struct {
+	Header    map[string]interface{} `json:"header"`
+	Claims    *authpkg.Claims        `json:"claims"`
+	Signature string                 `json:"signature"`
+	ExpiresIn string                 `json:"expires_in,omitempty"`
+}
+
+// runDecode 解析令牌；未配置密钥时不验证签名
+func runDecode(ctx context.Context, c *cli, args []string) error {
+	fs := c.newFlagSet("decode")
+	token := fs.String("token", "", "访问令牌")
+	if err := parseFlags(fs, args, "token"); err != nil {
+		return err
+	}
+
+	claims := &authpkg.Claims{}
+	jwtToken, _, err := jwt.NewParser().ParseUnverified(trimBearer(*token), claims)
+	if err != nil {
+		return fmt.Errorf("parse token failed : %w", err)
+	}
+	res := &decodeResult{
+		Header:    jwtToken.Header,
+		Claims:    claims,
+		Signature: "unverified",
+	}
+	if claims.ExpiresAt != nil {
+		res.ExpiresIn = time.Until(claims.ExpiresAt.Time).Truncate(time.Second).String()
+	}
+	if c.repo != nil {
+		if _, err = c.repo.DecodeAccessToken(ctx, trimBearer(*token)); err != nil {
+			res.Signature = "invalid: " + err.Error()
+		} else {
+			res.Signature = "valid"
+		}
+	}
+	return c.print(res)
+}
+
+// runVerify 与 Server 中间件相同的验证
+func runVerify(ctx context.Context, c *cli, args []string) error {
+	fs := c.newFlagSet("verify")
+	token := fs.String("token", "", "访问令牌")
+	if err := parseFlags(fs, args, "token"); err != nil {
+		return err
+	}
+	repo, err := c.authRepo()
+	if err != nil {
+		return err
+	}
+
+	jwtToken, err := jwt.ParseWithClaims(trimBearer(*token), repo.JWTSigningClaims(), repo.JWTSigningKeyFunc(ctx))
+	if err != nil {
+		return fmt.Errorf("parse token failed : %w", err)
+	}
+	claims, _ := jwtToken.Claims.(*authpkg.Claims)
+	if claims.Payload != nil && claims.Payload.TenantID != "" {
+		ctx = authpkg.PutTenantIntoContext(ctx, claims.Payload.TenantID)
+	}
+	if err = repo.VerifyToken(ctx, jwtToken); err != nil {
+		return err
+	}
+	return c.print(map[string]interface{}{
+		"valid":  true,
+		"claims": claims,
+	})
+}
+
+// runRefresh 解密刷新令牌
+func runRefresh(ctx context.Context, c *cli, args []string) error {
+	fs := c.newFlagSet("refresh")
+	token := fs.String("token", "", "刷新令牌")
+	if err := parseFlags(fs, args, "token"); err != nil {
+		return err
+	}
+	repo, err := c.authRepo()
+	if err != nil {
+		return err
+	}
+
+	claims, err := repo.DecodeRefreshToken(ctx, *token)
+	if err != nil {
+		return err
+	}
+	return c.print(claims)
+}
+
+// statusResult ...
+type statusResult struct {
+	UserIdentifier string                  `json:"user"`
+	TokenID        string                  `json:"token_id"`
+	Whitelist      *authpkg.TokenItem      `json:"whitelist"`
+	Blacklist      bool                    `json:"blacklist"`
+	LoginLimit     *authpkg.LoginLimitInfo `json:"login_limit"`
+	// Generation 用户当前的令牌代数
+	Generation uint64 `json:"generation"`
+	// TokenGeneration 令牌签发时的令牌代数；小于 Generation 时已注销
+	TokenGeneration *uint64 `json:"token_generation,omitempty"`
+}
+
+// runStatus 令牌在Redis中的状态；令牌不验证签名
+func runStatus(ctx context.Context, c *cli, args []string) error {
+	fs := c.newFlagSet("status")
+	var (
+		token   = fs.String("token", "", "访问令牌；或使用 -user 与 -id")
+		user    = fs.String("user", "", "用户标识")
+		tokenID = fs.String("id", "", "令牌id")
+	)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	res := &statusResult{
+		UserIdentifier: *user,
+		TokenID:        *tokenID,
+	}
+	if *token != "" {
+		claims := &authpkg.Claims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(trimBearer(*token), claims); err != nil {
+			return fmt.Errorf("parse token failed : %w", err)
+		}
+		if claims.Payload == nil {
+			return fmt.Errorf("token payload is empty")
+		}
+		res.UserIdentifier = claims.Payload.UserIdentifier()
+		res.TokenID = claims.ID
+		res.TokenGeneration = &claims.Generation
+		if claims.Payload.TenantID != "" {
+			ctx = authpkg.PutTenantIntoContext(ctx, claims.Payload.TenantID)
+		}
+	}
+	if res.UserIdentifier == "" || res.TokenID == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	item, isNotFound, err := c.tm.GetToken(ctx, res.UserIdentifier, res.TokenID)
+	if err != nil {
+		return fmt.Errorf("GetToken failed : %w", err)
+	}
+	if !isNotFound {
+		res.Whitelist = item
+	}
+	if res.Blacklist, err = c.tm.IsBlacklist(ctx, res.UserIdentifier, res.TokenID); err != nil {
+		return fmt.Errorf("IsBlacklist failed : %w", err)
+	}
+	info, isNotFound, err := c.tm.GetLoginLimit(ctx, res.UserIdentifier, res.TokenID)
+	if err != nil {
+		return fmt.Errorf("GetLoginLimit failed : %w", err)
+	}
+	if !isNotFound {
+		res.LoginLimit = info
+	}
+	if res.Generation, err = c.tm.GetTokenGeneration(ctx, res.UserIdentifier); err != nil {
+		return fmt.Errorf("GetTokenGeneration failed : %w", err)
+	}
+	return c.print(res)
+}
+
+// runSessions 用户的登录会话
+func runSessions(ctx context.Context, c *cli, args []string) error {
+	fs := c.newFlagSet("sessions")
+	user := fs.String("user", "", "用户标识")
+	if err := parseFlags(fs, args, "user"); err != nil {
+		return err
+	}
+	repo, err := c.authRepo()
+	if err != nil {
+		return err
+	}
+
+	sessions, err := repo.ListSessions(ctx, *user)
+	if err != nil {
+		return err
+	}
+	return c.print(sessions)
+}
+
+// runRevoke 注销
+func runRevoke(ctx context.Context, c *cli, args []string) error {
+	fs := c.newFlagSet("revoke")
+	var (
+		user       = fs.String("user", "", "用户标识")
+		tokenID    = fs.String("id", "", "注销一个会话：令牌id")
+		all        = fs.Bool("all", false, "注销用户的所有会话")
+		generation = fs.Bool("generation", false, "令牌代数加一：用户此前签发的所有令牌失效")
+	)
+	if err := parseFlags(fs, args, "user"); err != nil {
+		return err
+	}
+	repo, err := c.authRepo()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *generation:
+		gen, err := repo.BumpTokenGeneration(ctx, *user)
+		if err != nil {
+			return err
+		}
+		return c.print(map[string]interface{}{"user": *user, "generation": gen})
+	case *all:
+		if err = repo.RevokeAllSessions(ctx, *user); err != nil {
+			return err
+		}
+	case *tokenID != "":
+		if err = repo.RevokeSession(ctx, *user, *tokenID); err != nil {
+			return err
+		}
+	default:
+		_, _ = fmt.Fprintln(fs.Output(), "one of -id, -all or -generation is required")
+		fs.Usage()
+		return errUsage
+	}
+	return c.print(map[string]interface{}{"user": *user, "revoked": true})
+}
+
+// mintResult ...
+type mintResult struct {
+	AccessToken  string          `json:"access_token"`
+	RefreshToken string          `json:"refresh_token"`
+	Claims       *authpkg.Claims `json:"claims"`
+}
+
+// checkMintTarget 签发测试令牌的目标：-env 仅为自行声明的运行环境，不足以防止误用；Redis地址必须在白名单中
+func (c *cli) checkMintTarget() error {
+	apppkg.SetRuntimeEnv(apppkg.RuntimeEnv(strings.ToUpper(c.conf.env)))
+	if !apppkg.IsDebugMode() {
+		return fmt.Errorf("mint is not allowed in %s environment; set -env to LOCAL, DEVELOP or TESTING", apppkg.GetRuntimeEnv())
+	}
+	for _, addr := range strings.Split(c.conf.mintAllowRedis, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" && addr == c.conf.redisAddr {
+			return nil
+		}
+	}
+	return fmt.Errorf("mint is not allowed for redis %s; add it to -mint-allow-redis", c.conf.redisAddr)
+}
+
+// runMint 签发测试令牌；生产与预发布环境禁止，且Redis地址必须在 -mint-allow-redis 中
+func runMint(ctx context.Context, c *cli, args []string) error {
+	fs := c.newFlagSet("mint")
+	var (
+		payloadJSON = fs.String("payload", "", `授权信息(JSON)；例：{"uid":1,"tt":2,"rs":["admin"]}`)
+		expire      = fs.Duration("expire", time.Hour, "有效期")
+	)
+	if err := parseFlags(fs, args, "payload"); err != nil {
+		return err
+	}
+	if err := c.checkMintTarget(); err != nil {
+		return err
+	}
+	repo, err := c.authRepo()
+	if err != nil {
+		return err
+	}
+
+	payload := authpkg.Payload{}
+	if err = json.Unmarshal([]byte(*payloadJSON), &payload); err != nil {
+		return fmt.Errorf("decode payload failed : %w", err)
+	}
+	claims := authpkg.DefaultClaims(payload)
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(*expire))
+	res, _, err := repo.SignToken(ctx, claims)
+	if err != nil {
+		return err
+	}
+	return c.print(&mintResult{
+		AccessToken:  res.AccessToken,
+		RefreshToken: res.RefreshToken,
+		Claims:       claims,
+	})
+}
+
+// trimBearer 兼容复制的 Authorization 请求头
+func trimBearer(token string) string {
+	token = strings.TrimSpace(token)
+	if len(token) > len(authpkg.BearerWord) && strings.EqualFold(token[:len(authpkg.BearerWord)], authpkg.BearerWord) {
+		token = strings.TrimSpace(token[len(authpkg.BearerWord):])
+	}
+	return token
+}
+
+// envOrDefault ...
+func envOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -count=1 ./cmd/authctl -test.run=TestRun
+func TestRun(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		mr      = miniredis.RunT(t)
+		global  = []string{"-sign-key", "1234567890ABCDEF", "-redis", mr.Addr()}
+		execute = func(args ...string) (map[string]interface{}, error) {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			if err := run(ctx, append(append([]string{}, global...), args...), stdout, stderr); err != nil {
+				return nil, err
+			}
+			res := map[string]interface{}{}
+			if bytes.HasPrefix(stdout.Bytes(), []byte("[")) {
+				var list []interface{}
+				require.Nil(t, json.Unmarshal(stdout.Bytes(), &list))
+				res["list"] = list
+				return res, nil
+			}
+			require.Nil(t, json.Unmarshal(stdout.Bytes(), &res))
+			return res, nil
+		}
+	)
+
+	// 生产环境禁止签发
+	_, err := execute("mint", "-payload", `{"uid":1}`)
+	require.NotNil(t, err)
+
+	global = append(global, "-env", "TESTING")
+	// Redis地址不在白名单中
+	_, err = execute("mint", "-payload", `{"uid":1}`)
+	require.ErrorContains(t, err, "-mint-allow-redis")
+	_, err = execute("-mint-allow-redis", "127.0.0.1:1", "mint", "-payload", `{"uid":1}`)
+	require.NotNil(t, err)
+
+	global = append(global, "-mint-allow-redis", "127.0.0.1:1, "+mr.Addr())
+	minted, err := execute("mint", "-payload", `{"uid":1,"tt":2}`)
+	require.Nil(t, err)
+	var (
+		accessToken  = minted["access_token"].(string)
+		refreshToken = minted["refresh_token"].(string)
+		tokenID      = minted["claims"].(map[string]interface{})["jti"].(string)
+	)
+
+	// 解析
+	decoded, err := execute("decode", "-token", "Bearer "+accessToken)
+	require.Nil(t, err)
+	require.Equal(t, "valid", decoded["signature"])
+	require.Equal(t, "HS256", decoded["header"].(map[string]interface{})["alg"])
+	require.Equal(t, float64(1), decoded["claims"].(map[string]interface{})["p"].(map[string]interface{})["uid"])
+	decoded, err = execute("decode", "-token", accessToken[:len(accessToken)-2]+"xx")
+	require.Nil(t, err)
+	require.Contains(t, decoded["signature"], "invalid")
+
+	// 验证与解密刷新令牌
+	verified, err := execute("verify", "-token", accessToken)
+	require.Nil(t, err)
+	require.Equal(t, true, verified["valid"])
+	refreshed, err := execute("refresh", "-token", refreshToken)
+	require.Nil(t, err)
+	require.Equal(t, minted["claims"].(map[string]interface{})["fid"], refreshed["fid"])
+
+	// 状态与会话
+	status, err := execute("status", "-token", accessToken)
+	require.Nil(t, err)
+	require.Equal(t, "1", status["user"])
+	require.Equal(t, tokenID, status["token_id"])
+	require.NotNil(t, status["whitelist"])
+	require.Equal(t, false, status["blacklist"])
+	sessions, err := execute("sessions", "-user", "1")
+	require.Nil(t, err)
+	require.Len(t, sessions["list"], 1)
+
+	// 注销
+	_, err = execute("revoke", "-user", "1")
+	require.ErrorIs(t, err, errUsage)
+	_, err = execute("revoke", "-user", "1", "-id", tokenID)
+	require.Nil(t, err)
+	status, err = execute("status", "-user", "1", "-id", tokenID)
+	require.Nil(t, err)
+	require.Nil(t, status["whitelist"])
+	require.Equal(t, true, status["blacklist"])
+	_, err = execute("verify", "-token", accessToken)
+	require.NotNil(t, err)
+	generation, err := execute("revoke", "-user", "1", "-generation")
+	require.Nil(t, err)
+	require.Equal(t, float64(1), generation["generation"])
+
+	// 登录限制：签发后立即生效
+	first, err := execute("mint", "-payload", `{"uid":2,"tt":2,"ll":1}`)
+	require.Nil(t, err)
+	second, err := execute("mint", "-payload", `{"uid":2,"tt":2,"ll":1}`)
+	require.Nil(t, err)
+	_, err = execute("verify", "-token", first["access_token"].(string))
+	require.NotNil(t, err)
+	verified, err = execute("verify", "-token", second["access_token"].(string))
+	require.Nil(t, err)
+	require.Equal(t, true, verified["valid"])
+
+	// 参数错误
+	_, err = execute("unknown")
+	require.NotNil(t, err)
+	_, err = execute("decode")
+	require.ErrorIs(t, err, errUsage)
+	global = global[:0]
+	_, err = execute("refresh", "-token", refreshToken)
+	require.NotNil(t, err)
+}
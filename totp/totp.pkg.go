@@ -0,0 +1,237 @@
+package totppkg
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPeriod 时间步长
+	DefaultPeriod = time.Second * 30
+	// DefaultDigits 验证码位数
+	DefaultDigits = 6
+	// DefaultSkew 允许的时间偏差(步数)：前后各1步
+	DefaultSkew = 1
+	// DefaultSecretSize 密钥的随机字节数；RFC 4226 建议160位
+	DefaultSecretSize = 20
+)
+
+// Algorithm HMAC 算法
+type Algorithm string
+
+const (
+	AlgorithmSHA1   Algorithm = "SHA1"
+	AlgorithmSHA256 Algorithm = "SHA256"
+	AlgorithmSHA512 Algorithm = "SHA512"
+)
+
+// hash ...
+func (a Algorithm) hash() (func() hash.Hash, error) {
+	switch a {
+	case AlgorithmSHA1:
+		return sha1.New, nil
+	case AlgorithmSHA256:
+		return sha256.New, nil
+	case AlgorithmSHA512:
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported totp algorithm : %s", a)
+}
+
+// encoding 密钥使用无填充的 base32；与 Google Authenticator 等应用一致
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Option ...
+type Option func(*options)
+
+// options ...
+type options struct {
+	period    time.Duration
+	digits    int
+	skew      uint64
+	algorithm Algorithm
+}
+
+// WithPeriod 时间步长
+func WithPeriod(period time.Duration) Option {
+	return func(o *options) {
+		o.period = period
+	}
+}
+
+// WithDigits 验证码位数：6-8
+func WithDigits(digits int) Option {
+	return func(o *options) {
+		o.digits = digits
+	}
+}
+
+// WithSkew 允许的时间偏差(步数)
+func WithSkew(skew uint64) Option {
+	return func(o *options) {
+		o.skew = skew
+	}
+}
+
+// WithAlgorithm HMAC 算法；部分应用仅支持 SHA1
+func WithAlgorithm(algorithm Algorithm) Option {
+	return func(o *options) {
+		o.algorithm = algorithm
+	}
+}
+
+// newOptions ...
+func newOptions(opts []Option) (*options, error) {
+	o := &options{
+		period:    DefaultPeriod,
+		digits:    DefaultDigits,
+		skew:      DefaultSkew,
+		algorithm: AlgorithmSHA1,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.period < time.Second {
+		return nil, fmt.Errorf("invalid totp period : %s", o.period)
+	}
+	if o.digits < 6 || o.digits > 8 {
+		return nil, fmt.Errorf("invalid totp digits : %d", o.digits)
+	}
+	return o, nil
+}
+
+// GenerateSecret 随机密钥；base32 编码
+func GenerateSecret() (string, error) {
+	secret := make([]byte, DefaultSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("generate totp secret failed : %w", err)
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+// decodeSecret 忽略大小写、空格与填充
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("decode totp secret failed : %w", err)
+	}
+	return key, nil
+}
+
+// Counter 时间所在的步数
+func Counter(t time.Time, opts ...Option) (uint64, error) {
+	o, err := newOptions(opts)
+	if err != nil {
+		return 0, err
+	}
+	return counter(t, o.period), nil
+}
+
+// counter ...
+func counter(t time.Time, period time.Duration) uint64 {
+	return uint64(t.Unix()) / uint64(period/time.Second)
+}
+
+// GenerateCode 时间对应的验证码
+func GenerateCode(secret string, t time.Time, opts ...Option) (string, error) {
+	o, err := newOptions(opts)
+	if err != nil {
+		return "", err
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, counter(t, o.period), o)
+}
+
+// hotp RFC 4226
+func hotp(key []byte, counter uint64, o *options) (string, error) {
+	newHash, err := o.algorithm.hash()
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(newHash, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < o.digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", o.digits, value%mod), nil
+}
+
+// Validate 验证码在允许的时间偏差内有效时，返回匹配的步数
+// 调用方记录已使用的步数，拒绝小于等于已使用步数的验证码，防止重放
+func Validate(secret, code string, t time.Time, opts ...Option) (matched uint64, ok bool, err error) {
+	o, err := newOptions(opts)
+	if err != nil {
+		return 0, false, err
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != o.digits {
+		return 0, false, nil
+	}
+	current := counter(t, o.period)
+	for c := current - min(current, o.skew); c <= current+o.skew; c++ {
+		expected, err := hotp(key, c, o)
+		if err != nil {
+			return 0, false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return c, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// KeyURI otpauth URI；用于生成二维码
+// 例：otpauth://totp/Issuer:alice?secret=...&issuer=Issuer&algorithm=SHA1&digits=6&period=30
+func KeyURI(issuer, accountName, secret string, opts ...Option) (string, error) {
+	o, err := newOptions(opts)
+	if err != nil {
+		return "", err
+	}
+	label := accountName
+	if issuer != "" {
+		label = issuer + ":" + accountName
+	}
+	query := url.Values{}
+	query.Set("secret", secret)
+	if issuer != "" {
+		query.Set("issuer", issuer)
+	}
+	query.Set("algorithm", string(o.algorithm))
+	query.Set("digits", strconv.Itoa(o.digits))
+	query.Set("period", strconv.Itoa(int(o.period/time.Second)))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: query.Encode(),
+	}
+	return u.String(), nil
+}
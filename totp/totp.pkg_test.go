@@ -0,0 +1,106 @@
+package totppkg
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -count=1 ./totp -test.run=TestGenerateCode
+func TestGenerateCode(t *testing.T) {
+	// RFC 6238 附录B
+	secrets := map[Algorithm]string{
+		AlgorithmSHA1:   base32.StdEncoding.EncodeToString([]byte("12345678901234567890")),
+		AlgorithmSHA256: base32.StdEncoding.EncodeToString([]byte("12345678901234567890123456789012")),
+		AlgorithmSHA512: base32.StdEncoding.EncodeToString([]byte("1234567890123456789012345678901234567890123456789012345678901234")),
+	}
+	tests := []struct {
+		unix      int64
+		algorithm Algorithm
+		code      string
+	}{
+		{59, AlgorithmSHA1, "94287082"},
+		{59, AlgorithmSHA256, "46119246"},
+		{59, AlgorithmSHA512, "90693936"},
+		{1111111109, AlgorithmSHA1, "07081804"},
+		{1111111109, AlgorithmSHA256, "68084774"},
+		{1111111109, AlgorithmSHA512, "25091201"},
+		{2000000000, AlgorithmSHA1, "69279037"},
+		{20000000000, AlgorithmSHA512, "47863826"},
+	}
+	for _, tt := range tests {
+		code, err := GenerateCode(secrets[tt.algorithm], time.Unix(tt.unix, 0), WithDigits(8), WithAlgorithm(tt.algorithm))
+		require.Nil(t, err)
+		require.Equal(t, tt.code, code, "%d %s", tt.unix, tt.algorithm)
+	}
+
+	_, err := GenerateCode(secrets[AlgorithmSHA1], time.Now(), WithDigits(4))
+	require.NotNil(t, err)
+	_, err = GenerateCode("!invalid", time.Now())
+	require.NotNil(t, err)
+}
+
+// go test -v -count=1 ./totp -test.run=TestValidate
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.Nil(t, err)
+	var (
+		now        = time.Unix(1700000000, 0)
+		current, _ = Counter(now)
+	)
+
+	code, err := GenerateCode(secret, now)
+	require.Nil(t, err)
+	matched, ok, err := Validate(secret, code, now)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, current, matched)
+
+	// 时间偏差
+	code, err = GenerateCode(secret, now.Add(-DefaultPeriod))
+	require.Nil(t, err)
+	matched, ok, err = Validate(secret, code, now)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, current-1, matched)
+	code, err = GenerateCode(secret, now.Add(DefaultPeriod))
+	require.Nil(t, err)
+	matched, ok, err = Validate(secret, code, now)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, current+1, matched)
+
+	// 超出时间偏差
+	code, err = GenerateCode(secret, now.Add(-DefaultPeriod*2))
+	require.Nil(t, err)
+	_, ok, err = Validate(secret, code, now)
+	require.Nil(t, err)
+	require.False(t, ok)
+	_, ok, err = Validate(secret, code, now, WithSkew(2))
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	// 格式错误
+	_, ok, err = Validate(secret, "12345", now)
+	require.Nil(t, err)
+	require.False(t, ok)
+}
+
+// go test -v -count=1 ./totp -test.run=TestKeyURI
+func TestKeyURI(t *testing.T) {
+	uri, err := KeyURI("Example Co", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	require.Nil(t, err)
+	u, err := url.Parse(uri)
+	require.Nil(t, err)
+	require.Equal(t, "otpauth", u.Scheme)
+	require.Equal(t, "totp", u.Host)
+	require.Equal(t, "/Example Co:alice@example.com", u.Path)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", u.Query().Get("secret"))
+	require.Equal(t, "Example Co", u.Query().Get("issuer"))
+	require.Equal(t, "SHA1", u.Query().Get("algorithm"))
+	require.Equal(t, "6", u.Query().Get("digits"))
+	require.Equal(t, "30", u.Query().Get("period"))
+}